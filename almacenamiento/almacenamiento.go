@@ -0,0 +1,35 @@
+package almacenamiento // Paquete almacenamiento: guarda y lee los archivos de los libros.
+
+import (
+	"errors" // Paquete para errores comunes del almacenamiento.
+	"io"     // Paquete para flujos de lectura.
+	"time"   // Paquete para la fecha de modificación.
+)
+
+// ErrNoExiste se usa cuando la clave no corresponde a ningún archivo guardado.
+var ErrNoExiste = errors.New("archivo no existe")
+
+// ErrClaveInvalida se usa cuando la clave intenta salir del espacio permitido (ej. "../").
+var ErrClaveInvalida = errors.New("clave de archivo inválida")
+
+// Objeto es un archivo abierto para lectura.
+// Si el lector también implementa io.Seeker, la descarga admite rangos.
+type Objeto struct {
+	io.ReadCloser           // Contenido del archivo.
+	Tamano        int64     // Tamaño en bytes.
+	ModificadoEn  time.Time // Última modificación.
+}
+
+// Almacenamiento define dónde viven los archivos de los libros.
+// La implementación local es la primera; una compatible con S3 puede
+// agregarse después sin cambiar los handlers.
+type Almacenamiento interface {
+	// Guardar escribe el contenido bajo la clave indicada (reemplaza si existe).
+	Guardar(clave string, contenido io.Reader) error
+
+	// Abrir devuelve el archivo para lectura; el llamador debe cerrarlo.
+	Abrir(clave string) (Objeto, error)
+
+	// Eliminar borra el archivo (no falla si no existe).
+	Eliminar(clave string) error
+}
@@ -0,0 +1,49 @@
+package almacenamiento // Paquete almacenamiento.
+
+import (
+	"bytes"   // Paquete para comparar firmas de archivo.
+	"strings" // Paquete para normalizar el formato.
+)
+
+// TipoContenido devuelve el Content-Type correspondiente al formato del libro.
+func TipoContenido(formato string) string {
+	switch strings.ToUpper(formato) {
+	case "PDF":
+		return "application/pdf"
+	case "EPUB":
+		return "application/epub+zip"
+	case "MOBI":
+		return "application/x-mobipocket-ebook"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension devuelve la extensión de archivo (con punto) para el formato.
+func Extension(formato string) string {
+	switch strings.ToUpper(formato) {
+	case "PDF":
+		return ".pdf"
+	case "EPUB":
+		return ".epub"
+	case "MOBI":
+		return ".mobi"
+	default:
+		return ""
+	}
+}
+
+// DetectarFormato identifica PDF, EPUB o MOBI por la firma de los primeros bytes.
+// Se necesitan al menos 68 bytes para reconocer MOBI. Devuelve "" si no coincide.
+func DetectarFormato(cabecera []byte) string {
+	switch {
+	case bytes.HasPrefix(cabecera, []byte("%PDF-")):
+		return "PDF"
+	case bytes.HasPrefix(cabecera, []byte("PK\x03\x04")) && bytes.Contains(cabecera, []byte("application/epub+zip")):
+		return "EPUB"
+	case len(cabecera) >= 68 && string(cabecera[60:68]) == "BOOKMOBI":
+		return "MOBI"
+	default:
+		return ""
+	}
+}
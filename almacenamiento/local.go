@@ -0,0 +1,97 @@
+package almacenamiento // Paquete almacenamiento.
+
+import (
+	"io"            // Paquete para copiar flujos.
+	"os"            // Paquete para trabajar con archivos.
+	"path/filepath" // Paquete para construir rutas seguras.
+	"strings"       // Paquete para validar la clave.
+)
+
+// Local guarda los archivos en una carpeta del disco.
+type Local struct {
+	Raiz string // Carpeta base (ej. "uploads").
+}
+
+// NuevoLocal crea el almacenamiento local y asegura que la carpeta exista.
+func NuevoLocal(raiz string) (*Local, error) {
+	if err := os.MkdirAll(raiz, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{Raiz: raiz}, nil
+}
+
+// Guardar escribe primero en un temporal y luego lo renombra,
+// así una subida interrumpida no deja un archivo a medias.
+func (l *Local) Guardar(clave string, contenido io.Reader) error {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ruta), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(ruta), ".subida-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Si el rename funcionó, ya no existe.
+
+	if _, err := io.Copy(tmp, contenido); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ruta)
+}
+
+// Abrir abre el archivo; *os.File implementa io.Seeker, por lo que admite rangos.
+func (l *Local) Abrir(clave string) (Objeto, error) {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return Objeto{}, err
+	}
+
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Objeto{}, ErrNoExiste
+		}
+		return Objeto{}, err
+	}
+
+	info, err := archivo.Stat()
+	if err != nil {
+		archivo.Close()
+		return Objeto{}, err
+	}
+
+	return Objeto{
+		ReadCloser:   archivo,
+		Tamano:       info.Size(),
+		ModificadoEn: info.ModTime(),
+	}, nil
+}
+
+// Eliminar borra el archivo si existe.
+func (l *Local) Eliminar(clave string) error {
+	ruta, err := l.ruta(clave)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(ruta); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ruta convierte la clave en una ruta dentro de Raiz, rechazando "../" y rutas absolutas.
+func (l *Local) ruta(clave string) (string, error) {
+	limpia := filepath.Clean(filepath.FromSlash(clave))
+	if clave == "" || filepath.IsAbs(limpia) || limpia == ".." || strings.HasPrefix(limpia, ".."+string(filepath.Separator)) {
+		return "", ErrClaveInvalida
+	}
+	return filepath.Join(l.Raiz, limpia), nil
+}
@@ -0,0 +1,172 @@
+package auditoria // Paquete auditoria: registro de altas, cambios y bajas del catálogo.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"encoding/json"  // Paquete para guardar los cambios de campos.
+	"sistema/models" // Estructuras Auditoria, CambioCampo y Libro.
+	"strconv"        // Paquete para convertir números a texto.
+	"strings"        // Paquete para armar filtros.
+	"time"           // Paquete para fechas del registro.
+)
+
+// limiteDefecto acota la cantidad de filas que devuelve Listar.
+const limiteDefecto = 200
+
+// Ejecutor es lo que necesita Registrar: una conexión (*sql.DB) o, para que la
+// entrada se guarde junto con el cambio que anota, una transacción (*sql.Tx).
+type Ejecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Registrar agrega una entrada a la auditoría con la fecha actual.
+// El paquete no ofrece forma de modificar ni borrar entradas.
+func Registrar(db Ejecutor, a models.Auditoria) error {
+	if a.Cambios == nil {
+		a.Cambios = []models.CambioCampo{}
+	}
+	cambios, err := json.Marshal(a.Cambios)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO auditoria (id_actor, actor, rol, accion, id_libro, cambios, ip, fecha)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.IDActor, a.Actor, a.Rol, a.Accion, a.IDLibro, cambios, a.IP, time.Now())
+	return err
+}
+
+// Diferencias compara dos estados de un libro y devuelve los campos que cambiaron.
+// antes es nil al crear y despues es nil al eliminar: en esos casos se listan
+// todos los campos con valor, para saber cómo era el libro.
+func Diferencias(antes, despues *models.Libro) []models.CambioCampo {
+	var valoresAntes, valoresDespues []string
+	if antes != nil {
+		valoresAntes = valoresLibro(*antes)
+	}
+	if despues != nil {
+		valoresDespues = valoresLibro(*despues)
+	}
+
+	var cambios []models.CambioCampo
+	for i, campo := range camposLibro {
+		var a, d string
+		if antes != nil {
+			a = valoresAntes[i]
+		}
+		if despues != nil {
+			d = valoresDespues[i]
+		}
+		if a != d {
+			cambios = append(cambios, models.CambioCampo{Campo: campo, Antes: a, Despues: d})
+		}
+	}
+	return cambios
+}
+
+// camposLibro son los campos auditados, en el mismo orden que valoresLibro.
+var camposLibro = []string{
+	"titulo", "autor", "categoria", "anio_publicacion", "formato", "stock_licencias",
+	"licencias_totales", "idioma", "editorial", "isbn", "paginas", "archivo",
+}
+
+// valoresLibro devuelve los campos del libro como texto, en el orden de camposLibro.
+// Los números en cero se dejan vacíos, igual que en la base (NULL).
+func valoresLibro(l models.Libro) []string {
+	numero := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	return []string{
+		l.Titulo, l.Autor, l.Categoria, numero(l.AnioPublicacion), l.Formato, strconv.Itoa(l.StockLicencias),
+		strconv.Itoa(l.LicenciasTotales), l.Idioma, l.Editorial, l.ISBN, numero(l.Paginas), l.Archivo,
+	}
+}
+
+// Filtro acota la consulta de la auditoría. Los campos vacíos no filtran.
+type Filtro struct {
+	Actor  string    // ID exacto, o parte del nombre del actor.
+	Libro  int       // ID del libro.
+	Accion string    // Una de las acciones models.Auditoria*.
+	Desde  time.Time // Desde esa fecha inclusive.
+	Hasta  time.Time // Hasta esa fecha exclusive.
+	Limite int       // Máximo de filas (0: 200; negativo: sin límite).
+}
+
+// Listar devuelve las entradas que cumplen el filtro, de la más reciente a la más antigua.
+func Listar(db *sql.DB, f Filtro) ([]models.Auditoria, error) {
+	var entradas []models.Auditoria
+	err := Recorrer(db, f, func(a models.Auditoria) error {
+		entradas = append(entradas, a)
+		return nil
+	})
+	return entradas, err
+}
+
+// Recorrer llama a fn con cada entrada que cumple el filtro, sin cargarlas todas
+// en memoria (para exportar). Si fn devuelve error, se corta el recorrido.
+func Recorrer(db *sql.DB, f Filtro, fn func(models.Auditoria) error) error {
+	var (
+		condiciones []string
+		args        []any
+	)
+	if f.Actor != "" {
+		condiciones = append(condiciones, "(id_actor = ? OR actor LIKE ?)")
+		id, _ := strconv.Atoi(f.Actor)
+		args = append(args, id, "%"+f.Actor+"%")
+	}
+	if f.Libro > 0 {
+		condiciones = append(condiciones, "id_libro = ?")
+		args = append(args, f.Libro)
+	}
+	if f.Accion != "" {
+		condiciones = append(condiciones, "accion = ?")
+		args = append(args, f.Accion)
+	}
+	if !f.Desde.IsZero() {
+		condiciones = append(condiciones, "fecha >= ?")
+		args = append(args, f.Desde)
+	}
+	if !f.Hasta.IsZero() {
+		condiciones = append(condiciones, "fecha < ?")
+		args = append(args, f.Hasta)
+	}
+
+	query := `SELECT id, id_actor, actor, rol, accion, id_libro, cambios, ip, fecha FROM auditoria`
+	if len(condiciones) > 0 {
+		query += " WHERE " + strings.Join(condiciones, " AND ")
+	}
+	query += " ORDER BY fecha DESC, id DESC"
+	if f.Limite == 0 {
+		f.Limite = limiteDefecto
+	}
+	if f.Limite > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limite)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			a       models.Auditoria
+			cambios []byte
+		)
+		if err := rows.Scan(&a.ID, &a.IDActor, &a.Actor, &a.Rol, &a.Accion, &a.IDLibro, &cambios, &a.IP, &a.Fecha); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(cambios, &a.Cambios); err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,94 @@
+package auditoria // Paquete auditoria.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"sistema/models" // Estructura Auditoria.
+	"sort"           // Paquete para ordenar las entradas.
+	"strconv"        // Paquete para comparar el actor por ID.
+	"strings"        // Paquete para comparar el actor por nombre.
+)
+
+// Fuente es de donde se leen las entradas para la pantalla de auditoría: la
+// tabla auditoria de MySQL o el archivo de libros (LIBROS_STORE=json).
+type Fuente interface {
+	Recorrer(f Filtro, fn func(models.Auditoria) error) error
+}
+
+// FuenteFunc permite usar una función como Fuente.
+type FuenteFunc func(f Filtro, fn func(models.Auditoria) error) error
+
+// Recorrer llama a la función.
+func (g FuenteFunc) Recorrer(f Filtro, fn func(models.Auditoria) error) error {
+	return g(f, fn)
+}
+
+// NuevaFuenteMySQL lee la auditoría de la tabla auditoria.
+func NuevaFuenteMySQL(db *sql.DB) Fuente {
+	return FuenteFunc(func(f Filtro, fn func(models.Auditoria) error) error {
+		return Recorrer(db, f, fn)
+	})
+}
+
+// ListarFuente devuelve las entradas de la fuente que cumplen el filtro.
+func ListarFuente(fuente Fuente, f Filtro) ([]models.Auditoria, error) {
+	var entradas []models.Auditoria
+	err := fuente.Recorrer(f, func(a models.Auditoria) error {
+		entradas = append(entradas, a)
+		return nil
+	})
+	return entradas, err
+}
+
+// RecorrerLista aplica el filtro a entradas que no están en MySQL, con las
+// mismas reglas que la consulta: más nuevas primero y el mismo límite.
+func RecorrerLista(entradas []models.Auditoria, f Filtro, fn func(models.Auditoria) error) error {
+	elegidas := make([]models.Auditoria, 0, len(entradas))
+	for _, a := range entradas {
+		if f.cumple(a) {
+			elegidas = append(elegidas, a)
+		}
+	}
+	sort.SliceStable(elegidas, func(i, j int) bool {
+		if !elegidas[i].Fecha.Equal(elegidas[j].Fecha) {
+			return elegidas[i].Fecha.After(elegidas[j].Fecha)
+		}
+		return elegidas[i].ID > elegidas[j].ID
+	})
+
+	if f.Limite == 0 {
+		f.Limite = limiteDefecto
+	}
+	if f.Limite > 0 && len(elegidas) > f.Limite {
+		elegidas = elegidas[:f.Limite]
+	}
+	for _, a := range elegidas {
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cumple indica si la entrada pasa el filtro. El actor se compara por ID o por
+// parte del nombre sin distinguir mayúsculas, igual que el LIKE de MySQL.
+func (f Filtro) cumple(a models.Auditoria) bool {
+	if f.Actor != "" {
+		id, _ := strconv.Atoi(f.Actor)
+		if a.IDActor != id && !strings.Contains(strings.ToLower(a.Actor), strings.ToLower(f.Actor)) {
+			return false
+		}
+	}
+	if f.Libro > 0 && a.IDLibro != f.Libro {
+		return false
+	}
+	if f.Accion != "" && a.Accion != f.Accion {
+		return false
+	}
+	if !f.Desde.IsZero() && a.Fecha.Before(f.Desde) {
+		return false
+	}
+	if !f.Hasta.IsZero() && !a.Fecha.Before(f.Hasta) {
+		return false
+	}
+	return true
+}
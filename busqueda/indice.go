@@ -0,0 +1,156 @@
+package busqueda // Paquete busqueda: índice invertido en memoria para buscar libros por texto.
+
+import (
+	"math"           // Paquete para el logaritmo del IDF.
+	"sistema/models" // Estructura Libro.
+	"sort"           // Paquete para ordenar por relevancia.
+	"strings"        // Paquete para buscar por prefijo.
+	"sync"           // Paquete para proteger el índice ante accesos concurrentes.
+	"unicode/utf8"   // Paquete para contar las letras del prefijo.
+)
+
+// Peso de cada campo: una coincidencia en el título vale más que en la categoría.
+const (
+	PesoTitulo    = 3.0
+	PesoAutor     = 2.0
+	PesoCategoria = 1.0
+)
+
+// LongitudMinimaPrefijo es la cantidad mínima de letras de la última palabra
+// para buscarla también como prefijo: con menos, casi todo el catálogo coincide.
+const LongitudMinimaPrefijo = 3
+
+// Resultado es un libro encontrado con su puntaje de relevancia.
+type Resultado struct {
+	ID      int     // ID del libro.
+	Puntaje float64 // Mayor es más relevante.
+}
+
+// Indice relaciona cada término (raíz de palabra) con los libros que lo
+// contienen y el peso con que aparece en cada uno.
+type Indice struct {
+	mu       sync.RWMutex               // Protege los mapas.
+	terminos map[string]map[int]float64 // Término -> ID del libro -> peso.
+	porLibro map[int][]string           // ID del libro -> términos (para quitarlo).
+}
+
+// NuevoIndice crea un índice vacío.
+func NuevoIndice() *Indice {
+	return &Indice{
+		terminos: make(map[string]map[int]float64),
+		porLibro: make(map[int][]string),
+	}
+}
+
+// Agregar indexa el título, autor y categoría del libro. Si ya estaba indexado,
+// reemplaza sus términos (sirve para altas y modificaciones).
+func (i *Indice) Agregar(libro models.Libro) {
+	pesos := make(map[string]float64)
+	for _, campo := range []struct {
+		texto string
+		peso  float64
+	}{
+		{libro.Titulo, PesoTitulo},
+		{libro.Autor, PesoAutor},
+		{libro.Categoria, PesoCategoria},
+	} {
+		for _, t := range Terminos(campo.texto) {
+			pesos[t] += campo.peso
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.quitar(libro.ID)
+	for t, peso := range pesos {
+		if i.terminos[t] == nil {
+			i.terminos[t] = make(map[int]float64)
+		}
+		i.terminos[t][libro.ID] = peso
+		i.porLibro[libro.ID] = append(i.porLibro[libro.ID], t)
+	}
+}
+
+// Quitar saca un libro del índice (no falla si no estaba).
+func (i *Indice) Quitar(id int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.quitar(id)
+}
+
+// quitar saca un libro del índice (con el mutex tomado).
+func (i *Indice) quitar(id int) {
+	for _, t := range i.porLibro[id] {
+		delete(i.terminos[t], id)
+		if len(i.terminos[t]) == 0 {
+			delete(i.terminos, t)
+		}
+	}
+	delete(i.porLibro, id)
+}
+
+// Buscar devuelve los libros que contienen todas las palabras de la consulta,
+// del más relevante al menos relevante (a igual puntaje, por ID). La última
+// palabra también se busca como prefijo (si tiene al menos LongitudMinimaPrefijo
+// letras), para encontrar mientras se escribe.
+// Devuelve nil si la consulta no tiene palabras útiles (solo palabras vacías).
+//
+// El puntaje suma, por cada palabra, el peso del campo donde aparece por su
+// IDF: las palabras raras en el catálogo pesan más que las comunes.
+func (i *Indice) Buscar(consulta string) []Resultado {
+	consultadas := palabras(consulta)
+	if len(consultadas) == 0 {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	total := float64(len(i.porLibro))
+	var puntajes map[int]float64
+	for n, p := range consultadas {
+		// Términos que coinciden: la raíz y, para la última palabra, los que empiezan igual.
+		coincidencias := []string{p.raiz}
+		if n == len(consultadas)-1 && utf8.RuneCountInString(p.normal) >= LongitudMinimaPrefijo {
+			for t := range i.terminos {
+				if t != p.raiz && strings.HasPrefix(t, p.normal) {
+					coincidencias = append(coincidencias, t)
+				}
+			}
+		}
+
+		parciales := make(map[int]float64)
+		for _, t := range coincidencias {
+			libros := i.terminos[t]
+			idf := math.Log(1 + total/float64(len(libros)))
+			for id, peso := range libros {
+				parciales[id] = max(parciales[id], peso*idf)
+			}
+		}
+
+		// Solo siguen los libros que ya tenían todas las palabras anteriores.
+		if puntajes == nil {
+			puntajes = parciales
+			continue
+		}
+		for id := range puntajes {
+			if parcial, ok := parciales[id]; ok {
+				puntajes[id] += parcial
+			} else {
+				delete(puntajes, id)
+			}
+		}
+	}
+
+	resultados := make([]Resultado, 0, len(puntajes))
+	for id, puntaje := range puntajes {
+		resultados = append(resultados, Resultado{ID: id, Puntaje: puntaje})
+	}
+	sort.Slice(resultados, func(a, b int) bool {
+		if resultados[a].Puntaje != resultados[b].Puntaje {
+			return resultados[a].Puntaje > resultados[b].Puntaje
+		}
+		return resultados[a].ID < resultados[b].ID
+	})
+	return resultados
+}
@@ -0,0 +1,112 @@
+package busqueda // Paquete busqueda.
+
+import (
+	"strings"      // Paquete para pasar a minúsculas y quitar sufijos.
+	"unicode"      // Paquete para separar palabras.
+	"unicode/utf8" // Paquete para contar letras (no bytes).
+)
+
+// acentos pasa cada letra acentuada a su forma sin acento, para que "Garcia"
+// encuentre "García" y "nino" encuentre "niño".
+var acentos = map[rune]rune{
+	'á': 'a', 'à': 'a', 'ä': 'a', 'â': 'a', 'ã': 'a',
+	'é': 'e', 'è': 'e', 'ë': 'e', 'ê': 'e',
+	'í': 'i', 'ì': 'i', 'ï': 'i', 'î': 'i',
+	'ó': 'o', 'ò': 'o', 'ö': 'o', 'ô': 'o', 'õ': 'o',
+	'ú': 'u', 'ù': 'u', 'ü': 'u', 'û': 'u',
+	'ñ': 'n', 'ç': 'c',
+}
+
+// vacias son palabras frecuentes del español que no sirven para buscar
+// (ya sin acentos, como quedan después de normalizar).
+var vacias = map[string]bool{}
+
+func init() {
+	for _, p := range strings.Fields(`
+		a al algo algunas algunos ante antes como con contra cual cuando de del desde donde durante
+		e el ella ellas ellos en entre era es esa esas ese eso esos esta estas este esto estos fue
+		ha hay hasta la las le les lo los mas me mi mis mucho muchos muy nada ni no nos o otra otras
+		otro otros para pero poco por porque que quien quienes se sea ser si sin sobre su sus tambien
+		tanto te todo todos tu u un una unas uno unos y ya yo`) {
+		vacias[p] = true
+	}
+}
+
+// sufijos son las terminaciones que quita raiz, de la más larga a la más corta
+// para que "programaciones" pierda "aciones" y no solo la "s".
+var sufijos = []string{
+	"amientos", "imientos", "aciones", "uciones", "amiento", "imiento", "idades",
+	"adoras", "adores", "ancias", "mente", "acion", "ucion", "adora", "ancia",
+	"ables", "ibles", "istas", "ismos", "ador", "able", "ible", "ista", "ismo",
+	"idad", "osos", "osas", "oso", "osa",
+}
+
+// palabra es una palabra de un texto: normalizada (minúsculas, sin acentos) y su raíz.
+type palabra struct {
+	normal string
+	raiz   string
+}
+
+// palabras separa el texto en palabras normalizadas y sin palabras vacías.
+func palabras(texto string) []palabra {
+	var resultado []palabra
+	campos := strings.FieldsFunc(normalizar(texto), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, p := range campos {
+		if vacias[p] {
+			continue
+		}
+		resultado = append(resultado, palabra{normal: p, raiz: raiz(p)})
+	}
+	return resultado
+}
+
+// Terminos devuelve las raíces que se indexan o buscan para el texto.
+func Terminos(texto string) []string {
+	var terminos []string
+	for _, p := range palabras(texto) {
+		terminos = append(terminos, p.raiz)
+	}
+	return terminos
+}
+
+// normalizar pasa el texto a minúsculas y le quita los acentos.
+func normalizar(texto string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if sin, ok := acentos[r]; ok {
+			return sin
+		}
+		return r
+	}, texto)
+}
+
+// raiz reduce una palabra normalizada a su raíz con reglas simples del español:
+// quita un sufijo derivativo o, si no tiene, el plural y la vocal final
+// ("novelas" y "novela" quedan en "novel"; "programación" y "programador" en
+// "program"). La raíz conserva al menos tres letras; los números no cambian.
+func raiz(p string) string {
+	if utf8.RuneCountInString(p) <= 3 || unicode.IsDigit([]rune(p)[0]) {
+		return p
+	}
+	for _, s := range sufijos {
+		if strings.HasSuffix(p, s) && utf8.RuneCountInString(p)-len(s) >= 3 {
+			return p[:len(p)-len(s)]
+		}
+	}
+
+	// Plural: "autores" -> "autor", "libros" -> "libro".
+	switch {
+	case strings.HasSuffix(p, "es") && len(p) > 4 && !strings.ContainsRune("aeiou", rune(p[len(p)-3])):
+		p = p[:len(p)-2]
+	case strings.HasSuffix(p, "s") && len(p) > 4:
+		p = p[:len(p)-1]
+	}
+
+	// Vocal final de género o número: "libro" -> "libr", "novela" -> "novel".
+	if strings.ContainsRune("aeo", rune(p[len(p)-1])) && utf8.RuneCountInString(p) > 4 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
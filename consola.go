@@ -0,0 +1,177 @@
+package main // Paquete principal.
+
+import (
+	"bufio"          // Paquete para leer las opciones del menú.
+	"errors"         // Paquete para reconocer errores del repositorio.
+	"fmt"            // Paquete para imprimir el menú y los libros.
+	"os"             // Paquete para la entrada estándar y LIBROS_JSON.
+	"sistema/libros" // Paquete local con el repositorio de libros.
+	"sistema/models" // Paquete local con las estructuras Libro y Auditoria.
+	"strings"        // Paquete para validar textos.
+)
+
+// ErrEntradaInvalida se usa cuando el usuario ingresa datos que no cumplen lo esperado.
+var ErrEntradaInvalida = errors.New("entrada invalida")
+
+// formatosConsola son los formatos que aceptan el formulario web, la API y la importación.
+var formatosConsola = map[string]bool{"PDF": true, "EPUB": true, "MOBI": true}
+
+// autorConsola firma en la auditoría los cambios hechos desde la consola.
+var autorConsola = models.Auditoria{Actor: "consola", Rol: "CONSOLA", IP: "local"}
+
+// archivoLibrosJSON devuelve el archivo de libros de LIBROS_JSON (por defecto "libros.json").
+func archivoLibrosJSON() string {
+	if archivo := os.Getenv("LIBROS_JSON"); archivo != "" {
+		return archivo
+	}
+	return "libros.json"
+}
+
+// ejecutarConsola muestra el menú de libros por consola ("go run . consola").
+// Usa el mismo RepositorioJSON que la web con LIBROS_STORE=json, así que no
+// necesita MySQL y sus cambios quedan en la auditoría del archivo.
+func ejecutarConsola(archivo string) error {
+	repo, err := libros.NuevoRepositorioJSON(archivo)
+	if err != nil {
+		return fmt.Errorf("no se pudo cargar %s: %w", archivo, err)
+	}
+
+	lector := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Println()
+		fmt.Println("📚 Libros (" + archivo + ")")
+		fmt.Println("1) Listar libros")
+		fmt.Println("2) Buscar libro por ID")
+		fmt.Println("3) Agregar libro")
+		fmt.Println("4) Actualizar libro")
+		fmt.Println("5) Eliminar libro")
+		fmt.Println("0) Salir")
+
+		var err error
+		switch leerEntero(lector, "Opción: ") {
+		case 1:
+			err = listarConsola(repo)
+		case 2:
+			err = buscarConsola(lector, repo)
+		case 3:
+			err = agregarConsola(lector, repo)
+		case 4:
+			err = actualizarConsola(lector, repo)
+		case 5:
+			err = eliminarConsola(lector, repo)
+		case 0:
+			return nil
+		default:
+			fmt.Println("❌ Opción inválida.")
+		}
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+		}
+	}
+}
+
+// listarConsola imprime todos los libros por ID.
+func listarConsola(repo libros.LibroRepository) error {
+	lista, err := repo.Listar(libros.Filtro{Orden: libros.OrdenID})
+	if err != nil {
+		return err
+	}
+	if len(lista) == 0 {
+		fmt.Println("No hay libros registrados.")
+		return nil
+	}
+	for _, l := range lista {
+		imprimirLibro(l)
+	}
+	return nil
+}
+
+// buscarConsola pide un ID e imprime el libro.
+func buscarConsola(lector *bufio.Reader, repo libros.LibroRepository) error {
+	libro, err := repo.Obtener(leerEntero(lector, "ID: "))
+	if err != nil {
+		return err
+	}
+	imprimirLibro(libro)
+	return nil
+}
+
+// agregarConsola pide los datos obligatorios del formulario web y guarda el
+// libro con el siguiente ID (todas sus licencias quedan libres).
+func agregarConsola(lector *bufio.Reader, repo libros.LibroRepository) error {
+	var libro models.Libro
+	pedirDatosConsola(lector, &libro)
+	if err := validarLibroConsola(&libro); err != nil {
+		return err
+	}
+
+	if err := repo.Crear(&libro, autorConsola); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Libro agregado con ID %d.\n", libro.ID)
+	return nil
+}
+
+// actualizarConsola pide un ID y los datos nuevos; Enter conserva cada valor.
+// Los libros anteriores sin categoría o formato deben completarlos.
+func actualizarConsola(lector *bufio.Reader, repo libros.LibroRepository) error {
+	libro, err := repo.Obtener(leerEntero(lector, "ID: "))
+	if err != nil {
+		return err
+	}
+	pedirDatosConsola(lector, &libro)
+	if err := validarLibroConsola(&libro); err != nil {
+		return err
+	}
+
+	if _, err := repo.Actualizar(libro, false, autorConsola); err != nil {
+		return err
+	}
+	fmt.Println("✅ Libro actualizado.")
+	return nil
+}
+
+// eliminarConsola pide un ID y borra el libro.
+func eliminarConsola(lector *bufio.Reader, repo libros.LibroRepository) error {
+	libro, err := repo.Eliminar(leerEntero(lector, "ID: "), autorConsola)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Libro %q eliminado.\n", libro.Titulo)
+	return nil
+}
+
+// pedirDatosConsola pide los campos del formulario web; Enter conserva el valor
+// actual del libro (en un alta, vacío o 0).
+func pedirDatosConsola(lector *bufio.Reader, libro *models.Libro) {
+	libro.Titulo = leerTexto(lector, "Título", libro.Titulo)
+	libro.Autor = leerTexto(lector, "Autor", libro.Autor)
+	libro.Categoria = leerTexto(lector, "Categoría", libro.Categoria)
+	libro.Formato = leerTexto(lector, "Formato (PDF, EPUB o MOBI)", libro.Formato)
+	libro.AnioPublicacion = leerNoNegativo(lector, fmt.Sprintf("Año [%d]: ", libro.AnioPublicacion), libro.AnioPublicacion)
+	libro.LicenciasTotales = leerNoNegativo(lector, fmt.Sprintf("Licencias [%d]: ", libro.LicenciasTotales), libro.LicenciasTotales)
+}
+
+// validarLibroConsola aplica las reglas del formulario web: título, autor,
+// categoría y formato son obligatorios y el formato es PDF, EPUB o MOBI (el año
+// y las licencias ya llegan validados).
+func validarLibroConsola(libro *models.Libro) error {
+	libro.Titulo = strings.TrimSpace(libro.Titulo)
+	libro.Autor = strings.TrimSpace(libro.Autor)
+	libro.Categoria = strings.TrimSpace(libro.Categoria)
+	libro.Formato = strings.ToUpper(strings.TrimSpace(libro.Formato))
+
+	if libro.Titulo == "" || libro.Autor == "" || libro.Categoria == "" || libro.Formato == "" {
+		return fmt.Errorf("%w: todos los campos son obligatorios", ErrEntradaInvalida)
+	}
+	if !formatosConsola[libro.Formato] {
+		return fmt.Errorf("%w: el formato debe ser PDF, EPUB o MOBI", ErrEntradaInvalida)
+	}
+	return nil
+}
+
+// imprimirLibro muestra un libro en una línea.
+func imprimirLibro(l models.Libro) {
+	fmt.Printf("%d) %s - %s (%d) · %s · %s · %d/%d licencias libres\n",
+		l.ID, l.Titulo, l.Autor, l.AnioPublicacion, l.Categoria, l.Formato, l.StockLicencias, l.LicenciasTotales)
+}
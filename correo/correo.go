@@ -0,0 +1,133 @@
+package correo // Paquete correo: envío de correos del sistema (verificación, recuperación).
+
+import (
+	"encoding/base64" // Paquete para codificar el asunto.
+	"fmt"             // Paquete para armar el mensaje.
+	"log"             // Paquete para el enviador de consola.
+	"net"             // Paquete para unir host y puerto.
+	"net/smtp"        // Paquete para enviar por SMTP.
+	"strings"         // Paquete para armar encabezados.
+	"sync"            // Paquete para proteger la bandeja en memoria.
+	"time"            // Paquete para la fecha del mensaje.
+)
+
+// Mensaje es un correo de texto plano.
+type Mensaje struct {
+	Para   string // Dirección del destinatario.
+	Asunto string // Asunto del correo.
+	Texto  string // Cuerpo en texto plano.
+}
+
+// Enviador define cómo se envían los correos (SMTP, consola, memoria, etc.).
+type Enviador interface {
+	Enviar(m Mensaje) error
+}
+
+// SMTP envía correos a través de un servidor SMTP con autenticación PLAIN.
+type SMTP struct {
+	Host      string // Servidor SMTP (ej. smtp.correo.com).
+	Puerto    string // Puerto (ej. "587").
+	Usuario   string // Usuario de autenticación (vacío si no se autentica).
+	Clave     string // Clave de autenticación.
+	Remitente string // Dirección que figura en From.
+}
+
+// NuevoSMTP crea un enviador SMTP.
+func NuevoSMTP(host, puerto, usuario, clave, remitente string) *SMTP {
+	return &SMTP{
+		Host:      host,
+		Puerto:    puerto,
+		Usuario:   usuario,
+		Clave:     clave,
+		Remitente: remitente,
+	}
+}
+
+// Enviar envía el mensaje por SMTP.
+func (s *SMTP) Enviar(m Mensaje) error {
+	var auth smtp.Auth
+	if s.Usuario != "" {
+		auth = smtp.PlainAuth("", s.Usuario, s.Clave, s.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Puerto), auth, s.Remitente, []string{m.Para}, armar(s.Remitente, m))
+}
+
+// armar arma el mensaje con sus encabezados (UTF-8, texto plano).
+// Los saltos de línea se quitan de los encabezados para que no se puedan inyectar otros.
+func armar(remitente string, m Mensaje) []byte {
+	encabezado := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", encabezado.Replace(remitente))
+	fmt.Fprintf(&b, "To: %s\r\n", encabezado.Replace(m.Para))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mimeQ(encabezado.Replace(m.Asunto)))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(m.Texto, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// mimeQ codifica el asunto si tiene caracteres fuera de ASCII (tildes, ñ).
+func mimeQ(texto string) string {
+	for _, c := range texto {
+		if c > 127 {
+			return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(texto)) + "?="
+		}
+	}
+	return texto
+}
+
+// Consola escribe los correos en el log en lugar de enviarlos.
+// Sirve para desarrollo cuando no hay servidor SMTP configurado.
+type Consola struct{}
+
+// NuevoConsola crea un enviador que escribe en el log.
+func NuevoConsola() *Consola {
+	return &Consola{}
+}
+
+// Enviar escribe el mensaje en el log.
+func (c *Consola) Enviar(m Mensaje) error {
+	log.Printf("✉️ Correo para %s | %s\n%s", m.Para, m.Asunto, m.Texto)
+	return nil
+}
+
+// Memoria guarda los correos en una bandeja en memoria, sin enviarlos.
+// Es el enviador falso para pruebas: permite leer los enlaces enviados.
+type Memoria struct {
+	mu      sync.Mutex
+	bandeja []Mensaje
+}
+
+// NuevoMemoria crea un enviador con la bandeja vacía.
+func NuevoMemoria() *Memoria {
+	return &Memoria{}
+}
+
+// Enviar agrega el mensaje a la bandeja.
+func (m *Memoria) Enviar(msg Mensaje) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bandeja = append(m.bandeja, msg)
+	return nil
+}
+
+// Enviados devuelve una copia de los mensajes recibidos, del más antiguo al más nuevo.
+func (m *Memoria) Enviados() []Mensaje {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mensaje(nil), m.bandeja...)
+}
+
+// Ultimo devuelve el último mensaje enviado a una dirección.
+func (m *Memoria) Ultimo(para string) (Mensaje, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.bandeja) - 1; i >= 0; i-- {
+		if strings.EqualFold(m.bandeja[i].Para, para) {
+			return m.bandeja[i], true
+		}
+	}
+	return Mensaje{}, false
+}
@@ -0,0 +1,264 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migraciones contiene los cambios de esquema que el sistema aplica al arrancar.
+// Las tablas originales (libros, usuarios, roles) se crean con los scripts SQL del proyecto.
+// El número de versión de cada sentencia es su posición (desde 1): nunca se reordenan
+// ni se borran entradas, solo se agregan al final.
+var migraciones = []string{
+	// Sesiones del lado del servidor (solo se guarda el hash del ID).
+	`CREATE TABLE IF NOT EXISTS sesiones (
+		id_hash       CHAR(64)     NOT NULL PRIMARY KEY,
+		id_usuario    INT          NOT NULL,
+		nombre        VARCHAR(150) NOT NULL,
+		rol           VARCHAR(50)  NOT NULL,
+		creada_en     DATETIME     NOT NULL,
+		ultimo_acceso DATETIME     NOT NULL,
+		expira_en     DATETIME     NOT NULL,
+		INDEX idx_sesiones_usuario (id_usuario),
+		INDEX idx_sesiones_expira (expira_en)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// La clave guarda hashes bcrypt (60 caracteres), por lo que se amplía la columna.
+	`ALTER TABLE usuarios MODIFY clave VARCHAR(255) NOT NULL`,
+
+	// Tokens Bearer de acceso y refresco para clientes de la API (solo el hash).
+	`CREATE TABLE IF NOT EXISTS tokens_api (
+		id         INT         NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		hash       CHAR(64)    NOT NULL UNIQUE,
+		tipo       VARCHAR(10) NOT NULL,
+		id_usuario INT         NOT NULL,
+		creado_en  DATETIME    NOT NULL,
+		expira_en  DATETIME    NOT NULL,
+		revocado   TINYINT(1)  NOT NULL DEFAULT 0,
+		INDEX idx_tokens_usuario (id_usuario)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Claves de API por usuario, administradas por ADMIN.
+	`CREATE TABLE IF NOT EXISTS claves_api (
+		id          INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		hash        CHAR(64)     NOT NULL UNIQUE,
+		prefijo     VARCHAR(16)  NOT NULL,
+		nombre      VARCHAR(100) NOT NULL,
+		id_usuario  INT          NOT NULL,
+		creada_por  INT          NOT NULL,
+		creada_en   DATETIME     NOT NULL,
+		ultimo_uso  DATETIME     NULL,
+		revocada_en DATETIME     NULL,
+		INDEX idx_claves_usuario (id_usuario)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Archivo propio de cada libro (clave dentro del almacenamiento).
+	`ALTER TABLE libros ADD COLUMN archivo VARCHAR(255) NULL`,
+
+	// Metadatos bibliográficos (se completan desde el EPUB al subirlo).
+	`ALTER TABLE libros
+		ADD COLUMN idioma    VARCHAR(20)  NULL,
+		ADD COLUMN editorial VARCHAR(150) NULL,
+		ADD COLUMN isbn      VARCHAR(13)  NULL`,
+
+	// Cantidad de páginas (se lee del PDF al subirlo).
+	`ALTER TABLE libros ADD COLUMN paginas INT NULL`,
+
+	// Préstamos de licencias: mientras devuelto_en es NULL la licencia está ocupada.
+	`CREATE TABLE IF NOT EXISTS prestamos (
+		id                INT         NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		id_libro          INT         NOT NULL,
+		id_usuario        INT         NOT NULL,
+		prestado_en       DATETIME    NOT NULL,
+		vence_en          DATETIME    NOT NULL,
+		devuelto_en       DATETIME    NULL,
+		motivo_devolucion VARCHAR(10) NULL,
+		INDEX idx_prestamos_usuario (id_usuario, devuelto_en),
+		INDEX idx_prestamos_libro (id_libro, devuelto_en),
+		INDEX idx_prestamos_vence (devuelto_en, vence_en)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Lista de espera por libro (FIFO por id) cuando no quedan licencias.
+	`CREATE TABLE IF NOT EXISTS reservas (
+		id           INT         NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		id_libro     INT         NOT NULL,
+		id_usuario   INT         NOT NULL,
+		estado       VARCHAR(10) NOT NULL,
+		creada_en    DATETIME    NOT NULL,
+		asignada_en  DATETIME    NULL,
+		retiro_hasta DATETIME    NULL,
+		cerrada_en   DATETIME    NULL,
+		INDEX idx_reservas_cola (id_libro, estado, id),
+		INDEX idx_reservas_usuario (id_usuario, estado)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Historial de vistas, descargas, préstamos y devoluciones (models.History).
+	`CREATE TABLE IF NOT EXISTS historial (
+		id         INT         NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		id_usuario INT         NOT NULL,
+		id_libro   INT         NOT NULL,
+		accion     VARCHAR(20) NOT NULL,
+		fecha      DATETIME    NOT NULL,
+		INDEX idx_historial_usuario (id_usuario, fecha),
+		INDEX idx_historial_libro (id_libro, fecha),
+		INDEX idx_historial_fecha (fecha)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Auditoría de altas, cambios y bajas del catálogo (solo se insertan filas).
+	// Actor y rol se copian para que la entrada sobreviva a cambios del usuario.
+	`CREATE TABLE IF NOT EXISTS auditoria (
+		id       INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		id_actor INT          NOT NULL,
+		actor    VARCHAR(150) NOT NULL,
+		rol      VARCHAR(50)  NOT NULL,
+		accion   VARCHAR(20)  NOT NULL,
+		id_libro INT          NOT NULL,
+		cambios  JSON         NOT NULL,
+		ip       VARCHAR(45)  NOT NULL,
+		fecha    DATETIME     NOT NULL,
+		INDEX idx_auditoria_libro (id_libro, fecha),
+		INDEX idx_auditoria_actor (id_actor, fecha),
+		INDEX idx_auditoria_fecha (fecha)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// El estado admite PENDIENTE (registro sin verificar) además de ACTIVO e INACTIVO.
+	`ALTER TABLE usuarios MODIFY estado VARCHAR(20) NOT NULL DEFAULT 'ACTIVO'`,
+
+	// Códigos de un solo uso enviados por correo (solo el hash).
+	`CREATE TABLE IF NOT EXISTS codigos_correo (
+		id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		hash       CHAR(64)     NOT NULL UNIQUE,
+		tipo       VARCHAR(20)  NOT NULL,
+		id_usuario INT          NOT NULL,
+		creado_en  DATETIME     NOT NULL,
+		expira_en  DATETIME     NOT NULL,
+		usado_en   DATETIME     NULL,
+		INDEX idx_codigos_usuario (id_usuario, tipo)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Permisos con nombre asignados a cada rol (la lista de permisos está en el paquete permisos).
+	`CREATE TABLE IF NOT EXISTS roles_permisos (
+		id_rol  INT         NOT NULL,
+		permiso VARCHAR(50) NOT NULL,
+		PRIMARY KEY (id_rol, permiso)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Asignación inicial: reproduce los controles por rol que había en el código.
+	`INSERT IGNORE INTO roles_permisos (id_rol, permiso)
+	SELECT r.id_rol, p.permiso
+	FROM roles r
+	INNER JOIN (
+		SELECT 'ADMIN' AS rol, 'libros.crear' AS permiso
+		UNION ALL SELECT 'ADMIN', 'libros.editar'
+		UNION ALL SELECT 'ADMIN', 'libros.eliminar'
+		UNION ALL SELECT 'ADMIN', 'libros.descargar'
+		UNION ALL SELECT 'ADMIN', 'historial.ver'
+		UNION ALL SELECT 'ADMIN', 'auditoria.ver'
+		UNION ALL SELECT 'ADMIN', 'usuarios.administrar'
+		UNION ALL SELECT 'ADMIN', 'claves_api.administrar'
+		UNION ALL SELECT 'ADMIN', 'permisos.administrar'
+		UNION ALL SELECT 'OPERADOR', 'libros.crear'
+		UNION ALL SELECT 'OPERADOR', 'libros.editar'
+		UNION ALL SELECT 'OPERADOR', 'libros.descargar'
+		UNION ALL SELECT 'CONSULTA', 'prestamos.solicitar'
+		UNION ALL SELECT 'CONSULTA', 'historial.propio'
+	) p ON UPPER(TRIM(r.nombre_rol)) = p.rol`,
+
+	// Intentos fallidos de inicio de sesión (límite por IP y registro para ADMIN).
+	`CREATE TABLE IF NOT EXISTS intentos_login (
+		id     INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		correo VARCHAR(255) NOT NULL,
+		ip     VARCHAR(45)  NOT NULL,
+		fecha  DATETIME     NOT NULL,
+		INDEX idx_intentos_ip (ip, fecha),
+		INDEX idx_intentos_correo (correo, fecha)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Fallos seguidos y bloqueo temporal por correo.
+	`CREATE TABLE IF NOT EXISTS bloqueos_login (
+		correo          VARCHAR(255) NOT NULL PRIMARY KEY,
+		fallos          INT          NOT NULL DEFAULT 0,
+		bloqueos        INT          NOT NULL DEFAULT 0,
+		ultimo_fallo    DATETIME     NOT NULL,
+		bloqueado_hasta DATETIME     NULL
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Segundo factor TOTP por usuario (activo = 0 mientras el alta no se confirma).
+	`CREATE TABLE IF NOT EXISTS doble_factor (
+		id_usuario  INT         NOT NULL PRIMARY KEY,
+		secreto     VARCHAR(64) NOT NULL,
+		activo      TINYINT(1)  NOT NULL DEFAULT 0,
+		ultimo_paso BIGINT      NOT NULL DEFAULT 0,
+		creado_en   DATETIME    NOT NULL,
+		activado_en DATETIME    NULL
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Códigos de recuperación del segundo factor (solo se guarda su hash).
+	`CREATE TABLE IF NOT EXISTS codigos_recuperacion (
+		id         INT      NOT NULL AUTO_INCREMENT PRIMARY KEY,
+		id_usuario INT      NOT NULL,
+		hash       CHAR(64) NOT NULL,
+		creado_en  DATETIME NOT NULL,
+		usado_en   DATETIME NULL,
+		INDEX idx_codigos_recuperacion_usuario (id_usuario)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Inicios de sesión con la clave validada que esperan el código del segundo factor.
+	`CREATE TABLE IF NOT EXISTS pasos_login (
+		hash       CHAR(64) NOT NULL PRIMARY KEY,
+		id_usuario INT      NOT NULL,
+		expira_en  DATETIME NOT NULL,
+		INDEX idx_pasos_login_expira (expira_en)
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+	// Índices de los órdenes del listado, para paginar por cursor sin recorrer la tabla.
+	`ALTER TABLE libros
+		ADD INDEX idx_libros_titulo (titulo, id),
+		ADD INDEX idx_libros_autor (autor, titulo, id),
+		ADD INDEX idx_libros_anio (anio_publicacion, titulo, id)`,
+
+	// Exportación del catálogo: al principio solo para ADMIN.
+	`INSERT IGNORE INTO roles_permisos (id_rol, permiso)
+	SELECT id_rol, 'libros.exportar' FROM roles WHERE UPPER(TRIM(nombre_rol)) = 'ADMIN'`,
+
+	// Total de licencias de cada libro: stock_licencias pasa a contar solo las libres.
+	`ALTER TABLE libros ADD COLUMN licencias_totales INT NOT NULL DEFAULT 0 AFTER stock_licencias`,
+
+	// El total de los libros existentes es lo libre más lo prestado y lo apartado para la cola.
+	`UPDATE libros l SET licencias_totales = l.stock_licencias
+		+ (SELECT COUNT(*) FROM prestamos p WHERE p.id_libro = l.id AND p.devuelto_en IS NULL)
+		+ (SELECT COUNT(*) FROM reservas r WHERE r.id_libro = l.id AND r.estado = 'ASIGNADA')`,
+}
+
+// AplicarMigraciones aplica, en orden, las migraciones que aún no se registraron
+// en la tabla esquema_migraciones. Cada versión se ejecuta una sola vez.
+func AplicarMigraciones(conexion *sql.DB) error {
+	_, err := conexion.Exec(`CREATE TABLE IF NOT EXISTS esquema_migraciones (
+		version     INT      NOT NULL PRIMARY KEY,
+		aplicada_en DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`)
+	if err != nil {
+		return fmt.Errorf("tabla esquema_migraciones: %w", err)
+	}
+
+	for i, sentencia := range migraciones {
+		version := i + 1
+
+		var aplicada int
+		err := conexion.QueryRow(`SELECT COUNT(*) FROM esquema_migraciones WHERE version = ?`, version).Scan(&aplicada)
+		if err != nil {
+			return fmt.Errorf("migración %d: %w", version, err)
+		}
+		if aplicada > 0 {
+			continue
+		}
+
+		if _, err := conexion.Exec(sentencia); err != nil {
+			return fmt.Errorf("migración %d: %w", version, err)
+		}
+		if _, err := conexion.Exec(`INSERT INTO esquema_migraciones (version) VALUES (?)`, version); err != nil {
+			return fmt.Errorf("migración %d: %w", version, err)
+		}
+	}
+	return nil
+}
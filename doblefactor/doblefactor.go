@@ -0,0 +1,402 @@
+package doblefactor // Paquete doblefactor: segundo factor TOTP (RFC 6238) y códigos de recuperación.
+
+import (
+	"crypto/rand"       // Paquete para generar códigos de recuperación.
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"errors"            // Paquete para errores del módulo.
+	"sistema/models"    // Estructura DobleFactor.
+	"sistema/seguridad" // TOTP, códigos aleatorios y hashes.
+	"strings"           // Paquete para normalizar los códigos escritos.
+	"time"              // Paquete para vencimientos y pasos TOTP.
+)
+
+// CantidadCodigos es la cantidad de códigos de recuperación que se entregan en cada alta.
+const CantidadCodigos = 10
+
+// alfabetoRecuperacion evita caracteres que se confunden al copiarlos a mano (0/o, 1/l/i).
+const alfabetoRecuperacion = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// Errores del módulo de segundo factor.
+var (
+	ErrCodigoInvalido   = errors.New("código de verificación inválido")
+	ErrYaActivo         = errors.New("el segundo factor ya está activado")
+	ErrNoActivo         = errors.New("el segundo factor no está activado")
+	ErrSinAltaPendiente = errors.New("no hay un alta del segundo factor en curso")
+	ErrPasoInvalido     = errors.New("el inicio de sesión venció: ingrese de nuevo su correo y clave")
+)
+
+// Servicio administra el segundo factor de los usuarios:
+//   - alta: se genera un secreto (pendiente) que el usuario carga en su aplicación
+//     autenticadora y se activa al confirmar el primer código;
+//   - verificación: acepta el código TOTP del momento (una sola vez por paso) o
+//     uno de los códigos de recuperación, que se consumen al usarse;
+//   - pasos de login: guardan, por VigenciaPaso, que la clave ya se validó y
+//     falta el código.
+type Servicio struct {
+	DB           *sql.DB       // Conexión a la base de datos.
+	Emisor       string        // Nombre que muestra la aplicación autenticadora.
+	VigenciaPaso time.Duration // Plazo para ingresar el código tras validar la clave.
+}
+
+// NuevoServicio crea el servicio con el emisor "Biblioteca Digital" y 5 minutos
+// para completar el segundo paso del inicio de sesión.
+func NuevoServicio(db *sql.DB) *Servicio {
+	return &Servicio{
+		DB:           db,
+		Emisor:       "Biblioteca Digital",
+		VigenciaPaso: 5 * time.Minute,
+	}
+}
+
+// Estado devuelve el estado del segundo factor del usuario.
+func (s *Servicio) Estado(idUsuario int) (models.DobleFactor, error) {
+	var estado models.DobleFactor
+	var activadoEn sql.NullTime
+	err := s.DB.QueryRow(`
+		SELECT activo, activado_en FROM doble_factor WHERE id_usuario = ?
+	`, idUsuario).Scan(&estado.Activo, &activadoEn)
+	if err != nil && err != sql.ErrNoRows {
+		return models.DobleFactor{}, err
+	}
+	if !estado.Activo {
+		return models.DobleFactor{}, nil
+	}
+	if activadoEn.Valid {
+		estado.ActivadoEn = &activadoEn.Time
+	}
+
+	err = s.DB.QueryRow(`
+		SELECT COUNT(*) FROM codigos_recuperacion WHERE id_usuario = ? AND usado_en IS NULL
+	`, idUsuario).Scan(&estado.CodigosRestantes)
+	return estado, err
+}
+
+// Activos devuelve los IDs de los usuarios con el segundo factor activo.
+func (s *Servicio) Activos() (map[int]bool, error) {
+	rows, err := s.DB.Query(`SELECT id_usuario FROM doble_factor WHERE activo = 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activos := map[int]bool{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		activos[id] = true
+	}
+	return activos, rows.Err()
+}
+
+// IniciarAlta genera un secreto nuevo para el usuario y lo deja pendiente de
+// confirmación. Un alta anterior sin confirmar se reemplaza.
+func (s *Servicio) IniciarAlta(idUsuario int) (string, error) {
+	secreto, err := seguridad.GenerarSecretoTOTP()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var activo bool
+	err = tx.QueryRow(`SELECT activo FROM doble_factor WHERE id_usuario = ? FOR UPDATE`, idUsuario).Scan(&activo)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.Exec(`
+			INSERT INTO doble_factor (id_usuario, secreto, activo, ultimo_paso, creado_en)
+			VALUES (?, ?, 0, 0, ?)
+		`, idUsuario, secreto, time.Now())
+	case err != nil:
+		return "", err
+	case activo:
+		return "", ErrYaActivo
+	default:
+		_, err = tx.Exec(`
+			UPDATE doble_factor SET secreto = ?, ultimo_paso = 0, creado_en = ? WHERE id_usuario = ?
+		`, secreto, time.Now(), idUsuario)
+	}
+	if err != nil {
+		return "", err
+	}
+	return secreto, tx.Commit()
+}
+
+// SecretoPendiente devuelve el secreto del alta en curso, para volver a mostrar el QR.
+func (s *Servicio) SecretoPendiente(idUsuario int) (string, error) {
+	var secreto string
+	err := s.DB.QueryRow(`
+		SELECT secreto FROM doble_factor WHERE id_usuario = ? AND activo = 0
+	`, idUsuario).Scan(&secreto)
+	if err == sql.ErrNoRows {
+		return "", ErrSinAltaPendiente
+	}
+	return secreto, err
+}
+
+// ConfirmarAlta activa el segundo factor si el código corresponde al secreto
+// pendiente, y devuelve los códigos de recuperación (solo se muestran esta vez).
+func (s *Servicio) ConfirmarAlta(idUsuario int, codigo string) ([]string, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var secreto string
+	var activo bool
+	err = tx.QueryRow(`
+		SELECT secreto, activo FROM doble_factor WHERE id_usuario = ? FOR UPDATE
+	`, idUsuario).Scan(&secreto, &activo)
+	if err == sql.ErrNoRows {
+		return nil, ErrSinAltaPendiente
+	}
+	if err != nil {
+		return nil, err
+	}
+	if activo {
+		return nil, ErrYaActivo
+	}
+
+	ahora := time.Now()
+	paso, ok := seguridad.VerificarTOTP(secreto, normalizarCodigo(codigo), ahora, 0)
+	if !ok {
+		return nil, ErrCodigoInvalido
+	}
+
+	_, err = tx.Exec(`
+		UPDATE doble_factor SET activo = 1, ultimo_paso = ?, activado_en = ? WHERE id_usuario = ?
+	`, paso, ahora, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	codigos, err := reemplazarCodigos(tx, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+	return codigos, tx.Commit()
+}
+
+// Verificar acepta el código TOTP vigente o un código de recuperación sin usar.
+// Devuelve true si se consumió un código de recuperación.
+func (s *Servicio) Verificar(idUsuario int, codigo string) (bool, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	recuperacion, err := verificarEnTx(tx, idUsuario, codigo)
+	if err != nil {
+		return false, err
+	}
+	return recuperacion, tx.Commit()
+}
+
+// RegenerarCodigos anula los códigos de recuperación del usuario y entrega otros,
+// previa verificación de un código válido.
+func (s *Servicio) RegenerarCodigos(idUsuario int, codigo string) ([]string, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := verificarEnTx(tx, idUsuario, codigo); err != nil {
+		return nil, err
+	}
+	codigos, err := reemplazarCodigos(tx, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+	return codigos, tx.Commit()
+}
+
+// Desactivar quita el segundo factor del usuario, previa verificación de un código válido.
+func (s *Servicio) Desactivar(idUsuario int, codigo string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := verificarEnTx(tx, idUsuario, codigo); err != nil {
+		return err
+	}
+	if err := borrar(tx, idUsuario); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restablecer quita el segundo factor sin pedir código, para que un ADMIN ayude
+// a quien perdió el dispositivo y sus códigos de recuperación.
+func (s *Servicio) Restablecer(idUsuario int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := borrar(tx, idUsuario); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// URIProvision arma la URI otpauth:// del secreto para la cuenta indicada.
+func (s *Servicio) URIProvision(cuenta, secreto string) string {
+	return seguridad.URIProvisionTOTP(s.Emisor, cuenta, secreto)
+}
+
+// =========================================================
+// PASOS DE INICIO DE SESIÓN
+// =========================================================
+
+// CrearPaso registra que el usuario validó su clave y devuelve el código que
+// identifica el paso pendiente (va en una cookie; en la base solo queda su hash).
+func (s *Servicio) CrearPaso(idUsuario int) (string, error) {
+	codigo, err := seguridad.GenerarCodigo()
+	if err != nil {
+		return "", err
+	}
+
+	ahora := time.Now()
+	if _, err := s.DB.Exec(`DELETE FROM pasos_login WHERE expira_en <= ?`, ahora); err != nil {
+		return "", err
+	}
+	_, err = s.DB.Exec(`
+		INSERT INTO pasos_login (hash, id_usuario, expira_en) VALUES (?, ?, ?)
+	`, seguridad.HashCodigo(codigo), idUsuario, ahora.Add(s.VigenciaPaso))
+	if err != nil {
+		return "", err
+	}
+	return codigo, nil
+}
+
+// UsuarioDelPaso devuelve el usuario de un paso pendiente vigente, o ErrPasoInvalido.
+func (s *Servicio) UsuarioDelPaso(codigo string) (int, error) {
+	if codigo == "" {
+		return 0, ErrPasoInvalido
+	}
+	var idUsuario int
+	err := s.DB.QueryRow(`
+		SELECT id_usuario FROM pasos_login WHERE hash = ? AND expira_en > ?
+	`, seguridad.HashCodigo(codigo), time.Now()).Scan(&idUsuario)
+	if err == sql.ErrNoRows {
+		return 0, ErrPasoInvalido
+	}
+	return idUsuario, err
+}
+
+// TerminarPaso borra el paso pendiente (al completar el login o al bloquearse la cuenta).
+func (s *Servicio) TerminarPaso(codigo string) error {
+	_, err := s.DB.Exec(`DELETE FROM pasos_login WHERE hash = ?`, seguridad.HashCodigo(codigo))
+	return err
+}
+
+// =========================================================
+// FUNCIONES AUXILIARES
+// =========================================================
+
+// verificarEnTx valida el código dentro de la transacción. Los códigos TOTP se
+// aceptan una sola vez (se guarda el último paso usado); los de recuperación se
+// marcan como usados.
+func verificarEnTx(tx *sql.Tx, idUsuario int, codigo string) (bool, error) {
+	var secreto string
+	var ultimoPaso int64
+	err := tx.QueryRow(`
+		SELECT secreto, ultimo_paso FROM doble_factor
+		WHERE id_usuario = ? AND activo = 1
+		FOR UPDATE
+	`, idUsuario).Scan(&secreto, &ultimoPaso)
+	if err == sql.ErrNoRows {
+		return false, ErrNoActivo
+	}
+	if err != nil {
+		return false, err
+	}
+
+	codigo = normalizarCodigo(codigo)
+	ahora := time.Now()
+	if paso, ok := seguridad.VerificarTOTP(secreto, codigo, ahora, ultimoPaso); ok {
+		_, err := tx.Exec(`UPDATE doble_factor SET ultimo_paso = ? WHERE id_usuario = ?`, paso, idUsuario)
+		return false, err
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		SELECT id FROM codigos_recuperacion
+		WHERE id_usuario = ? AND hash = ? AND usado_en IS NULL
+		FOR UPDATE
+	`, idUsuario, seguridad.HashCodigo(codigo)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, ErrCodigoInvalido
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`UPDATE codigos_recuperacion SET usado_en = ? WHERE id = ?`, ahora, id)
+	return true, err
+}
+
+// reemplazarCodigos borra los códigos de recuperación del usuario y genera
+// CantidadCodigos nuevos con el formato xxxxx-xxxxx.
+func reemplazarCodigos(tx *sql.Tx, idUsuario int) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM codigos_recuperacion WHERE id_usuario = ?`, idUsuario); err != nil {
+		return nil, err
+	}
+
+	ahora := time.Now()
+	codigos := make([]string, 0, CantidadCodigos)
+	for len(codigos) < CantidadCodigos {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		for i := range b {
+			b[i] = alfabetoRecuperacion[int(b[i])%len(alfabetoRecuperacion)]
+		}
+		codigo := string(b[:5]) + "-" + string(b[5:])
+
+		_, err := tx.Exec(`
+			INSERT INTO codigos_recuperacion (id_usuario, hash, creado_en) VALUES (?, ?, ?)
+		`, idUsuario, seguridad.HashCodigo(normalizarCodigo(codigo)), ahora)
+		if err != nil {
+			return nil, err
+		}
+		codigos = append(codigos, codigo)
+	}
+	return codigos, nil
+}
+
+// borrar quita el secreto, los códigos de recuperación y los pasos pendientes del usuario.
+func borrar(tx *sql.Tx, idUsuario int) error {
+	for _, consulta := range []string{
+		`DELETE FROM doble_factor WHERE id_usuario = ?`,
+		`DELETE FROM codigos_recuperacion WHERE id_usuario = ?`,
+		`DELETE FROM pasos_login WHERE id_usuario = ?`,
+	} {
+		if _, err := tx.Exec(consulta, idUsuario); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizarCodigo quita espacios y guiones y pasa a minúsculas lo que escribió
+// el usuario, para aceptar "123 456" o "ABCDE-FGHJK".
+func normalizarCodigo(codigo string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(codigo)))
+}
@@ -0,0 +1,167 @@
+package exportacion // Paquete exportacion: descarga del catálogo en CSV, JSON y MARCXML.
+
+import (
+	"encoding/csv"   // Paquete para escribir el CSV.
+	"encoding/json"  // Paquete para escribir el JSON.
+	"errors"         // Paquete para errores de la exportación.
+	"io"             // Paquete para escribir la salida.
+	"sistema/libros" // Repositorio de libros.
+	"sistema/models" // Estructura Libro.
+	"strconv"        // Paquete para convertir números a texto.
+	"time"           // Paquete para la fecha de los registros MARC.
+)
+
+// Formatos de exportación (valor del parámetro salida).
+const (
+	FormatoCSV     = "csv"     // Una fila por libro, con las columnas que acepta la importación.
+	FormatoJSON    = "json"    // Lista con el formato de libros.json.
+	FormatoMARCXML = "marcxml" // Registros MARC 21 en XML, para intercambiar con sistemas de bibliotecas.
+)
+
+// Formatos lista los formatos de exportación en el orden de pantalla.
+var Formatos = []string{FormatoCSV, FormatoJSON, FormatoMARCXML}
+
+// ErrFormato se usa cuando se pide un formato de exportación desconocido.
+var ErrFormato = errors.New("formato de exportación desconocido")
+
+// escritor escribe los libros de una exportación de a uno, a medida que se leen.
+type escritor interface {
+	escribir(l models.Libro) error // Agrega un libro a la salida.
+	cerrar() error                 // Completa el archivo después del último libro.
+}
+
+// TipoContenido devuelve el Content-Type del formato de exportación.
+func TipoContenido(formato string) string {
+	switch formato {
+	case FormatoCSV:
+		return "text/csv; charset=utf-8"
+	case FormatoJSON:
+		return "application/json; charset=utf-8"
+	case FormatoMARCXML:
+		return "application/marcxml+xml; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension devuelve la extensión de archivo (con punto) del formato de exportación.
+func Extension(formato string) string {
+	switch formato {
+	case FormatoCSV:
+		return ".csv"
+	case FormatoJSON:
+		return ".json"
+	case FormatoMARCXML:
+		return ".xml"
+	default:
+		return ""
+	}
+}
+
+// Exportar escribe en salida los libros que cumplen el filtro, en el formato
+// pedido, sin cargarlos todos en memoria. Devuelve ErrFormato (sin escribir
+// nada) si el formato no es uno de Formatos.
+func Exportar(salida io.Writer, formato string, repo libros.LibroRepository, f libros.Filtro) error {
+	var e escritor
+	switch formato {
+	case FormatoCSV:
+		e = nuevoEscritorCSV(salida)
+	case FormatoJSON:
+		e = &escritorJSON{salida: salida}
+	case FormatoMARCXML:
+		e = nuevoEscritorMARC(salida, time.Now())
+	default:
+		return ErrFormato
+	}
+
+	if err := repo.Recorrer(f, e.escribir); err != nil {
+		return err
+	}
+	return e.cerrar()
+}
+
+// columnasCSV son los títulos del CSV: los nombres JSON de los campos, los
+// mismos que reconoce la importación (que ignora la columna id).
+var columnasCSV = []string{"id", "titulo", "autor", "categoria", "anio_publicacion", "formato", "licencias_totales", "stock_licencias", "idioma", "editorial", "isbn", "paginas"}
+
+// escritorCSV escribe un libro por fila, después de la fila de títulos.
+type escritorCSV struct {
+	salida *csv.Writer
+}
+
+// nuevoEscritorCSV crea el escritor y escribe la fila de títulos.
+func nuevoEscritorCSV(w io.Writer) *escritorCSV {
+	e := &escritorCSV{salida: csv.NewWriter(w)}
+	_ = e.salida.Write(columnasCSV)
+	return e
+}
+
+// escribir agrega el libro como una fila (páginas vacío si no se conoce).
+func (e *escritorCSV) escribir(l models.Libro) error {
+	paginas := ""
+	if l.Paginas > 0 {
+		paginas = strconv.Itoa(l.Paginas)
+	}
+	return e.salida.Write([]string{
+		strconv.Itoa(l.ID),
+		l.Titulo,
+		l.Autor,
+		l.Categoria,
+		strconv.Itoa(l.AnioPublicacion),
+		l.Formato,
+		strconv.Itoa(l.LicenciasTotales),
+		strconv.Itoa(l.StockLicencias),
+		l.Idioma,
+		l.Editorial,
+		l.ISBN,
+		paginas,
+	})
+}
+
+// cerrar vacía el búfer del CSV.
+func (e *escritorCSV) cerrar() error {
+	e.salida.Flush()
+	return e.salida.Error()
+}
+
+// libroJSON es un libro tal como se exporta: todos los campos de models.Libro
+// más "anio", así el archivo lo leen tanto RepositorioJSON como quienes usan el
+// formato anterior de libros.json (id, titulo, autor, anio).
+type libroJSON struct {
+	models.Libro
+	Anio int `json:"anio"` // Igual a anio_publicacion.
+}
+
+// escritorJSON escribe una lista JSON con la misma sangría que libros.json.
+type escritorJSON struct {
+	salida   io.Writer
+	escritos int // Libros escritos hasta ahora.
+}
+
+// escribir agrega el libro a la lista (abre la lista con el primero).
+func (e *escritorJSON) escribir(l models.Libro) error {
+	datos, err := json.MarshalIndent(libroJSON{Libro: l, Anio: l.AnioPublicacion}, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	separador := ",\n  "
+	if e.escritos == 0 {
+		separador = "[\n  "
+	}
+	e.escritos++
+	if _, err := io.WriteString(e.salida, separador); err != nil {
+		return err
+	}
+	_, err = e.salida.Write(datos)
+	return err
+}
+
+// cerrar termina la lista (vacía si no hubo libros).
+func (e *escritorJSON) cerrar() error {
+	fin := "\n]\n"
+	if e.escritos == 0 {
+		fin = "[]\n"
+	}
+	_, err := io.WriteString(e.salida, fin)
+	return err
+}
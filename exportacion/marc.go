@@ -0,0 +1,193 @@
+package exportacion // Paquete exportacion.
+
+import (
+	"encoding/xml"   // Paquete para escribir los registros MARCXML.
+	"fmt"            // Paquete para armar los campos de largo fijo.
+	"io"             // Paquete para escribir la salida.
+	"sistema/models" // Estructura Libro.
+	"strconv"        // Paquete para convertir números a texto.
+	"strings"        // Paquete para normalizar el idioma.
+	"time"           // Paquete para la fecha de creación del registro.
+)
+
+// espacioMARC es el espacio de nombres de MARC 21 en XML (MARCXML).
+const espacioMARC = "http://www.loc.gov/MARC21/slim"
+
+// liderMARC es la cabecera de cada registro: nuevo (n), material textual (a),
+// monografía (m), en Unicode (a). El largo y la dirección base quedan en cero
+// porque en MARCXML no se usan.
+const liderMARC = "00000nam a2200000   4500"
+
+// idiomasMARC traduce los códigos ISO 639-1 más comunes al código de idioma de
+// MARC (tres letras). Los códigos de tres letras se usan tal cual.
+var idiomasMARC = map[string]string{
+	"es": "spa",
+	"en": "eng",
+	"pt": "por",
+	"fr": "fre",
+	"de": "ger",
+	"it": "ita",
+	"ca": "cat",
+	"gl": "glg",
+	"eu": "baq",
+	"la": "lat",
+}
+
+// registroMARC es un registro bibliográfico MARC 21 (elemento record).
+type registroMARC struct {
+	XMLName xml.Name       `xml:"record"`
+	Lider   string         `xml:"leader"`
+	Control []campoControl `xml:"controlfield"`
+	Datos   []campoDatos   `xml:"datafield"`
+}
+
+// campoControl es un campo de control (001-009): solo un valor.
+type campoControl struct {
+	Etiqueta string `xml:"tag,attr"`
+	Valor    string `xml:",chardata"`
+}
+
+// campoDatos es un campo de datos con sus indicadores y subcampos.
+type campoDatos struct {
+	Etiqueta  string     `xml:"tag,attr"`
+	Ind1      string     `xml:"ind1,attr"`
+	Ind2      string     `xml:"ind2,attr"`
+	Subcampos []subcampo `xml:"subfield"`
+}
+
+// subcampo es un subcampo de un campo de datos ($a, $b, ...).
+type subcampo struct {
+	Codigo string `xml:"code,attr"`
+	Valor  string `xml:",chardata"`
+}
+
+// escritorMARC escribe una colección MARCXML con un registro por libro.
+type escritorMARC struct {
+	salida   io.Writer
+	xml      *xml.Encoder
+	creado   string // Fecha de creación de los registros (aammdd, campo 008).
+	escritos int    // Registros escritos hasta ahora.
+}
+
+// nuevoEscritorMARC crea el escritor; fecha es la de creación de los registros.
+func nuevoEscritorMARC(w io.Writer, fecha time.Time) *escritorMARC {
+	e := &escritorMARC{salida: w, xml: xml.NewEncoder(w), creado: fecha.Format("060102")}
+	e.xml.Indent("  ", "  ")
+	return e
+}
+
+// abrir escribe la declaración XML y el comienzo de la colección.
+func (e *escritorMARC) abrir() error {
+	_, err := io.WriteString(e.salida, xml.Header+`<collection xmlns="`+espacioMARC+`">`+"\n")
+	return err
+}
+
+// escribir agrega el registro MARC del libro (abre la colección con el primero).
+func (e *escritorMARC) escribir(l models.Libro) error {
+	if e.escritos == 0 {
+		if err := e.abrir(); err != nil {
+			return err
+		}
+	}
+	e.escritos++
+	return e.xml.Encode(registroLibro(l, e.creado))
+}
+
+// cerrar termina la colección (vacía si no hubo libros).
+func (e *escritorMARC) cerrar() error {
+	if e.escritos == 0 {
+		if err := e.abrir(); err != nil {
+			return err
+		}
+		_, err := io.WriteString(e.salida, "</collection>\n")
+		return err
+	}
+	if err := e.xml.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.salida, "\n</collection>\n")
+	return err
+}
+
+// registroLibro arma el registro MARC 21 de un libro electrónico:
+//
+//	001 ID del libro
+//	008 datos de largo fijo (fecha, año, recurso en línea, idioma)
+//	020 $a ISBN
+//	041 $a idioma
+//	100 $a autor
+//	245 $a título
+//	264 $b editorial $c año de publicación
+//	300 $a extensión (páginas)
+//	347 $a tipo de archivo $b formato (PDF, EPUB, MOBI)
+//	650 $a categoría
+//	949 $a licencias totales $b licencias libres (campo local)
+//
+// Los campos sin dato no se incluyen.
+func registroLibro(l models.Libro, creado string) registroMARC {
+	idioma := idiomaMARC(l.Idioma)
+	anio := "uuuu"
+	if l.AnioPublicacion > 0 && l.AnioPublicacion <= 9999 {
+		anio = fmt.Sprintf("%04d", l.AnioPublicacion)
+	}
+
+	r := registroMARC{
+		Lider: liderMARC,
+		Control: []campoControl{
+			{"001", strconv.Itoa(l.ID)},
+			// Posición 6: una sola fecha; 15-17: lugar desconocido; 23: recurso en línea.
+			{"008", creado + "s" + anio + "    " + "xx " + "     o           " + idioma + " d"},
+		},
+	}
+
+	agregar := func(etiqueta, ind1, ind2 string, pares ...string) {
+		campo := campoDatos{Etiqueta: etiqueta, Ind1: ind1, Ind2: ind2}
+		for i := 0; i+1 < len(pares); i += 2 {
+			if valor := strings.TrimSpace(pares[i+1]); valor != "" {
+				campo.Subcampos = append(campo.Subcampos, subcampo{pares[i], valor})
+			}
+		}
+		if len(campo.Subcampos) > 0 {
+			r.Datos = append(r.Datos, campo)
+		}
+	}
+
+	extension := "1 recurso en línea"
+	if l.Paginas > 0 {
+		extension += " (" + strconv.Itoa(l.Paginas) + " páginas)"
+	}
+	publicacion := ""
+	if anio != "uuuu" {
+		publicacion = strconv.Itoa(l.AnioPublicacion)
+	}
+	if idioma == "und" {
+		idioma = ""
+	}
+
+	agregar("020", " ", " ", "a", l.ISBN)
+	agregar("041", "0", " ", "a", idioma)
+	agregar("100", "1", " ", "a", l.Autor)
+	agregar("245", "1", "0", "a", l.Titulo)
+	agregar("264", " ", "1", "b", l.Editorial, "c", publicacion)
+	agregar("300", " ", " ", "a", extension)
+	agregar("347", " ", " ", "a", "text file", "b", l.Formato)
+	agregar("650", " ", "4", "a", l.Categoria)
+	agregar("949", " ", " ", "a", strconv.Itoa(l.LicenciasTotales), "b", strconv.Itoa(l.StockLicencias))
+	return r
+}
+
+// idiomaMARC devuelve el código MARC del idioma ("es", "es-AR" o "spa"), o
+// "und" (indeterminado) si no se conoce.
+func idiomaMARC(idioma string) string {
+	idioma = strings.ToLower(strings.TrimSpace(idioma))
+	if i := strings.IndexAny(idioma, "-_"); i >= 0 {
+		idioma = idioma[:i]
+	}
+	if codigo, ok := idiomasMARC[idioma]; ok {
+		return codigo
+	}
+	if len(idioma) == 3 && strings.Trim(idioma, "abcdefghijklmnopqrstuvwxyz") == "" {
+		return idioma
+	}
+	return "und"
+}
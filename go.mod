@@ -2,6 +2,10 @@ module sistema
 
 go 1.25.6
 
-require github.com/go-sql-driver/mysql v1.9.3
+require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/go-sql-driver/mysql v1.9.3
+	golang.org/x/crypto v0.45.0
+)
 
 require filippo.io/edwards25519 v1.1.0 // indirect
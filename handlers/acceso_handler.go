@@ -0,0 +1,100 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"    // Paquete para renderizar plantillas HTML.
+	"net/http"         // Paquete para rutas y respuestas HTTP.
+	"net/url"          // Paquete para codificar mensajes en la URL.
+	"sistema/intentos" // Intentos fallidos y bloqueos de cuentas.
+	"sistema/models"   // Estructuras IntentoLogin y BloqueoLogin.
+	"strings"          // Paquete para limpiar texto.
+)
+
+// limiteFallosPantalla es la cantidad de intentos fallidos recientes que se muestran.
+const limiteFallosPantalla = 100
+
+// AccesoHandler muestra los intentos fallidos de inicio de sesión y permite
+// desbloquear cuentas.
+type AccesoHandler struct {
+	Templates *template.Template // Plantillas HTML cargadas.
+	Intentos  *intentos.Servicio // Servicio de intentos.
+}
+
+// NuevoAccesoHandler crea una nueva instancia del handler de accesos.
+func NuevoAccesoHandler(templates *template.Template, servicio *intentos.Servicio) *AccesoHandler {
+	return &AccesoHandler{
+		Templates: templates,
+		Intentos:  servicio,
+	}
+}
+
+// VerAccesos lista las cuentas bloqueadas (o todas con fallos, con todos=1)
+// y los últimos intentos fallidos.
+// Ruta: GET /admin/accesos?todos=
+func (h *AccesoHandler) VerAccesos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	todos := r.URL.Query().Get("todos") == "1"
+	bloqueos, err := h.Intentos.Bloqueos(!todos)
+	if err != nil {
+		http.Error(w, "Error al consultar bloqueos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fallos, err := h.Intentos.UltimosFallos(limiteFallosPantalla)
+	if err != nil {
+		http.Error(w, "Error al consultar intentos fallidos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Bloqueos      []models.BloqueoLogin // Cuentas bloqueadas o con fallos.
+		Fallos        []models.IntentoLogin // Últimos intentos fallidos.
+		Todos         bool                  // Se muestran también las cuentas no bloqueadas.
+		Mensaje       string                // Resultado de la última acción.
+		UsuarioNombre string                // Usuario actual.
+		UsuarioRol    string                // Rol actual.
+		CSRF          string                // Token CSRF de los formularios.
+	}{
+		Bloqueos:      bloqueos,
+		Fallos:        fallos,
+		Todos:         todos,
+		Mensaje:       strings.TrimSpace(r.URL.Query().Get("msg")),
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+		CSRF:          TokenCSRF(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "admin_accesos.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla admin_accesos.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Desbloquear quita el bloqueo y los fallos acumulados de un correo.
+// Ruta: POST /admin/accesos/desbloquear (correo)
+func (h *AccesoHandler) Desbloquear(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	correo := strings.TrimSpace(r.FormValue("correo"))
+	if correo == "" {
+		http.Error(w, "Correo inválido", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Intentos.Desbloquear(correo); err != nil {
+		http.Error(w, "Error al desbloquear cuenta: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	destino := "/admin/accesos?msg=" + url.QueryEscape("Cuenta "+correo+" desbloqueada")
+	if r.FormValue("todos") == "1" {
+		destino += "&todos=1"
+	}
+	http.Redirect(w, r, destino, http.StatusSeeOther)
+}
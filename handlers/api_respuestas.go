@@ -0,0 +1,53 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"encoding/json" // Paquete para serializar respuestas JSON.
+	"net/http"      // Paquete para escribir respuestas HTTP.
+	"strings"       // Paquete para reconocer rutas de la API.
+)
+
+// ErrorAPI es el cuerpo estructurado de error que devuelve la API JSON.
+type ErrorAPI struct {
+	Codigo  string `json:"codigo"`            // Código estable para clientes (ej. "no_encontrado").
+	Mensaje string `json:"mensaje"`           // Descripción legible del error.
+	Campo   string `json:"campo,omitempty"`   // Campo inválido, si aplica.
+	Detalle string `json:"detalle,omitempty"` // Información adicional opcional.
+}
+
+// ResponderJSON escribe un valor como JSON con el código de estado indicado.
+func ResponderJSON(w http.ResponseWriter, estado int, valor any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(estado)
+	_ = json.NewEncoder(w).Encode(valor)
+}
+
+// ResponderErrorJSON escribe un error estructurado con la forma {"error": {...}}.
+func ResponderErrorJSON(w http.ResponseWriter, estado int, codigo, mensaje string) {
+	ResponderJSON(w, estado, map[string]ErrorAPI{
+		"error": {Codigo: codigo, Mensaje: mensaje},
+	})
+}
+
+// responderErrorCampo escribe un error de validación asociado a un campo.
+func responderErrorCampo(w http.ResponseWriter, campo, mensaje string) {
+	ResponderJSON(w, http.StatusUnprocessableEntity, map[string]ErrorAPI{
+		"error": {Codigo: "validacion", Mensaje: mensaje, Campo: campo},
+	})
+}
+
+// RutaAPINoEncontrada responde 404 en JSON para rutas o métodos inexistentes bajo /api/.
+func RutaAPINoEncontrada(w http.ResponseWriter, r *http.Request) {
+	ResponderErrorJSON(w, http.StatusNotFound, "ruta_no_encontrada", "Ruta o método no disponible: "+r.Method+" "+r.URL.Path)
+}
+
+// SinMySQL responde 503 en las funciones que necesitan MySQL cuando el sistema
+// corre con LIBROS_STORE=json (cuentas, préstamos, historial, tokens, etc.).
+// Bajo /api/ responde en JSON.
+func SinMySQL(w http.ResponseWriter, r *http.Request) {
+	mensaje := "Esta función necesita MySQL: no está disponible con LIBROS_STORE=json"
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		ResponderErrorJSON(w, http.StatusServiceUnavailable, "sin_mysql", mensaje)
+		return
+	}
+	http.Error(w, mensaje, http.StatusServiceUnavailable)
+}
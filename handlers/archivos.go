@@ -0,0 +1,107 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"bytes"                  // Paquete para leer en memoria archivos sin acceso aleatorio.
+	"crypto/rand"            // Paquete para generar nombres de archivo únicos.
+	"encoding/hex"           // Paquete para representar bytes como texto.
+	"errors"                 // Paquete para errores de validación del archivo.
+	"fmt"                    // Paquete para armar mensajes de error.
+	"io"                     // Paquete para leer la cabecera del archivo.
+	"net/http"               // Paquete para leer el formulario multipart.
+	"path/filepath"          // Paquete para obtener la extensión.
+	"regexp"                 // Paquete para validar claves generadas por el sistema.
+	"sistema/almacenamiento" // Almacenamiento de archivos de libros.
+	"sistema/metadatos"      // Extracción de metadatos de EPUB/PDF.
+	"strings"                // Paquete para normalizar textos.
+)
+
+// tamanoMaximoLibro limita el tamaño de los archivos subidos (100 MB).
+const tamanoMaximoLibro = 100 << 20
+
+// patronClaveArchivo reconoce las claves que genera guardarArchivoLibro.
+var patronClaveArchivo = regexp.MustCompile(`^libros/[0-9a-f]{32}\.(pdf|epub|mobi)$`)
+
+// ErrArchivoInvalido se usa cuando el archivo subido no coincide con el formato indicado.
+var ErrArchivoInvalido = errors.New("archivo inválido")
+
+// guardarArchivoLibro valida y guarda el archivo del campo "archivo" del formulario.
+// Devuelve la clave guardada, o "" si no se adjuntó ningún archivo.
+func guardarArchivoLibro(almacen almacenamiento.Almacenamiento, r *http.Request, formato string) (string, error) {
+	archivo, cabecera, err := r.FormFile("archivo")
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer archivo.Close()
+
+	// La extensión debe corresponder al formato seleccionado.
+	extension := almacenamiento.Extension(formato)
+	if extension == "" {
+		return "", fmt.Errorf("%w: el formato debe ser PDF, EPUB o MOBI", ErrArchivoInvalido)
+	}
+	if strings.ToLower(filepath.Ext(cabecera.Filename)) != extension {
+		return "", fmt.Errorf("%w: se esperaba un archivo %s", ErrArchivoInvalido, extension)
+	}
+
+	// El contenido también debe corresponder (no basta con renombrar el archivo).
+	inicio := make([]byte, 512)
+	n, err := io.ReadFull(archivo, inicio)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("%w: no se pudo leer el archivo", ErrArchivoInvalido)
+	}
+	if almacenamiento.DetectarFormato(inicio[:n]) != strings.ToUpper(formato) {
+		return "", fmt.Errorf("%w: el contenido no es un %s válido", ErrArchivoInvalido, strings.ToUpper(formato))
+	}
+	if _, err := archivo.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	aleatorio := make([]byte, 16)
+	if _, err := rand.Read(aleatorio); err != nil {
+		return "", err
+	}
+	clave := "libros/" + hex.EncodeToString(aleatorio) + extension
+
+	if err := almacen.Guardar(clave, archivo); err != nil {
+		return "", err
+	}
+	return clave, nil
+}
+
+// responderErrorArchivo responde 400 si el archivo es inválido y 500 en otro caso.
+func responderErrorArchivo(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrArchivoInvalido) {
+		http.Error(w, "Archivo del libro inválido: "+strings.TrimPrefix(err.Error(), ErrArchivoInvalido.Error()+": "), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Error al guardar archivo del libro: "+err.Error(), http.StatusInternalServerError)
+}
+
+// claveArchivoValida indica si la clave tiene la forma generada por el sistema
+// y la extensión corresponde al formato.
+func claveArchivoValida(clave, formato string) bool {
+	return patronClaveArchivo.MatchString(clave) && strings.HasSuffix(clave, almacenamiento.Extension(formato))
+}
+
+// leerMetadatosArchivo abre un archivo ya guardado y extrae sus metadatos.
+func leerMetadatosArchivo(almacen almacenamiento.Almacenamiento, clave, formato string) (metadatos.Metadatos, error) {
+	objeto, err := almacen.Abrir(clave)
+	if err != nil {
+		return metadatos.Metadatos{}, err
+	}
+	defer objeto.Close()
+
+	// Los extractores necesitan acceso aleatorio; si el almacenamiento no lo ofrece
+	// (ej. un flujo remoto) el archivo se lee en memoria.
+	lector, ok := objeto.ReadCloser.(io.ReaderAt)
+	if !ok {
+		datos, err := io.ReadAll(io.LimitReader(objeto, tamanoMaximoLibro))
+		if err != nil {
+			return metadatos.Metadatos{}, err
+		}
+		lector = bytes.NewReader(datos)
+	}
+	return metadatos.Extraer(formato, lector, objeto.Tamano)
+}
@@ -0,0 +1,177 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"encoding/csv"      // Paquete para la exportación CSV.
+	"encoding/json"     // Paquete para los cambios en el CSV.
+	"html/template"     // Paquete para renderizar plantillas HTML.
+	"net"               // Paquete para separar la IP del puerto.
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"sistema/auditoria" // Registro y consulta de la auditoría.
+	"sistema/models"    // Estructuras Auditoria y Libro.
+	"strconv"           // Paquete para convertir números.
+	"strings"           // Paquete para limpiar texto.
+	"time"              // Paquete para el formato de fechas.
+)
+
+// accionesAuditoria son las acciones que se pueden filtrar, en orden de pantalla.
+var accionesAuditoria = []opcionAccion{
+	{models.AuditoriaCrear, "Alta"},
+	{models.AuditoriaActualizar, "Modificación"},
+	{models.AuditoriaEliminar, "Baja"},
+}
+
+// AuditoriaHandler muestra y exporta la auditoría del catálogo (solo ADMIN).
+type AuditoriaHandler struct {
+	Fuente    auditoria.Fuente   // Tabla auditoria de MySQL o archivo de libros JSON.
+	Templates *template.Template // Plantillas HTML cargadas.
+}
+
+// NuevoAuditoriaHandler crea una nueva instancia del handler de auditoría.
+func NuevoAuditoriaHandler(fuente auditoria.Fuente, templates *template.Template) *AuditoriaHandler {
+	return &AuditoriaHandler{
+		Fuente:    fuente,
+		Templates: templates,
+	}
+}
+
+// VerAuditoria muestra las entradas de la auditoría con filtros.
+// Ruta: GET /admin/auditoria?actor=&libro=&accion=&desde=AAAA-MM-DD&hasta=AAAA-MM-DD
+func (h *AuditoriaHandler) VerAuditoria(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filtro, ok := leerFiltroAuditoria(w, r)
+	if !ok {
+		return
+	}
+
+	entradas, err := auditoria.ListarFuente(h.Fuente, filtro)
+	if err != nil {
+		http.Error(w, "Error al consultar auditoría: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	data := struct {
+		Entradas      []models.Auditoria // Entradas de la auditoría.
+		Actor         string             // Filtro de actor.
+		Libro         string             // Filtro de libro.
+		Accion        string             // Filtro de acción.
+		Desde         string             // Filtro de fecha inicial.
+		Hasta         string             // Filtro de fecha final.
+		Exportar      string             // Enlace de exportación con los filtros actuales.
+		Acciones      []opcionAccion     // Opciones del filtro de acción.
+		UsuarioNombre string             // Usuario actual.
+		UsuarioRol    string             // Rol actual.
+	}{
+		Entradas:      entradas,
+		Actor:         filtro.Actor,
+		Libro:         strings.TrimSpace(q.Get("libro")),
+		Accion:        filtro.Accion,
+		Desde:         strings.TrimSpace(q.Get("desde")),
+		Hasta:         strings.TrimSpace(q.Get("hasta")),
+		Exportar:      "/admin/auditoria/exportar?" + r.URL.RawQuery,
+		Acciones:      accionesAuditoria,
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "admin_auditoria.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla admin_auditoria.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExportarAuditoria descarga en CSV todas las entradas que cumplen los filtros.
+// Ruta: GET /admin/auditoria/exportar (mismos filtros que /admin/auditoria)
+func (h *AuditoriaHandler) ExportarAuditoria(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filtro, ok := leerFiltroAuditoria(w, r)
+	if !ok {
+		return
+	}
+	filtro.Limite = -1
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="auditoria-`+time.Now().Format("20060102-150405")+`.csv"`)
+
+	// Las filas se escriben a medida que se leen; un error a mitad de camino
+	// ya no puede cambiar el código de estado, así que solo corta el archivo.
+	salida := csv.NewWriter(w)
+	_ = salida.Write([]string{"id", "fecha", "id_actor", "actor", "rol", "accion", "id_libro", "ip", "cambios"})
+	_ = h.Fuente.Recorrer(filtro, func(a models.Auditoria) error {
+		cambios, err := json.Marshal(a.Cambios)
+		if err != nil {
+			return err
+		}
+		return salida.Write([]string{
+			strconv.Itoa(a.ID),
+			a.Fecha.Format("2006-01-02 15:04:05"),
+			strconv.Itoa(a.IDActor),
+			a.Actor,
+			a.Rol,
+			a.Accion,
+			strconv.Itoa(a.IDLibro),
+			a.IP,
+			string(cambios),
+		})
+	})
+	salida.Flush()
+}
+
+// leerFiltroAuditoria arma el filtro desde la query; si hay errores responde 400 y devuelve false.
+func leerFiltroAuditoria(w http.ResponseWriter, r *http.Request) (auditoria.Filtro, bool) {
+	q := r.URL.Query()
+	filtro := auditoria.Filtro{
+		Actor: strings.TrimSpace(q.Get("actor")),
+	}
+
+	accion := strings.ToUpper(strings.TrimSpace(q.Get("accion")))
+	for _, a := range accionesAuditoria {
+		if a.Valor == accion {
+			filtro.Accion = accion
+		}
+	}
+
+	if v := strings.TrimSpace(q.Get("libro")); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			http.Error(w, "ID de libro inválido", http.StatusBadRequest)
+			return filtro, false
+		}
+		filtro.Libro = id
+	}
+
+	var ok bool
+	filtro.Desde, filtro.Hasta, ok = leerRangoFechas(w, strings.TrimSpace(q.Get("desde")), strings.TrimSpace(q.Get("hasta")))
+	return filtro, ok
+}
+
+// autorCambio arma la entrada de auditoría con el usuario de la petición; el
+// repositorio de libros la completa con la acción y los cambios.
+func autorCambio(r *http.Request) models.Auditoria {
+	sesion, _ := SesionActual(r)
+	return models.Auditoria{
+		IDActor: sesion.IDUsuario,
+		Actor:   sesion.Nombre,
+		Rol:     sesion.Rol,
+		IP:      ipCliente(r),
+	}
+}
+
+// ipCliente devuelve la IP de la conexión, sin el puerto.
+// No se usa X-Forwarded-For porque el cliente puede falsificarlo.
+func ipCliente(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
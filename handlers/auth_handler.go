@@ -1,260 +1,666 @@
-package handlers // Paquete handlers: contiene controladores del sistema.
-
-import (
-	"database/sql"   // Paquete para trabajar con bases de datos SQL.
-	"html/template"  // Paquete para renderizar plantillas HTML.
-	"net/http"       // Paquete para servidor web, rutas y cookies.
-	"sistema/models" // Importa la estructura Usuario.
-	"strings"        // Paquete para limpiar y comparar textos.
-)
-
-// AuthHandler agrupa los recursos necesarios para autenticación.
-type AuthHandler struct {
-	DB        *sql.DB            // Conexión a la base de datos.
-	Templates *template.Template // Plantillas HTML cargadas.
-}
-
-// NuevoAuthHandler crea una nueva instancia del handler de autenticación.
-func NuevoAuthHandler(db *sql.DB, templates *template.Template) *AuthHandler {
-	return &AuthHandler{
-		DB:        db,        // Guarda la conexión MySQL.
-		Templates: templates, // Guarda las plantillas HTML.
-	}
-}
-
-// MostrarLogin renderiza la plantilla login.html.
-// Ruta: GET /login
-func (h *AuthHandler) MostrarLogin(w http.ResponseWriter, r *http.Request) {
-	// Solo se permite método GET para mostrar el formulario.
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Si el usuario ya está logueado, se redirige al panel principal.
-	if EstaLogueado(r) {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
-	}
-
-	// Lee mensaje de error/información desde la URL (ej: ?error=Credenciales+inválidas).
-	errorMsg := strings.TrimSpace(r.URL.Query().Get("error"))
-
-	// Estructura de datos para la plantilla login.html.
-	data := struct {
-		Error string // Mensaje de error o aviso.
-	}{
-		Error: errorMsg,
-	}
-
-	// Renderiza la plantilla login.html.
-	err := h.Templates.ExecuteTemplate(w, "login.html", data)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla login.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// ProcesarLogin valida credenciales y crea sesión con cookies.
-// Ruta: POST /login/procesar
-func (h *AuthHandler) ProcesarLogin(w http.ResponseWriter, r *http.Request) {
-	// Solo se permite método POST.
-	if r.Method != http.MethodPost {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Lee el formulario enviado desde login.html.
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
-		return
-	}
-
-	// Obtiene y limpia los valores enviados por el usuario.
-	correo := strings.TrimSpace(r.FormValue("correo"))
-	clave := strings.TrimSpace(r.FormValue("clave"))
-
-	// Validación básica: ambos campos son obligatorios.
-	if correo == "" || clave == "" {
-		http.Redirect(w, r, "/login?error=Debe+ingresar+correo+y+clave", http.StatusSeeOther)
-		return
-	}
-
-	// Variable para cargar los datos del usuario si las credenciales son válidas.
-	var usuario models.Usuario
-
-	// Consulta SQL para validar correo + clave + estado ACTIVO.
-	// Nota: En esta fase la clave se compara como texto plano (demo académica).
-	query := `
-		SELECT 
-			u.id_usuario,
-			u.nombre,
-			u.correo,
-			u.id_rol,
-			r.nombre_rol,
-			u.estado
-		FROM usuarios u
-		INNER JOIN roles r ON u.id_rol = r.id_rol
-		WHERE u.correo = ? AND u.clave = ? AND u.estado = 'ACTIVO'
-		LIMIT 1
-	`
-
-	// Ejecuta la consulta y llena la estructura usuario.
-	err = h.DB.QueryRow(query, correo, clave).Scan(
-		&usuario.IDUsuario,
-		&usuario.Nombre,
-		&usuario.Correo,
-		&usuario.IDRol,
-		&usuario.NombreRol,
-		&usuario.Estado,
-	)
-
-	// Si no coincide usuario/clave, redirige al login con error.
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Redirect(w, r, "/login?error=Credenciales+inválidas", http.StatusSeeOther)
-			return
-		}
-
-		// Si ocurre otro error, responde 500.
-		http.Error(w, "Error al validar usuario: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// =========================================================
-	// CREACIÓN DE SESIÓN SIMPLE CON COOKIES
-	// =========================================================
-
-	// Cookie principal: indica que el usuario está autenticado.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_logueado", // Nombre de la cookie.
-		Value:    "true",             // Valor que representa sesión activa.
-		Path:     "/",                // Disponible en todo el sitio.
-		HttpOnly: true,               // Evita acceso desde JavaScript.
-	})
-
-	// Cookie con el nombre del usuario (para mostrar en interfaz).
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_nombre",
-		Value:    usuario.Nombre,
-		Path:     "/",
-		HttpOnly: true,
-	})
-
-	// Cookie con el rol del usuario (ADMIN / OPERADOR / CONSULTA).
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_rol",
-		Value:    usuario.NombreRol,
-		Path:     "/",
-		HttpOnly: true,
-	})
-
-	// =========================================================
-	// REDIRECCIÓN SEGÚN ROL
-	// =========================================================
-
-	// Si el usuario tiene rol CONSULTA, se envía directamente al catálogo.
-	// Esto permite que el usuario lector vea catálogo, detalle y descarga.
-	if strings.ToUpper(strings.TrimSpace(usuario.NombreRol)) == "CONSULTA" {
-		http.Redirect(w, r, "/catalogo", http.StatusSeeOther)
-		return
-	}
-
-	// Si es ADMIN u OPERADOR, se envía al panel principal.
-	http.Redirect(w, r, "/?msg=Bienvenido+"+usuario.Nombre, http.StatusSeeOther)
-}
-
-// Logout elimina cookies de sesión y redirige al login.
-// Ruta: GET /logout
-func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Elimina cookie de estado de login.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_logueado",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1, // MaxAge negativo elimina la cookie.
-		HttpOnly: true,
-	})
-
-	// Elimina cookie de nombre de usuario.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_nombre",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
-
-	// Elimina cookie de rol de usuario.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "usuario_rol",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
-
-	// Redirige al login con mensaje informativo.
-	http.Redirect(w, r, "/login?error=Sesión+cerrada+correctamente", http.StatusSeeOther)
-}
-
-// =========================================================
-// FUNCIONES AUXILIARES DE SESIÓN Y ROLES
-// =========================================================
-
-// EstaLogueado verifica si existe la cookie de sesión activa.
-func EstaLogueado(r *http.Request) bool {
-	// Busca la cookie de login.
-	cookie, err := r.Cookie("usuario_logueado")
-	if err != nil {
-		// Si no existe cookie, no hay sesión.
-		return false
-	}
-
-	// Si existe y su valor es "true", se considera logueado.
-	return cookie.Value == "true"
-}
-
-// ObtenerNombreUsuario obtiene el nombre del usuario desde cookie.
-// Si no existe cookie, devuelve cadena vacía.
-func ObtenerNombreUsuario(r *http.Request) string {
-	cookie, err := r.Cookie("usuario_nombre")
-	if err != nil {
-		return ""
-	}
-	return cookie.Value
-}
-
-// ObtenerRolUsuario obtiene el rol del usuario desde cookie.
-// Si no existe cookie, devuelve cadena vacía.
-func ObtenerRolUsuario(r *http.Request) string {
-	cookie, err := r.Cookie("usuario_rol")
-	if err != nil {
-		return ""
-	}
-	return cookie.Value
-}
-
-// TieneRol verifica si el usuario tiene alguno de los roles permitidos.
-// Ejemplo de uso: TieneRol(r, "ADMIN", "OPERADOR")
-func TieneRol(r *http.Request, rolesPermitidos ...string) bool {
-	// Obtiene el rol actual desde cookie y lo normaliza a mayúsculas.
-	rolActual := strings.ToUpper(strings.TrimSpace(ObtenerRolUsuario(r)))
-
-	// Si no existe rol, no tiene permisos.
-	if rolActual == "" {
-		return false
-	}
-
-	// Recorre la lista de roles permitidos.
-	for _, rolPermitido := range rolesPermitidos {
-		// Compara normalizando mayúsculas/espacios.
-		if rolActual == strings.ToUpper(strings.TrimSpace(rolPermitido)) {
-			return true
-		}
-	}
-
-	// Si no coincidió con ninguno, no tiene permiso.
-	return false
-}
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"context"             // Paquete para guardar la sesión en la petición.
+	"database/sql"        // Paquete para trabajar con bases de datos SQL.
+	"errors"              // Paquete para errores de autenticación.
+	"html/template"       // Paquete para renderizar plantillas HTML.
+	"log"                 // Paquete para avisar claves heredadas que no se pueden hashear.
+	"math"                // Paquete para redondear la espera en minutos.
+	"net/http"            // Paquete para servidor web, rutas y cookies.
+	"net/url"             // Paquete para codificar mensajes en la URL.
+	"sistema/doblefactor" // Segundo factor TOTP y códigos de recuperación.
+	"sistema/intentos"    // Límite de intentos y bloqueo de cuentas.
+	"sistema/models"      // Importa las estructuras Usuario y Sesion.
+	"sistema/permisos"    // Política de segundo factor obligatorio por rol.
+	"sistema/qr"          // Código QR del alta del segundo factor.
+	"sistema/seguridad"   // Hash y verificación de contraseñas.
+	"sistema/sesiones"    // Gestor de sesiones del lado del servidor.
+	"sistema/usuarios"    // Cuenta local cuando no hay MySQL.
+	"strconv"             // Paquete para armar mensajes de espera.
+	"strings"             // Paquete para limpiar y comparar textos.
+	"time"                // Paquete para la espera de los bloqueos.
+)
+
+// nombreCookiePaso es la cookie que identifica un inicio de sesión con la clave
+// validada que espera el código del segundo factor.
+const nombreCookiePaso = "paso_login"
+
+// AuthHandler agrupa los recursos necesarios para autenticación.
+type AuthHandler struct {
+	DB          *sql.DB               // Conexión a la base de datos.
+	Templates   *template.Template    // Plantillas HTML cargadas.
+	Sesiones    *sesiones.Gestor      // Gestor de sesiones del servidor.
+	Intentos    *intentos.Servicio    // Límite de intentos fallidos.
+	DobleFactor *doblefactor.Servicio // Segundo factor TOTP.
+	Permisos    *permisos.Servicio    // Política de segundo factor por rol.
+	Local       *usuarios.CuentaLocal // Única cuenta cuando no hay MySQL (DB nil).
+}
+
+// NuevoAuthHandler crea una nueva instancia del handler de autenticación.
+func NuevoAuthHandler(db *sql.DB, templates *template.Template, gestor *sesiones.Gestor, servicioIntentos *intentos.Servicio, servicioDobleFactor *doblefactor.Servicio, servicioPermisos *permisos.Servicio) *AuthHandler {
+	return &AuthHandler{
+		DB:          db,                  // Guarda la conexión MySQL.
+		Templates:   templates,           // Guarda las plantillas HTML.
+		Sesiones:    gestor,              // Guarda el gestor de sesiones.
+		Intentos:    servicioIntentos,    // Guarda el límite de intentos.
+		DobleFactor: servicioDobleFactor, // Guarda el servicio de segundo factor.
+		Permisos:    servicioPermisos,    // Guarda el servicio de permisos.
+	}
+}
+
+// MostrarLogin renderiza la plantilla login.html.
+// Ruta: GET /login
+func (h *AuthHandler) MostrarLogin(w http.ResponseWriter, r *http.Request) {
+	// Solo se permite método GET para mostrar el formulario.
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Si el usuario ya tiene una sesión vigente, se redirige al panel principal.
+	if _, ok := h.Sesiones.Resolver(r); ok {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Lee mensaje de error/información desde la URL (ej: ?error=Credenciales+inválidas).
+	errorMsg := strings.TrimSpace(r.URL.Query().Get("error"))
+
+	// Estructura de datos para la plantilla login.html.
+	data := struct {
+		Error string // Mensaje de error o aviso.
+		CSRF  string // Token CSRF del formulario.
+	}{
+		Error: errorMsg,
+		CSRF:  TokenCSRF(r),
+	}
+
+	// Renderiza la plantilla login.html.
+	err := h.Templates.ExecuteTemplate(w, "login.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla login.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ProcesarLogin valida credenciales y crea la sesión del servidor.
+// Ruta: POST /login/procesar
+func (h *AuthHandler) ProcesarLogin(w http.ResponseWriter, r *http.Request) {
+	// Solo se permite método POST.
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Lee el formulario enviado desde login.html.
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	// Obtiene y limpia los valores enviados por el usuario.
+	correo := strings.TrimSpace(r.FormValue("correo"))
+	clave := strings.TrimSpace(r.FormValue("clave"))
+
+	// Validación básica: ambos campos son obligatorios.
+	if correo == "" || clave == "" {
+		http.Redirect(w, r, "/login?error=Debe+ingresar+correo+y+clave", http.StatusSeeOther)
+		return
+	}
+
+	// Verifica correo y clave respetando el límite de intentos fallidos.
+	usuario, espera, err := VerificarConLimite(h.verificarClave, h.Intentos, correo, clave, ipCliente(r))
+	if err != nil {
+		switch err {
+		case ErrCredencialesInvalidas:
+			http.Redirect(w, r, "/login?error=Credenciales+inválidas", http.StatusSeeOther)
+			return
+		case intentos.ErrCuentaBloqueada, intentos.ErrDemasiadosIntentos:
+			http.Redirect(w, r, "/login?error="+url.QueryEscape(MensajeBloqueo(err, espera)), http.StatusSeeOther)
+			return
+		}
+
+		// Si ocurre otro error, responde 500.
+		http.Error(w, "Error al validar usuario: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// =========================================================
+	// SEGUNDO FACTOR
+	// =========================================================
+
+	// Si el usuario activó el segundo factor, o su rol lo exige, la sesión se
+	// crea recién después de verificar el código en /login/segundo-paso.
+	exigir, err := h.requiereSegundoPaso(usuario)
+	if err != nil {
+		http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if exigir {
+		codigo, err := h.DobleFactor.CrearPaso(usuario.IDUsuario)
+		if err != nil {
+			http.Error(w, "Error al iniciar segundo paso: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.fijarCookiePaso(w, codigo, int(h.DobleFactor.VigenciaPaso.Seconds()))
+		http.Redirect(w, r, "/login/segundo-paso", http.StatusSeeOther)
+		return
+	}
+
+	// Acceso correcto: se borran los fallos acumulados de la cuenta.
+	if err := h.Intentos.RegistrarExito(usuario.Correo); err != nil {
+		http.Error(w, "Error al registrar acceso: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.iniciarSesion(w, r, usuario)
+}
+
+// iniciarSesion crea la sesión del servidor y redirige según el rol.
+func (h *AuthHandler) iniciarSesion(w http.ResponseWriter, r *http.Request, usuario models.Usuario) {
+	// =========================================================
+	// CREACIÓN DE SESIÓN DEL LADO DEL SERVIDOR
+	// =========================================================
+
+	// Se guarda la sesión en el almacén; el navegador solo recibe un ID firmado.
+	// Nombre y rol quedan en el servidor, por lo que el cliente no puede falsificarlos.
+	_, err := h.Sesiones.Crear(w, usuario)
+	if err != nil {
+		http.Error(w, "Error al crear sesión: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// =========================================================
+	// REDIRECCIÓN SEGÚN ROL
+	// =========================================================
+
+	http.Redirect(w, r, destinoInicial(usuario), http.StatusSeeOther)
+}
+
+// destinoInicial devuelve la primera pantalla del usuario tras iniciar sesión.
+func destinoInicial(usuario models.Usuario) string {
+	// Si el usuario tiene rol CONSULTA, se envía directamente al catálogo.
+	// Esto permite que el usuario lector vea catálogo, detalle y descarga.
+	if strings.ToUpper(strings.TrimSpace(usuario.NombreRol)) == "CONSULTA" {
+		return "/catalogo"
+	}
+
+	// Si es ADMIN u OPERADOR, se envía al panel principal.
+	return "/?msg=" + url.QueryEscape("Bienvenido "+usuario.Nombre)
+}
+
+// Logout elimina la sesión del servidor, borra la cookie y redirige al login.
+// Ruta: GET /logout
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// Elimina la sesión del almacén y la cookie con su ID.
+	h.Sesiones.Destruir(w, r)
+
+	// Redirige al login con mensaje informativo.
+	http.Redirect(w, r, "/login?error=Sesión+cerrada+correctamente", http.StatusSeeOther)
+}
+
+// =========================================================
+// SEGUNDO PASO DEL INICIO DE SESIÓN (TOTP)
+// =========================================================
+
+// formularioSegundoPaso son los datos de la plantilla segundo_paso.html.
+type formularioSegundoPaso struct {
+	Nombre  string       // Nombre del usuario que validó su clave.
+	Alta    bool         // El rol exige segundo factor y el usuario aún no lo activó.
+	Secreto string       // Secreto en grupos de 4, para cargarlo a mano (solo en el alta).
+	URI     template.URL // URI otpauth:// del código QR (solo en el alta); la arma el sistema.
+	Error   string       // Código incorrecto u otro aviso.
+	CSRF    string       // Token CSRF del formulario.
+}
+
+// MostrarSegundoPaso pide el código de la aplicación autenticadora (o uno de
+// recuperación). Si el rol exige segundo factor y el usuario no lo activó,
+// muestra primero el código QR para darlo de alta.
+// Ruta: GET /login/segundo-paso
+func (h *AuthHandler) MostrarSegundoPaso(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	usuario, _, err := h.usuarioDelPaso(r)
+	if err != nil {
+		h.cancelarPaso(w, r, err)
+		return
+	}
+	h.renderizarSegundoPaso(w, r, usuario, "")
+}
+
+// ProcesarSegundoPaso verifica el código y recién entonces crea la sesión.
+// Los códigos incorrectos cuentan como intentos fallidos de la cuenta.
+// Ruta: POST /login/segundo-paso (codigo)
+func (h *AuthHandler) ProcesarSegundoPaso(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	usuario, paso, err := h.usuarioDelPaso(r)
+	if err != nil {
+		h.cancelarPaso(w, r, err)
+		return
+	}
+
+	ip := ipCliente(r)
+	if espera, err := h.Intentos.Permitir(usuario.Correo, ip); err != nil {
+		h.bloquearPaso(w, r, paso, err, espera)
+		return
+	}
+
+	estado, err := h.DobleFactor.Estado(usuario.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Con el segundo factor activo se verifica el código; si no, se confirma el alta.
+	var codigosNuevos []string
+	codigo := r.FormValue("codigo")
+	if estado.Activo {
+		_, err = h.DobleFactor.Verificar(usuario.IDUsuario, codigo)
+	} else {
+		codigosNuevos, err = h.DobleFactor.ConfirmarAlta(usuario.IDUsuario, codigo)
+	}
+	switch err {
+	case nil:
+	case doblefactor.ErrCodigoInvalido:
+		espera, err := h.Intentos.RegistrarFallo(usuario.Correo, ip)
+		if err != nil {
+			http.Error(w, "Error al registrar intento: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if espera > 0 {
+			h.bloquearPaso(w, r, paso, intentos.ErrCuentaBloqueada, espera)
+			return
+		}
+		h.renderizarSegundoPaso(w, r, usuario, "Código incorrecto o ya usado")
+		return
+	case doblefactor.ErrYaActivo, doblefactor.ErrNoActivo, doblefactor.ErrSinAltaPendiente:
+		// El estado cambió mientras se mostraba el formulario (otra pestaña, un ADMIN):
+		// se vuelve a mostrar el paso con el estado actual.
+		http.Redirect(w, r, "/login/segundo-paso", http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Error al verificar código: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Intentos.RegistrarExito(usuario.Correo); err != nil {
+		http.Error(w, "Error al registrar acceso: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.DobleFactor.TerminarPaso(paso); err != nil {
+		http.Error(w, "Error al cerrar segundo paso: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.fijarCookiePaso(w, "", -1)
+
+	// Tras un alta se muestran los códigos de recuperación antes de continuar.
+	if codigosNuevos != nil {
+		if _, err := h.Sesiones.Crear(w, usuario); err != nil {
+			http.Error(w, "Error al crear sesión: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mostrarCodigosRecuperacion(w, h.Templates, codigosNuevos, destinoInicial(usuario))
+		return
+	}
+	h.iniciarSesion(w, r, usuario)
+}
+
+// QRSegundoPaso devuelve el código QR (SVG) del alta en curso del segundo paso.
+// Ruta: GET /login/segundo-paso/qr
+func (h *AuthHandler) QRSegundoPaso(w http.ResponseWriter, r *http.Request) {
+	usuario, _, err := h.usuarioDelPaso(r)
+	if err != nil {
+		http.Error(w, "Inicio de sesión vencido", http.StatusNotFound)
+		return
+	}
+	secreto, err := h.DobleFactor.SecretoPendiente(usuario.IDUsuario)
+	if err != nil {
+		http.Error(w, "No hay un alta del segundo factor en curso", http.StatusNotFound)
+		return
+	}
+	responderQR(w, h.DobleFactor.URIProvision(usuario.Correo, secreto))
+}
+
+// verificarClave valida correo y clave contra la tabla usuarios o, sin MySQL,
+// contra la cuenta local.
+func (h *AuthHandler) verificarClave(correo, clave string) (models.Usuario, error) {
+	if h.DB != nil {
+		return VerificarCredenciales(h.DB, correo, clave)
+	}
+	if usuario, ok := h.Local.Verificar(correo, clave); ok {
+		return usuario, nil
+	}
+	return models.Usuario{}, ErrCredencialesInvalidas
+}
+
+// requiereSegundoPaso indica si el usuario debe ingresar un código tras la clave:
+// lo activó por su cuenta o su rol tiene el permiso doble_factor.obligatorio.
+// La cuenta local (sin MySQL) no tiene segundo factor.
+func (h *AuthHandler) requiereSegundoPaso(usuario models.Usuario) (bool, error) {
+	if h.DB == nil {
+		return false, nil
+	}
+	estado, err := h.DobleFactor.Estado(usuario.IDUsuario)
+	if err != nil {
+		return false, err
+	}
+	return estado.Activo || h.Permisos.Tiene(usuario.NombreRol, permisos.DobleFactorObligatorio), nil
+}
+
+// renderizarSegundoPaso muestra segundo_paso.html; en el alta obligatoria
+// reutiliza el secreto pendiente o genera uno.
+func (h *AuthHandler) renderizarSegundoPaso(w http.ResponseWriter, r *http.Request, usuario models.Usuario, mensaje string) {
+	estado, err := h.DobleFactor.Estado(usuario.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	form := formularioSegundoPaso{
+		Nombre: usuario.Nombre,
+		Alta:   !estado.Activo,
+		Error:  mensaje,
+		CSRF:   TokenCSRF(r),
+	}
+	if form.Alta {
+		secreto, err := h.DobleFactor.SecretoPendiente(usuario.IDUsuario)
+		if err == doblefactor.ErrSinAltaPendiente {
+			secreto, err = h.DobleFactor.IniciarAlta(usuario.IDUsuario)
+		}
+		if err != nil {
+			http.Error(w, "Error al preparar el segundo factor: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		form.Secreto = agruparSecreto(secreto)
+		form.URI = template.URL(h.DobleFactor.URIProvision(usuario.Correo, secreto))
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "segundo_paso.html", form)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla segundo_paso.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// usuarioDelPaso resuelve la cookie del paso pendiente y devuelve el usuario
+// (que debe seguir ACTIVO) junto con el código del paso.
+func (h *AuthHandler) usuarioDelPaso(r *http.Request) (models.Usuario, string, error) {
+	cookie, err := r.Cookie(nombreCookiePaso)
+	if err != nil {
+		return models.Usuario{}, "", doblefactor.ErrPasoInvalido
+	}
+	idUsuario, err := h.DobleFactor.UsuarioDelPaso(cookie.Value)
+	if err != nil {
+		return models.Usuario{}, "", err
+	}
+
+	var usuario models.Usuario
+	err = h.DB.QueryRow(`
+		SELECT u.id_usuario, u.nombre, u.correo, u.id_rol, r.nombre_rol, u.estado
+		FROM usuarios u
+		INNER JOIN roles r ON u.id_rol = r.id_rol
+		WHERE u.id_usuario = ? AND u.estado = 'ACTIVO'
+	`, idUsuario).Scan(&usuario.IDUsuario, &usuario.Nombre, &usuario.Correo, &usuario.IDRol, &usuario.NombreRol, &usuario.Estado)
+	if err == sql.ErrNoRows {
+		return models.Usuario{}, "", doblefactor.ErrPasoInvalido
+	}
+	return usuario, cookie.Value, err
+}
+
+// cancelarPaso borra la cookie del paso y vuelve al login con el motivo.
+func (h *AuthHandler) cancelarPaso(w http.ResponseWriter, r *http.Request, err error) {
+	if err != doblefactor.ErrPasoInvalido {
+		http.Error(w, "Error al consultar inicio de sesión: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.fijarCookiePaso(w, "", -1)
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+}
+
+// bloquearPaso anula el paso pendiente cuando la cuenta o la IP quedan bloqueadas.
+func (h *AuthHandler) bloquearPaso(w http.ResponseWriter, r *http.Request, paso string, err error, espera time.Duration) {
+	if errPaso := h.DobleFactor.TerminarPaso(paso); errPaso != nil {
+		http.Error(w, "Error al cerrar segundo paso: "+errPaso.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.fijarCookiePaso(w, "", -1)
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(MensajeBloqueo(err, espera)), http.StatusSeeOther)
+}
+
+// fijarCookiePaso guarda (o borra, con maxAge negativo) la cookie del paso pendiente.
+// Solo viaja a las rutas /login.
+func (h *AuthHandler) fijarCookiePaso(w http.ResponseWriter, valor string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     nombreCookiePaso,
+		Value:    valor,
+		Path:     "/login",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.Sesiones.CookieSegura,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// mostrarCodigosRecuperacion renderiza los códigos de recuperación recién
+// generados (no se pueden volver a ver) con un enlace para continuar.
+func mostrarCodigosRecuperacion(w http.ResponseWriter, templates *template.Template, codigos []string, continuar string) {
+	data := struct {
+		Codigos   []string // Códigos de recuperación en claro.
+		Continuar string   // Pantalla siguiente.
+	}{
+		Codigos:   codigos,
+		Continuar: continuar,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err := templates.ExecuteTemplate(w, "codigos_recuperacion.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla codigos_recuperacion.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// responderQR escribe el código QR de la URI como imagen SVG.
+func responderQR(w http.ResponseWriter, uri string) {
+	svg, err := qr.SVG(uri, 4)
+	if err != nil {
+		http.Error(w, "Error al generar código QR: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(svg))
+}
+
+// agruparSecreto separa el secreto en grupos de 4 letras para copiarlo a mano.
+func agruparSecreto(secreto string) string {
+	var grupos []string
+	for len(secreto) > 4 {
+		grupos = append(grupos, secreto[:4])
+		secreto = secreto[4:]
+	}
+	return strings.Join(append(grupos, secreto), " ")
+}
+
+// =========================================================
+// VERIFICACIÓN DE CREDENCIALES
+// =========================================================
+
+// ErrCredencialesInvalidas se usa cuando el correo no existe, el usuario no está
+// ACTIVO o la clave no coincide. No se distingue el motivo para no filtrar información.
+var ErrCredencialesInvalidas = errors.New("credenciales inválidas")
+
+// VerificarCredenciales valida correo + clave de un usuario ACTIVO.
+// La usan el login por formulario y el intercambio de credenciales por tokens.
+// Si la clave guardada es texto plano heredado, se reemplaza por su hash.
+func VerificarCredenciales(db *sql.DB, correo, clave string) (models.Usuario, error) {
+	// Variable para cargar los datos del usuario si el correo existe.
+	var usuario models.Usuario
+
+	// Consulta SQL para buscar el usuario ACTIVO por correo.
+	// La clave no se compara en SQL: se verifica el hash en Go.
+	query := `
+		SELECT 
+			u.id_usuario,
+			u.nombre,
+			u.correo,
+			u.clave,
+			u.id_rol,
+			r.nombre_rol,
+			u.estado
+		FROM usuarios u
+		INNER JOIN roles r ON u.id_rol = r.id_rol
+		WHERE u.correo = ? AND u.estado = 'ACTIVO'
+		LIMIT 1
+	`
+
+	// Ejecuta la consulta y llena la estructura usuario.
+	err := db.QueryRow(query, correo).Scan(
+		&usuario.IDUsuario,
+		&usuario.Nombre,
+		&usuario.Correo,
+		&usuario.Clave,
+		&usuario.IDRol,
+		&usuario.NombreRol,
+		&usuario.Estado,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Se simula la verificación para no revelar qué correos existen.
+			seguridad.SimularVerificacion(clave)
+			return models.Usuario{}, ErrCredencialesInvalidas
+		}
+		return models.Usuario{}, err
+	}
+
+	// Verifica la clave contra el hash guardado (o texto plano heredado).
+	ok, rehashear := seguridad.VerificarClave(usuario.Clave, clave)
+	if !ok {
+		return models.Usuario{}, ErrCredencialesInvalidas
+	}
+
+	// Migración en el primer login: se reemplaza la clave plana por su hash.
+	// Una clave heredada de más de 72 bytes no se puede hashear con bcrypt: el
+	// usuario entra igual y la clave queda como estaba hasta que la cambie.
+	if rehashear {
+		err := actualizarHashClave(db, usuario.IDUsuario, clave)
+		if err == seguridad.ErrClaveLarga {
+			log.Printf("⚠️ La clave del usuario %d supera los 72 bytes y sigue sin hash: debe cambiarla", usuario.IDUsuario)
+		} else if err != nil {
+			return models.Usuario{}, err
+		}
+	}
+
+	return usuario, nil
+}
+
+// credencialesMySQL devuelve VerificarCredenciales sobre la conexión, para VerificarConLimite.
+func credencialesMySQL(db *sql.DB) func(correo, clave string) (models.Usuario, error) {
+	return func(correo, clave string) (models.Usuario, error) {
+		return VerificarCredenciales(db, correo, clave)
+	}
+}
+
+// VerificarConLimite aplica el límite de intentos alrededor de verificar
+// (VerificarCredenciales o la cuenta local).
+// Antes de comparar la clave devuelve intentos.ErrCuentaBloqueada o
+// intentos.ErrDemasiadosIntentos con la espera restante; luego registra el fallo
+// (que puede bloquear la cuenta). Los fallos no se borran aquí: el llamador llama
+// a RegistrarExito recién cuando también pasó el segundo factor, para que repetir
+// la clave correcta no reinicie el contador de códigos incorrectos.
+func VerificarConLimite(verificar func(correo, clave string) (models.Usuario, error), servicio *intentos.Servicio, correo, clave, ip string) (models.Usuario, time.Duration, error) {
+	if espera, err := servicio.Permitir(correo, ip); err != nil {
+		return models.Usuario{}, espera, err
+	}
+
+	usuario, err := verificar(correo, clave)
+	if err == ErrCredencialesInvalidas {
+		espera, errFallo := servicio.RegistrarFallo(correo, ip)
+		if errFallo != nil {
+			return models.Usuario{}, 0, errFallo
+		}
+		if espera > 0 {
+			return models.Usuario{}, espera, intentos.ErrCuentaBloqueada
+		}
+		return models.Usuario{}, 0, err
+	}
+	if err != nil {
+		return models.Usuario{}, 0, err
+	}
+	return usuario, 0, nil
+}
+
+// MensajeBloqueo arma el aviso para el usuario según el motivo del bloqueo.
+func MensajeBloqueo(err error, espera time.Duration) string {
+	minutos := int(math.Ceil(espera.Minutes()))
+	plazo := strconv.Itoa(minutos) + " minutos"
+	if minutos <= 1 {
+		plazo = "1 minuto"
+	}
+	if err == intentos.ErrDemasiadosIntentos {
+		return "Demasiados intentos fallidos desde su red: intente de nuevo en " + plazo
+	}
+	return "Cuenta bloqueada por intentos fallidos: intente de nuevo en " + plazo
+}
+
+// actualizarHashClave guarda el hash bcrypt de la clave del usuario.
+func actualizarHashClave(db *sql.DB, idUsuario int, clave string) error {
+	hash, err := seguridad.HashearClave(clave)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE usuarios SET clave = ? WHERE id_usuario = ?`, hash, idUsuario)
+	return err
+}
+
+// =========================================================
+// FUNCIONES AUXILIARES DE SESIÓN Y ROLES
+// =========================================================
+
+// claveSesion es la clave privada para guardar la sesión en el contexto de la petición.
+type claveSesion struct{}
+
+// ConSesion devuelve una copia de la petición con la sesión resuelta por el middleware.
+func ConSesion(r *http.Request, sesion models.Sesion) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claveSesion{}, sesion))
+}
+
+// SesionActual obtiene la sesión guardada en la petición por el middleware.
+func SesionActual(r *http.Request) (models.Sesion, bool) {
+	sesion, ok := r.Context().Value(claveSesion{}).(models.Sesion)
+	return sesion, ok
+}
+
+// EstaLogueado verifica si la petición trae una sesión resuelta desde el almacén.
+func EstaLogueado(r *http.Request) bool {
+	_, ok := SesionActual(r)
+	return ok
+}
+
+// ObtenerNombreUsuario obtiene el nombre del usuario desde la sesión.
+// Si no hay sesión, devuelve cadena vacía.
+func ObtenerNombreUsuario(r *http.Request) string {
+	sesion, ok := SesionActual(r)
+	if !ok {
+		return ""
+	}
+	return sesion.Nombre
+}
+
+// ObtenerRolUsuario obtiene el rol del usuario desde la sesión.
+// Si no hay sesión, devuelve cadena vacía.
+func ObtenerRolUsuario(r *http.Request) string {
+	sesion, ok := SesionActual(r)
+	if !ok {
+		return ""
+	}
+	return sesion.Rol
+}
@@ -1,251 +1,521 @@
-package handlers // Paquete handlers: contiene controladores del sistema.
-
-import (
-	"database/sql"   // Paquete para trabajar con SQL.
-	"html/template"  // Paquete para renderizar plantillas HTML.
-	"net/http"       // Paquete para rutas, respuestas y descarga de archivos.
-	"os"             // Paquete para validar existencia de archivos.
-	"path/filepath"  // Paquete para manejar rutas de archivos.
-	"sistema/models" // Estructuras del sistema (Libro).
-	"strconv"        // Paquete para convertir string a int.
-	"strings"        // Paquete para limpiar texto.
-)
-
-// CatalogoHandler maneja las vistas del catálogo para usuario lector.
-type CatalogoHandler struct {
-	DB        *sql.DB            // Conexión a la base de datos.
-	Templates *template.Template // Plantillas HTML cargadas.
-}
-
-// NuevoCatalogoHandler crea una nueva instancia del handler de catálogo.
-func NuevoCatalogoHandler(db *sql.DB, templates *template.Template) *CatalogoHandler {
-	return &CatalogoHandler{
-		DB:        db,
-		Templates: templates,
-	}
-}
-
-// VerCatalogo muestra el catálogo de libros para el usuario lector.
-// Ruta: GET /catalogo
-func (h *CatalogoHandler) VerCatalogo(w http.ResponseWriter, r *http.Request) {
-	// Solo permitir GET.
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Obtiene texto de búsqueda opcional.
-	busqueda := strings.TrimSpace(r.URL.Query().Get("buscar"))
-
-	// Obtiene datos del usuario desde cookies.
-	nombreUsuario := ObtenerNombreUsuario(r)
-	rolUsuario := ObtenerRolUsuario(r)
-
-	// Variables para la consulta.
-	var (
-		rows *sql.Rows
-		err  error
-	)
-
-	// Si hay búsqueda, filtra por título o categoría.
-	if busqueda != "" {
-		query := `
-			SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-			FROM libros
-			WHERE titulo LIKE ? OR categoria LIKE ?
-			ORDER BY titulo ASC
-		`
-		filtro := "%" + busqueda + "%"
-		rows, err = h.DB.Query(query, filtro, filtro)
-	} else {
-		// Si no hay búsqueda, lista todos los libros.
-		query := `
-			SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-			FROM libros
-			ORDER BY titulo ASC
-		`
-		rows, err = h.DB.Query(query)
-	}
-
-	// Manejo de error en consulta.
-	if err != nil {
-		http.Error(w, "Error al consultar catálogo: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	// Slice de libros del catálogo.
-	var libros []models.Libro
-
-	// Recorre resultados de la consulta.
-	for rows.Next() {
-		var libro models.Libro
-
-		err := rows.Scan(
-			&libro.ID,
-			&libro.Titulo,
-			&libro.Autor,
-			&libro.Categoria,
-			&libro.AnioPublicacion,
-			&libro.Formato,
-			&libro.StockLicencias,
-		)
-		if err != nil {
-			http.Error(w, "Error al leer datos del catálogo: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		libros = append(libros, libro)
-	}
-
-	// Data para la plantilla catalogo.html.
-	data := struct {
-		Libros        []models.Libro // Lista de libros para mostrar.
-		Buscar        string         // Texto del buscador.
-		UsuarioNombre string         // Nombre del usuario logueado.
-		UsuarioRol    string         // Rol del usuario logueado.
-	}{
-		Libros:        libros,
-		Buscar:        busqueda,
-		UsuarioNombre: nombreUsuario,
-		UsuarioRol:    rolUsuario,
-	}
-
-	// Renderiza la plantilla catalogo.html.
-	err = h.Templates.ExecuteTemplate(w, "catalogo.html", data)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla catalogo.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// VerDetalleLibro muestra el detalle de un libro seleccionado del catálogo.
-// Ruta: GET /catalogo/detalle?id=...
-func (h *CatalogoHandler) VerDetalleLibro(w http.ResponseWriter, r *http.Request) {
-	// Solo permitir GET.
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Obtiene ID desde URL.
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
-		return
-	}
-
-	// Variable para cargar libro.
-	var libro models.Libro
-
-	// Consulta libro por ID.
-	query := `
-		SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-		FROM libros
-		WHERE id = ?
-	`
-
-	err = h.DB.QueryRow(query, id).Scan(
-		&libro.ID,
-		&libro.Titulo,
-		&libro.Autor,
-		&libro.Categoria,
-		&libro.AnioPublicacion,
-		&libro.Formato,
-		&libro.StockLicencias,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Libro no encontrado", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error al consultar detalle del libro: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Data para detalle_libro.html.
-	data := struct {
-		Libro         models.Libro // Libro seleccionado.
-		UsuarioNombre string       // Usuario actual.
-		UsuarioRol    string       // Rol actual.
-	}{
-		Libro:         libro,
-		UsuarioNombre: ObtenerNombreUsuario(r),
-		UsuarioRol:    ObtenerRolUsuario(r),
-	}
-
-	// Renderiza detalle_libro.html.
-	err = h.Templates.ExecuteTemplate(w, "detalle_libro.html", data)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla detalle_libro.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// DescargarLibroDemo descarga un PDF de demostración para simular la descarga del libro.
-// Ruta: GET /catalogo/descargar?id=...
-func (h *CatalogoHandler) DescargarLibroDemo(w http.ResponseWriter, r *http.Request) {
-	// Solo permitir GET para la descarga.
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Obtiene ID del libro (solo para validar que el libro existe y para personalizar nombre).
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
-		return
-	}
-
-	// Consulta el libro para validar existencia y obtener título.
-	var libro models.Libro
-	query := `
-		SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-		FROM libros
-		WHERE id = ?
-	`
-	err = h.DB.QueryRow(query, id).Scan(
-		&libro.ID,
-		&libro.Titulo,
-		&libro.Autor,
-		&libro.Categoria,
-		&libro.AnioPublicacion,
-		&libro.Formato,
-		&libro.StockLicencias,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Libro no encontrado", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error al validar libro para descarga: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Ruta del archivo PDF de demostración (misma para todos los libros en esta fase).
-	archivoDemo := filepath.Join("static", "demo", "demo.pdf")
-
-	// Verifica que el archivo exista.
-	if _, err := os.Stat(archivoDemo); err != nil {
-		if os.IsNotExist(err) {
-			http.Error(w, "Archivo PDF de demostración no encontrado. Coloque demo.pdf en static/demo/", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error al verificar archivo de descarga: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Genera un nombre sugerido para la descarga (solo demostrativo).
-	// Se reemplazan espacios por guiones bajos.
-	nombreDescarga := strings.ReplaceAll(libro.Titulo, " ", "_") + "_demo.pdf"
-
-	// Configura encabezados para forzar descarga en navegador.
-	w.Header().Set("Content-Type", "application/pdf")
-	w.Header().Set("Content-Disposition", `attachment; filename="`+nombreDescarga+`"`)
-
-	// Envía el archivo al cliente.
-	http.ServeFile(w, r, archivoDemo)
-}
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"database/sql"           // Paquete para trabajar con SQL.
+	"html/template"          // Paquete para renderizar plantillas HTML.
+	"io"                     // Paquete para copiar el archivo a la respuesta.
+	"log"                    // Paquete para informar fallas del historial.
+	"mime"                   // Paquete para armar Content-Disposition.
+	"net/http"               // Paquete para rutas, respuestas y descarga de archivos.
+	"net/url"                // Paquete para armar los enlaces de los filtros.
+	"sistema/almacenamiento" // Almacenamiento de archivos de libros.
+	"sistema/historial"      // Registro de vistas y descargas.
+	"sistema/libros"         // Repositorio de libros.
+	"sistema/models"         // Estructuras del sistema (Libro).
+	"sistema/permisos"       // Permisos de cada rol.
+	"sistema/prestamos"      // Préstamos de licencias.
+	"strconv"                // Paquete para convertir string a int.
+	"strings"                // Paquete para limpiar texto.
+)
+
+// CatalogoHandler maneja las vistas del catálogo para usuario lector.
+type CatalogoHandler struct {
+	DB        *sql.DB                       // Conexión para el historial (nil: no se registra).
+	Libros    libros.LibroRepository        // Libros del catálogo.
+	Templates *template.Template            // Plantillas HTML cargadas.
+	Archivos  almacenamiento.Almacenamiento // Archivos de los libros.
+	Prestamos *prestamos.Servicio           // Préstamos de licencias.
+	Permisos  *permisos.Servicio            // Permisos de cada rol.
+	PorPagina int                           // Libros por página si la URL no indica por_pagina.
+}
+
+// NuevoCatalogoHandler crea una nueva instancia del handler de catálogo.
+func NuevoCatalogoHandler(db *sql.DB, repositorio libros.LibroRepository, templates *template.Template, archivos almacenamiento.Almacenamiento, servicioPrestamos *prestamos.Servicio, servicioPermisos *permisos.Servicio) *CatalogoHandler {
+	return &CatalogoHandler{
+		DB:        db,
+		Libros:    repositorio,
+		Templates: templates,
+		Archivos:  archivos,
+		Prestamos: servicioPrestamos,
+		Permisos:  servicioPermisos,
+		PorPagina: porPaginaDefecto,
+	}
+}
+
+// ordenesCatalogo son los órdenes que puede elegir el lector, en orden de pantalla.
+var ordenesCatalogo = []opcionOrden{
+	{libros.OrdenRelevancia, "Relevancia"},
+	{libros.OrdenTitulo, "Título"},
+	{libros.OrdenAutor, "Autor"},
+	{libros.OrdenAnio, "Año de publicación"},
+	{libros.OrdenRecientes, "Agregados recientemente"},
+}
+
+// opcionOrden es un orden del catálogo con su nombre en pantalla.
+type opcionOrden struct {
+	Valor    string
+	Etiqueta string
+}
+
+// opcionFaceta es un valor de un filtro del catálogo con la cantidad de libros
+// y el enlace que lo aplica (o lo quita, si ya está aplicado).
+type opcionFaceta struct {
+	Etiqueta string // Valor a mostrar.
+	Cantidad int    // Libros con ese valor (con los demás filtros aplicados).
+	URL      string // Catálogo con el filtro aplicado o quitado.
+	Activa   bool   // El filtro está aplicado.
+}
+
+// VerCatalogo muestra el catálogo de libros para el usuario lector, con
+// búsqueda, filtros por categoría, formato, años y disponibilidad, orden y
+// la cantidad de libros de cada filtro, de a una página por vez. Todo viaja en
+// la URL para poder guardarla como marcador.
+// Ruta: GET /catalogo?buscar=&categoria=&formato=&anio_desde=&anio_hasta=&disponibles=1&orden=&por_pagina=&cursor=
+func (h *CatalogoHandler) VerCatalogo(w http.ResponseWriter, r *http.Request) {
+	// Solo permitir GET.
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Filtros y orden desde la URL.
+	q := r.URL.Query()
+	filtro, ordenElegido, ok := leerFiltroCatalogo(w, r)
+	if !ok {
+		return
+	}
+
+	// Página pedida.
+	porPagina, ok := leerPorPagina(q.Get("por_pagina"), h.PorPagina)
+	if !ok {
+		http.Error(w, "Cantidad de libros por página inválida", http.StatusBadRequest)
+		return
+	}
+	filtro.Limite = porPagina
+	filtro.Cursor = q.Get("cursor")
+
+	// Obtiene datos del usuario desde la sesión.
+	nombreUsuario := ObtenerNombreUsuario(r)
+	rolUsuario := ObtenerRolUsuario(r)
+
+	pagina, err := h.Libros.Pagina(filtro)
+	if err != nil {
+		if err == libros.ErrCursorInvalido {
+			http.Error(w, "Enlace de página inválido", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error al consultar catálogo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// El total sale de un COUNT aparte, sin leer los libros de las demás páginas.
+	total, err := h.Libros.Contar(filtro)
+	if err != nil {
+		http.Error(w, "Error al contar libros del catálogo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	facetas, err := h.Libros.Facetas(filtro)
+	if err != nil {
+		http.Error(w, "Error al contar libros del catálogo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Parámetros actuales, sin los vacíos: base de los enlaces de cada filtro y
+	// de cada página. Los enlaces de los filtros vuelven a la primera página.
+	actuales := parametrosCatalogo(filtro, ordenElegido)
+	filtrado := len(actuales) > 0
+	if q.Get("por_pagina") != "" {
+		actuales.Set("por_pagina", strconv.Itoa(porPagina))
+	}
+
+	var categorias, formatos, decadas []opcionFaceta
+	for _, f := range facetas.Categorias {
+		activa := strings.EqualFold(f.Valor, filtro.Categoria)
+		categorias = append(categorias, opcionFaceta{f.Valor, f.Cantidad, enlaceCatalogo(actuales, "categoria", alternar(activa, f.Valor)), activa})
+	}
+	for _, f := range facetas.Formatos {
+		activa := f.Valor == filtro.Formato
+		formatos = append(formatos, opcionFaceta{f.Valor, f.Cantidad, enlaceCatalogo(actuales, "formato", alternar(activa, f.Valor)), activa})
+	}
+	for _, f := range facetas.Decadas {
+		desde, _ := strconv.Atoi(f.Valor)
+		hasta := strconv.Itoa(desde + 9)
+		activa := filtro.AnioDesde == desde && filtro.AnioHasta == desde+9
+		decadas = append(decadas, opcionFaceta{f.Valor + "–" + hasta, f.Cantidad,
+			enlaceCatalogo(actuales, "anio_desde", alternar(activa, f.Valor), "anio_hasta", alternar(activa, hasta)), activa})
+	}
+	disponibles := opcionFaceta{"Solo disponibles", facetas.Disponibles, enlaceCatalogo(actuales, "disponibles", alternar(filtro.Disponibles, "1")), filtro.Disponibles}
+
+	// Data para la plantilla catalogo.html.
+	data := struct {
+		Libros        []models.Libro      // Libros de la página actual.
+		Total         int                 // Cantidad de libros encontrados (en todas las páginas).
+		Paginacion    paginacion          // Enlaces a la primera página, la anterior y la siguiente.
+		PorPagina     string              // Libros por página elegidos en la URL ("" si es el valor por defecto).
+		Buscar        string              // Texto del buscador.
+		Categoria     string              // Filtro de categoría.
+		Formato       string              // Filtro de formato.
+		AnioDesde     string              // Filtro de año inicial.
+		AnioHasta     string              // Filtro de año final.
+		Disponibles   bool                // Filtro de disponibilidad.
+		Orden         string              // Orden aplicado.
+		Ordenes       []opcionOrden       // Opciones del selector de orden.
+		Categorias    []opcionFaceta      // Categorías con su cantidad de libros.
+		Formatos      []opcionFaceta      // Formatos con su cantidad de libros.
+		Decadas       []opcionFaceta      // Décadas de publicación con su cantidad de libros.
+		SoloStock     opcionFaceta        // Filtro de disponibilidad con su cantidad de libros.
+		Filtrado      bool                // Hay algún filtro o búsqueda aplicado.
+		Exportar      []enlaceExportacion // Descarga de los libros filtrados en cada formato (con permiso libros.exportar).
+		UsuarioNombre string              // Nombre del usuario logueado.
+		UsuarioRol    string              // Rol del usuario logueado.
+	}{
+		Libros:        pagina.Libros,
+		Total:         total,
+		Paginacion:    nuevaPaginacion("/catalogo", actuales, pagina),
+		PorPagina:     actuales.Get("por_pagina"),
+		Buscar:        filtro.Buscar,
+		Categoria:     filtro.Categoria,
+		Formato:       filtro.Formato,
+		AnioDesde:     actuales.Get("anio_desde"),
+		AnioHasta:     actuales.Get("anio_hasta"),
+		Disponibles:   filtro.Disponibles,
+		Orden:         filtro.Orden,
+		Ordenes:       ordenesCatalogo,
+		Categorias:    categorias,
+		Formatos:      formatos,
+		Decadas:       decadas,
+		SoloStock:     disponibles,
+		Filtrado:      filtrado,
+		Exportar:      enlacesExportacion(parametrosCatalogo(filtro, ordenElegido)),
+		UsuarioNombre: nombreUsuario,
+		UsuarioRol:    rolUsuario,
+	}
+
+	// Renderiza la plantilla catalogo.html.
+	err = h.Templates.ExecuteTemplate(w, "catalogo.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla catalogo.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// leerFiltroCatalogo arma el filtro y el orden elegido ("" si no se eligió)
+// desde la query del catálogo; si hay errores responde 400 y devuelve false.
+// Sin orden elegido, ordena por relevancia al buscar y por título si no.
+func leerFiltroCatalogo(w http.ResponseWriter, r *http.Request) (libros.Filtro, string, bool) {
+	q := r.URL.Query()
+	filtro := libros.Filtro{
+		Buscar:      strings.TrimSpace(q.Get("buscar")),
+		Categoria:   strings.TrimSpace(q.Get("categoria")),
+		Formato:     strings.ToUpper(strings.TrimSpace(q.Get("formato"))),
+		Disponibles: q.Get("disponibles") == "1",
+	}
+	for _, p := range []struct {
+		nombre string
+		anio   *int
+	}{
+		{"anio_desde", &filtro.AnioDesde},
+		{"anio_hasta", &filtro.AnioHasta},
+	} {
+		if v := strings.TrimSpace(q.Get(p.nombre)); v != "" {
+			anio, err := strconv.Atoi(v)
+			if err != nil || anio < 0 {
+				http.Error(w, "Año de publicación inválido", http.StatusBadRequest)
+				return filtro, "", false
+			}
+			*p.anio = anio
+		}
+	}
+
+	// Orden elegido; si no hay (o no es válido), por relevancia al buscar y por título si no.
+	filtro.Orden = libros.OrdenTitulo
+	if filtro.Buscar != "" {
+		filtro.Orden = libros.OrdenRelevancia
+	}
+	var ordenElegido string
+	for _, o := range ordenesCatalogo {
+		if o.Valor == q.Get("orden") {
+			ordenElegido = o.Valor
+			filtro.Orden = o.Valor
+		}
+	}
+	return filtro, ordenElegido, true
+}
+
+// parametrosCatalogo arma los parámetros de la URL del catálogo para el filtro,
+// sin los vacíos (así los enlaces quedan cortos y se pueden guardar como marcador).
+func parametrosCatalogo(f libros.Filtro, orden string) url.Values {
+	q := url.Values{}
+	for _, p := range []struct{ nombre, valor string }{
+		{"buscar", f.Buscar},
+		{"categoria", f.Categoria},
+		{"formato", f.Formato},
+		{"orden", orden},
+	} {
+		if p.valor != "" {
+			q.Set(p.nombre, p.valor)
+		}
+	}
+	if f.AnioDesde != 0 {
+		q.Set("anio_desde", strconv.Itoa(f.AnioDesde))
+	}
+	if f.AnioHasta != 0 {
+		q.Set("anio_hasta", strconv.Itoa(f.AnioHasta))
+	}
+	if f.Disponibles {
+		q.Set("disponibles", "1")
+	}
+	return q
+}
+
+// enlaceCatalogo devuelve la URL del catálogo con los parámetros actuales y los
+// cambios indicados en pares nombre, valor (un valor vacío quita el parámetro).
+func enlaceCatalogo(actuales url.Values, cambios ...string) string {
+	return enlace("/catalogo", actuales, cambios...)
+}
+
+// alternar devuelve "" (quitar el filtro) si ya está activo, o el valor para aplicarlo.
+func alternar(activo bool, valor string) string {
+	if activo {
+		return ""
+	}
+	return valor
+}
+
+// VerDetalleLibro muestra el detalle de un libro seleccionado del catálogo.
+// Ruta: GET /catalogo/detalle?id=...
+func (h *CatalogoHandler) VerDetalleLibro(w http.ResponseWriter, r *http.Request) {
+	// Solo permitir GET.
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Obtiene ID desde URL.
+	idStr := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Consulta libro por ID.
+	libro, err := h.Libros.Obtener(id)
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			http.Error(w, "Libro no encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error al consultar detalle del libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	registrarHistorial(h.DB, sesion.IDUsuario, libro.ID, models.AccionVer)
+
+	// Préstamo vigente del usuario para este libro (si tiene uno) y su lugar en
+	// la lista de espera (si está en ella). Sin préstamos quedan en nil.
+	var (
+		prestamo *models.Prestamo
+		reserva  *models.Reserva
+	)
+	if h.Prestamos != nil {
+		activo, err := h.Prestamos.Activo(sesion.IDUsuario, libro.ID)
+		switch {
+		case err == nil:
+			prestamo = &activo
+		case err != prestamos.ErrPrestamoNoExiste:
+			http.Error(w, "Error al consultar préstamo: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		enCola, err := h.Prestamos.ReservaActiva(sesion.IDUsuario, libro.ID)
+		switch {
+		case err == nil:
+			reserva = &enCola
+		case err != prestamos.ErrReservaNoExiste:
+			http.Error(w, "Error al consultar reserva: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Data para detalle_libro.html.
+	data := struct {
+		Libro           models.Libro     // Libro seleccionado.
+		UsuarioNombre   string           // Usuario actual.
+		UsuarioRol      string           // Rol actual.
+		Prestamo        *models.Prestamo // Préstamo vigente del usuario (nil si no tiene).
+		Reserva         *models.Reserva  // Reserva en la lista de espera (nil si no tiene).
+		DescargaDirecta bool             // ADMIN y OPERADOR descargan sin préstamo.
+		Prestamos       bool             // Hay préstamos (no con LIBROS_STORE=json).
+		Mensaje         string           // Resultado de prestar/devolver.
+		CSRF            string           // Token CSRF de los formularios.
+	}{
+		Libro:           libro,
+		UsuarioNombre:   ObtenerNombreUsuario(r),
+		UsuarioRol:      ObtenerRolUsuario(r),
+		Prestamo:        prestamo,
+		Reserva:         reserva,
+		DescargaDirecta: h.descargaSinPrestamo(r),
+		Prestamos:       h.Prestamos != nil,
+		Mensaje:         strings.TrimSpace(r.URL.Query().Get("msg")),
+		CSRF:            TokenCSRF(r),
+	}
+
+	// Renderiza detalle_libro.html.
+	err = h.Templates.ExecuteTemplate(w, "detalle_libro.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla detalle_libro.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// DescargarLibro envía el archivo propio del libro solicitado.
+// Ruta: GET /catalogo/descargar?id=...
+func (h *CatalogoHandler) DescargarLibro(w http.ResponseWriter, r *http.Request) {
+	// Solo permitir GET (y HEAD, que no se anota) para la descarga.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Obtiene ID del libro.
+	idStr := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Consulta el libro para obtener título, formato y clave del archivo.
+	libro, err := h.Libros.Obtener(id)
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			http.Error(w, "Libro no encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error al validar libro para descarga: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if libro.Archivo == "" {
+		http.Error(w, "El libro aún no tiene un archivo disponible", http.StatusNotFound)
+		return
+	}
+
+	// Los lectores solo descargan mientras tienen el libro prestado; sin
+	// préstamos (LIBROS_STORE=json) solo descarga el personal.
+	sesion, _ := SesionActual(r)
+	if !h.descargaSinPrestamo(r) {
+		if h.Prestamos == nil {
+			http.Error(w, "Los préstamos no están disponibles: solo el personal descarga archivos", http.StatusForbidden)
+			return
+		}
+		if _, err := h.Prestamos.Activo(sesion.IDUsuario, libro.ID); err != nil {
+			if err == prestamos.ErrPrestamoNoExiste {
+				http.Error(w, "Debe tener el libro prestado para descargarlo", http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Error al validar préstamo: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Abre el archivo desde el almacenamiento configurado.
+	objeto, err := h.Archivos.Abrir(libro.Archivo)
+	if err != nil {
+		if err == almacenamiento.ErrNoExiste {
+			http.Error(w, "Archivo del libro no encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error al abrir archivo del libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer objeto.Close()
+
+	// La descarga se anota cuando ya se sabe qué se respondió (ver seDescargo).
+	respuesta := &respuestaDescarga{ResponseWriter: w}
+	defer func() {
+		if seDescargo(r, respuesta.estado) {
+			registrarHistorial(h.DB, sesion.IDUsuario, libro.ID, models.AccionDescargar)
+		}
+	}()
+	w = respuesta
+
+	// Nombre sugerido: título + extensión del formato (los acentos se codifican según RFC 2231).
+	nombreDescarga := strings.ReplaceAll(libro.Titulo, " ", "_") + almacenamiento.Extension(libro.Formato)
+
+	// Configura encabezados para forzar descarga con el tipo correcto.
+	w.Header().Set("Content-Type", almacenamiento.TipoContenido(libro.Formato))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": nombreDescarga}))
+
+	// Si el archivo admite Seek (almacenamiento local) se usa ServeContent,
+	// que maneja rangos y caché; si no, se copia el flujo completo.
+	if lector, ok := objeto.ReadCloser.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, nombreDescarga, objeto.ModificadoEn, lector)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(objeto.Tamano, 10))
+	_, _ = io.Copy(w, objeto)
+}
+
+// descargaSinPrestamo indica si el rol descarga archivos sin préstamo
+// (permiso libros.descargar: el personal que administra los archivos no consume licencias).
+func (h *CatalogoHandler) descargaSinPrestamo(r *http.Request) bool {
+	return h.Permisos.Tiene(ObtenerRolUsuario(r), permisos.LibrosDescargar)
+}
+
+// respuestaDescarga guarda el código de estado que se envió con el archivo.
+type respuestaDescarga struct {
+	http.ResponseWriter
+	estado int // 0 mientras no se escribió nada.
+}
+
+// WriteHeader guarda el código y lo envía.
+func (r *respuestaDescarga) WriteHeader(estado int) {
+	if r.estado == 0 {
+		r.estado = estado
+	}
+	r.ResponseWriter.WriteHeader(estado)
+}
+
+// Write envía parte del cuerpo (sin WriteHeader previo, el código es 200).
+func (r *respuestaDescarga) Write(datos []byte) (int, error) {
+	if r.estado == 0 {
+		r.estado = http.StatusOK
+	}
+	return r.ResponseWriter.Write(datos)
+}
+
+// seDescargo indica si la respuesta envió el archivo desde el byte 0. Los
+// visores de PDF y los gestores de descarga piden el archivo en varios rangos:
+// se anota una sola descarga, la del cuerpo que empieza por el principio. No
+// cuentan HEAD, 304 (el navegador ya tenía el archivo por If-None-Match o
+// If-Modified-Since), 416 ni los rangos que empiezan más adelante. Un 200
+// cuenta aunque se haya pedido un rango: ServeContent envía el archivo
+// completo cuando If-Range ya no coincide.
+func seDescargo(r *http.Request, estado int) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+	switch estado {
+	case http.StatusOK:
+		return true
+	case http.StatusPartialContent:
+		rango := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(r.Header.Get("Range")), "bytes="))
+		return strings.HasPrefix(rango, "0-")
+	default:
+		return false
+	}
+}
+
+// registrarHistorial anota una vista o descarga (sin conexión no se anota). Si
+// falla, solo se informa en consola: no se le niega el libro al usuario por un
+// problema del historial.
+func registrarHistorial(db *sql.DB, idUsuario, idLibro int, accion string) {
+	if db == nil {
+		return
+	}
+	if err := historial.Registrar(db, idUsuario, idLibro, accion); err != nil {
+		log.Println("⚠️ Error al registrar historial: ", err)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"          // Paquete para códigos y métodos HTTP.
+	"net/http/httptest" // Paquete para simular pedidos.
+	"strings"           // Paquete para el contenido del archivo.
+	"testing"           // Paquete de pruebas.
+	"time"              // Paquete para la fecha del archivo.
+)
+
+func TestSeDescargoSoloConElCuerpoDesdeElPrincipio(t *testing.T) {
+	modificado := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	casos := []struct {
+		nombre     string
+		metodo     string
+		encabezado string
+		valor      string
+		estado     int
+		anota      bool
+	}{
+		{"completo", http.MethodGet, "", "", http.StatusOK, true},
+		{"rango inicial", http.MethodGet, "Range", "bytes=0-9", http.StatusPartialContent, true},
+		{"rango siguiente", http.MethodGet, "Range", "bytes=10-", http.StatusPartialContent, false},
+		{"en caché", http.MethodGet, "If-Modified-Since", modificado.Format(http.TimeFormat), http.StatusNotModified, false},
+		{"HEAD", http.MethodHead, "", "", http.StatusOK, false},
+	}
+	for _, c := range casos {
+		r := httptest.NewRequest(c.metodo, "/catalogo/descargar?id=1", nil)
+		if c.encabezado != "" {
+			r.Header.Set(c.encabezado, c.valor)
+		}
+		respuesta := &respuestaDescarga{ResponseWriter: httptest.NewRecorder()}
+		http.ServeContent(respuesta, r, "libro.pdf", modificado, strings.NewReader(strings.Repeat("x", 100)))
+
+		if respuesta.estado != c.estado {
+			t.Errorf("%s: código %d, se esperaba %d", c.nombre, respuesta.estado, c.estado)
+		}
+		if anota := seDescargo(r, respuesta.estado); anota != c.anota {
+			t.Errorf("%s: se anota %v, se esperaba %v", c.nombre, anota, c.anota)
+		}
+	}
+}
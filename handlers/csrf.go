@@ -0,0 +1,102 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"context"          // Paquete para guardar el token en la petición.
+	"html/template"    // Paquete para renderizar plantillas HTML.
+	"mime"             // Paquete para reconocer formularios multipart.
+	"net/http"         // Paquete para rutas y respuestas HTTP.
+	"sistema/sesiones" // Nombres del campo y encabezado CSRF.
+)
+
+// claveCSRF es la clave privada para guardar el token CSRF en el contexto de la petición.
+type claveCSRF struct{}
+
+// ConCSRF devuelve una copia de la petición con la función que obtiene su token CSRF.
+// El middleware la agrega a todas las peticiones; el token se calcula al pedirlo.
+func ConCSRF(r *http.Request, token func() string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), claveCSRF{}, token))
+}
+
+// TokenCSRF devuelve el token CSRF que los formularios de la página deben enviar
+// en el campo oculto csrf_token. Si no hay middleware, devuelve cadena vacía.
+func TokenCSRF(r *http.Request) string {
+	token, ok := r.Context().Value(claveCSRF{}).(func() string)
+	if !ok {
+		return ""
+	}
+	return token()
+}
+
+// tamanoMaximoFormulario limita los formularios que no suben archivos (1 MB).
+const tamanoMaximoFormulario = 1 << 20
+
+// rutasSubida son las rutas cuyos formularios llevan el archivo de un libro o un
+// CSV: las únicas en las que se lee un formulario de hasta tamanoMaximoLibro.
+var rutasSubida = map[string]bool{
+	"/libros/crear":             true,
+	"/libros/actualizar":        true,
+	"/libros/metadatos":         true,
+	"/libros/importar/procesar": true,
+}
+
+// LeerTokenCSRF obtiene el token enviado en el encabezado X-CSRF-Token o en el
+// campo csrf_token del formulario. El formulario se lee hasta
+// tamanoMaximoFormulario, salvo en las rutasSubida con sesión iniciada
+// (conSesion, que solo se consulta en ellas): ahí se lee con el mismo límite que
+// los libros, así los handlers reutilizan el formulario ya leído. Un cliente sin
+// sesión no puede obligar a leer (y pasar a disco) archivos grandes.
+func LeerTokenCSRF(w http.ResponseWriter, r *http.Request, conSesion func() bool) (string, error) {
+	if token := r.Header.Get(sesiones.EncabezadoCSRF); token != "" {
+		return token, nil
+	}
+
+	limite := int64(tamanoMaximoFormulario)
+	if rutasSubida[r.URL.Path] && conSesion() {
+		limite = tamanoMaximoLibro + (1 << 20)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limite)
+
+	tipo, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if tipo == "multipart/form-data" {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return "", err
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	return r.PostFormValue(sesiones.CampoCSRF), nil
+}
+
+// CSRFHandler muestra la página de solicitud rechazada.
+type CSRFHandler struct {
+	Templates *template.Template // Plantillas HTML cargadas.
+}
+
+// NuevoCSRFHandler crea una nueva instancia del handler de rechazo CSRF.
+func NuevoCSRFHandler(templates *template.Template) *CSRFHandler {
+	return &CSRFHandler{
+		Templates: templates,
+	}
+}
+
+// Rechazar responde 403 con la página error_csrf.html cuando el token falta o no coincide.
+func (h *CSRFHandler) Rechazar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+
+	// Con cookie de sesión se vuelve al panel; sin ella, al login.
+	data := struct {
+		Volver string // Página a la que se ofrece volver.
+	}{
+		Volver: "/login",
+	}
+	if _, err := r.Cookie(sesiones.NombreCookie); err == nil {
+		data.Volver = "/"
+	}
+
+	err := h.Templates.ExecuteTemplate(w, "error_csrf.html", data)
+	if err != nil {
+		http.Error(w, "Solicitud rechazada", http.StatusForbidden)
+		return
+	}
+}
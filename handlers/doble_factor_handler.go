@@ -0,0 +1,243 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"       // Paquete para renderizar plantillas HTML.
+	"net/http"            // Paquete para rutas y respuestas HTTP.
+	"net/url"             // Paquete para codificar mensajes en la URL.
+	"sistema/doblefactor" // Segundo factor TOTP y códigos de recuperación.
+	"sistema/intentos"    // Límite de intentos fallidos.
+	"sistema/models"      // Estructura DobleFactor.
+	"sistema/permisos"    // Política de segundo factor obligatorio por rol.
+	"sistema/usuarios"    // Datos del usuario de la sesión.
+	"strings"             // Paquete para limpiar texto.
+)
+
+// DobleFactorHandler agrupa la pantalla "Seguridad de la cuenta": alta, baja y
+// códigos de recuperación del segundo factor del usuario de la sesión.
+type DobleFactorHandler struct {
+	Templates   *template.Template    // Plantillas HTML cargadas.
+	DobleFactor *doblefactor.Servicio // Servicio de segundo factor.
+	Permisos    *permisos.Servicio    // Política de segundo factor por rol.
+	Usuarios    *usuarios.Servicio    // Correo del usuario (etiqueta del QR).
+	Intentos    *intentos.Servicio    // Los códigos incorrectos cuentan como fallos.
+}
+
+// NuevoDobleFactorHandler crea una nueva instancia del handler de segundo factor.
+func NuevoDobleFactorHandler(templates *template.Template, servicio *doblefactor.Servicio, servicioPermisos *permisos.Servicio, servicioUsuarios *usuarios.Servicio, servicioIntentos *intentos.Servicio) *DobleFactorHandler {
+	return &DobleFactorHandler{
+		Templates:   templates,
+		DobleFactor: servicio,
+		Permisos:    servicioPermisos,
+		Usuarios:    servicioUsuarios,
+		Intentos:    servicioIntentos,
+	}
+}
+
+// VerSeguridad muestra el estado del segundo factor y, si hay un alta en curso,
+// el código QR para confirmarla.
+// Ruta: GET /cuenta/seguridad
+func (h *DobleFactorHandler) VerSeguridad(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	estado, err := h.DobleFactor.Estado(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var secreto string
+	var uri template.URL
+	if !estado.Activo {
+		secreto, err = h.DobleFactor.SecretoPendiente(sesion.IDUsuario)
+		switch err {
+		case nil:
+			usuario, err := h.Usuarios.Obtener(sesion.IDUsuario)
+			if err != nil {
+				http.Error(w, "Error al consultar usuario: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			uri = template.URL(h.DobleFactor.URIProvision(usuario.Correo, secreto))
+			secreto = agruparSecreto(secreto)
+		case doblefactor.ErrSinAltaPendiente:
+		default:
+			http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	data := struct {
+		Estado        models.DobleFactor // Estado del segundo factor.
+		Obligatorio   bool               // El rol exige segundo factor (no se puede desactivar).
+		Secreto       string             // Secreto del alta en curso, en grupos de 4.
+		URI           template.URL       // URI otpauth:// del alta en curso (la arma el sistema).
+		Mensaje       string             // Resultado de la última acción.
+		Error         string             // Motivo si la última acción falló.
+		UsuarioNombre string             // Usuario actual.
+		UsuarioRol    string             // Rol actual.
+		CSRF          string             // Token CSRF de los formularios.
+	}{
+		Estado:        estado,
+		Obligatorio:   h.Permisos.Tiene(sesion.Rol, permisos.DobleFactorObligatorio),
+		Secreto:       secreto,
+		URI:           uri,
+		Mensaje:       strings.TrimSpace(r.URL.Query().Get("msg")),
+		Error:         strings.TrimSpace(r.URL.Query().Get("error")),
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+		CSRF:          TokenCSRF(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "cuenta_seguridad.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla cuenta_seguridad.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// IniciarAlta genera un secreto nuevo pendiente de confirmación.
+// Ruta: POST /cuenta/seguridad/activar
+func (h *DobleFactorHandler) IniciarAlta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	if _, err := h.DobleFactor.IniciarAlta(sesion.IDUsuario); err != nil {
+		h.responderError(w, r, "No se pudo iniciar el alta", err)
+		return
+	}
+	http.Redirect(w, r, "/cuenta/seguridad", http.StatusSeeOther)
+}
+
+// QRAlta devuelve el código QR (SVG) del alta en curso.
+// Ruta: GET /cuenta/seguridad/qr
+func (h *DobleFactorHandler) QRAlta(w http.ResponseWriter, r *http.Request) {
+	sesion, _ := SesionActual(r)
+	secreto, err := h.DobleFactor.SecretoPendiente(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "No hay un alta del segundo factor en curso", http.StatusNotFound)
+		return
+	}
+	usuario, err := h.Usuarios.Obtener(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar usuario: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	responderQR(w, h.DobleFactor.URIProvision(usuario.Correo, secreto))
+}
+
+// ConfirmarAlta activa el segundo factor con el primer código de la aplicación
+// y muestra los códigos de recuperación.
+// Ruta: POST /cuenta/seguridad/confirmar (codigo)
+func (h *DobleFactorHandler) ConfirmarAlta(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	if !h.permitir(w, r, sesion) {
+		return
+	}
+	codigos, err := h.DobleFactor.ConfirmarAlta(sesion.IDUsuario, r.FormValue("codigo"))
+	if err != nil {
+		h.responderError(w, r, "No se activó el segundo factor", err)
+		return
+	}
+	mostrarCodigosRecuperacion(w, h.Templates, codigos, "/cuenta/seguridad?msg="+url.QueryEscape("Segundo factor activado"))
+}
+
+// RegenerarCodigos anula los códigos de recuperación y muestra otros nuevos.
+// Ruta: POST /cuenta/seguridad/codigos (codigo)
+func (h *DobleFactorHandler) RegenerarCodigos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	if !h.permitir(w, r, sesion) {
+		return
+	}
+	codigos, err := h.DobleFactor.RegenerarCodigos(sesion.IDUsuario, r.FormValue("codigo"))
+	if err != nil {
+		h.responderError(w, r, "No se generaron códigos nuevos", err)
+		return
+	}
+	mostrarCodigosRecuperacion(w, h.Templates, codigos, "/cuenta/seguridad?msg="+url.QueryEscape("Códigos de recuperación renovados"))
+}
+
+// Desactivar quita el segundo factor, salvo que el rol lo exija.
+// Ruta: POST /cuenta/seguridad/desactivar (codigo)
+func (h *DobleFactorHandler) Desactivar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	if h.Permisos.Tiene(sesion.Rol, permisos.DobleFactorObligatorio) {
+		h.volver(w, r, "error", "Su rol exige el segundo factor: no se puede desactivar")
+		return
+	}
+	if !h.permitir(w, r, sesion) {
+		return
+	}
+	if err := h.DobleFactor.Desactivar(sesion.IDUsuario, r.FormValue("codigo")); err != nil {
+		h.responderError(w, r, "No se desactivó el segundo factor", err)
+		return
+	}
+	h.volver(w, r, "msg", "Segundo factor desactivado")
+}
+
+// permitir aplica el límite de intentos de la cuenta antes de verificar un código;
+// si está bloqueada, vuelve a la pantalla con la espera.
+func (h *DobleFactorHandler) permitir(w http.ResponseWriter, r *http.Request, sesion models.Sesion) bool {
+	usuario, err := h.Usuarios.Obtener(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar usuario: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	espera, err := h.Intentos.Permitir(usuario.Correo, ipCliente(r))
+	switch err {
+	case nil:
+		return true
+	case intentos.ErrCuentaBloqueada, intentos.ErrDemasiadosIntentos:
+		h.volver(w, r, "error", MensajeBloqueo(err, espera))
+	default:
+		http.Error(w, "Error al consultar intentos: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
+
+// responderError vuelve a la pantalla con el motivo si el error es de validación
+// (un código incorrecto suma un fallo a la cuenta); si no, responde 500.
+func (h *DobleFactorHandler) responderError(w http.ResponseWriter, r *http.Request, contexto string, err error) {
+	switch err {
+	case doblefactor.ErrCodigoInvalido:
+		sesion, _ := SesionActual(r)
+		usuario, errUsuario := h.Usuarios.Obtener(sesion.IDUsuario)
+		if errUsuario == nil {
+			_, errUsuario = h.Intentos.RegistrarFallo(usuario.Correo, ipCliente(r))
+		}
+		if errUsuario != nil {
+			http.Error(w, "Error al registrar intento: "+errUsuario.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.volver(w, r, "error", contexto+": "+err.Error())
+	case doblefactor.ErrYaActivo, doblefactor.ErrNoActivo, doblefactor.ErrSinAltaPendiente:
+		h.volver(w, r, "error", contexto+": "+err.Error())
+	default:
+		http.Error(w, contexto+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// volver redirige a la pantalla de seguridad con un mensaje (msg) o un error (error).
+func (h *DobleFactorHandler) volver(w http.ResponseWriter, r *http.Request, parametro, mensaje string) {
+	http.Redirect(w, r, "/cuenta/seguridad?"+parametro+"="+url.QueryEscape(mensaje), http.StatusSeeOther)
+}
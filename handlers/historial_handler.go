@@ -0,0 +1,182 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"database/sql"      // Paquete para trabajar con SQL.
+	"html/template"     // Paquete para renderizar plantillas HTML.
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"sistema/historial" // Consulta del historial.
+	"sistema/models"    // Estructura History y acciones.
+	"strings"           // Paquete para limpiar texto.
+	"time"              // Paquete para el rango de fechas.
+)
+
+// opcionAccion es una acción del historial con su nombre en pantalla.
+type opcionAccion struct {
+	Valor    string
+	Etiqueta string
+}
+
+// accionesHistorial son las acciones que se pueden filtrar, en orden de pantalla.
+var accionesHistorial = []opcionAccion{
+	{models.AccionVer, "Vista"},
+	{models.AccionDescargar, "Descarga"},
+	{models.AccionPrestamo, "Préstamo"},
+	{models.AccionDevolucion, "Devolución"},
+}
+
+// etiquetasHistorial traduce cada acción a su nombre en pantalla.
+var etiquetasHistorial = func() map[string]string {
+	etiquetas := map[string]string{}
+	for _, a := range accionesHistorial {
+		etiquetas[a.Valor] = a.Etiqueta
+	}
+	return etiquetas
+}()
+
+// HistorialHandler muestra el historial de lecturas, descargas y préstamos.
+type HistorialHandler struct {
+	DB        *sql.DB            // Conexión a la base de datos.
+	Templates *template.Template // Plantillas HTML cargadas.
+}
+
+// NuevoHistorialHandler crea una nueva instancia del handler de historial.
+func NuevoHistorialHandler(db *sql.DB, templates *template.Template) *HistorialHandler {
+	return &HistorialHandler{
+		DB:        db,
+		Templates: templates,
+	}
+}
+
+// MiHistorial muestra el historial del usuario que inició sesión.
+// Ruta: GET /historial?accion=
+func (h *HistorialHandler) MiHistorial(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	filtro := historial.Filtro{
+		IDUsuario: sesion.IDUsuario,
+		Accion:    accionValida(r.URL.Query().Get("accion")),
+	}
+
+	entradas, err := historial.Listar(h.DB, filtro)
+	if err != nil {
+		http.Error(w, "Error al consultar historial: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Entradas      []models.History  // Entradas del historial.
+		Accion        string            // Acción filtrada.
+		Acciones      []opcionAccion    // Opciones del filtro de acción.
+		Etiquetas     map[string]string // Nombre en pantalla de cada acción.
+		UsuarioNombre string            // Usuario actual.
+		UsuarioRol    string            // Rol actual.
+	}{
+		Entradas:      entradas,
+		Accion:        filtro.Accion,
+		Acciones:      accionesHistorial,
+		Etiquetas:     etiquetasHistorial,
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "mi_historial.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla mi_historial.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// HistorialAdmin muestra el historial de todos los usuarios con filtros.
+// Ruta: GET /admin/historial?usuario=&libro=&accion=&desde=AAAA-MM-DD&hasta=AAAA-MM-DD
+func (h *HistorialHandler) HistorialAdmin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	filtro := historial.Filtro{
+		Usuario: strings.TrimSpace(q.Get("usuario")),
+		Libro:   strings.TrimSpace(q.Get("libro")),
+		Accion:  accionValida(q.Get("accion")),
+	}
+
+	desde, hasta := strings.TrimSpace(q.Get("desde")), strings.TrimSpace(q.Get("hasta"))
+	var ok bool
+	filtro.Desde, filtro.Hasta, ok = leerRangoFechas(w, desde, hasta)
+	if !ok {
+		return
+	}
+
+	entradas, err := historial.Listar(h.DB, filtro)
+	if err != nil {
+		http.Error(w, "Error al consultar historial: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Entradas      []models.History  // Entradas del historial.
+		Usuario       string            // Filtro de usuario.
+		Libro         string            // Filtro de libro.
+		Accion        string            // Filtro de acción.
+		Desde         string            // Filtro de fecha inicial.
+		Hasta         string            // Filtro de fecha final.
+		Acciones      []opcionAccion    // Opciones del filtro de acción.
+		Etiquetas     map[string]string // Nombre en pantalla de cada acción.
+		UsuarioNombre string            // Usuario actual.
+		UsuarioRol    string            // Rol actual.
+	}{
+		Entradas:      entradas,
+		Usuario:       filtro.Usuario,
+		Libro:         filtro.Libro,
+		Accion:        filtro.Accion,
+		Desde:         desde,
+		Hasta:         hasta,
+		Acciones:      accionesHistorial,
+		Etiquetas:     etiquetasHistorial,
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "admin_historial.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla admin_historial.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// accionValida devuelve la acción si es una de las conocidas, o "" para no filtrar.
+func accionValida(accion string) string {
+	accion = strings.ToUpper(strings.TrimSpace(accion))
+	if _, ok := etiquetasHistorial[accion]; ok {
+		return accion
+	}
+	return ""
+}
+
+// leerRangoFechas convierte los filtros "desde" y "hasta" (AAAA-MM-DD, opcionales).
+// "hasta" incluye el día completo. Si alguna fecha es inválida responde 400 y devuelve false.
+func leerRangoFechas(w http.ResponseWriter, desde, hasta string) (time.Time, time.Time, bool) {
+	var inicio, fin time.Time
+	if desde != "" {
+		fecha, err := time.ParseInLocation("2006-01-02", desde, time.Local)
+		if err != nil {
+			http.Error(w, "Fecha 'desde' inválida", http.StatusBadRequest)
+			return inicio, fin, false
+		}
+		inicio = fecha
+	}
+	if hasta != "" {
+		fecha, err := time.ParseInLocation("2006-01-02", hasta, time.Local)
+		if err != nil {
+			http.Error(w, "Fecha 'hasta' inválida", http.StatusBadRequest)
+			return inicio, fin, false
+		}
+		fin = fecha.AddDate(0, 0, 1)
+	}
+	return inicio, fin, true
+}
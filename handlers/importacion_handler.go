@@ -0,0 +1,134 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"bytes"               // Paquete para leer el archivo ya recibido.
+	"encoding/base64"     // Paquete para que el archivo viaje en el formulario de confirmación.
+	"errors"              // Paquete para reconocer los errores del archivo.
+	"html/template"       // Paquete para renderizar plantillas HTML.
+	"io"                  // Paquete para leer el archivo subido.
+	"net/http"            // Paquete para rutas y respuestas HTTP.
+	"sistema/importacion" // Lectura y validación del CSV.
+	"sistema/libros"      // Repositorio de libros.
+)
+
+// erroresArchivoCSV son los errores del archivo que se muestran al operador;
+// los demás son fallas internas.
+var erroresArchivoCSV = []error{
+	importacion.ErrMuyGrande,
+	importacion.ErrDemasiadasFilas,
+	importacion.ErrNoUTF8,
+	importacion.ErrFormato,
+	importacion.ErrColumnas,
+	importacion.ErrVacio,
+}
+
+// ImportacionHandler atiende la carga masiva de libros desde un CSV.
+type ImportacionHandler struct {
+	Libros    libros.LibroRepository // Libros del catálogo.
+	Templates *template.Template     // Plantillas HTML cargadas.
+}
+
+// NuevoImportacionHandler crea una nueva instancia del handler de importación.
+func NuevoImportacionHandler(repositorio libros.LibroRepository, templates *template.Template) *ImportacionHandler {
+	return &ImportacionHandler{
+		Libros:    repositorio,
+		Templates: templates,
+	}
+}
+
+// formularioImportacion son los datos de la plantilla importar.html.
+type formularioImportacion struct {
+	Resultado     *importacion.Resultado // Vista previa o libros importados (nil antes de subir un archivo).
+	Contenido     string                 // Archivo de la vista previa en base64, para importarlo sin volver a subirlo.
+	Importados    int                    // Libros guardados (solo después de importar).
+	Confirmado    bool                   // Ya se importó (si no, es la vista previa).
+	Error         string                 // Motivo por el que no se pudo leer el archivo.
+	UsuarioNombre string                 // Nombre del usuario logueado.
+	UsuarioRol    string                 // Rol del usuario logueado.
+	CSRF          string                 // Token CSRF de los formularios.
+}
+
+// MostrarImportar muestra el formulario para subir el CSV.
+// Ruta: GET /libros/importar
+func (h *ImportacionHandler) MostrarImportar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	h.renderizar(w, r, formularioImportacion{})
+}
+
+// ProcesarImportar analiza el CSV subido y muestra la vista previa, con los
+// errores de cada fila y los duplicados. Con confirmar=1 guarda las filas
+// válidas en una sola transacción y muestra el resultado.
+// Ruta: POST /libros/importar/procesar (multipart: archivo o contenido, confirmar)
+func (h *ImportacionHandler) ProcesarImportar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, tamanoMaximoLibro+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	// Archivo: el subido o el de la vista previa que se está confirmando.
+	var contenido []byte
+	archivo, _, err := r.FormFile("archivo")
+	switch {
+	case err == nil:
+		defer archivo.Close()
+		contenido, err = io.ReadAll(io.LimitReader(archivo, importacion.TamanoMaximo+1))
+		if err != nil {
+			http.Error(w, "Error al leer archivo", http.StatusBadRequest)
+			return
+		}
+	case r.FormValue("contenido") != "":
+		contenido, err = base64.StdEncoding.DecodeString(r.FormValue("contenido"))
+		if err != nil {
+			http.Error(w, "Archivo de la vista previa inválido", http.StatusBadRequest)
+			return
+		}
+	default:
+		h.renderizar(w, r, formularioImportacion{Error: "Debe adjuntar un archivo CSV"})
+		return
+	}
+
+	form := formularioImportacion{Confirmado: r.FormValue("confirmar") == "1"}
+	var resultado importacion.Resultado
+	if form.Confirmado {
+		// Todas las altas y sus entradas de auditoría se guardan juntas.
+		resultado, err = importacion.Importar(bytes.NewReader(contenido), h.Libros, autorCambio(r))
+		form.Importados = resultado.Validas()
+	} else {
+		resultado, err = importacion.Analizar(bytes.NewReader(contenido), h.Libros)
+		form.Contenido = base64.StdEncoding.EncodeToString(contenido)
+	}
+	if err != nil {
+		for _, errArchivo := range erroresArchivoCSV {
+			if errors.Is(err, errArchivo) {
+				h.renderizar(w, r, formularioImportacion{Error: err.Error()})
+				return
+			}
+		}
+		http.Error(w, "Error al importar libros: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	form.Resultado = &resultado
+	h.renderizar(w, r, form)
+}
+
+// renderizar muestra importar.html con los datos del usuario y el token CSRF.
+func (h *ImportacionHandler) renderizar(w http.ResponseWriter, r *http.Request, form formularioImportacion) {
+	form.UsuarioNombre = ObtenerNombreUsuario(r)
+	form.UsuarioRol = ObtenerRolUsuario(r)
+	form.CSRF = TokenCSRF(r)
+	err := h.Templates.ExecuteTemplate(w, "importar.html", form)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla importar.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
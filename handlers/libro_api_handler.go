@@ -0,0 +1,309 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"net/http"               // Paquete para rutas y respuestas HTTP.
+	"sistema/almacenamiento" // Archivos de los libros (se borran al eliminar).
+	"sistema/libros"         // Repositorio de libros.
+	"sistema/metadatos"      // Normalización del ISBN.
+	"sistema/models"         // Estructura Libro.
+	"strconv"                // Paquete para convertir parámetros a enteros.
+	"strings"                // Paquete para limpiar textos.
+)
+
+// formatosValidos contiene los formatos de archivo aceptados.
+var formatosValidos = map[string]bool{"PDF": true, "EPUB": true, "MOBI": true}
+
+// LibroAPIHandler expone el recurso /api/v1/libros en formato JSON.
+type LibroAPIHandler struct {
+	Libros    libros.LibroRepository        // Libros del catálogo.
+	Archivos  almacenamiento.Almacenamiento // Archivos de los libros.
+	PorPagina int                           // Libros por página si no se indica por_pagina.
+}
+
+// NuevoLibroAPIHandler crea una nueva instancia del handler de la API de libros.
+func NuevoLibroAPIHandler(repositorio libros.LibroRepository, archivos almacenamiento.Almacenamiento) *LibroAPIHandler {
+	return &LibroAPIHandler{
+		Libros:    repositorio,
+		Archivos:  archivos,
+		PorPagina: porPaginaDefecto,
+	}
+}
+
+// ListaLibrosAPI es la respuesta paginada del listado de libros.
+type ListaLibrosAPI struct {
+	Datos     []models.Libro `json:"datos"`               // Libros de la página actual.
+	Pagina    int            `json:"pagina,omitempty"`    // Número de página (desde 1); no se informa al paginar por cursor.
+	PorPagina int            `json:"por_pagina"`          // Tamaño de página.
+	Total     int            `json:"total"`               // Total de libros que cumplen los filtros.
+	Siguiente string         `json:"siguiente,omitempty"` // Cursor de la página siguiente (parámetro cursor).
+	Anterior  string         `json:"anterior,omitempty"`  // Cursor de la página anterior.
+}
+
+// Listar devuelve libros filtrados y paginados, por ID ascendente. Por defecto
+// pagina por cursor: la respuesta trae "siguiente" y "anterior" para pasar en
+// el parámetro cursor. Con el parámetro pagina usa el número de página (más
+// lento en las páginas altas; se mantiene por compatibilidad).
+// Ruta: GET /api/v1/libros?buscar=&autor=&categoria=&formato=&anio_desde=&anio_hasta=&disponibles=&por_pagina=&cursor=&pagina=
+func (h *LibroAPIHandler) Listar(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	// Paginación.
+	porNumero := q.Get("pagina") != ""
+	pagina, err := enteroOpcional(q.Get("pagina"), 1)
+	if err != nil || pagina < 1 {
+		ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", "pagina debe ser un entero mayor que 0")
+		return
+	}
+	porPagina, ok := leerPorPagina(q.Get("por_pagina"), h.PorPagina)
+	if !ok {
+		ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", "por_pagina debe estar entre 1 y 100")
+		return
+	}
+	cursor := q.Get("cursor")
+	if porNumero && cursor != "" {
+		ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", "pagina y cursor no se pueden usar juntos")
+		return
+	}
+
+	// Filtros.
+	filtro := libros.Filtro{
+		Buscar:         strings.TrimSpace(q.Get("buscar")),
+		Autor:          strings.TrimSpace(q.Get("autor")),
+		Categoria:      strings.TrimSpace(q.Get("categoria")),
+		Formato:        strings.ToUpper(strings.TrimSpace(q.Get("formato"))),
+		Disponibles:    q.Get("disponibles") == "true",
+		Orden:          libros.OrdenID,
+		Limite:         porPagina,
+		Desplazamiento: (pagina - 1) * porPagina,
+		Cursor:         cursor,
+	}
+	for _, p := range []struct {
+		nombre string
+		anio   *int
+	}{
+		{"anio_desde", &filtro.AnioDesde},
+		{"anio_hasta", &filtro.AnioHasta},
+	} {
+		if v := strings.TrimSpace(q.Get(p.nombre)); v != "" {
+			anio, err := strconv.Atoi(v)
+			if err != nil {
+				ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", p.nombre+" debe ser un año válido")
+				return
+			}
+			*p.anio = anio
+		}
+	}
+
+	// Total de resultados para la paginación.
+	respuesta := ListaLibrosAPI{Datos: []models.Libro{}, PorPagina: porPagina}
+	respuesta.Total, err = h.Libros.Contar(filtro)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al contar libros")
+		return
+	}
+
+	if porNumero {
+		lista, err := h.Libros.Listar(filtro)
+		if err != nil {
+			ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al consultar libros")
+			return
+		}
+		respuesta.Datos = append(respuesta.Datos, lista...)
+		respuesta.Pagina = pagina
+		ResponderJSON(w, http.StatusOK, respuesta)
+		return
+	}
+
+	p, err := h.Libros.Pagina(filtro)
+	if err != nil {
+		if err == libros.ErrCursorInvalido {
+			ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", "cursor inválido")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al consultar libros")
+		return
+	}
+	respuesta.Datos = append(respuesta.Datos, p.Libros...)
+	respuesta.Siguiente, respuesta.Anterior = p.Siguiente, p.Anterior
+	if cursor == "" {
+		respuesta.Pagina = 1
+	}
+
+	ResponderJSON(w, http.StatusOK, respuesta)
+}
+
+// Obtener devuelve un libro por ID.
+// Ruta: GET /api/v1/libros/{id}
+func (h *LibroAPIHandler) Obtener(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	libro, err := h.Libros.Obtener(id)
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			ResponderErrorJSON(w, http.StatusNotFound, "no_encontrado", "Libro no encontrado")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al consultar libro")
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, libro)
+}
+
+// Crear registra un libro nuevo a partir de un cuerpo JSON.
+// Ruta: POST /api/v1/libros
+func (h *LibroAPIHandler) Crear(w http.ResponseWriter, r *http.Request) {
+	libro, ok := leerLibroJSON(w, r)
+	if !ok {
+		return
+	}
+
+	// El alta y su entrada de auditoría se guardan juntas.
+	if err := h.Libros.Crear(&libro, autorCambio(r)); err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al guardar libro")
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/libros/"+strconv.Itoa(libro.ID))
+	ResponderJSON(w, http.StatusCreated, libro)
+}
+
+// Actualizar reemplaza los datos de un libro existente. El stock libre no se
+// toma del cuerpo: se corre en lo que cambie licencias_totales.
+// Ruta: PUT /api/v1/libros/{id}
+func (h *LibroAPIHandler) Actualizar(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	libro, ok := leerLibroJSON(w, r)
+	if !ok {
+		return
+	}
+	libro.ID = id
+
+	// Se guardan los datos (sin tocar archivo ni páginas) junto con la entrada de auditoría.
+	despues, err := h.Libros.Actualizar(libro, false, autorCambio(r))
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			ResponderErrorJSON(w, http.StatusNotFound, "no_encontrado", "Libro no encontrado")
+			return
+		}
+		if err == libros.ErrLicenciasEnUso {
+			responderErrorCampo(w, "licencias_totales", "El total de licencias no puede ser menor que las prestadas o apartadas")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al actualizar libro")
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, despues)
+}
+
+// Eliminar borra un libro por ID.
+// Ruta: DELETE /api/v1/libros/{id}
+func (h *LibroAPIHandler) Eliminar(w http.ResponseWriter, r *http.Request) {
+	id, ok := idDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	// Se borra el registro (con su entrada de auditoría) y se obtiene cómo era, para borrar su archivo.
+	eliminado, err := h.Libros.Eliminar(id, autorCambio(r))
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			ResponderErrorJSON(w, http.StatusNotFound, "no_encontrado", "Libro no encontrado")
+			return
+		}
+		if err == libros.ErrLibroEnUso {
+			ResponderErrorJSON(w, http.StatusConflict, "libro_en_uso", "El libro tiene préstamos activos o reservas pendientes")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al eliminar libro")
+		return
+	}
+
+	if eliminado.Archivo != "" {
+		_ = h.Archivos.Eliminar(eliminado.Archivo)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// idDesdeRuta lee el {id} de la ruta; si es inválido responde 400 y devuelve false.
+func idDesdeRuta(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		ResponderErrorJSON(w, http.StatusBadRequest, "id_invalido", "ID de libro inválido")
+		return 0, false
+	}
+	return id, true
+}
+
+// libroEntradaJSON es el cuerpo de un alta o edición. Las licencias se leen
+// aparte para saber si vinieron en el cuerpo.
+type libroEntradaJSON struct {
+	models.Libro
+	StockLicencias   *int `json:"stock_licencias"`   // Solo lectura: se acepta (el cliente reenvía lo que leyó) y se ignora.
+	LicenciasTotales *int `json:"licencias_totales"` // Total de licencias del libro.
+}
+
+// leerLibroJSON decodifica y valida el cuerpo de un libro. stock_licencias es
+// de solo lectura: el servidor lo calcula con los préstamos. Un cuerpo que lo
+// trae sin licencias_totales (clientes anteriores al total) se rechaza, para
+// no tomarlo como un total de 0.
+// Si hay errores responde al cliente y devuelve false.
+func leerLibroJSON(w http.ResponseWriter, r *http.Request) (models.Libro, bool) {
+	var entrada libroEntradaJSON
+	if !leerCuerpoJSON(w, r, &entrada) {
+		return entrada.Libro, false
+	}
+	libro := entrada.Libro
+	if entrada.LicenciasTotales != nil {
+		libro.LicenciasTotales = *entrada.LicenciasTotales
+	}
+
+	libro.Titulo = strings.TrimSpace(libro.Titulo)
+	libro.Autor = strings.TrimSpace(libro.Autor)
+	libro.Categoria = strings.TrimSpace(libro.Categoria)
+	libro.Formato = strings.ToUpper(strings.TrimSpace(libro.Formato))
+	libro.Idioma = strings.TrimSpace(libro.Idioma)
+	libro.Editorial = strings.TrimSpace(libro.Editorial)
+	isbnEscrito := strings.TrimSpace(libro.ISBN)
+	libro.ISBN = metadatos.NormalizarISBN(isbnEscrito)
+
+	switch {
+	case libro.Titulo == "":
+		responderErrorCampo(w, "titulo", "El título es obligatorio")
+	case libro.Autor == "":
+		responderErrorCampo(w, "autor", "El autor es obligatorio")
+	case libro.Categoria == "":
+		responderErrorCampo(w, "categoria", "La categoría es obligatoria")
+	case libro.AnioPublicacion < 0:
+		responderErrorCampo(w, "anio_publicacion", "El año de publicación no puede ser negativo")
+	case !formatosValidos[libro.Formato]:
+		responderErrorCampo(w, "formato", "El formato debe ser PDF, EPUB o MOBI")
+	case entrada.StockLicencias != nil && entrada.LicenciasTotales == nil:
+		responderErrorCampo(w, "licencias_totales", "Indique licencias_totales: stock_licencias es de solo lectura")
+	case libro.LicenciasTotales < 0:
+		responderErrorCampo(w, "licencias_totales", "El total de licencias no puede ser negativo")
+	case isbnEscrito != "" && libro.ISBN == "":
+		responderErrorCampo(w, "isbn", "El ISBN no es válido")
+	default:
+		return libro, true
+	}
+	return libro, false
+}
+
+// enteroOpcional convierte un parámetro; si viene vacío devuelve el valor por defecto.
+func enteroOpcional(valor string, defecto int) (int, error) {
+	valor = strings.TrimSpace(valor)
+	if valor == "" {
+		return defecto, nil
+	}
+	return strconv.Atoi(valor)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"                // Paquete para poner la sesión en el pedido.
+	"encoding/json"          // Paquete para leer las respuestas.
+	"net/http"               // Paquete para códigos y métodos HTTP.
+	"net/http/httptest"      // Paquete para simular pedidos.
+	"sistema/almacenamiento" // Archivos de los libros en una carpeta temporal.
+	"sistema/libros"         // Repositorio de libros en memoria.
+	"sistema/models"         // Estructuras Libro, Sesion y Auditoria.
+	"strings"                // Paquete para armar cuerpos.
+	"testing"                // Paquete de pruebas.
+)
+
+// pruebaLibrosAPI es la API de libros sobre un catálogo en memoria.
+type pruebaLibrosAPI struct {
+	repo     *libros.RepositorioMemoria
+	archivos *almacenamiento.Local
+	rutas    *http.ServeMux
+}
+
+// nuevaPruebaLibrosAPI arma las rutas de /api/v1/libros como en main.go, sin
+// autenticación, sobre los libros indicados.
+func nuevaPruebaLibrosAPI(t *testing.T, lista ...models.Libro) *pruebaLibrosAPI {
+	t.Helper()
+	archivos, err := almacenamiento.NuevoLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &pruebaLibrosAPI{repo: libros.NuevoRepositorioMemoria(lista...), archivos: archivos, rutas: http.NewServeMux()}
+
+	h := NuevoLibroAPIHandler(p.repo, archivos)
+	h.PorPagina = 2
+	p.rutas.HandleFunc("GET /api/v1/libros", h.Listar)
+	p.rutas.HandleFunc("GET /api/v1/libros/{id}", h.Obtener)
+	p.rutas.HandleFunc("POST /api/v1/libros", h.Crear)
+	p.rutas.HandleFunc("PUT /api/v1/libros/{id}", h.Actualizar)
+	p.rutas.HandleFunc("DELETE /api/v1/libros/{id}", h.Eliminar)
+	return p
+}
+
+// pedir ejecuta el pedido como la sesión de un operador y decodifica la
+// respuesta JSON en destino (si no es nil).
+func (p *pruebaLibrosAPI) pedir(t *testing.T, metodo, ruta, cuerpo string, destino any) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(metodo, ruta, strings.NewReader(cuerpo))
+	sesion := models.Sesion{IDUsuario: 5, Nombre: "Olga", Rol: "OPERADOR"}
+	r = r.WithContext(context.WithValue(r.Context(), claveSesion{}, sesion))
+	w := httptest.NewRecorder()
+	p.rutas.ServeHTTP(w, r)
+	if destino != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), destino); err != nil {
+			t.Fatalf("%s %s: respuesta no es JSON: %v\n%s", metodo, ruta, err, w.Body.String())
+		}
+	}
+	return w
+}
+
+// catalogoPrueba son cinco libros de dos categorías.
+func catalogoPrueba() []models.Libro {
+	return []models.Libro{
+		{ID: 1, Titulo: "Clean Code", Autor: "Robert C. Martin", Categoria: "Programación", AnioPublicacion: 2008, Formato: "PDF", StockLicencias: 5},
+		{ID: 2, Titulo: "Rayuela", Autor: "Julio Cortázar", Categoria: "Novela", AnioPublicacion: 1963, Formato: "EPUB", StockLicencias: 0, LicenciasTotales: 2},
+		{ID: 3, Titulo: "Refactoring", Autor: "Martin Fowler", Categoria: "Programación", AnioPublicacion: 1999, Formato: "PDF", StockLicencias: 2},
+		{ID: 4, Titulo: "Ficciones", Autor: "Jorge Luis Borges", Categoria: "Novela", AnioPublicacion: 1944, Formato: "MOBI", StockLicencias: 1},
+		{ID: 5, Titulo: "The Go Programming Language", Autor: "Alan Donovan", Categoria: "Programación", AnioPublicacion: 2015, Formato: "EPUB", StockLicencias: 3},
+	}
+}
+
+func TestAPILibrosListarPorCursor(t *testing.T) {
+	p := nuevaPruebaLibrosAPI(t, catalogoPrueba()...)
+
+	var ids []int
+	ruta := "/api/v1/libros?categoria=Programación"
+	for paginas := 0; ; paginas++ {
+		if paginas > 5 {
+			t.Fatal("el cursor no termina")
+		}
+		var lista ListaLibrosAPI
+		if w := p.pedir(t, http.MethodGet, ruta, "", &lista); w.Code != http.StatusOK {
+			t.Fatalf("GET %s: código %d", ruta, w.Code)
+		}
+		if lista.Total != 3 || lista.PorPagina != 2 {
+			t.Errorf("total %d, por página %d; se esperaba 3 y 2", lista.Total, lista.PorPagina)
+		}
+		for _, l := range lista.Datos {
+			ids = append(ids, l.ID)
+		}
+		if lista.Siguiente == "" {
+			break
+		}
+		ruta = "/api/v1/libros?categoria=Programación&cursor=" + lista.Siguiente
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 3 || ids[2] != 5 {
+		t.Errorf("libros recorridos %v, se esperaba [1 3 5]", ids)
+	}
+}
+
+func TestAPILibrosListarFiltrosYPaginaNumerada(t *testing.T) {
+	p := nuevaPruebaLibrosAPI(t, catalogoPrueba()...)
+
+	var lista ListaLibrosAPI
+	p.pedir(t, http.MethodGet, "/api/v1/libros?disponibles=true&anio_hasta=2000&pagina=1", "", &lista)
+	if lista.Total != 2 || len(lista.Datos) != 2 || lista.Datos[0].ID != 3 || lista.Datos[1].ID != 4 || lista.Pagina != 1 {
+		t.Errorf("disponibles hasta 2000: %+v", lista)
+	}
+
+	var fallo map[string]ErrorAPI
+	if w := p.pedir(t, http.MethodGet, "/api/v1/libros?cursor=basura", "", &fallo); w.Code != http.StatusBadRequest || fallo["error"].Codigo != "parametro_invalido" {
+		t.Errorf("cursor inválido: código %d, %+v", w.Code, fallo)
+	}
+}
+
+func TestAPILibrosCrearYObtener(t *testing.T) {
+	p := nuevaPruebaLibrosAPI(t, catalogoPrueba()...)
+
+	var creado models.Libro
+	cuerpo := `{"titulo":" Kafka en la orilla ","autor":"Haruki Murakami","categoria":"Novela","anio_publicacion":2002,"formato":"epub","licencias_totales":4,"isbn":"978-0-306-40615-7"}`
+	w := p.pedir(t, http.MethodPost, "/api/v1/libros", cuerpo, &creado)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != "/api/v1/libros/6" {
+		t.Fatalf("POST: código %d, Location %q", w.Code, w.Header().Get("Location"))
+	}
+	if creado.Titulo != "Kafka en la orilla" || creado.Formato != "EPUB" || creado.ISBN != "9780306406157" {
+		t.Errorf("no se normalizaron los datos: %+v", creado)
+	}
+	if creado.StockLicencias != 4 || creado.LicenciasTotales != 4 {
+		t.Errorf("licencias %d de %d, se esperaban 4 de 4", creado.StockLicencias, creado.LicenciasTotales)
+	}
+
+	var obtenido models.Libro
+	if w := p.pedir(t, http.MethodGet, "/api/v1/libros/6", "", &obtenido); w.Code != http.StatusOK || obtenido != creado {
+		t.Errorf("GET: código %d, %+v", w.Code, obtenido)
+	}
+
+	auditoria := p.repo.Auditoria()
+	if len(auditoria) != 1 || auditoria[0].Accion != models.AuditoriaCrear || auditoria[0].IDActor != 5 || auditoria[0].Actor != "Olga" {
+		t.Errorf("auditoría del alta: %+v", auditoria)
+	}
+}
+
+func TestAPILibrosCrearValidaCampos(t *testing.T) {
+	p := nuevaPruebaLibrosAPI(t)
+
+	casos := []struct {
+		cuerpo string
+		campo  string
+	}{
+		{`{"titulo":"","autor":"A","categoria":"C","formato":"PDF"}`, "titulo"},
+		{`{"titulo":"T","autor":"A","categoria":"C","formato":"DOCX"}`, "formato"},
+		{`{"titulo":"T","autor":"A","categoria":"C","formato":"PDF","licencias_totales":-1}`, "licencias_totales"},
+		{`{"titulo":"T","autor":"A","categoria":"C","formato":"PDF","stock_licencias":3}`, "licencias_totales"},
+		{`{"titulo":"T","autor":"A","categoria":"C","formato":"PDF","isbn":"123"}`, "isbn"},
+	}
+	for _, c := range casos {
+		var fallo map[string]ErrorAPI
+		w := p.pedir(t, http.MethodPost, "/api/v1/libros", c.cuerpo, &fallo)
+		if w.Code != http.StatusUnprocessableEntity || fallo["error"].Campo != c.campo {
+			t.Errorf("%s: código %d, %+v; se esperaba 422 en %s", c.cuerpo, w.Code, fallo, c.campo)
+		}
+	}
+
+	if w := p.pedir(t, http.MethodPost, "/api/v1/libros", `{"titulo":"T","desconocido":1}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("campo desconocido: código %d, se esperaba 400", w.Code)
+	}
+	if n, _ := p.repo.Contar(libros.Filtro{}); n != 0 {
+		t.Errorf("se guardaron %d libros inválidos", n)
+	}
+}
+
+func TestAPILibrosActualizarCorreElStock(t *testing.T) {
+	p := nuevaPruebaLibrosAPI(t, catalogoPrueba()...)
+
+	// Rayuela tiene 2 licencias, las dos prestadas: el stock que manda el
+	// cliente se ignora y el libre se corre en lo que sube el total.
+	var actualizado models.Libro
+	cuerpo := `{"titulo":"Rayuela","autor":"Julio Cortázar","categoria":"Novela","anio_publicacion":1963,"formato":"EPUB","stock_licencias":9,"licencias_totales":3}`
+	if w := p.pedir(t, http.MethodPut, "/api/v1/libros/2", cuerpo, &actualizado); w.Code != http.StatusOK {
+		t.Fatalf("PUT: código %d: %s", w.Code, w.Body.String())
+	}
+	if actualizado.StockLicencias != 1 || actualizado.LicenciasTotales != 3 {
+		t.Errorf("licencias %d de %d, se esperaban 1 de 3", actualizado.StockLicencias, actualizado.LicenciasTotales)
+	}
+
+	// Menos licencias que las prestadas no se puede.
+	var fallo map[string]ErrorAPI
+	cuerpo = strings.Replace(cuerpo, `"licencias_totales":3`, `"licencias_totales":1`, 1)
+	if w := p.pedir(t, http.MethodPut, "/api/v1/libros/2", cuerpo, &fallo); w.Code != http.StatusUnprocessableEntity || fallo["error"].Campo != "licencias_totales" {
+		t.Errorf("bajar el total: código %d, %+v", w.Code, fallo)
+	}
+
+	if w := p.pedir(t, http.MethodPut, "/api/v1/libros/99", cuerpo, nil); w.Code != http.StatusNotFound {
+		t.Errorf("PUT de un libro inexistente: código %d", w.Code)
+	}
+	if auditoria := p.repo.Auditoria(); len(auditoria) != 1 || auditoria[0].Accion != models.AuditoriaActualizar {
+		t.Errorf("auditoría: %+v", auditoria)
+	}
+}
+
+func TestAPILibrosEliminarBorraElArchivo(t *testing.T) {
+	lista := catalogoPrueba()
+	lista[0].Archivo = "clean-code.pdf"
+	p := nuevaPruebaLibrosAPI(t, lista...)
+	if err := p.archivos.Guardar("clean-code.pdf", strings.NewReader("%PDF-1.4")); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := p.pedir(t, http.MethodDelete, "/api/v1/libros/1", "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: código %d", w.Code)
+	}
+	if _, err := p.archivos.Abrir("clean-code.pdf"); err == nil {
+		t.Error("el archivo del libro eliminado sigue guardado")
+	}
+	if w := p.pedir(t, http.MethodGet, "/api/v1/libros/1", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET después de eliminar: código %d", w.Code)
+	}
+	if w := p.pedir(t, http.MethodDelete, "/api/v1/libros/1", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("segundo DELETE: código %d", w.Code)
+	}
+	if w := p.pedir(t, http.MethodDelete, "/api/v1/libros/abc", "", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("DELETE con ID inválido: código %d", w.Code)
+	}
+}
+
+func TestAPILibrosEliminarRechazaLibroEnUso(t *testing.T) {
+	// Rayuela tiene sus 2 licencias fuera del stock: no se puede borrar.
+	p := nuevaPruebaLibrosAPI(t, catalogoPrueba()...)
+
+	var fallo map[string]ErrorAPI
+	w := p.pedir(t, http.MethodDelete, "/api/v1/libros/2", "", &fallo)
+	if w.Code != http.StatusConflict || fallo["error"].Codigo != "libro_en_uso" {
+		t.Fatalf("DELETE de un libro en uso: código %d, %+v", w.Code, fallo)
+	}
+	if w := p.pedir(t, http.MethodGet, "/api/v1/libros/2", "", nil); w.Code != http.StatusOK {
+		t.Errorf("el libro en uso se borró: código %d", w.Code)
+	}
+}
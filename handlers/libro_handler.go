@@ -1,328 +1,516 @@
-package handlers // Paquete handlers: contiene la lógica de las rutas/controladores.
-
-import (
-	"database/sql"
-	"html/template"
-	"net/http"
-	"sistema/models"
-	"strconv"
-	"strings"
-)
-
-// Stats representa estadísticas básicas del sistema para el dashboard.
-type Stats struct {
-	TotalLibros int
-	TotalPDF    int
-	TotalEPUB   int
-	TotalMOBI   int
-}
-
-// LibroHandler agrupa recursos que usan los handlers de libros.
-type LibroHandler struct {
-	DB        *sql.DB
-	Templates *template.Template
-}
-
-// NuevoLibroHandler crea una nueva instancia de LibroHandler.
-func NuevoLibroHandler(db *sql.DB, templates *template.Template) *LibroHandler {
-	return &LibroHandler{
-		DB:        db,
-		Templates: templates,
-	}
-}
-
-// Index muestra el panel principal y envía permisos según rol.
-func (h *LibroHandler) Index(w http.ResponseWriter, r *http.Request) {
-	busqueda := strings.TrimSpace(r.URL.Query().Get("buscar"))
-	mensaje := strings.TrimSpace(r.URL.Query().Get("msg"))
-
-	nombreUsuario := ObtenerNombreUsuario(r)
-	rolUsuario := ObtenerRolUsuario(r)
-
-	// Banderas de permisos para el template (más seguro y simple que comparar en HTML).
-	puedeCrear := TieneRol(r, "ADMIN", "OPERADOR")
-	puedeEditar := TieneRol(r, "ADMIN", "OPERADOR")
-	puedeEliminar := TieneRol(r, "ADMIN")
-
-	// Estadísticas dashboard.
-	var stats Stats
-	queryStats := `
-		SELECT
-			(SELECT COUNT(*) FROM libros) AS total_libros,
-			(SELECT COUNT(*) FROM libros WHERE formato = 'PDF') AS total_pdf,
-			(SELECT COUNT(*) FROM libros WHERE formato = 'EPUB') AS total_epub,
-			(SELECT COUNT(*) FROM libros WHERE formato = 'MOBI') AS total_mobi
-	`
-	err := h.DB.QueryRow(queryStats).Scan(
-		&stats.TotalLibros,
-		&stats.TotalPDF,
-		&stats.TotalEPUB,
-		&stats.TotalMOBI,
-	)
-	if err != nil {
-		http.Error(w, "Error al consultar estadísticas: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Consulta de libros con/sin búsqueda.
-	var rows *sql.Rows
-	if busqueda != "" {
-		query := `
-			SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-			FROM libros
-			WHERE titulo LIKE ?
-			ORDER BY id DESC
-		`
-		rows, err = h.DB.Query(query, "%"+busqueda+"%")
-	} else {
-		query := `
-			SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-			FROM libros
-			ORDER BY id DESC
-		`
-		rows, err = h.DB.Query(query)
-	}
-	if err != nil {
-		http.Error(w, "Error al consultar libros: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
-	var libros []models.Libro
-	for rows.Next() {
-		var libro models.Libro
-		err := rows.Scan(
-			&libro.ID,
-			&libro.Titulo,
-			&libro.Autor,
-			&libro.Categoria,
-			&libro.AnioPublicacion,
-			&libro.Formato,
-			&libro.StockLicencias,
-		)
-		if err != nil {
-			http.Error(w, "Error al leer datos de libros: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		libros = append(libros, libro)
-	}
-
-	// Data para index.
-	data := struct {
-		Libros        []models.Libro
-		Buscar        string
-		Mensaje       string
-		Stats         Stats
-		UsuarioNombre string
-		UsuarioRol    string
-		PuedeCrear    bool
-		PuedeEditar   bool
-		PuedeEliminar bool
-	}{
-		Libros:        libros,
-		Buscar:        busqueda,
-		Mensaje:       mensaje,
-		Stats:         stats,
-		UsuarioNombre: nombreUsuario,
-		UsuarioRol:    rolUsuario,
-		PuedeCrear:    puedeCrear,
-		PuedeEditar:   puedeEditar,
-		PuedeEliminar: puedeEliminar,
-	}
-
-	err = h.Templates.ExecuteTemplate(w, "index.html", data)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla index.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// NuevoLibroForm muestra el formulario para registrar un libro.
-func (h *LibroHandler) NuevoLibroForm(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-	err := h.Templates.ExecuteTemplate(w, "nuevo.html", nil)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla nuevo.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// CrearLibro guarda un nuevo libro.
-func (h *LibroHandler) CrearLibro(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
-		return
-	}
-
-	titulo := strings.TrimSpace(r.FormValue("titulo"))
-	autor := strings.TrimSpace(r.FormValue("autor"))
-	categoria := strings.TrimSpace(r.FormValue("categoria"))
-	formato := strings.TrimSpace(r.FormValue("formato"))
-
-	anio, err := strconv.Atoi(r.FormValue("anio_publicacion"))
-	if err != nil {
-		http.Error(w, "Año de publicación inválido", http.StatusBadRequest)
-		return
-	}
-
-	stock, err := strconv.Atoi(r.FormValue("stock_licencias"))
-	if err != nil {
-		http.Error(w, "Stock de licencias inválido", http.StatusBadRequest)
-		return
-	}
-
-	if titulo == "" || autor == "" || categoria == "" || formato == "" {
-		http.Error(w, "Todos los campos son obligatorios", http.StatusBadRequest)
-		return
-	}
-
-	query := `
-		INSERT INTO libros (titulo, autor, categoria, anio_publicacion, formato, stock_licencias)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`
-	_, err = h.DB.Exec(query, titulo, autor, categoria, anio, formato, stock)
-	if err != nil {
-		http.Error(w, "Error al guardar libro: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/?msg=Libro+creado+correctamente", http.StatusSeeOther)
-}
-
-// EditarLibroForm muestra formulario de edición.
-func (h *LibroHandler) EditarLibroForm(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
-	}
-
-	var libro models.Libro
-	query := `
-		SELECT id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias
-		FROM libros
-		WHERE id = ?
-	`
-	err = h.DB.QueryRow(query, id).Scan(
-		&libro.ID,
-		&libro.Titulo,
-		&libro.Autor,
-		&libro.Categoria,
-		&libro.AnioPublicacion,
-		&libro.Formato,
-		&libro.StockLicencias,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Libro no encontrado", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error al consultar libro: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = h.Templates.ExecuteTemplate(w, "editar.html", libro)
-	if err != nil {
-		http.Error(w, "Error al renderizar plantilla editar.html: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
-
-// ActualizarLibro actualiza un libro existente.
-func (h *LibroHandler) ActualizarLibro(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.Atoi(r.FormValue("id"))
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
-	}
-
-	titulo := strings.TrimSpace(r.FormValue("titulo"))
-	autor := strings.TrimSpace(r.FormValue("autor"))
-	categoria := strings.TrimSpace(r.FormValue("categoria"))
-	formato := strings.TrimSpace(r.FormValue("formato"))
-
-	anio, err := strconv.Atoi(r.FormValue("anio_publicacion"))
-	if err != nil {
-		http.Error(w, "Año de publicación inválido", http.StatusBadRequest)
-		return
-	}
-
-	stock, err := strconv.Atoi(r.FormValue("stock_licencias"))
-	if err != nil {
-		http.Error(w, "Stock de licencias inválido", http.StatusBadRequest)
-		return
-	}
-
-	if titulo == "" || autor == "" || categoria == "" || formato == "" {
-		http.Error(w, "Todos los campos son obligatorios", http.StatusBadRequest)
-		return
-	}
-
-	query := `
-		UPDATE libros
-		SET titulo = ?, autor = ?, categoria = ?, anio_publicacion = ?, formato = ?, stock_licencias = ?
-		WHERE id = ?
-	`
-	_, err = h.DB.Exec(query, titulo, autor, categoria, anio, formato, stock, id)
-	if err != nil {
-		http.Error(w, "Error al actualizar libro: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/?msg=Libro+actualizado+correctamente", http.StatusSeeOther)
-}
-
-// EliminarLibro elimina un libro por ID.
-func (h *LibroHandler) EliminarLibro(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
-		return
-	}
-
-	err := r.ParseForm()
-	if err != nil {
-		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
-		return
-	}
-
-	id, err := strconv.Atoi(r.FormValue("id"))
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
-	}
-
-	query := `DELETE FROM libros WHERE id = ?`
-	_, err = h.DB.Exec(query, id)
-	if err != nil {
-		http.Error(w, "Error al eliminar libro: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	http.Redirect(w, r, "/?msg=Libro+eliminado+correctamente", http.StatusSeeOther)
-}
+package handlers // Paquete handlers: contiene la lógica de las rutas/controladores.
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sistema/almacenamiento"
+	"sistema/libros"
+	"sistema/metadatos"
+	"sistema/models"
+	"strconv"
+	"strings"
+)
+
+// LibroHandler agrupa recursos que usan los handlers de libros.
+type LibroHandler struct {
+	Libros    libros.LibroRepository
+	Templates *template.Template
+	Archivos  almacenamiento.Almacenamiento
+	PorPagina int // Libros por página del panel si la URL no indica por_pagina.
+}
+
+// NuevoLibroHandler crea una nueva instancia de LibroHandler.
+func NuevoLibroHandler(repositorio libros.LibroRepository, templates *template.Template, archivos almacenamiento.Almacenamiento) *LibroHandler {
+	return &LibroHandler{
+		Libros:    repositorio,
+		Templates: templates,
+		Archivos:  archivos,
+		PorPagina: porPaginaDefecto,
+	}
+}
+
+// Index muestra el panel principal, con el listado de libros de a una página
+// por vez. Los botones de cada acción se muestran según los permisos del rol
+// (función "puede" de las plantillas).
+// Ruta: GET /?buscar=&por_pagina=&cursor=
+func (h *LibroHandler) Index(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	busqueda := strings.TrimSpace(q.Get("buscar"))
+	mensaje := strings.TrimSpace(q.Get("msg"))
+	porPagina, ok := leerPorPagina(q.Get("por_pagina"), h.PorPagina)
+	if !ok {
+		http.Error(w, "Cantidad de libros por página inválida", http.StatusBadRequest)
+		return
+	}
+
+	nombreUsuario := ObtenerNombreUsuario(r)
+	rolUsuario := ObtenerRolUsuario(r)
+
+	// Estadísticas dashboard.
+	stats, err := h.Libros.Estadisticas()
+	if err != nil {
+		http.Error(w, "Error al consultar estadísticas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Libros que coinciden con la búsqueda, del más relevante al menos relevante;
+	// sin búsqueda, del más reciente al más antiguo.
+	filtro := libros.Filtro{Buscar: busqueda, Orden: libros.OrdenRelevancia, Limite: porPagina, Cursor: q.Get("cursor")}
+	pagina, err := h.Libros.Pagina(filtro)
+	if err != nil {
+		if err == libros.ErrCursorInvalido {
+			http.Error(w, "Enlace de página inválido", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error al consultar libros: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	total, err := h.Libros.Contar(filtro)
+	if err != nil {
+		http.Error(w, "Error al contar libros: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Los enlaces de cada página conservan la búsqueda y el tamaño de página.
+	actuales := url.Values{}
+	if busqueda != "" {
+		actuales.Set("buscar", busqueda)
+	}
+	if q.Get("por_pagina") != "" {
+		actuales.Set("por_pagina", strconv.Itoa(porPagina))
+	}
+
+	// Data para index.
+	data := struct {
+		Libros        []models.Libro
+		Total         int
+		Paginacion    paginacion
+		PorPagina     string
+		Buscar        string
+		Mensaje       string
+		Stats         libros.Estadisticas
+		UsuarioNombre string
+		UsuarioRol    string
+		CSRF          string
+	}{
+		Libros:        pagina.Libros,
+		Total:         total,
+		Paginacion:    nuevaPaginacion("/", actuales, pagina),
+		PorPagina:     actuales.Get("por_pagina"),
+		Buscar:        busqueda,
+		Mensaje:       mensaje,
+		Stats:         stats,
+		UsuarioNombre: nombreUsuario,
+		UsuarioRol:    rolUsuario,
+		CSRF:          TokenCSRF(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "index.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla index.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// formularioNuevoLibro son los datos de la plantilla nuevo.html.
+// Se reutiliza al volver a mostrar el formulario con conflictos de metadatos.
+type formularioNuevoLibro struct {
+	Libro            models.Libro          // Valores escritos por el operador.
+	Conflictos       []metadatos.Conflicto // Diferencias con los metadatos del archivo.
+	ArchivoPendiente string                // Archivo ya subido que espera confirmación.
+	CSRF             string                // Token CSRF del formulario.
+}
+
+// NuevoLibroForm muestra el formulario para registrar un libro.
+func (h *LibroHandler) NuevoLibroForm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	err := h.Templates.ExecuteTemplate(w, "nuevo.html", formularioNuevoLibro{CSRF: TokenCSRF(r)})
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla nuevo.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ExtraerMetadatos lee los metadatos del archivo elegido en nuevo.html sin guardarlo,
+// para prellenar el formulario antes de enviarlo.
+// Ruta: POST /libros/metadatos (multipart: archivo, formato)
+func (h *LibroHandler) ExtraerMetadatos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		ResponderErrorJSON(w, http.StatusMethodNotAllowed, "metodo_no_permitido", "Método no permitido")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, tamanoMaximoLibro+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		ResponderErrorJSON(w, http.StatusBadRequest, "formulario_invalido", "Error al leer formulario")
+		return
+	}
+
+	archivo, cabecera, err := r.FormFile("archivo")
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusBadRequest, "archivo_requerido", "Debe adjuntar un archivo")
+		return
+	}
+	defer archivo.Close()
+
+	m, err := metadatos.Extraer(r.FormValue("formato"), archivo, cabecera.Size)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusUnprocessableEntity, "sin_metadatos", "No se pudieron leer metadatos: "+err.Error())
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, m)
+}
+
+// CrearLibro guarda un nuevo libro.
+// Si el archivo es EPUB o PDF, sus metadatos completan los campos vacíos; si contradicen
+// lo escrito por el operador, se vuelve a mostrar el formulario para confirmar.
+func (h *LibroHandler) CrearLibro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El formulario es multipart porque incluye el archivo del libro.
+	r.Body = http.MaxBytesReader(w, r.Body, tamanoMaximoLibro+(1<<20))
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	libro, ok := leerFormularioLibro(w, r)
+	if !ok {
+		return
+	}
+
+	// Archivo: uno nuevo del formulario o el que quedó pendiente de confirmación.
+	archivo, err := guardarArchivoLibro(h.Archivos, r, libro.Formato)
+	if err != nil {
+		responderErrorArchivo(w, err)
+		return
+	}
+	pendiente, err := h.validarArchivoPendiente(r.FormValue("archivo_pendiente"), libro.Formato)
+	if err != nil {
+		if archivo != "" {
+			_ = h.Archivos.Eliminar(archivo)
+		}
+		responderErrorArchivo(w, err)
+		return
+	}
+	switch {
+	case archivo == "":
+		archivo = pendiente
+	case pendiente != "":
+		// Se adjuntó un archivo nuevo: el pendiente anterior ya no se usará.
+		_ = h.Archivos.Eliminar(pendiente)
+	}
+	if archivo == "" {
+		http.Error(w, "Debe adjuntar el archivo del libro", http.StatusBadRequest)
+		return
+	}
+	libro.Archivo = archivo
+
+	// Metadatos del archivo (si el formato los admite).
+	m, err := leerMetadatosArchivo(h.Archivos, archivo, libro.Formato)
+	if err == nil {
+		switch {
+		case r.FormValue("usar_metadatos") == "1":
+			// El operador eligió los valores del archivo para los campos en conflicto.
+			aplicarMetadatos(&libro, m, true)
+		case r.FormValue("confirmar_conflictos") == "1":
+			// El operador confirmó sus valores: solo se completan los vacíos.
+			aplicarMetadatos(&libro, m, false)
+		default:
+			aplicarMetadatos(&libro, m, false)
+			if conflictos := metadatos.Comparar(libro.Titulo, libro.Autor, libro.AnioPublicacion, m); len(conflictos) > 0 {
+				h.mostrarConflictos(w, r, libro, conflictos)
+				return
+			}
+		}
+	}
+
+	// El alta y su entrada de auditoría se guardan juntas.
+	err = h.Libros.Crear(&libro, autorCambio(r))
+	if err != nil {
+		// Si no se pudo guardar el registro, el archivo queda huérfano: se elimina.
+		_ = h.Archivos.Eliminar(archivo)
+		http.Error(w, "Error al guardar libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/?msg=Libro+creado+correctamente", http.StatusSeeOther)
+}
+
+// mostrarConflictos vuelve a renderizar nuevo.html con las diferencias encontradas.
+// El archivo ya guardado viaja como pendiente para no tener que subirlo otra vez.
+func (h *LibroHandler) mostrarConflictos(w http.ResponseWriter, r *http.Request, libro models.Libro, conflictos []metadatos.Conflicto) {
+	data := formularioNuevoLibro{
+		Libro:            libro,
+		Conflictos:       conflictos,
+		ArchivoPendiente: libro.Archivo,
+		CSRF:             TokenCSRF(r),
+	}
+	err := h.Templates.ExecuteTemplate(w, "nuevo.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla nuevo.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// validarArchivoPendiente acepta la clave de un archivo subido en un intento anterior.
+// Solo se aceptan claves generadas por el sistema, no asignadas a ningún libro.
+func (h *LibroHandler) validarArchivoPendiente(clave, formato string) (string, error) {
+	if clave == "" {
+		return "", nil
+	}
+	if !claveArchivoValida(clave, formato) {
+		return "", fmt.Errorf("%w: archivo pendiente desconocido", ErrArchivoInvalido)
+	}
+
+	asignado, err := h.Libros.ArchivoAsignado(clave)
+	if err != nil {
+		return "", err
+	}
+	if asignado {
+		return "", fmt.Errorf("%w: el archivo pendiente ya está asignado", ErrArchivoInvalido)
+	}
+
+	objeto, err := h.Archivos.Abrir(clave)
+	if err != nil {
+		return "", fmt.Errorf("%w: el archivo pendiente ya no existe", ErrArchivoInvalido)
+	}
+	objeto.Close()
+	return clave, nil
+}
+
+// leerFormularioLibro lee y valida los campos comunes de nuevo.html y editar.html.
+// Si hay errores responde 400 y devuelve false.
+func leerFormularioLibro(w http.ResponseWriter, r *http.Request) (models.Libro, bool) {
+	libro := models.Libro{
+		Titulo:    strings.TrimSpace(r.FormValue("titulo")),
+		Autor:     strings.TrimSpace(r.FormValue("autor")),
+		Categoria: strings.TrimSpace(r.FormValue("categoria")),
+		Formato:   strings.ToUpper(strings.TrimSpace(r.FormValue("formato"))),
+		Idioma:    strings.TrimSpace(r.FormValue("idioma")),
+		Editorial: strings.TrimSpace(r.FormValue("editorial")),
+	}
+
+	var err error
+	libro.AnioPublicacion, err = strconv.Atoi(r.FormValue("anio_publicacion"))
+	if err != nil {
+		http.Error(w, "Año de publicación inválido", http.StatusBadRequest)
+		return libro, false
+	}
+
+	libro.LicenciasTotales, err = strconv.Atoi(r.FormValue("licencias_totales"))
+	if err != nil || libro.LicenciasTotales < 0 {
+		http.Error(w, "Cantidad de licencias inválida", http.StatusBadRequest)
+		return libro, false
+	}
+
+	if libro.Titulo == "" || libro.Autor == "" || libro.Categoria == "" || libro.Formato == "" {
+		http.Error(w, "Todos los campos son obligatorios", http.StatusBadRequest)
+		return libro, false
+	}
+
+	// El ISBN es opcional, pero si se escribe debe ser válido.
+	if isbn := strings.TrimSpace(r.FormValue("isbn")); isbn != "" {
+		libro.ISBN = metadatos.NormalizarISBN(isbn)
+		if libro.ISBN == "" {
+			http.Error(w, "ISBN inválido", http.StatusBadRequest)
+			return libro, false
+		}
+	}
+
+	return libro, true
+}
+
+// aplicarMetadatos completa el libro con los metadatos del archivo.
+// Con reemplazar=true también sobrescribe título, autor y año escritos.
+func aplicarMetadatos(libro *models.Libro, m metadatos.Metadatos, reemplazar bool) {
+	if m.Titulo != "" && (reemplazar || libro.Titulo == "") {
+		libro.Titulo = m.Titulo
+	}
+	if m.Autor != "" && (reemplazar || libro.Autor == "") {
+		libro.Autor = m.Autor
+	}
+	if m.AnioPublicacion != 0 && (reemplazar || libro.AnioPublicacion == 0) {
+		libro.AnioPublicacion = m.AnioPublicacion
+	}
+	if libro.Idioma == "" {
+		libro.Idioma = m.Idioma
+	}
+	if libro.Editorial == "" {
+		libro.Editorial = m.Editorial
+	}
+	if libro.ISBN == "" {
+		libro.ISBN = m.ISBN
+	}
+	// La cantidad de páginas no se escribe a mano: siempre viene del archivo.
+	libro.Paginas = m.Paginas
+}
+
+// EditarLibroForm muestra formulario de edición.
+func (h *LibroHandler) EditarLibroForm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idStr := r.URL.Query().Get("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	libro, err := h.Libros.Obtener(id)
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			http.Error(w, "Libro no encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error al consultar libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// editar.html usa los campos del libro directamente, más el token CSRF.
+	data := struct {
+		models.Libro
+		CSRF string
+	}{
+		Libro: libro,
+		CSRF:  TokenCSRF(r),
+	}
+	err = h.Templates.ExecuteTemplate(w, "editar.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla editar.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// ActualizarLibro actualiza un libro existente.
+func (h *LibroHandler) ActualizarLibro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// El formulario es multipart porque puede incluir un archivo nuevo.
+	r.Body = http.MaxBytesReader(w, r.Body, tamanoMaximoLibro+(1<<20))
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	libro, ok := leerFormularioLibro(w, r)
+	if !ok {
+		return
+	}
+	libro.ID = id
+
+	// Datos actuales del archivo para decidir si se reemplaza.
+	actual, err := h.Libros.Obtener(id)
+	if err != nil {
+		if err == libros.ErrNoExiste {
+			http.Error(w, "Libro no encontrado", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error al consultar libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Guarda el archivo nuevo si se adjuntó uno (opcional al editar).
+	archivoNuevo, err := guardarArchivoLibro(h.Archivos, r, libro.Formato)
+	if err != nil {
+		responderErrorArchivo(w, err)
+		return
+	}
+
+	// Si cambia el formato, el archivo existente ya no corresponde.
+	if archivoNuevo == "" && actual.Archivo != "" && !strings.EqualFold(libro.Formato, actual.Formato) {
+		http.Error(w, "Debe adjuntar un archivo nuevo al cambiar el formato", http.StatusBadRequest)
+		return
+	}
+
+	if archivoNuevo != "" {
+		libro.Archivo = archivoNuevo
+
+		// La cantidad de páginas se vuelve a leer del archivo nuevo.
+		if m, err := leerMetadatosArchivo(h.Archivos, archivoNuevo, libro.Formato); err == nil {
+			libro.Paginas = m.Paginas
+		}
+	}
+
+	// El cambio y su entrada de auditoría se guardan juntos; las páginas solo se
+	// reemplazan si cambió el archivo.
+	_, err = h.Libros.Actualizar(libro, archivoNuevo != "", autorCambio(r))
+	if err != nil {
+		if archivoNuevo != "" {
+			_ = h.Archivos.Eliminar(archivoNuevo)
+		}
+		if err == libros.ErrLicenciasEnUso {
+			http.Error(w, "No se puede bajar el total de licencias por debajo de las prestadas o apartadas", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error al actualizar libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// El archivo reemplazado ya no se usa.
+	if archivoNuevo != "" && actual.Archivo != "" {
+		_ = h.Archivos.Eliminar(actual.Archivo)
+	}
+
+	http.Redirect(w, r, "/?msg=Libro+actualizado+correctamente", http.StatusSeeOther)
+}
+
+// EliminarLibro elimina un libro por ID.
+func (h *LibroHandler) EliminarLibro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Se borra el registro (con su entrada de auditoría) y se obtiene cómo era, para borrar su archivo.
+	eliminado, err := h.Libros.Eliminar(id, autorCambio(r))
+	if err == libros.ErrLibroEnUso {
+		http.Error(w, "No se puede eliminar un libro con préstamos activos o reservas pendientes", http.StatusConflict)
+		return
+	}
+	if err != nil && err != libros.ErrNoExiste {
+		http.Error(w, "Error al eliminar libro: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if eliminado.Archivo != "" {
+		_ = h.Archivos.Eliminar(eliminado.Archivo)
+	}
+
+	http.Redirect(w, r, "/?msg=Libro+eliminado+correctamente", http.StatusSeeOther)
+}
@@ -0,0 +1,70 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"net/url"        // Paquete para armar los enlaces de cada página.
+	"sistema/libros" // Páginas de libros.
+	"strconv"        // Paquete para leer el tamaño de página.
+	"strings"        // Paquete para limpiar el parámetro.
+)
+
+// Límites de paginación de los listados (pantallas y API).
+const (
+	porPaginaDefecto = 20  // Cantidad de libros por página si no se indica.
+	porPaginaMaxima  = 100 // Máximo permitido para evitar respuestas enormes.
+)
+
+// paginacion son los enlaces para moverse entre páginas de un listado. Los
+// enlaces vacíos no se muestran.
+type paginacion struct {
+	Primera   string // Enlace a la primera página ("" si ya se está en ella).
+	Anterior  string // Enlace a la página anterior.
+	Siguiente string // Enlace a la página siguiente.
+}
+
+// nuevaPaginacion arma los enlaces de la página: la ruta con los parámetros
+// actuales más el cursor de cada dirección.
+func nuevaPaginacion(ruta string, actuales url.Values, p libros.Pagina) paginacion {
+	var pag paginacion
+	if p.Anterior != "" {
+		pag.Primera = enlace(ruta, actuales)
+		pag.Anterior = enlace(ruta, actuales, "cursor", p.Anterior)
+	}
+	if p.Siguiente != "" {
+		pag.Siguiente = enlace(ruta, actuales, "cursor", p.Siguiente)
+	}
+	return pag
+}
+
+// leerPorPagina lee el parámetro por_pagina; si no viene, devuelve defecto.
+// Devuelve false si no es un entero entre 1 y porPaginaMaxima.
+func leerPorPagina(valor string, defecto int) (int, bool) {
+	valor = strings.TrimSpace(valor)
+	if valor == "" {
+		return defecto, true
+	}
+	n, err := strconv.Atoi(valor)
+	if err != nil || n < 1 || n > porPaginaMaxima {
+		return 0, false
+	}
+	return n, true
+}
+
+// enlace devuelve la ruta con los parámetros actuales y los cambios indicados
+// en pares nombre, valor (un valor vacío quita el parámetro).
+func enlace(ruta string, actuales url.Values, cambios ...string) string {
+	q := url.Values{}
+	for nombre, valores := range actuales {
+		q[nombre] = valores
+	}
+	for i := 0; i+1 < len(cambios); i += 2 {
+		if cambios[i+1] == "" {
+			q.Del(cambios[i])
+		} else {
+			q.Set(cambios[i], cambios[i+1])
+		}
+	}
+	if len(q) == 0 {
+		return ruta
+	}
+	return ruta + "?" + q.Encode()
+}
@@ -0,0 +1,105 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"    // Paquete para renderizar plantillas HTML.
+	"net/http"         // Paquete para rutas y respuestas HTTP.
+	"net/url"          // Paquete para codificar mensajes en la URL.
+	"sistema/models"   // Estructuras Permiso y Rol.
+	"sistema/permisos" // Asignación de permisos a roles.
+	"strconv"          // Paquete para armar los nombres de los campos.
+	"strings"          // Paquete para limpiar texto.
+)
+
+// PermisoHandler muestra y guarda la asignación de permisos a roles.
+type PermisoHandler struct {
+	Templates *template.Template // Plantillas HTML cargadas.
+	Permisos  *permisos.Servicio // Servicio de permisos.
+}
+
+// NuevoPermisoHandler crea una nueva instancia del handler de permisos.
+func NuevoPermisoHandler(templates *template.Template, servicio *permisos.Servicio) *PermisoHandler {
+	return &PermisoHandler{
+		Templates: templates,
+		Permisos:  servicio,
+	}
+}
+
+// VerPermisos muestra la tabla de permisos por rol.
+// Ruta: GET /admin/permisos
+func (h *PermisoHandler) VerPermisos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roles, asignados, err := h.Permisos.Asignacion()
+	if err != nil {
+		http.Error(w, "Error al consultar permisos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Permisos      []models.Permiso        // Permisos en orden de pantalla.
+		Roles         []models.Rol            // Columnas de la tabla.
+		Asignados     map[int]map[string]bool // Permisos marcados por ID de rol.
+		Mensaje       string                  // Resultado de la última acción.
+		UsuarioNombre string                  // Usuario actual.
+		UsuarioRol    string                  // Rol actual.
+		CSRF          string                  // Token CSRF de los formularios.
+	}{
+		Permisos:      permisos.Catalogo,
+		Roles:         roles,
+		Asignados:     asignados,
+		Mensaje:       strings.TrimSpace(r.URL.Query().Get("msg")),
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+		CSRF:          TokenCSRF(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "admin_permisos.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla admin_permisos.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GuardarPermisos reemplaza los permisos de todos los roles. Cada casilla marcada
+// llega como permiso_<id_rol>=<permiso>; un rol sin casillas queda sin permisos.
+// Ruta: POST /admin/permisos/guardar
+func (h *PermisoHandler) GuardarPermisos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	roles, _, err := h.Permisos.Asignacion()
+	if err != nil {
+		http.Error(w, "Error al consultar roles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	asignados := map[int][]string{}
+	for _, rol := range roles {
+		asignados[rol.IDRol] = r.PostForm["permiso_"+strconv.Itoa(rol.IDRol)]
+	}
+
+	err = h.Permisos.Guardar(asignados)
+	switch err {
+	case nil:
+		h.volver(w, r, "Permisos guardados: rigen de inmediato para todas las sesiones")
+	case permisos.ErrPermisoNoExiste, permisos.ErrRolNoExiste, permisos.ErrSinAdministrador:
+		h.volver(w, r, "No se guardaron los cambios: "+err.Error())
+	default:
+		http.Error(w, "Error al guardar permisos: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// volver redirige a la pantalla de permisos con un mensaje.
+func (h *PermisoHandler) volver(w http.ResponseWriter, r *http.Request, mensaje string) {
+	http.Redirect(w, r, "/admin/permisos?msg="+url.QueryEscape(mensaje), http.StatusSeeOther)
+}
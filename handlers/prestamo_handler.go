@@ -0,0 +1,202 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"fmt"               // Paquete para armar URLs de redirección.
+	"html/template"     // Paquete para renderizar plantillas HTML.
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"net/url"           // Paquete para codificar mensajes en la URL.
+	"sistema/models"    // Estructura Prestamo.
+	"sistema/prestamos" // Servicio de préstamos.
+	"strconv"           // Paquete para convertir string a int.
+	"strings"           // Paquete para limpiar texto.
+)
+
+// PrestamoHandler maneja el préstamo, la devolución y la lista de espera de libros
+// por parte de los lectores.
+type PrestamoHandler struct {
+	Templates *template.Template  // Plantillas HTML cargadas.
+	Prestamos *prestamos.Servicio // Servicio de préstamos.
+}
+
+// NuevoPrestamoHandler crea una nueva instancia del handler de préstamos.
+func NuevoPrestamoHandler(templates *template.Template, servicio *prestamos.Servicio) *PrestamoHandler {
+	return &PrestamoHandler{
+		Templates: templates,
+		Prestamos: servicio,
+	}
+}
+
+// VerPrestamos muestra los préstamos vigentes y las reservas en curso del usuario.
+// Ruta: GET /prestamos
+func (h *PrestamoHandler) VerPrestamos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	lista, err := h.Prestamos.ListarActivos(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar préstamos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reservas, err := h.Prestamos.ListarReservas(sesion.IDUsuario)
+	if err != nil {
+		http.Error(w, "Error al consultar reservas: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Prestamos     []models.Prestamo // Préstamos vigentes.
+		Reservas      []models.Reserva  // Reservas en la lista de espera.
+		UsuarioNombre string            // Usuario actual.
+		UsuarioRol    string            // Rol actual.
+		CSRF          string            // Token CSRF de los formularios.
+		Mensaje       string            // Resultado de la última acción.
+	}{
+		Prestamos:     lista,
+		Reservas:      reservas,
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+		CSRF:          TokenCSRF(r),
+		Mensaje:       strings.TrimSpace(r.URL.Query().Get("msg")),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "mis_prestamos.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla mis_prestamos.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// PrestarLibro reserva una licencia del libro para el usuario.
+// Ruta: POST /prestamos/prestar (id_libro)
+func (h *PrestamoHandler) PrestarLibro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idLibro, err := strconv.Atoi(r.FormValue("id_libro"))
+	if err != nil {
+		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	prestamo, err := h.Prestamos.Prestar(sesion.IDUsuario, idLibro)
+
+	var mensaje string
+	switch err {
+	case nil:
+		mensaje = "Préstamo registrado hasta el " + prestamo.VenceEn.Format("02/01/2006 15:04")
+	case prestamos.ErrLibroNoExiste:
+		http.Error(w, "Libro no encontrado", http.StatusNotFound)
+		return
+	case prestamos.ErrSinLicencias:
+		mensaje = "No hay licencias disponibles: puede unirse a la lista de espera"
+	case prestamos.ErrYaPrestado:
+		mensaje = "Ya tiene este libro prestado"
+	default:
+		http.Error(w, "Error al registrar préstamo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	destino := fmt.Sprintf("/catalogo/detalle?id=%d&msg=%s", idLibro, url.QueryEscape(mensaje))
+	http.Redirect(w, r, destino, http.StatusSeeOther)
+}
+
+// DevolverPrestamo devuelve la licencia antes del vencimiento.
+// Ruta: POST /prestamos/devolver (id)
+func (h *PrestamoHandler) DevolverPrestamo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de préstamo inválido", http.StatusBadRequest)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	err = h.Prestamos.Devolver(id, sesion.IDUsuario)
+	if err != nil {
+		if err == prestamos.ErrPrestamoNoExiste {
+			http.Redirect(w, r, "/prestamos?msg=El+préstamo+ya+no+está+activo", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error al devolver préstamo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/prestamos?msg=Libro+devuelto+correctamente", http.StatusSeeOther)
+}
+
+// ReservarLibro pone al usuario en la lista de espera de un libro sin licencias.
+// Ruta: POST /prestamos/reservar (id_libro)
+func (h *PrestamoHandler) ReservarLibro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idLibro, err := strconv.Atoi(r.FormValue("id_libro"))
+	if err != nil {
+		http.Error(w, "ID de libro inválido", http.StatusBadRequest)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	reserva, err := h.Prestamos.Reservar(sesion.IDUsuario, idLibro)
+
+	var mensaje string
+	switch err {
+	case nil:
+		mensaje = fmt.Sprintf("Está en la lista de espera (posición %d)", reserva.Posicion)
+	case prestamos.ErrLibroNoExiste:
+		http.Error(w, "Libro no encontrado", http.StatusNotFound)
+		return
+	case prestamos.ErrHayLicencias:
+		mensaje = "El libro tiene licencias disponibles: puede pedirlo prestado"
+	case prestamos.ErrYaPrestado:
+		mensaje = "Ya tiene este libro prestado"
+	case prestamos.ErrYaReservado:
+		mensaje = "Ya está en la lista de espera de este libro"
+	default:
+		http.Error(w, "Error al registrar reserva: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	destino := fmt.Sprintf("/catalogo/detalle?id=%d&msg=%s", idLibro, url.QueryEscape(mensaje))
+	http.Redirect(w, r, destino, http.StatusSeeOther)
+}
+
+// CancelarReserva saca al usuario de la lista de espera.
+// Ruta: POST /prestamos/cancelar-reserva (id)
+func (h *PrestamoHandler) CancelarReserva(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de reserva inválido", http.StatusBadRequest)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	err = h.Prestamos.CancelarReserva(id, sesion.IDUsuario)
+	if err != nil {
+		if err == prestamos.ErrReservaNoExiste {
+			http.Redirect(w, r, "/prestamos?msg=La+reserva+ya+no+está+activa", http.StatusSeeOther)
+			return
+		}
+		http.Error(w, "Error al cancelar reserva: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/prestamos?msg=Reserva+cancelada", http.StatusSeeOther)
+}
@@ -0,0 +1,135 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"     // Paquete para renderizar plantillas HTML.
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"sistema/seguridad" // Errores de validación de claves.
+	"sistema/usuarios"  // Recuperación de claves.
+	"strings"           // Paquete para limpiar texto.
+)
+
+// RecuperacionHandler atiende el pedido y el cambio de clave por enlace enviado al correo.
+type RecuperacionHandler struct {
+	Templates *template.Template // Plantillas HTML cargadas.
+	Usuarios  *usuarios.Servicio // Servicio de usuarios.
+}
+
+// NuevoRecuperacionHandler crea una nueva instancia del handler de recuperación de clave.
+func NuevoRecuperacionHandler(templates *template.Template, servicio *usuarios.Servicio) *RecuperacionHandler {
+	return &RecuperacionHandler{
+		Templates: templates,
+		Usuarios:  servicio,
+	}
+}
+
+// MostrarRecuperar renderiza el formulario para pedir el enlace.
+// Ruta: GET /recuperar
+func (h *RecuperacionHandler) MostrarRecuperar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data := struct {
+		CSRF string // Token CSRF del formulario.
+	}{
+		CSRF: TokenCSRF(r),
+	}
+	err := h.Templates.ExecuteTemplate(w, "recuperar.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla recuperar.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// EnviarEnlace envía el enlace de recuperación si el correo tiene una cuenta activa.
+// Ruta: POST /recuperar/enviar (correo)
+func (h *RecuperacionHandler) EnviarEnlace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	err = h.Usuarios.SolicitarRecuperacion(strings.TrimSpace(r.FormValue("correo")))
+	if err != nil {
+		http.Error(w, "Error al enviar enlace de recuperación: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Mismo mensaje exista o no el correo, para no revelar cuentas registradas.
+	http.Redirect(w, r, "/login?error=Si+el+correo+corresponde+a+una+cuenta+activa,+le+enviamos+un+enlace+para+cambiar+la+contraseña", http.StatusSeeOther)
+}
+
+// formularioNuevaClave son los datos de la plantilla nueva_clave.html.
+type formularioNuevaClave struct {
+	Codigo string // Código del enlace (viaja oculto en el formulario).
+	Error  string // Motivo por el que no se pudo cambiar la clave.
+	CSRF   string // Token CSRF del formulario.
+}
+
+// MostrarNuevaClave renderiza el formulario de clave nueva si el enlace sigue vigente.
+// Ruta: GET /recuperar/clave?codigo=
+func (h *RecuperacionHandler) MostrarNuevaClave(w http.ResponseWriter, r *http.Request) {
+	codigo := strings.TrimSpace(r.URL.Query().Get("codigo"))
+	vigente, err := h.Usuarios.CodigoRecuperacionVigente(codigo)
+	if err != nil {
+		http.Error(w, "Error al validar enlace: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !vigente {
+		http.Redirect(w, r, "/login?error=El+enlace+de+recuperación+no+es+válido+o+ya+venció", http.StatusSeeOther)
+		return
+	}
+
+	h.renderizarNuevaClave(w, r, formularioNuevaClave{Codigo: codigo})
+}
+
+// GuardarNuevaClave cambia la clave con el enlace y cierra todas las sesiones del usuario.
+// Ruta: POST /recuperar/clave (codigo, clave, confirmar)
+func (h *RecuperacionHandler) GuardarNuevaClave(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	form := formularioNuevaClave{Codigo: strings.TrimSpace(r.FormValue("codigo"))}
+	clave := r.FormValue("clave")
+	if clave != r.FormValue("confirmar") {
+		form.Error = "Las claves no coinciden"
+		h.renderizarNuevaClave(w, r, form)
+		return
+	}
+
+	err = h.Usuarios.RecuperarClave(form.Codigo, clave)
+	switch err {
+	case nil:
+		http.Redirect(w, r, "/login?error=Contraseña+actualizada:+inicie+sesión+con+la+clave+nueva", http.StatusSeeOther)
+	case seguridad.ErrClaveCorta:
+		form.Error = "La clave debe tener al menos 8 caracteres"
+		h.renderizarNuevaClave(w, r, form)
+	case seguridad.ErrClaveLarga:
+		form.Error = "La clave es demasiado larga (máximo 72 caracteres; los acentos cuentan doble)"
+		h.renderizarNuevaClave(w, r, form)
+	case usuarios.ErrCodigoInvalido:
+		http.Redirect(w, r, "/login?error=El+enlace+de+recuperación+no+es+válido+o+ya+venció", http.StatusSeeOther)
+	default:
+		http.Error(w, "Error al cambiar clave: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// renderizarNuevaClave muestra nueva_clave.html con los datos del formulario y su token CSRF.
+func (h *RecuperacionHandler) renderizarNuevaClave(w http.ResponseWriter, r *http.Request, form formularioNuevaClave) {
+	form.CSRF = TokenCSRF(r)
+	err := h.Templates.ExecuteTemplate(w, "nueva_clave.html", form)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla nueva_clave.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
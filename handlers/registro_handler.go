@@ -0,0 +1,117 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"     // Paquete para renderizar plantillas HTML.
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"sistema/seguridad" // Errores de validación de claves.
+	"sistema/usuarios"  // Registro y verificación de cuentas.
+	"strings"           // Paquete para limpiar texto.
+)
+
+// RegistroHandler atiende el registro de lectores y la verificación del correo.
+type RegistroHandler struct {
+	Templates *template.Template // Plantillas HTML cargadas.
+	Usuarios  *usuarios.Servicio // Servicio de usuarios.
+}
+
+// NuevoRegistroHandler crea una nueva instancia del handler de registro.
+func NuevoRegistroHandler(templates *template.Template, servicio *usuarios.Servicio) *RegistroHandler {
+	return &RegistroHandler{
+		Templates: templates,
+		Usuarios:  servicio,
+	}
+}
+
+// formularioRegistro son los datos de la plantilla registro.html.
+type formularioRegistro struct {
+	Nombre string // Nombre escrito (se conserva si hay error).
+	Correo string // Correo escrito (se conserva si hay error).
+	Error  string // Motivo por el que no se pudo registrar.
+	CSRF   string // Token CSRF del formulario.
+}
+
+// MostrarRegistro renderiza el formulario de registro.
+// Ruta: GET /registro
+func (h *RegistroHandler) MostrarRegistro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+	h.renderizar(w, r, formularioRegistro{})
+}
+
+// ProcesarRegistro crea la cuenta pendiente y envía el enlace de verificación.
+// Ruta: POST /registro/procesar (nombre, correo, clave, confirmar)
+func (h *RegistroHandler) ProcesarRegistro(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+		return
+	}
+
+	form := formularioRegistro{
+		Nombre: strings.TrimSpace(r.FormValue("nombre")),
+		Correo: strings.TrimSpace(r.FormValue("correo")),
+	}
+	clave := r.FormValue("clave")
+	if clave != r.FormValue("confirmar") {
+		form.Error = "Las claves no coinciden"
+		h.renderizar(w, r, form)
+		return
+	}
+
+	err = h.Usuarios.Registrar(form.Nombre, form.Correo, clave)
+	switch err {
+	case nil:
+		// Mismo mensaje exista o no el correo, para no revelar cuentas registradas.
+		http.Redirect(w, r, "/login?error=Le+enviamos+un+enlace+para+activar+la+cuenta:+revise+su+correo", http.StatusSeeOther)
+	case usuarios.ErrNombreVacio:
+		form.Error = "El nombre es obligatorio"
+		h.renderizar(w, r, form)
+	case usuarios.ErrCorreoInvalido:
+		form.Error = "El correo no es válido"
+		h.renderizar(w, r, form)
+	case seguridad.ErrClaveCorta:
+		form.Error = "La clave debe tener al menos 8 caracteres"
+		h.renderizar(w, r, form)
+	case seguridad.ErrClaveLarga:
+		form.Error = "La clave es demasiado larga (máximo 72 caracteres; los acentos cuentan doble)"
+		h.renderizar(w, r, form)
+	default:
+		http.Error(w, "Error al registrar usuario: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// VerificarCorreo activa la cuenta con el enlace recibido por correo.
+// Ruta: GET /registro/verificar?codigo=
+func (h *RegistroHandler) VerificarCorreo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := h.Usuarios.VerificarCorreo(strings.TrimSpace(r.URL.Query().Get("codigo")))
+	switch err {
+	case nil:
+		http.Redirect(w, r, "/login?error=Cuenta+verificada:+ya+puede+iniciar+sesión", http.StatusSeeOther)
+	case usuarios.ErrCodigoInvalido:
+		http.Redirect(w, r, "/login?error=El+enlace+de+verificación+no+es+válido+o+ya+venció", http.StatusSeeOther)
+	default:
+		http.Error(w, "Error al verificar correo: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// renderizar muestra registro.html con los datos del formulario y su token CSRF.
+func (h *RegistroHandler) renderizar(w http.ResponseWriter, r *http.Request, form formularioRegistro) {
+	form.CSRF = TokenCSRF(r)
+	err := h.Templates.ExecuteTemplate(w, "registro.html", form)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla registro.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"html/template"     // Paquete para cargar registro.html.
+	"net/http"          // Paquete para códigos y métodos HTTP.
+	"net/http/httptest" // Paquete para simular pedidos.
+	"net/url"           // Paquete para armar formularios y leer redirecciones.
+	"regexp"            // Paquete para encontrar el enlace en el correo.
+	"sistema/correo"    // Enviador falso con bandeja en memoria.
+	"sistema/models"    // Estados de usuario.
+	"sistema/seguridad" // Hash de los códigos.
+	"sistema/usuarios"  // Servicio de registro.
+	"strings"           // Paquete para armar cuerpos y revisar respuestas.
+	"testing"           // Paquete de pruebas.
+
+	"github.com/DATA-DOG/go-sqlmock" // Base de datos simulada.
+)
+
+// enlaceVerificacion encuentra el enlace de verificación en un correo.
+var enlaceVerificacion = regexp.MustCompile(`https://biblioteca\.test(/registro/verificar\?codigo=\S+)`)
+
+// nuevoRegistroPrueba crea el handler con la base simulada y el correo en memoria.
+func nuevoRegistroPrueba(t *testing.T) (*RegistroHandler, sqlmock.Sqlmock, *correo.Memoria) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	templates := template.Must(template.New("").Funcs(template.FuncMap{
+		"puede": func(string, string) bool { return false },
+	}).ParseFiles("../templates/registro.html"))
+
+	bandeja := correo.NuevoMemoria()
+	servicio := usuarios.NuevoServicio(db, nil, nil, bandeja)
+	servicio.URLBase = "https://biblioteca.test"
+	return NuevoRegistroHandler(templates, servicio), mock, bandeja
+}
+
+// registrar envía el formulario de registro y devuelve la respuesta.
+func registrar(h *RegistroHandler, nombre, direccion, clave, confirmar string) *httptest.ResponseRecorder {
+	form := url.Values{"nombre": {nombre}, "correo": {direccion}, "clave": {clave}, "confirmar": {confirmar}}
+	r := httptest.NewRequest(http.MethodPost, "/registro/procesar", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ProcesarRegistro(w, r)
+	return w
+}
+
+// verificar abre el enlace de verificación y devuelve la respuesta.
+func verificar(h *RegistroHandler, enlace string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.VerificarCorreo(w, httptest.NewRequest(http.MethodGet, enlace, nil))
+	return w
+}
+
+// mensajeLogin devuelve el aviso con que la respuesta redirige a /login.
+func mensajeLogin(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("código %d, se esperaba una redirección: %s", w.Code, w.Body.String())
+	}
+	destino, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || destino.Path != "/login" {
+		t.Fatalf("redirección inesperada: %q", w.Header().Get("Location"))
+	}
+	return destino.Query().Get("error")
+}
+
+// esperarRoles prepara la búsqueda del rol de lector (ID 3).
+func esperarRoles(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT id_rol FROM roles WHERE nombre_rol`).
+		WillReturnRows(sqlmock.NewRows([]string{"id_rol"}).AddRow(3))
+	mock.ExpectQuery(`SELECT nombre_rol FROM roles WHERE id_rol`).
+		WillReturnRows(sqlmock.NewRows([]string{"nombre_rol"}).AddRow("CONSULTA"))
+}
+
+// esperarEmision prepara la emisión de un código para el usuario 7.
+func esperarEmision(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE codigos_correo SET usado_en`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO codigos_correo`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestRegistroYVerificacionPorHTTP(t *testing.T) {
+	h, mock, bandeja := nuevoRegistroPrueba(t)
+
+	esperarRoles(mock)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM usuarios WHERE correo`).
+		WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(0))
+	mock.ExpectExec(`INSERT INTO usuarios`).
+		WithArgs("Ana", "ana@correo.test", sqlmock.AnyArg(), 3, models.EstadoPendiente).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	esperarEmision(mock)
+
+	w := registrar(h, "Ana", "ana@correo.test", "clave-segura-1", "clave-segura-1")
+	if aviso := mensajeLogin(t, w); !strings.Contains(aviso, "revise su correo") {
+		t.Errorf("aviso inesperado tras registrarse: %q", aviso)
+	}
+
+	msg, ok := bandeja.Ultimo("ana@correo.test")
+	if !ok {
+		t.Fatal("no se envió el correo de verificación")
+	}
+	m := enlaceVerificacion.FindStringSubmatch(msg.Texto)
+	if m == nil {
+		t.Fatalf("el correo no tiene el enlace de verificación:\n%s", msg.Texto)
+	}
+	enlace, _ := url.Parse(m[1])
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, id_usuario FROM codigos_correo`).
+		WithArgs(seguridad.HashCodigo(enlace.Query().Get("codigo")), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_usuario"}).AddRow(1, 7))
+	mock.ExpectExec(`UPDATE codigos_correo SET usado_en`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE usuarios SET estado`).
+		WithArgs(models.EstadoActivo, 7, models.EstadoPendiente).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if aviso := mensajeLogin(t, verificar(h, m[1])); !strings.Contains(aviso, "Cuenta verificada") {
+		t.Errorf("aviso inesperado tras verificar: %q", aviso)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistroCorreoDuplicadoPorHTTP(t *testing.T) {
+	h, mock, bandeja := nuevoRegistroPrueba(t)
+
+	esperarRoles(mock)
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM usuarios WHERE correo`).
+		WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	mock.ExpectQuery(`SELECT id_usuario, nombre, correo, estado FROM usuarios WHERE correo`).
+		WillReturnRows(sqlmock.NewRows([]string{"id_usuario", "nombre", "correo", "estado"}).AddRow(7, "Ana", "ana@correo.test", models.EstadoActivo))
+
+	// La respuesta es la misma que la de un alta nueva.
+	w := registrar(h, "Ana", "ana@correo.test", "clave-segura-1", "clave-segura-1")
+	if aviso := mensajeLogin(t, w); !strings.Contains(aviso, "revise su correo") {
+		t.Errorf("aviso inesperado: %q", aviso)
+	}
+	if msg, ok := bandeja.Ultimo("ana@correo.test"); !ok || enlaceVerificacion.MatchString(msg.Texto) {
+		t.Errorf("se esperaba solo el aviso de cuenta existente, se envió: %+v", msg)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistroClavesDistintas(t *testing.T) {
+	h, mock, bandeja := nuevoRegistroPrueba(t)
+
+	w := registrar(h, "Ana", "ana@correo.test", "clave-segura-1", "clave-segura-2")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Las claves no coinciden") {
+		t.Errorf("código %d, se esperaba el formulario con el error:\n%s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "ana@correo.test") {
+		t.Error("el formulario no conserva el correo escrito")
+	}
+	if n := len(bandeja.Enviados()); n != 0 {
+		t.Errorf("se enviaron %d correos", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerificacionEnlaceVencidoPorHTTP(t *testing.T) {
+	h, mock, _ := nuevoRegistroPrueba(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, id_usuario FROM codigos_correo`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_usuario"}))
+	mock.ExpectRollback()
+
+	if aviso := mensajeLogin(t, verificar(h, "/registro/verificar?codigo=vencido")); !strings.Contains(aviso, "ya venció") {
+		t.Errorf("aviso inesperado: %q", aviso)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,249 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"database/sql"        // Paquete para trabajar con SQL.
+	"encoding/json"       // Paquete para leer cuerpos JSON.
+	"math"                // Paquete para redondear la espera en segundos.
+	"net/http"            // Paquete para rutas y respuestas HTTP.
+	"sistema/doblefactor" // Segundo factor TOTP.
+	"sistema/intentos"    // Límite de intentos y bloqueo de cuentas.
+	"sistema/permisos"    // Política de segundo factor obligatorio por rol.
+	"sistema/tokens"      // Servicio de tokens Bearer y claves de API.
+	"strconv"             // Paquete para convertir parámetros a enteros.
+	"strings"             // Paquete para limpiar textos.
+	"time"                // Paquete para la espera de los bloqueos.
+)
+
+// TokenAPIHandler expone el intercambio de credenciales por tokens
+// y la administración de claves de API.
+type TokenAPIHandler struct {
+	DB          *sql.DB               // Conexión a la base de datos.
+	Tokens      *tokens.Servicio      // Servicio de tokens.
+	Intentos    *intentos.Servicio    // Límite de intentos fallidos.
+	DobleFactor *doblefactor.Servicio // Segundo factor TOTP.
+	Permisos    *permisos.Servicio    // Política de segundo factor por rol.
+}
+
+// NuevoTokenAPIHandler crea una nueva instancia del handler de tokens.
+func NuevoTokenAPIHandler(db *sql.DB, servicio *tokens.Servicio, servicioIntentos *intentos.Servicio, servicioDobleFactor *doblefactor.Servicio, servicioPermisos *permisos.Servicio) *TokenAPIHandler {
+	return &TokenAPIHandler{
+		DB:          db,
+		Tokens:      servicio,
+		Intentos:    servicioIntentos,
+		DobleFactor: servicioDobleFactor,
+		Permisos:    servicioPermisos,
+	}
+}
+
+// EmitirToken canjea correo + clave por un token de acceso y uno de refresco.
+// Si el usuario tiene el segundo factor activo también debe enviar "codigo"
+// (TOTP o de recuperación); si su rol lo exige y no lo activó, debe activarlo
+// antes desde la web.
+// Ruta: POST /api/v1/auth/token
+func (h *TokenAPIHandler) EmitirToken(w http.ResponseWriter, r *http.Request) {
+	var cuerpo struct {
+		Correo string `json:"correo"`
+		Clave  string `json:"clave"`
+		Codigo string `json:"codigo"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	correo := strings.TrimSpace(cuerpo.Correo)
+	clave := strings.TrimSpace(cuerpo.Clave)
+	if correo == "" || clave == "" {
+		ResponderErrorJSON(w, http.StatusBadRequest, "credenciales_requeridas", "Debe ingresar correo y clave")
+		return
+	}
+
+	ip := ipCliente(r)
+	usuario, espera, err := VerificarConLimite(credencialesMySQL(h.DB), h.Intentos, correo, clave, ip)
+	if err != nil {
+		switch err {
+		case ErrCredencialesInvalidas:
+			ResponderErrorJSON(w, http.StatusUnauthorized, "credenciales_invalidas", "Credenciales inválidas")
+			return
+		case intentos.ErrCuentaBloqueada, intentos.ErrDemasiadosIntentos:
+			responderBloqueo(w, err, espera)
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al validar usuario")
+		return
+	}
+
+	// Segundo factor: el código se verifica con el mismo límite de intentos que la clave.
+	estado, err := h.DobleFactor.Estado(usuario.IDUsuario)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al consultar segundo factor")
+		return
+	}
+	if !estado.Activo && h.Permisos.Tiene(usuario.NombreRol, permisos.DobleFactorObligatorio) {
+		ResponderErrorJSON(w, http.StatusForbidden, "segundo_factor_pendiente", "Su rol exige segundo factor: actívelo iniciando sesión en la web")
+		return
+	}
+	if estado.Activo {
+		if strings.TrimSpace(cuerpo.Codigo) == "" {
+			ResponderErrorJSON(w, http.StatusUnauthorized, "segundo_factor_requerido", "Debe enviar el código de su aplicación autenticadora en \"codigo\"")
+			return
+		}
+		_, err := h.DobleFactor.Verificar(usuario.IDUsuario, cuerpo.Codigo)
+		if err == doblefactor.ErrCodigoInvalido {
+			espera, err := h.Intentos.RegistrarFallo(correo, ip)
+			if err != nil {
+				ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al registrar intento")
+				return
+			}
+			if espera > 0 {
+				responderBloqueo(w, intentos.ErrCuentaBloqueada, espera)
+				return
+			}
+			ResponderErrorJSON(w, http.StatusUnauthorized, "codigo_invalido", "Código incorrecto o ya usado")
+			return
+		}
+		if err != nil {
+			ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al verificar código")
+			return
+		}
+	}
+
+	// Acceso correcto: se borran los fallos acumulados de la cuenta.
+	if err := h.Intentos.RegistrarExito(correo); err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al registrar acceso")
+		return
+	}
+
+	par, err := h.Tokens.Emitir(usuario.IDUsuario)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al emitir tokens")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	ResponderJSON(w, http.StatusOK, par)
+}
+
+// responderBloqueo responde 429 con Retry-After cuando la cuenta o la IP están bloqueadas.
+func responderBloqueo(w http.ResponseWriter, err error, espera time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(espera.Seconds()))))
+	ResponderErrorJSON(w, http.StatusTooManyRequests, "demasiados_intentos", MensajeBloqueo(err, espera))
+}
+
+// RefrescarToken canjea un token de refresco por un par nuevo (rotación).
+// Ruta: POST /api/v1/auth/refresh
+func (h *TokenAPIHandler) RefrescarToken(w http.ResponseWriter, r *http.Request) {
+	var cuerpo struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	par, err := h.Tokens.Refrescar(strings.TrimSpace(cuerpo.RefreshToken))
+	if err != nil {
+		if err == tokens.ErrTokenInvalido {
+			ResponderErrorJSON(w, http.StatusUnauthorized, "token_invalido", "Token de refresco inválido o vencido")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al refrescar tokens")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	ResponderJSON(w, http.StatusOK, par)
+}
+
+// ListarClaves devuelve las claves de API (opcionalmente de un usuario).
+// Ruta: GET /api/v1/claves-api?id_usuario=
+func (h *TokenAPIHandler) ListarClaves(w http.ResponseWriter, r *http.Request) {
+	idUsuario, err := enteroOpcional(r.URL.Query().Get("id_usuario"), 0)
+	if err != nil || idUsuario < 0 {
+		ResponderErrorJSON(w, http.StatusBadRequest, "parametro_invalido", "id_usuario inválido")
+		return
+	}
+
+	claves, err := h.Tokens.ListarClaves(idUsuario)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al listar claves de API")
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, map[string]any{"datos": claves})
+}
+
+// CrearClave genera una clave de API para un usuario.
+// El valor secreto se devuelve solo en esta respuesta.
+// Ruta: POST /api/v1/claves-api
+func (h *TokenAPIHandler) CrearClave(w http.ResponseWriter, r *http.Request) {
+	var cuerpo struct {
+		IDUsuario int    `json:"id_usuario"`
+		Nombre    string `json:"nombre"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	cuerpo.Nombre = strings.TrimSpace(cuerpo.Nombre)
+	if cuerpo.Nombre == "" {
+		responderErrorCampo(w, "nombre", "El nombre de la clave es obligatorio")
+		return
+	}
+
+	// Valida que el usuario destino exista.
+	var existe int
+	err := h.DB.QueryRow(`SELECT COUNT(*) FROM usuarios WHERE id_usuario = ?`, cuerpo.IDUsuario).Scan(&existe)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al consultar usuario")
+		return
+	}
+	if existe == 0 {
+		responderErrorCampo(w, "id_usuario", "El usuario no existe")
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	clave, secreto, err := h.Tokens.CrearClave(cuerpo.IDUsuario, sesion.IDUsuario, cuerpo.Nombre)
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al crear clave de API")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	ResponderJSON(w, http.StatusCreated, map[string]any{
+		"clave":   clave,
+		"secreto": secreto,
+	})
+}
+
+// RevocarClave revoca una clave de API.
+// Ruta: DELETE /api/v1/claves-api/{id}
+func (h *TokenAPIHandler) RevocarClave(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		ResponderErrorJSON(w, http.StatusBadRequest, "id_invalido", "ID de clave inválido")
+		return
+	}
+
+	err = h.Tokens.RevocarClave(id)
+	if err != nil {
+		if err == tokens.ErrClaveNoExiste {
+			ResponderErrorJSON(w, http.StatusNotFound, "no_encontrado", "Clave de API no encontrada o ya revocada")
+			return
+		}
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al revocar clave de API")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// leerCuerpoJSON decodifica el cuerpo de la petición; si falla responde 400.
+func leerCuerpoJSON(w http.ResponseWriter, r *http.Request, destino any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(destino); err != nil {
+		ResponderErrorJSON(w, http.StatusBadRequest, "json_invalido", "Cuerpo JSON inválido: "+err.Error())
+		return false
+	}
+	return true
+}
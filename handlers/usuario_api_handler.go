@@ -0,0 +1,180 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"net/http"          // Paquete para rutas y respuestas HTTP.
+	"sistema/seguridad" // Errores de validación de claves.
+	"sistema/usuarios"  // Servicio de administración de usuarios.
+	"strconv"           // Paquete para convertir IDs.
+)
+
+// UsuarioAPIHandler expone la administración de usuarios en /api/v1/usuarios (solo ADMIN).
+type UsuarioAPIHandler struct {
+	Usuarios *usuarios.Servicio // Servicio de usuarios.
+}
+
+// NuevoUsuarioAPIHandler crea una nueva instancia del handler de la API de usuarios.
+func NuevoUsuarioAPIHandler(servicio *usuarios.Servicio) *UsuarioAPIHandler {
+	return &UsuarioAPIHandler{
+		Usuarios: servicio,
+	}
+}
+
+// Listar devuelve los usuarios, opcionalmente filtrados por nombre, correo o ID.
+// Ruta: GET /api/v1/usuarios?buscar=
+func (h *UsuarioAPIHandler) Listar(w http.ResponseWriter, r *http.Request) {
+	lista, err := h.Usuarios.Listar(r.URL.Query().Get("buscar"))
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al listar usuarios")
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, map[string]any{"datos": lista})
+}
+
+// Obtener devuelve un usuario por ID.
+// Ruta: GET /api/v1/usuarios/{id}
+func (h *UsuarioAPIHandler) Obtener(w http.ResponseWriter, r *http.Request) {
+	id, ok := idUsuarioDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	usuario, err := h.Usuarios.Obtener(id)
+	if err != nil {
+		responderErrorUsuario(w, err)
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, usuario)
+}
+
+// Crear registra un usuario ACTIVO.
+// Ruta: POST /api/v1/usuarios {"nombre", "correo", "clave", "id_rol"}
+func (h *UsuarioAPIHandler) Crear(w http.ResponseWriter, r *http.Request) {
+	var cuerpo struct {
+		Nombre string `json:"nombre"`
+		Correo string `json:"correo"`
+		Clave  string `json:"clave"`
+		IDRol  int    `json:"id_rol"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	usuario, err := h.Usuarios.Crear(cuerpo.Nombre, cuerpo.Correo, cuerpo.Clave, cuerpo.IDRol)
+	if err != nil {
+		responderErrorUsuario(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/usuarios/"+strconv.Itoa(usuario.IDUsuario))
+	ResponderJSON(w, http.StatusCreated, usuario)
+}
+
+// Modificar cambia el rol y/o el estado de un usuario; los campos omitidos no cambian.
+// Ruta: PATCH /api/v1/usuarios/{id} {"id_rol", "estado"}
+func (h *UsuarioAPIHandler) Modificar(w http.ResponseWriter, r *http.Request) {
+	id, ok := idUsuarioDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	var cuerpo struct {
+		IDRol  *int    `json:"id_rol"`
+		Estado *string `json:"estado"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	if cuerpo.IDRol != nil {
+		if err := h.Usuarios.CambiarRol(id, *cuerpo.IDRol); err != nil {
+			responderErrorUsuario(w, err)
+			return
+		}
+	}
+	if cuerpo.Estado != nil {
+		if err := h.Usuarios.CambiarEstado(id, *cuerpo.Estado); err != nil {
+			responderErrorUsuario(w, err)
+			return
+		}
+	}
+
+	usuario, err := h.Usuarios.Obtener(id)
+	if err != nil {
+		responderErrorUsuario(w, err)
+		return
+	}
+	ResponderJSON(w, http.StatusOK, usuario)
+}
+
+// RestablecerClave asigna una clave nueva y cierra las sesiones y tokens del usuario.
+// Ruta: PUT /api/v1/usuarios/{id}/clave {"clave"}
+func (h *UsuarioAPIHandler) RestablecerClave(w http.ResponseWriter, r *http.Request) {
+	id, ok := idUsuarioDesdeRuta(w, r)
+	if !ok {
+		return
+	}
+
+	var cuerpo struct {
+		Clave string `json:"clave"`
+	}
+	if !leerCuerpoJSON(w, r, &cuerpo) {
+		return
+	}
+
+	if err := h.Usuarios.RestablecerClave(id, cuerpo.Clave); err != nil {
+		responderErrorUsuario(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListarRoles devuelve los roles que se pueden asignar.
+// Ruta: GET /api/v1/roles
+func (h *UsuarioAPIHandler) ListarRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.Usuarios.Roles()
+	if err != nil {
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al listar roles")
+		return
+	}
+
+	ResponderJSON(w, http.StatusOK, map[string]any{"datos": roles})
+}
+
+// idUsuarioDesdeRuta lee el {id} de la ruta; si es inválido responde 400 y devuelve false.
+func idUsuarioDesdeRuta(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		ResponderErrorJSON(w, http.StatusBadRequest, "id_invalido", "ID de usuario inválido")
+		return 0, false
+	}
+	return id, true
+}
+
+// responderErrorUsuario traduce los errores del servicio de usuarios a respuestas JSON.
+func responderErrorUsuario(w http.ResponseWriter, err error) {
+	switch err {
+	case usuarios.ErrUsuarioNoExiste:
+		ResponderErrorJSON(w, http.StatusNotFound, "no_encontrado", "Usuario no encontrado")
+	case usuarios.ErrNombreVacio:
+		responderErrorCampo(w, "nombre", "El nombre es obligatorio")
+	case usuarios.ErrCorreoInvalido:
+		responderErrorCampo(w, "correo", "El correo no es válido")
+	case usuarios.ErrCorreoEnUso:
+		ResponderErrorJSON(w, http.StatusConflict, "correo_en_uso", "El correo ya está registrado")
+	case seguridad.ErrClaveCorta:
+		responderErrorCampo(w, "clave", "La clave debe tener al menos 8 caracteres")
+	case seguridad.ErrClaveLarga:
+		responderErrorCampo(w, "clave", "La clave es demasiado larga (máximo 72 caracteres; los acentos cuentan doble)")
+	case usuarios.ErrRolNoExiste:
+		responderErrorCampo(w, "id_rol", "El rol no existe")
+	case usuarios.ErrEstadoInvalido:
+		responderErrorCampo(w, "estado", "El estado debe ser ACTIVO o INACTIVO")
+	case usuarios.ErrUltimoAdmin:
+		ResponderErrorJSON(w, http.StatusConflict, "ultimo_admin", "Debe quedar al menos un ADMIN activo")
+	default:
+		ResponderErrorJSON(w, http.StatusInternalServerError, "error_interno", "Error al procesar usuario")
+	}
+}
@@ -0,0 +1,234 @@
+package handlers // Paquete handlers: contiene controladores del sistema.
+
+import (
+	"html/template"       // Paquete para renderizar plantillas HTML.
+	"net/http"            // Paquete para rutas y respuestas HTTP.
+	"net/url"             // Paquete para codificar mensajes en la URL.
+	"sistema/doblefactor" // Restablecimiento del segundo factor.
+	"sistema/models"      // Estructuras Usuario y Rol.
+	"sistema/seguridad"   // Errores de validación de claves.
+	"sistema/usuarios"    // Servicio de administración de usuarios.
+	"strconv"             // Paquete para convertir IDs.
+	"strings"             // Paquete para limpiar texto.
+)
+
+// UsuarioHandler agrupa las pantallas de administración de usuarios (solo ADMIN).
+type UsuarioHandler struct {
+	Templates   *template.Template    // Plantillas HTML cargadas.
+	Usuarios    *usuarios.Servicio    // Servicio de usuarios.
+	DobleFactor *doblefactor.Servicio // Segundo factor de cada usuario.
+}
+
+// NuevoUsuarioHandler crea una nueva instancia del handler de usuarios.
+func NuevoUsuarioHandler(templates *template.Template, servicio *usuarios.Servicio, servicioDobleFactor *doblefactor.Servicio) *UsuarioHandler {
+	return &UsuarioHandler{
+		Templates:   templates,
+		Usuarios:    servicio,
+		DobleFactor: servicioDobleFactor,
+	}
+}
+
+// VerUsuarios lista los usuarios con búsqueda y formularios de alta y edición.
+// Ruta: GET /admin/usuarios?buscar=
+func (h *UsuarioHandler) VerUsuarios(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	busqueda := strings.TrimSpace(r.URL.Query().Get("buscar"))
+	lista, err := h.Usuarios.Listar(busqueda)
+	if err != nil {
+		http.Error(w, "Error al consultar usuarios: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	roles, err := h.Usuarios.Roles()
+	if err != nil {
+		http.Error(w, "Error al consultar roles: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dobleFactor, err := h.DobleFactor.Activos()
+	if err != nil {
+		http.Error(w, "Error al consultar segundo factor: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sesion, _ := SesionActual(r)
+	data := struct {
+		Usuarios      []models.Usuario // Usuarios encontrados.
+		Roles         []models.Rol     // Roles para los selectores.
+		DobleFactor   map[int]bool     // Usuarios con segundo factor activo.
+		Buscar        string           // Texto buscado.
+		Mensaje       string           // Resultado de la última acción.
+		IDActual      int              // ADMIN que está viendo la pantalla.
+		UsuarioNombre string           // Usuario actual.
+		UsuarioRol    string           // Rol actual.
+		CSRF          string           // Token CSRF de los formularios.
+	}{
+		Usuarios:      lista,
+		Roles:         roles,
+		DobleFactor:   dobleFactor,
+		Buscar:        busqueda,
+		Mensaje:       strings.TrimSpace(r.URL.Query().Get("msg")),
+		IDActual:      sesion.IDUsuario,
+		UsuarioNombre: ObtenerNombreUsuario(r),
+		UsuarioRol:    ObtenerRolUsuario(r),
+		CSRF:          TokenCSRF(r),
+	}
+
+	err = h.Templates.ExecuteTemplate(w, "admin_usuarios.html", data)
+	if err != nil {
+		http.Error(w, "Error al renderizar plantilla admin_usuarios.html: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// CrearUsuario registra un usuario ACTIVO.
+// Ruta: POST /admin/usuarios/crear (nombre, correo, clave, id_rol)
+func (h *UsuarioHandler) CrearUsuario(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idRol, err := strconv.Atoi(r.FormValue("id_rol"))
+	if err != nil {
+		http.Error(w, "Rol inválido", http.StatusBadRequest)
+		return
+	}
+
+	usuario, err := h.Usuarios.Crear(r.FormValue("nombre"), r.FormValue("correo"), r.FormValue("clave"), idRol)
+	if err != nil {
+		h.responderError(w, r, "Error al crear usuario", err)
+		return
+	}
+
+	h.volver(w, r, "Usuario "+usuario.Correo+" creado correctamente")
+}
+
+// CambiarRol asigna otro rol a un usuario.
+// Ruta: POST /admin/usuarios/rol (id, id_rol)
+func (h *UsuarioHandler) CambiarRol(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de usuario inválido", http.StatusBadRequest)
+		return
+	}
+	idRol, err := strconv.Atoi(r.FormValue("id_rol"))
+	if err != nil {
+		http.Error(w, "Rol inválido", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Usuarios.CambiarRol(id, idRol); err != nil {
+		h.responderError(w, r, "Error al cambiar rol", err)
+		return
+	}
+
+	h.volver(w, r, "Rol actualizado: el usuario deberá iniciar sesión otra vez")
+}
+
+// CambiarEstado activa o desactiva un usuario.
+// Ruta: POST /admin/usuarios/estado (id, estado)
+func (h *UsuarioHandler) CambiarEstado(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de usuario inválido", http.StatusBadRequest)
+		return
+	}
+
+	estado := strings.ToUpper(strings.TrimSpace(r.FormValue("estado")))
+	if err := h.Usuarios.CambiarEstado(id, estado); err != nil {
+		h.responderError(w, r, "Error al cambiar estado", err)
+		return
+	}
+
+	if estado == models.EstadoInactivo {
+		h.volver(w, r, "Usuario desactivado y sesiones cerradas")
+		return
+	}
+	h.volver(w, r, "Usuario activado")
+}
+
+// RestablecerClave asigna una clave nueva a un usuario.
+// Ruta: POST /admin/usuarios/clave (id, clave)
+func (h *UsuarioHandler) RestablecerClave(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de usuario inválido", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Usuarios.RestablecerClave(id, r.FormValue("clave")); err != nil {
+		h.responderError(w, r, "Error al restablecer clave", err)
+		return
+	}
+
+	h.volver(w, r, "Clave restablecida: se cerraron las sesiones del usuario")
+}
+
+// RestablecerDobleFactor quita el segundo factor de un usuario que perdió su
+// dispositivo y sus códigos de recuperación. Si su rol lo exige, deberá volver
+// a darlo de alta en el próximo inicio de sesión.
+// Ruta: POST /admin/usuarios/doble-factor (id)
+func (h *UsuarioHandler) RestablecerDobleFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Método no permitido", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "ID de usuario inválido", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.Usuarios.Obtener(id); err != nil {
+		h.responderError(w, r, "Error al restablecer segundo factor", err)
+		return
+	}
+
+	if err := h.DobleFactor.Restablecer(id); err != nil {
+		h.responderError(w, r, "Error al restablecer segundo factor", err)
+		return
+	}
+
+	h.volver(w, r, "Segundo factor quitado: el usuario podrá activarlo de nuevo")
+}
+
+// responderError vuelve al listado con el motivo si el error es de validación;
+// si no, responde 404 o 500.
+func (h *UsuarioHandler) responderError(w http.ResponseWriter, r *http.Request, contexto string, err error) {
+	switch err {
+	case usuarios.ErrUsuarioNoExiste:
+		http.Error(w, "Usuario no encontrado", http.StatusNotFound)
+	case usuarios.ErrRolNoExiste, usuarios.ErrCorreoEnUso, usuarios.ErrCorreoInvalido, usuarios.ErrNombreVacio,
+		usuarios.ErrEstadoInvalido, usuarios.ErrUltimoAdmin, seguridad.ErrClaveCorta, seguridad.ErrClaveLarga:
+		h.volver(w, r, contexto+": "+err.Error())
+	default:
+		http.Error(w, contexto+": "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// volver redirige al listado de usuarios con un mensaje, conservando la búsqueda.
+func (h *UsuarioHandler) volver(w http.ResponseWriter, r *http.Request, mensaje string) {
+	destino := "/admin/usuarios?msg=" + url.QueryEscape(mensaje)
+	if buscar := strings.TrimSpace(r.FormValue("buscar")); buscar != "" {
+		destino += "&buscar=" + url.QueryEscape(buscar)
+	}
+	http.Redirect(w, r, destino, http.StatusSeeOther)
+}
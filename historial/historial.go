@@ -0,0 +1,109 @@
+package historial // Paquete historial: registro de lecturas, descargas, préstamos y devoluciones.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"sistema/models" // Estructura History.
+	"strconv"        // Paquete para reconocer filtros numéricos.
+	"strings"        // Paquete para armar filtros.
+	"time"           // Paquete para fechas del registro.
+)
+
+// limiteDefecto acota la cantidad de filas que devuelve Listar.
+const limiteDefecto = 200
+
+// Ejecutor es lo que necesita Registrar: una conexión (*sql.DB) o una
+// transacción (*sql.Tx), para registrar junto con el cambio que se anota.
+type Ejecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Registrar agrega una entrada al historial con la fecha actual.
+func Registrar(db Ejecutor, idUsuario, idLibro int, accion string) error {
+	_, err := db.Exec(`
+		INSERT INTO historial (id_usuario, id_libro, accion, fecha)
+		VALUES (?, ?, ?, ?)
+	`, idUsuario, idLibro, accion, time.Now())
+	return err
+}
+
+// Filtro acota la consulta del historial. Los campos vacíos no filtran.
+type Filtro struct {
+	IDUsuario int       // Solo un usuario (ej. "Mi historial").
+	Usuario   string    // ID exacto, o parte del nombre o correo.
+	Libro     string    // ID exacto, o parte del título.
+	Accion    string    // Una de las acciones models.Accion*.
+	Desde     time.Time // Desde esa fecha inclusive.
+	Hasta     time.Time // Hasta esa fecha exclusive.
+	Limite    int       // Máximo de filas (por defecto 200).
+}
+
+// Listar devuelve las entradas que cumplen el filtro, de la más reciente a la más antigua.
+func Listar(db *sql.DB, f Filtro) ([]models.History, error) {
+	var (
+		condiciones []string
+		args        []any
+	)
+	if f.IDUsuario > 0 {
+		condiciones = append(condiciones, "h.id_usuario = ?")
+		args = append(args, f.IDUsuario)
+	}
+	if f.Usuario != "" {
+		condiciones = append(condiciones, "(h.id_usuario = ? OR u.nombre LIKE ? OR u.correo LIKE ?)")
+		id, _ := strconv.Atoi(f.Usuario)
+		args = append(args, id, "%"+f.Usuario+"%", "%"+f.Usuario+"%")
+	}
+	if f.Libro != "" {
+		condiciones = append(condiciones, "(h.id_libro = ? OR l.titulo LIKE ?)")
+		id, _ := strconv.Atoi(f.Libro)
+		args = append(args, id, "%"+f.Libro+"%")
+	}
+	if f.Accion != "" {
+		condiciones = append(condiciones, "h.accion = ?")
+		args = append(args, f.Accion)
+	}
+	if !f.Desde.IsZero() {
+		condiciones = append(condiciones, "h.fecha >= ?")
+		args = append(args, f.Desde)
+	}
+	if !f.Hasta.IsZero() {
+		condiciones = append(condiciones, "h.fecha < ?")
+		args = append(args, f.Hasta)
+	}
+
+	where := ""
+	if len(condiciones) > 0 {
+		where = "WHERE " + strings.Join(condiciones, " AND ")
+	}
+	if f.Limite <= 0 {
+		f.Limite = limiteDefecto
+	}
+	args = append(args, f.Limite)
+
+	// El libro o el usuario pueden haberse eliminado: la entrada se conserva igual.
+	query := `
+		SELECT h.id, h.id_usuario, h.id_libro, h.accion, DATE_FORMAT(h.fecha, '%Y-%m-%d %H:%i:%s'),
+			COALESCE(u.nombre, CONCAT('Usuario #', h.id_usuario)),
+			COALESCE(l.titulo, CONCAT('Libro #', h.id_libro))
+		FROM historial h
+		LEFT JOIN usuarios u ON u.id_usuario = h.id_usuario
+		LEFT JOIN libros l ON l.id = h.id_libro
+		` + where + `
+		ORDER BY h.fecha DESC, h.id DESC
+		LIMIT ?
+	`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entradas []models.History
+	for rows.Next() {
+		var e models.History
+		if err := rows.Scan(&e.ID, &e.UserID, &e.BookID, &e.Accion, &e.Fecha, &e.Usuario, &e.Titulo); err != nil {
+			return nil, err
+		}
+		entradas = append(entradas, e)
+	}
+	return entradas, rows.Err()
+}
@@ -0,0 +1,387 @@
+package importacion // Paquete importacion: carga masiva de libros desde archivos CSV.
+
+import (
+	"bytes"             // Paquete para quitar la marca BOM y contar separadores.
+	"encoding/csv"      // Paquete para leer el archivo CSV.
+	"errors"            // Paquete para errores de la importación.
+	"fmt"               // Paquete para armar los mensajes de cada fila.
+	"io"                // Paquete para leer el archivo subido.
+	"sistema/libros"    // Repositorio de libros.
+	"sistema/metadatos" // Normalización del ISBN.
+	"sistema/models"    // Estructuras Libro y Auditoria.
+	"strconv"           // Paquete para convertir números.
+	"strings"           // Paquete para limpiar textos.
+	"unicode/utf8"      // Paquete para verificar la codificación.
+)
+
+// Límites del archivo, para no cargar en memoria archivos enormes.
+const (
+	TamanoMaximo = 5 << 20 // Tamaño máximo del archivo (5 MB).
+	MaxFilas     = 5000    // Máximo de libros por archivo.
+)
+
+// Errores del archivo completo (los de cada fila van en Fila.Errores).
+var (
+	ErrMuyGrande       = errors.New("el archivo supera los 5 MB")
+	ErrDemasiadasFilas = errors.New("el archivo supera los 5000 libros")
+	ErrNoUTF8          = errors.New("el archivo no está en UTF-8 (en Excel, guárdelo como «CSV UTF-8»)")
+	ErrFormato         = errors.New("el archivo no es un CSV válido")
+	ErrColumnas        = errors.New("faltan columnas obligatorias")
+	ErrVacio           = errors.New("el archivo no tiene libros")
+)
+
+// columnas relaciona los nombres aceptados en la fila de títulos (en
+// minúsculas, sin acentos y con "_" en lugar de espacios) con el campo de
+// models.Libro. Los nombres de los campos JSON son los principales.
+var columnas = map[string]string{
+	"titulo":            "titulo",
+	"autor":             "autor",
+	"categoria":         "categoria",
+	"anio_publicacion":  "anio_publicacion",
+	"anio":              "anio_publicacion",
+	"ano":               "anio_publicacion",
+	"ano_publicacion":   "anio_publicacion",
+	"formato":           "formato",
+	"licencias_totales": "licencias_totales",
+	"total_licencias":   "licencias_totales",
+	"licencias":         "licencias_totales",
+	"stock_licencias":   "stock_licencias",
+	"stock":             "stock_licencias",
+	"idioma":            "idioma",
+	"editorial":         "editorial",
+	"isbn":              "isbn",
+	"paginas":           "paginas",
+}
+
+// obligatorias son las columnas que debe tener el archivo, en el orden del
+// mensaje de error. Además debe tener licencias_totales o, en los archivos
+// anteriores, stock_licencias.
+var obligatorias = []string{"titulo", "autor", "categoria", "anio_publicacion", "formato"}
+
+// formatos son los formatos de archivo aceptados.
+var formatos = map[string]bool{"PDF": true, "EPUB": true, "MOBI": true}
+
+// Fila es un libro leído del archivo con los problemas encontrados.
+type Fila struct {
+	Numero    int          // Línea del archivo (la fila de títulos es la 1).
+	Libro     models.Libro // Datos leídos (ID asignado si se importó).
+	Errores   []string     // Valores inválidos u obligatorios vacíos.
+	Duplicado string       // Libro del catálogo o fila del archivo con el mismo título y autor o ISBN.
+}
+
+// Valida indica si la fila se puede importar.
+func (f Fila) Valida() bool {
+	return len(f.Errores) == 0 && f.Duplicado == ""
+}
+
+// Resultado es el análisis de un archivo: la vista previa antes de importar,
+// o lo importado después.
+type Resultado struct {
+	Filas     []Fila   // Filas del archivo, en orden.
+	Ignoradas []string // Columnas que no corresponden a ningún campo.
+}
+
+// Validas devuelve cuántas filas se pueden importar.
+func (r Resultado) Validas() int {
+	n := 0
+	for _, f := range r.Filas {
+		if f.Valida() {
+			n++
+		}
+	}
+	return n
+}
+
+// Analizar lee el archivo CSV (UTF-8, separado por ";" o ","), valida cada
+// fila y marca las que ya están en el catálogo o se repiten en el archivo.
+// No guarda nada: es la vista previa de Importar.
+func Analizar(archivo io.Reader, repositorio libros.LibroRepository) (Resultado, error) {
+	resultado, err := leer(archivo)
+	if err != nil {
+		return resultado, err
+	}
+	return resultado, marcarDuplicados(&resultado, repositorio)
+}
+
+// Importar analiza el archivo otra vez (el catálogo pudo cambiar desde la
+// vista previa) y guarda las filas válidas en una sola transacción, con una
+// entrada de auditoría por libro. Las demás filas se informan y no se guardan.
+func Importar(archivo io.Reader, repositorio libros.LibroRepository, autor models.Auditoria) (Resultado, error) {
+	resultado, err := Analizar(archivo, repositorio)
+	if err != nil {
+		return resultado, err
+	}
+
+	var (
+		lista   []models.Libro
+		indices []int
+	)
+	for i, f := range resultado.Filas {
+		if f.Valida() {
+			lista = append(lista, f.Libro)
+			indices = append(indices, i)
+		}
+	}
+	if len(lista) == 0 {
+		return resultado, nil
+	}
+
+	if err := repositorio.CrearLote(lista, autor); err != nil {
+		return resultado, err
+	}
+	for n, i := range indices {
+		resultado.Filas[i].Libro.ID = lista[n].ID
+	}
+	return resultado, nil
+}
+
+// leer decodifica el archivo y valida cada fila.
+func leer(archivo io.Reader) (Resultado, error) {
+	var resultado Resultado
+
+	datos, err := io.ReadAll(io.LimitReader(archivo, TamanoMaximo+1))
+	if err != nil {
+		return resultado, err
+	}
+	if len(datos) > TamanoMaximo {
+		return resultado, ErrMuyGrande
+	}
+	if !utf8.Valid(datos) {
+		return resultado, ErrNoUTF8
+	}
+	datos = bytes.TrimPrefix(datos, []byte("\xEF\xBB\xBF")) // Marca BOM que agrega Excel.
+
+	lector := csv.NewReader(bytes.NewReader(datos))
+	lector.Comma = separador(datos)
+	lector.FieldsPerRecord = -1 // Las filas pueden tener menos celdas (se toman como vacías).
+	lector.TrimLeadingSpace = true
+
+	cabecera, err := lector.Read()
+	if err == io.EOF {
+		return resultado, ErrVacio
+	}
+	if err != nil {
+		return resultado, errorFormato(err)
+	}
+
+	// Posición de cada campo en la fila.
+	posiciones := make(map[string]int)
+	for i, nombre := range cabecera {
+		campo, ok := columnas[nombreColumna(nombre)]
+		if !ok {
+			if strings.TrimSpace(nombre) != "" {
+				resultado.Ignoradas = append(resultado.Ignoradas, strings.TrimSpace(nombre))
+			}
+			continue
+		}
+		if _, repetida := posiciones[campo]; !repetida {
+			posiciones[campo] = i
+		}
+	}
+	var faltan []string
+	for _, campo := range obligatorias {
+		if _, ok := posiciones[campo]; !ok {
+			faltan = append(faltan, campo)
+		}
+	}
+	_, totales := posiciones["licencias_totales"]
+	_, stock := posiciones["stock_licencias"]
+	if !totales && !stock {
+		faltan = append(faltan, "licencias_totales")
+	}
+	if len(faltan) > 0 {
+		return resultado, fmt.Errorf("%w: %s", ErrColumnas, strings.Join(faltan, ", "))
+	}
+
+	for {
+		registro, err := lector.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return resultado, errorFormato(err)
+		}
+		if vacio(registro) {
+			continue
+		}
+		if len(resultado.Filas) == MaxFilas {
+			return resultado, ErrDemasiadasFilas
+		}
+
+		linea, _ := lector.FieldPos(0)
+		valor := func(campo string) string {
+			if i, ok := posiciones[campo]; ok && i < len(registro) {
+				return strings.TrimSpace(registro[i])
+			}
+			return ""
+		}
+		resultado.Filas = append(resultado.Filas, leerFila(linea, valor))
+	}
+
+	if len(resultado.Filas) == 0 {
+		return resultado, ErrVacio
+	}
+	return resultado, nil
+}
+
+// leerFila arma el libro de una fila y anota los valores inválidos.
+// Aplica las mismas reglas que el alta desde el formulario y la API.
+func leerFila(linea int, valor func(campo string) string) Fila {
+	fila := Fila{
+		Numero: linea,
+		Libro: models.Libro{
+			Titulo:    valor("titulo"),
+			Autor:     valor("autor"),
+			Categoria: valor("categoria"),
+			Formato:   strings.ToUpper(valor("formato")),
+			Idioma:    valor("idioma"),
+			Editorial: valor("editorial"),
+		},
+	}
+	anotar := func(mensaje string, args ...any) {
+		fila.Errores = append(fila.Errores, fmt.Sprintf(mensaje, args...))
+	}
+
+	if fila.Libro.Titulo == "" {
+		anotar("Falta el título")
+	}
+	if fila.Libro.Autor == "" {
+		anotar("Falta el autor")
+	}
+	if fila.Libro.Categoria == "" {
+		anotar("Falta la categoría")
+	}
+	if !formatos[fila.Libro.Formato] {
+		anotar("Formato «%s» inválido (PDF, EPUB o MOBI)", valor("formato"))
+	}
+
+	// Un libro importado es nuevo: todas sus licencias quedan libres. El total
+	// se toma de licencias_totales y, si falta, de stock_licencias (el CSV de
+	// versiones anteriores de la exportación).
+	licencias, etiqueta := "licencias_totales", "Licencias totales"
+	if valor(licencias) == "" && valor("stock_licencias") != "" {
+		licencias, etiqueta = "stock_licencias", "Stock de licencias"
+	}
+
+	for _, n := range []struct {
+		campo    string
+		etiqueta string
+		destino  *int
+		opcional bool
+	}{
+		{"anio_publicacion", "Año de publicación", &fila.Libro.AnioPublicacion, false},
+		{licencias, etiqueta, &fila.Libro.LicenciasTotales, false},
+		{"paginas", "Páginas", &fila.Libro.Paginas, true},
+	} {
+		texto := valor(n.campo)
+		if texto == "" && n.opcional {
+			continue
+		}
+		numero, err := strconv.Atoi(texto)
+		if err != nil || numero < 0 {
+			anotar("%s «%s» inválido", n.etiqueta, texto)
+			continue
+		}
+		*n.destino = numero
+	}
+	fila.Libro.StockLicencias = fila.Libro.LicenciasTotales
+
+	// El ISBN es opcional, pero si se escribe debe ser válido.
+	if isbn := valor("isbn"); isbn != "" {
+		fila.Libro.ISBN = metadatos.NormalizarISBN(isbn)
+		if fila.Libro.ISBN == "" {
+			anotar("ISBN «%s» inválido", isbn)
+		}
+	}
+	return fila
+}
+
+// marcarDuplicados marca las filas válidas cuyo título y autor, o ISBN, ya
+// están en el catálogo o en una fila anterior del archivo.
+func marcarDuplicados(resultado *Resultado, repositorio libros.LibroRepository) error {
+	var candidatas []models.Libro
+	for _, f := range resultado.Filas {
+		if len(f.Errores) == 0 {
+			candidatas = append(candidatas, f.Libro)
+		}
+	}
+	if len(candidatas) == 0 {
+		return nil
+	}
+
+	existentes, err := repositorio.Coincidencias(candidatas)
+	if err != nil {
+		return err
+	}
+	catalogo := make(map[string]models.Libro)
+	for _, l := range existentes {
+		for _, clave := range libros.ClavesLibro(l) {
+			if _, ok := catalogo[clave]; !ok {
+				catalogo[clave] = l
+			}
+		}
+	}
+
+	anteriores := make(map[string]int) // Clave -> línea de la primera fila que la usa.
+	for i := range resultado.Filas {
+		f := &resultado.Filas[i]
+		if len(f.Errores) > 0 {
+			continue
+		}
+		for _, clave := range libros.ClavesLibro(f.Libro) {
+			if l, ok := catalogo[clave]; ok {
+				f.Duplicado = fmt.Sprintf("Ya está en el catálogo: #%d «%s» de %s", l.ID, l.Titulo, l.Autor)
+				break
+			}
+			if linea, ok := anteriores[clave]; ok {
+				f.Duplicado = fmt.Sprintf("Repite la fila %d del archivo", linea)
+				break
+			}
+		}
+		if f.Duplicado == "" {
+			for _, clave := range libros.ClavesLibro(f.Libro) {
+				anteriores[clave] = f.Numero
+			}
+		}
+	}
+	return nil
+}
+
+// separador elige ";" o "," según cuál aparezca más en la fila de títulos
+// (Excel usa ";" cuando la coma es el separador decimal).
+func separador(datos []byte) rune {
+	primera, _, _ := bytes.Cut(datos, []byte("\n"))
+	if bytes.Count(primera, []byte(";")) > bytes.Count(primera, []byte(",")) {
+		return ';'
+	}
+	return ','
+}
+
+// nombreColumna normaliza el nombre de una columna: minúsculas, sin acentos y
+// con "_" en lugar de espacios ("Año de publicación" queda "ano_de_publicacion").
+func nombreColumna(nombre string) string {
+	nombre = strings.ToLower(strings.TrimSpace(nombre))
+	nombre = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ñ", "n", "ü", "u").Replace(nombre)
+	nombre = strings.Join(strings.Fields(nombre), "_")
+	return strings.ReplaceAll(nombre, "_de_", "_")
+}
+
+// vacio indica si todas las celdas del registro están vacías (Excel deja filas
+// así al final de la hoja).
+func vacio(registro []string) bool {
+	for _, v := range registro {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// errorFormato agrega la línea del error de lectura del CSV.
+func errorFormato(err error) error {
+	var errCSV *csv.ParseError
+	if errors.As(err, &errCSV) {
+		return fmt.Errorf("%w (línea %d)", ErrFormato, errCSV.Line)
+	}
+	return fmt.Errorf("%w: %v", ErrFormato, err)
+}
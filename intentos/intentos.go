@@ -0,0 +1,239 @@
+package intentos // Paquete intentos: límite de intentos de inicio de sesión y bloqueo de cuentas.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"errors"         // Paquete para errores del módulo.
+	"sistema/models" // Estructuras IntentoLogin y BloqueoLogin.
+	"strings"        // Paquete para normalizar correos.
+	"time"           // Paquete para ventanas y esperas.
+)
+
+// Errores del módulo de intentos.
+var (
+	ErrCuentaBloqueada    = errors.New("cuenta bloqueada temporalmente por intentos fallidos")
+	ErrDemasiadosIntentos = errors.New("demasiados intentos fallidos desde la misma dirección")
+)
+
+// Servicio controla los intentos de inicio de sesión:
+//   - por cuenta: tras MaxFallos fallos seguidos el correo se bloquea; cada
+//     bloqueo seguido dura el doble que el anterior (desde BloqueoBase hasta BloqueoMaximo);
+//   - por IP: no se aceptan más de LimiteIP fallos dentro de VentanaIP.
+//
+// Los bloqueos se aplican a cualquier correo, exista o no la cuenta, para no
+// revelar qué correos están registrados. Sin MySQL (NuevoServicioMemoria) los
+// datos se guardan en memoria.
+type Servicio struct {
+	DB            *sql.DB       // Conexión a la base de datos.
+	MaxFallos     int           // Fallos seguidos que bloquean la cuenta.
+	BloqueoBase   time.Duration // Duración del primer bloqueo.
+	BloqueoMaximo time.Duration // Tope de la espera exponencial.
+	Olvido        time.Duration // Sin fallos durante este plazo, el contador vuelve a cero.
+	LimiteIP      int           // Fallos aceptados por IP dentro de VentanaIP.
+	VentanaIP     time.Duration // Ventana del límite por IP.
+
+	mem *memoria // Intentos y bloqueos sin MySQL (nil: se usa DB).
+}
+
+// NuevoServicio crea el servicio con 5 fallos por cuenta (bloqueos de 1 minuto
+// hasta 1 hora, olvidados tras 24 horas) y 20 fallos por IP cada 15 minutos.
+func NuevoServicio(db *sql.DB) *Servicio {
+	return &Servicio{
+		DB:            db,
+		MaxFallos:     5,
+		BloqueoBase:   time.Minute,
+		BloqueoMaximo: time.Hour,
+		Olvido:        24 * time.Hour,
+		LimiteIP:      20,
+		VentanaIP:     15 * time.Minute,
+	}
+}
+
+// Permitir indica si se puede intentar iniciar sesión con el correo desde la IP.
+// Si no, devuelve ErrCuentaBloqueada o ErrDemasiadosIntentos y la espera restante.
+func (s *Servicio) Permitir(correo, ip string) (time.Duration, error) {
+	if s.mem != nil {
+		return s.mem.permitir(s, correo, ip)
+	}
+	ahora := time.Now()
+
+	var hasta sql.NullTime
+	err := s.DB.QueryRow(`SELECT bloqueado_hasta FROM bloqueos_login WHERE correo = ?`, normalizarCorreo(correo)).Scan(&hasta)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if hasta.Valid && hasta.Time.After(ahora) {
+		return hasta.Time.Sub(ahora), ErrCuentaBloqueada
+	}
+
+	var fallos int
+	var primero sql.NullTime
+	err = s.DB.QueryRow(`
+		SELECT COUNT(*), MIN(fecha) FROM intentos_login
+		WHERE ip = ? AND fecha > ?
+	`, ip, ahora.Add(-s.VentanaIP)).Scan(&fallos, &primero)
+	if err != nil {
+		return 0, err
+	}
+	if fallos >= s.LimiteIP && primero.Valid {
+		return primero.Time.Add(s.VentanaIP).Sub(ahora), ErrDemasiadosIntentos
+	}
+	return 0, nil
+}
+
+// RegistrarFallo guarda el intento fallido y suma un fallo al correo. Si con
+// este fallo se alcanza MaxFallos, bloquea la cuenta y devuelve la espera.
+func (s *Servicio) RegistrarFallo(correo, ip string) (time.Duration, error) {
+	if s.mem != nil {
+		return s.mem.registrarFallo(s, correo, ip), nil
+	}
+	correo = normalizarCorreo(correo)
+	ahora := time.Now()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO intentos_login (correo, ip, fecha) VALUES (?, ?, ?)`, correo, ip, ahora); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT IGNORE INTO bloqueos_login (correo, fallos, bloqueos, ultimo_fallo)
+		VALUES (?, 0, 0, ?)
+	`, correo, ahora)
+	if err != nil {
+		return 0, err
+	}
+
+	var fallos, bloqueos int
+	var ultimo time.Time
+	err = tx.QueryRow(`
+		SELECT fallos, bloqueos, ultimo_fallo FROM bloqueos_login
+		WHERE correo = ? FOR UPDATE
+	`, correo).Scan(&fallos, &bloqueos, &ultimo)
+	if err != nil {
+		return 0, err
+	}
+	if ahora.Sub(ultimo) > s.Olvido {
+		fallos, bloqueos = 0, 0
+	}
+
+	fallos++
+	var espera time.Duration
+	var hasta sql.NullTime
+	if fallos >= s.MaxFallos {
+		espera = s.espera(bloqueos)
+		hasta = sql.NullTime{Time: ahora.Add(espera), Valid: true}
+		fallos = 0
+		bloqueos++
+	}
+
+	// Si no se bloquea ahora, se conserva el fin del bloqueo anterior.
+	_, err = tx.Exec(`
+		UPDATE bloqueos_login
+		SET fallos = ?, bloqueos = ?, ultimo_fallo = ?, bloqueado_hasta = COALESCE(?, bloqueado_hasta)
+		WHERE correo = ?
+	`, fallos, bloqueos, ahora, hasta, correo)
+	if err != nil {
+		return 0, err
+	}
+	return espera, tx.Commit()
+}
+
+// RegistrarExito borra el contador de fallos del correo tras un acceso correcto.
+func (s *Servicio) RegistrarExito(correo string) error {
+	if s.mem != nil {
+		s.mem.registrarExito(correo)
+		return nil
+	}
+	_, err := s.DB.Exec(`DELETE FROM bloqueos_login WHERE correo = ?`, normalizarCorreo(correo))
+	return err
+}
+
+// Desbloquear quita el bloqueo y los fallos acumulados del correo.
+func (s *Servicio) Desbloquear(correo string) error {
+	return s.RegistrarExito(correo)
+}
+
+// Bloqueos devuelve los correos con fallos acumulados o bloqueados, del último
+// fallo al más antiguo. Con soloActivos, solo los que siguen bloqueados.
+func (s *Servicio) Bloqueos(soloActivos bool) ([]models.BloqueoLogin, error) {
+	if s.mem != nil {
+		return s.mem.bloqueos(soloActivos), nil
+	}
+	ahora := time.Now()
+	query := `
+		SELECT correo, fallos, bloqueos, ultimo_fallo, bloqueado_hasta
+		FROM bloqueos_login
+	`
+	var args []any
+	if soloActivos {
+		query += ` WHERE bloqueado_hasta > ?`
+		args = append(args, ahora)
+	}
+	query += ` ORDER BY ultimo_fallo DESC`
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []models.BloqueoLogin{}
+	for rows.Next() {
+		var b models.BloqueoLogin
+		var hasta sql.NullTime
+		if err := rows.Scan(&b.Correo, &b.Fallos, &b.Bloqueos, &b.UltimoFallo, &hasta); err != nil {
+			return nil, err
+		}
+		if hasta.Valid {
+			b.BloqueadoHasta = &hasta.Time
+			b.Activo = hasta.Time.After(ahora)
+		}
+		lista = append(lista, b)
+	}
+	return lista, rows.Err()
+}
+
+// UltimosFallos devuelve los intentos fallidos más recientes (como máximo limite).
+func (s *Servicio) UltimosFallos(limite int) ([]models.IntentoLogin, error) {
+	if s.mem != nil {
+		return s.mem.ultimosFallos(limite), nil
+	}
+	rows, err := s.DB.Query(`
+		SELECT id, correo, ip, fecha FROM intentos_login
+		ORDER BY id DESC
+		LIMIT ?
+	`, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []models.IntentoLogin{}
+	for rows.Next() {
+		var i models.IntentoLogin
+		if err := rows.Scan(&i.ID, &i.Correo, &i.IP, &i.Fecha); err != nil {
+			return nil, err
+		}
+		lista = append(lista, i)
+	}
+	return lista, rows.Err()
+}
+
+// espera calcula la duración del bloqueo número bloqueos+1: BloqueoBase * 2^bloqueos,
+// sin pasar de BloqueoMaximo.
+func (s *Servicio) espera(bloqueos int) time.Duration {
+	espera := s.BloqueoBase
+	for i := 0; i < bloqueos && espera < s.BloqueoMaximo; i++ {
+		espera *= 2
+	}
+	return min(espera, s.BloqueoMaximo)
+}
+
+// normalizarCorreo quita espacios y pasa el correo a minúsculas.
+func normalizarCorreo(correo string) string {
+	return strings.ToLower(strings.TrimSpace(correo))
+}
@@ -0,0 +1,127 @@
+package intentos // Paquete intentos.
+
+import (
+	"sistema/models" // Estructuras IntentoLogin y BloqueoLogin.
+	"sort"           // Paquete para ordenar los bloqueos.
+	"sync"           // Paquete para proteger los datos ante accesos concurrentes.
+	"time"           // Paquete para ventanas y esperas.
+)
+
+// maxIntentosMemoria acota los intentos fallidos que se guardan sin MySQL.
+const maxIntentosMemoria = 1000
+
+// memoria guarda intentos y bloqueos cuando el sistema corre sin MySQL
+// (LIBROS_STORE=json). Se pierden al reiniciar el servidor.
+type memoria struct {
+	mu        sync.Mutex                      // Protege intentos, cuentas y siguiente.
+	intentos  []models.IntentoLogin           // Fallos, del más antiguo al más nuevo.
+	cuentas   map[string]*models.BloqueoLogin // Clave = correo normalizado.
+	siguiente int                             // Último ID de intento asignado.
+}
+
+// NuevoServicioMemoria crea el servicio con los mismos límites que
+// NuevoServicio, guardando intentos y bloqueos en memoria.
+func NuevoServicioMemoria() *Servicio {
+	s := NuevoServicio(nil)
+	s.mem = &memoria{cuentas: make(map[string]*models.BloqueoLogin)}
+	return s
+}
+
+// permitir es Permitir sobre los datos en memoria.
+func (m *memoria) permitir(s *Servicio, correo, ip string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ahora := time.Now()
+
+	if c, ok := m.cuentas[normalizarCorreo(correo)]; ok && c.BloqueadoHasta != nil && c.BloqueadoHasta.After(ahora) {
+		return c.BloqueadoHasta.Sub(ahora), ErrCuentaBloqueada
+	}
+
+	var (
+		fallos  int
+		primero time.Time
+	)
+	for i := len(m.intentos) - 1; i >= 0 && m.intentos[i].Fecha.After(ahora.Add(-s.VentanaIP)); i-- {
+		if m.intentos[i].IP == ip {
+			fallos++
+			primero = m.intentos[i].Fecha
+		}
+	}
+	if fallos >= s.LimiteIP {
+		return primero.Add(s.VentanaIP).Sub(ahora), ErrDemasiadosIntentos
+	}
+	return 0, nil
+}
+
+// registrarFallo es RegistrarFallo sobre los datos en memoria.
+func (m *memoria) registrarFallo(s *Servicio, correo, ip string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	correo = normalizarCorreo(correo)
+	ahora := time.Now()
+
+	m.siguiente++
+	m.intentos = append(m.intentos, models.IntentoLogin{ID: m.siguiente, Correo: correo, IP: ip, Fecha: ahora})
+	if len(m.intentos) > maxIntentosMemoria {
+		m.intentos = append([]models.IntentoLogin(nil), m.intentos[len(m.intentos)-maxIntentosMemoria:]...)
+	}
+
+	c, ok := m.cuentas[correo]
+	if !ok {
+		c = &models.BloqueoLogin{Correo: correo}
+		m.cuentas[correo] = c
+	}
+	if ahora.Sub(c.UltimoFallo) > s.Olvido {
+		c.Fallos, c.Bloqueos = 0, 0
+	}
+	c.UltimoFallo = ahora
+
+	c.Fallos++
+	if c.Fallos < s.MaxFallos {
+		return 0
+	}
+	espera := s.espera(c.Bloqueos)
+	hasta := ahora.Add(espera)
+	c.BloqueadoHasta = &hasta
+	c.Fallos = 0
+	c.Bloqueos++
+	return espera
+}
+
+// registrarExito es RegistrarExito sobre los datos en memoria.
+func (m *memoria) registrarExito(correo string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.cuentas, normalizarCorreo(correo))
+}
+
+// bloqueos es Bloqueos sobre los datos en memoria.
+func (m *memoria) bloqueos(soloActivos bool) []models.BloqueoLogin {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ahora := time.Now()
+
+	lista := []models.BloqueoLogin{}
+	for _, c := range m.cuentas {
+		b := *c
+		b.Activo = b.BloqueadoHasta != nil && b.BloqueadoHasta.After(ahora)
+		if soloActivos && !b.Activo {
+			continue
+		}
+		lista = append(lista, b)
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].UltimoFallo.After(lista[j].UltimoFallo) })
+	return lista
+}
+
+// ultimosFallos es UltimosFallos sobre los datos en memoria.
+func (m *memoria) ultimosFallos(limite int) []models.IntentoLogin {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lista := []models.IntentoLogin{}
+	for i := len(m.intentos) - 1; i >= 0 && len(lista) < limite; i-- {
+		lista = append(lista, m.intentos[i])
+	}
+	return lista
+}
@@ -0,0 +1,42 @@
+package intentos
+
+import (
+	"testing" // Paquete de pruebas.
+	"time"    // Paquete para acortar los plazos.
+)
+
+func TestMemoriaBloqueaYDesbloquea(t *testing.T) {
+	s := NuevoServicioMemoria()
+	s.MaxFallos = 3
+	s.LimiteIP = 4
+
+	for i := 0; i < 2; i++ {
+		if espera, err := s.RegistrarFallo("Ana@Correo.test ", "10.0.0.1"); err != nil || espera != 0 {
+			t.Fatalf("fallo %d: espera %v, error %v", i+1, espera, err)
+		}
+	}
+	if espera, _ := s.RegistrarFallo("ana@correo.test", "10.0.0.1"); espera != time.Minute {
+		t.Fatalf("el tercer fallo debía bloquear un minuto, espera %v", espera)
+	}
+	if _, err := s.Permitir("ana@correo.test", "10.0.0.2"); err != ErrCuentaBloqueada {
+		t.Fatalf("se esperaba la cuenta bloqueada, error %v", err)
+	}
+
+	// Otro correo desde la misma IP: a los LimiteIP fallos se corta la IP.
+	if _, err := s.RegistrarFallo("otro@correo.test", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Permitir("nuevo@correo.test", "10.0.0.1"); err != ErrDemasiadosIntentos {
+		t.Fatalf("se esperaba el límite por IP, error %v", err)
+	}
+
+	if err := s.Desbloquear("ana@correo.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Permitir("ana@correo.test", "10.0.0.2"); err != nil {
+		t.Errorf("la cuenta sigue bloqueada: %v", err)
+	}
+	if fallos, _ := s.UltimosFallos(10); len(fallos) != 4 || fallos[0].Correo != "otro@correo.test" {
+		t.Errorf("últimos fallos inesperados: %+v", fallos)
+	}
+}
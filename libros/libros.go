@@ -0,0 +1,143 @@
+package libros // Paquete libros: acceso a los libros del catálogo (MySQL, memoria, etc.).
+
+import (
+	"errors"         // Paquete para errores del repositorio.
+	"sistema/models" // Estructuras Libro y Auditoria.
+	"strings"        // Paquete para normalizar título y autor.
+)
+
+// ErrNoExiste se usa cuando el ID no corresponde a ningún libro.
+var ErrNoExiste = errors.New("libro no existe")
+
+// ErrLicenciasEnUso se usa cuando se quiere bajar el total de licencias de un
+// libro por debajo de las que están prestadas o apartadas para la cola.
+var ErrLicenciasEnUso = errors.New("hay más licencias prestadas o apartadas que el total pedido")
+
+// ErrLibroEnUso se usa cuando se quiere eliminar un libro que tiene préstamos
+// sin devolver o reservas en la cola.
+var ErrLibroEnUso = errors.New("el libro tiene préstamos activos o reservas pendientes")
+
+// Órdenes del listado.
+const (
+	OrdenRecientes  = "recientes"  // Del más nuevo al más antiguo (por ID).
+	OrdenTitulo     = "titulo"     // Alfabético por título.
+	OrdenAutor      = "autor"      // Alfabético por autor (y título).
+	OrdenAnio       = "anio"       // Por año de publicación, del más nuevo al más viejo.
+	OrdenID         = "id"         // Por ID ascendente (estable para paginar).
+	OrdenRelevancia = "relevancia" // Por relevancia de la búsqueda (solo con RepositorioIndexado; si no, como OrdenRecientes).
+)
+
+// LibroRepository define dónde se guardan los libros. Los handlers dependen solo
+// de esta interfaz, así se pueden probar sin MySQL con RepositorioMemoria.
+//
+// Los cambios reciben el autor (IDActor, Actor, Rol e IP de una entrada de
+// auditoría) y registran el cambio junto con el libro: si uno falla, no se
+// guarda ninguno.
+type LibroRepository interface {
+	// Listar devuelve los libros que cumplen el filtro.
+	Listar(f Filtro) ([]models.Libro, error)
+	// Pagina devuelve Limite libros (0: todos) a partir de Filtro.Cursor, con los
+	// cursores de la página siguiente y la anterior. Desplazamiento no se usa.
+	// Devuelve ErrCursorInvalido si el cursor está dañado o es de otro orden.
+	Pagina(f Filtro) (Pagina, error)
+	// Recorrer llama a fn con cada libro que cumple el filtro, en el orden del
+	// filtro, sin cargarlos todos en memoria (para exportar). Si fn devuelve
+	// error, se corta el recorrido.
+	Recorrer(f Filtro, fn func(models.Libro) error) error
+	// Contar devuelve cuántos libros cumplen el filtro (sin Limite ni Desplazamiento).
+	Contar(f Filtro) (int, error)
+	// Estadisticas cuenta los libros del catálogo por formato.
+	Estadisticas() (Estadisticas, error)
+	// Obtener devuelve todos los campos de un libro, o ErrNoExiste.
+	Obtener(id int) (models.Libro, error)
+	// Facetas cuenta los libros que cumplen el filtro por cada valor de categoría,
+	// formato, década y disponibilidad.
+	Facetas(f Filtro) (Facetas, error)
+	// ArchivoAsignado indica si algún libro usa la clave de archivo.
+	ArchivoAsignado(clave string) (bool, error)
+	// Coincidencias devuelve los libros del catálogo que comparten alguna clave
+	// de ClavesLibro (título y autor, o ISBN) con alguno de la lista.
+	Coincidencias(lista []models.Libro) ([]models.Libro, error)
+	// Crear guarda un libro nuevo y le asigna el ID generado.
+	Crear(libro *models.Libro, autor models.Auditoria) error
+	// CrearLote guarda varios libros nuevos (con una entrada de auditoría cada
+	// uno) y les asigna el ID generado. Se guardan todos o ninguno.
+	CrearLote(lista []models.Libro, autor models.Auditoria) error
+	// Actualizar guarda los datos del libro. Archivo y páginas solo se reemplazan
+	// con reemplazarArchivo=true. El stock no se toma del libro: se corre en lo
+	// que cambia LicenciasTotales (ver licenciasLibres). Devuelve el libro
+	// guardado, ErrNoExiste o ErrLicenciasEnUso.
+	Actualizar(libro models.Libro, reemplazarArchivo bool, autor models.Auditoria) (models.Libro, error)
+	// Eliminar borra un libro y devuelve cómo era (para borrar su archivo),
+	// ErrNoExiste o ErrLibroEnUso si tiene préstamos o reservas abiertos.
+	Eliminar(id int, autor models.Auditoria) (models.Libro, error)
+}
+
+// Filtro acota el listado de libros. Los campos vacíos no filtran.
+type Filtro struct {
+	Buscar         string // Parte del título, autor o categoría.
+	Autor          string // Parte del nombre del autor.
+	Categoria      string // Categoría exacta.
+	Formato        string // PDF, EPUB o MOBI.
+	AnioDesde      int    // Publicados desde ese año inclusive.
+	AnioHasta      int    // Publicados hasta ese año inclusive.
+	Disponibles    bool   // Solo libros con licencias en stock.
+	IDs            []int  // Solo estos libros (nil: sin restricción; vacío: ninguno).
+	Orden          string // Uno de los Orden*; vacío es OrdenRecientes.
+	Limite         int    // Máximo de libros (0: sin límite).
+	Desplazamiento int    // Libros que se saltean antes del primero (solo Listar).
+	Cursor         string // Cursor de Pagina.Siguiente o Pagina.Anterior (solo Pagina; vacío: primera página).
+}
+
+// Faceta es un valor de un filtro con la cantidad de libros que lo tienen.
+type Faceta struct {
+	Valor    string // Categoría, formato o década (ej. "1990").
+	Cantidad int    // Libros con ese valor.
+}
+
+// Facetas agrupa las cantidades de cada filtro del catálogo. Cada grupo se
+// cuenta con los demás filtros aplicados pero sin el propio, así se ve a
+// cuántos libros lleva cambiar ese filtro.
+type Facetas struct {
+	Categorias  []Faceta // Por categoría, en orden alfabético.
+	Formatos    []Faceta // Por formato, en orden alfabético.
+	Decadas     []Faceta // Por década de publicación, de la más vieja a la más nueva.
+	Disponibles int      // Libros con licencias en stock.
+}
+
+// Estadisticas resume el catálogo para el panel principal.
+type Estadisticas struct {
+	TotalLibros int
+	TotalPDF    int
+	TotalEPUB   int
+	TotalMOBI   int
+}
+
+// prepararAlta deja todas las licencias de un libro nuevo libres: el stock que
+// traiga el libro no se usa.
+func prepararAlta(libro *models.Libro) {
+	libro.StockLicencias = libro.LicenciasTotales
+}
+
+// licenciasLibres devuelve el stock libre del libro editado: el actual (antes,
+// leído con la fila bloqueada) corrido en lo que cambia el total. Así una
+// edición no pisa los préstamos y devoluciones hechos mientras tanto.
+func licenciasLibres(antes, libro models.Libro) (int, error) {
+	libres := antes.StockLicencias + libro.LicenciasTotales - antes.LicenciasTotales
+	if libres < 0 {
+		return 0, ErrLicenciasEnUso
+	}
+	return libres, nil
+}
+
+// ClavesLibro devuelve las claves por las que dos libros se consideran el
+// mismo: título y autor (sin distinguir mayúsculas ni espacios de más) y, si
+// tiene, el ISBN. La usan Coincidencias y la importación.
+func ClavesLibro(l models.Libro) []string {
+	texto := func(s string) string { return strings.ToLower(strings.Join(strings.Fields(s), " ")) }
+	resultado := []string{"t:" + texto(l.Titulo) + "\x00" + texto(l.Autor)}
+	if l.ISBN != "" {
+		resultado = append(resultado, "i:"+l.ISBN)
+	}
+	return resultado
+}
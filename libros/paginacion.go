@@ -0,0 +1,192 @@
+package libros // Paquete libros.
+
+import (
+	"cmp"             // Paquete para comparar números.
+	"encoding/base64" // Paquete para que el cursor viaje en la URL.
+	"encoding/json"   // Paquete para codificar el cursor.
+	"errors"          // Paquete para el error de cursor inválido.
+	"sistema/models"  // Estructura Libro.
+	"strings"         // Paquete para comparar textos sin distinguir mayúsculas.
+)
+
+// ErrCursorInvalido se usa cuando el cursor está dañado o es de otro orden.
+var ErrCursorInvalido = errors.New("cursor de página inválido")
+
+// Pagina es una página de libros con los cursores para moverse entre páginas.
+// La paginación es por cursor (keyset): cada página empieza justo después del
+// último libro de la anterior según el orden, sin OFFSET, así pedir la página
+// mil cuesta lo mismo que la primera.
+type Pagina struct {
+	Libros    []models.Libro // Libros de la página.
+	Siguiente string         // Cursor de la página siguiente ("" si es la última).
+	Anterior  string         // Cursor de la página anterior ("" si es la primera).
+}
+
+// cursor guarda la posición de un libro en un orden: los valores por los que se
+// ordena. Así la página sigue bien aunque ese libro se borre o cambie.
+type cursor struct {
+	Orden   string  `json:"o"`           // Orden en que se armó.
+	Atras   bool    `json:"b,omitempty"` // Pide los libros anteriores a esta posición (si no, los siguientes).
+	ID      int     `json:"i"`
+	Titulo  string  `json:"t,omitempty"`
+	Autor   string  `json:"a,omitempty"`
+	Anio    int     `json:"y,omitempty"`
+	Puntaje float64 `json:"p,omitempty"`
+}
+
+// claveOrden es una columna del orden y su sentido.
+type claveOrden struct {
+	columna string // Columna de la tabla "libros" (o "puntaje", solo en memoria).
+	desc    bool   // De mayor a menor.
+}
+
+// clavesOrden devuelve las columnas de cada orden; la última siempre es el ID,
+// para que dos libros nunca empaten.
+func clavesOrden(orden string) []claveOrden {
+	switch orden {
+	case OrdenID:
+		return []claveOrden{{"id", false}}
+	case OrdenTitulo:
+		return []claveOrden{{"titulo", false}, {"id", false}}
+	case OrdenAutor:
+		return []claveOrden{{"autor", false}, {"titulo", false}, {"id", false}}
+	case OrdenAnio:
+		return []claveOrden{{"anio_publicacion", true}, {"titulo", false}, {"id", false}}
+	case OrdenRelevancia:
+		return []claveOrden{{"puntaje", true}, {"id", false}}
+	default:
+		return []claveOrden{{"id", true}}
+	}
+}
+
+// ordenBase devuelve el orden que aplican los repositorios sin índice: uno de
+// los conocidos, o OrdenRecientes (también para OrdenRelevancia).
+func ordenBase(orden string) string {
+	switch orden {
+	case OrdenID, OrdenTitulo, OrdenAutor, OrdenAnio:
+		return orden
+	default:
+		return OrdenRecientes
+	}
+}
+
+// posicion arma el cursor de un libro en un orden.
+func posicion(orden string, l models.Libro, puntaje float64) cursor {
+	c := cursor{Orden: orden, ID: l.ID}
+	for _, k := range clavesOrden(orden) {
+		switch k.columna {
+		case "titulo":
+			c.Titulo = l.Titulo
+		case "autor":
+			c.Autor = l.Autor
+		case "anio_publicacion":
+			c.Anio = l.AnioPublicacion
+		case "puntaje":
+			c.Puntaje = puntaje
+		}
+	}
+	return c
+}
+
+// texto codifica el cursor para la URL.
+func (c cursor) texto() string {
+	datos, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(datos)
+}
+
+// valor devuelve el valor de una columna del orden guardado en el cursor.
+func (c cursor) valor(columna string) any {
+	switch columna {
+	case "titulo":
+		return c.Titulo
+	case "autor":
+		return c.Autor
+	case "anio_publicacion":
+		return c.Anio
+	case "puntaje":
+		return c.Puntaje
+	default:
+		return c.ID
+	}
+}
+
+// leerCursor decodifica el cursor y verifica que sea del orden pedido.
+func leerCursor(texto, orden string) (cursor, error) {
+	var c cursor
+	datos, err := base64.RawURLEncoding.DecodeString(texto)
+	if err != nil || json.Unmarshal(datos, &c) != nil || c.Orden != orden {
+		return c, ErrCursorInvalido
+	}
+	return c, nil
+}
+
+// comparar devuelve -1, 0 o 1 según a vaya antes, en el mismo lugar o después
+// de b en el orden. Los textos se comparan sin distinguir mayúsculas, igual que en MySQL.
+func comparar(orden string, a, b cursor) int {
+	for _, k := range clavesOrden(orden) {
+		r := 0
+		switch va := a.valor(k.columna).(type) {
+		case string:
+			r = strings.Compare(strings.ToLower(va), strings.ToLower(b.valor(k.columna).(string)))
+		case int:
+			r = cmp.Compare(va, b.valor(k.columna).(int))
+		case float64:
+			r = cmp.Compare(va, b.valor(k.columna).(float64))
+		}
+		if k.desc {
+			r = -r
+		}
+		if r != 0 {
+			return r
+		}
+	}
+	return 0
+}
+
+// paginar corta una página de una lista ya ordenada, a partir del cursor del
+// filtro. puntaje da el puntaje de cada libro (solo para OrdenRelevancia).
+func paginar(lista []models.Libro, orden string, f Filtro, puntaje func(id int) float64) (Pagina, error) {
+	if puntaje == nil {
+		puntaje = func(int) float64 { return 0 }
+	}
+	pos := func(i int) cursor { return posicion(orden, lista[i], puntaje(lista[i].ID)) }
+
+	limite := f.Limite
+	if limite <= 0 {
+		limite = len(lista)
+	}
+
+	// Rango [desde, hasta) de la página.
+	desde, hasta := 0, min(limite, len(lista))
+	if f.Cursor != "" {
+		c, err := leerCursor(f.Cursor, orden)
+		if err != nil {
+			return Pagina{}, err
+		}
+		if c.Atras {
+			// Los últimos "limite" libros antes de la posición.
+			hasta = 0
+			for hasta < len(lista) && comparar(orden, pos(hasta), c) < 0 {
+				hasta++
+			}
+			desde = max(hasta-limite, 0)
+		} else {
+			// Los primeros "limite" libros después de la posición.
+			for desde < len(lista) && comparar(orden, pos(desde), c) <= 0 {
+				desde++
+			}
+			hasta = min(desde+limite, len(lista))
+		}
+	}
+
+	pagina := Pagina{Libros: lista[desde:hasta]}
+	if hasta < len(lista) && hasta > desde {
+		pagina.Siguiente = pos(hasta - 1).texto()
+	}
+	if desde > 0 && hasta > desde {
+		anterior := pos(desde)
+		anterior.Atras = true
+		pagina.Anterior = anterior.texto()
+	}
+	return pagina, nil
+}
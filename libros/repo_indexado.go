@@ -0,0 +1,249 @@
+package libros // Paquete libros.
+
+import (
+	"sistema/busqueda" // Índice invertido para buscar por texto.
+	"sistema/models"   // Estructuras Libro y Auditoria.
+	"sort"             // Paquete para ordenar por relevancia.
+)
+
+// RepositorioIndexado envuelve otro repositorio y resuelve Filtro.Buscar con un
+// índice invertido en memoria (sin acentos, con raíces y sin palabras vacías),
+// que se mantiene al día con cada alta, modificación y baja hecha por él.
+// Los datos de los libros encontrados se leen del repositorio envuelto en cada
+// consulta, así el stock que cambian los préstamos siempre está al día.
+type RepositorioIndexado struct {
+	Repositorio LibroRepository  // Dónde se guardan los libros.
+	Indice      *busqueda.Indice // Títulos, autores y categorías indexados.
+}
+
+// NuevoRepositorioIndexado crea el índice con todos los libros del repositorio.
+func NuevoRepositorioIndexado(repositorio LibroRepository) (*RepositorioIndexado, error) {
+	todos, err := repositorio.Listar(Filtro{})
+	if err != nil {
+		return nil, err
+	}
+	indice := busqueda.NuevoIndice()
+	for _, l := range todos {
+		indice.Agregar(l)
+	}
+	return &RepositorioIndexado{Repositorio: repositorio, Indice: indice}, nil
+}
+
+// loteIDs es la cantidad de libros encontrados que se leen por consulta del
+// repositorio envuelto (MySQL no acepta más de 65.535 parámetros por sentencia).
+const loteIDs = 1000
+
+// Listar devuelve los libros que cumplen el filtro. Con búsqueda y
+// OrdenRelevancia, los más relevantes primero.
+func (r *RepositorioIndexado) Listar(f Filtro) ([]models.Libro, error) {
+	encontrados, sub, puntajes, err := r.buscar(f)
+	if err != nil {
+		return nil, err
+	}
+	if encontrados == nil {
+		return r.Repositorio.Listar(sub)
+	}
+	if f.Orden != OrdenRelevancia {
+		return encontrados.Listar(sub)
+	}
+
+	lista, err := porRelevancia(encontrados, sub, puntajes)
+	if err != nil {
+		return nil, err
+	}
+	if f.Desplazamiento >= len(lista) {
+		return nil, nil
+	}
+	lista = lista[max(f.Desplazamiento, 0):]
+	if f.Limite > 0 && f.Limite < len(lista) {
+		lista = lista[:f.Limite]
+	}
+	return lista, nil
+}
+
+// Pagina devuelve una página de libros a partir del cursor del filtro. Con
+// búsqueda y OrdenRelevancia, el cursor guarda el puntaje del último libro.
+func (r *RepositorioIndexado) Pagina(f Filtro) (Pagina, error) {
+	encontrados, sub, puntajes, err := r.buscar(f)
+	if err != nil {
+		return Pagina{}, err
+	}
+	if encontrados == nil {
+		return r.Repositorio.Pagina(sub)
+	}
+	if f.Orden != OrdenRelevancia {
+		return encontrados.Pagina(sub)
+	}
+
+	lista, err := porRelevancia(encontrados, sub, puntajes)
+	if err != nil {
+		return Pagina{}, err
+	}
+	return paginar(lista, OrdenRelevancia, f, func(id int) float64 { return puntajes[id] })
+}
+
+// Recorrer llama a fn con cada libro que cumple el filtro. Con búsqueda, los
+// encontrados ya están en memoria y se recorren en el orden del filtro.
+func (r *RepositorioIndexado) Recorrer(f Filtro, fn func(models.Libro) error) error {
+	if len(busqueda.Terminos(f.Buscar)) == 0 {
+		f.Buscar = ""
+		return r.Repositorio.Recorrer(f, fn)
+	}
+
+	lista, err := r.Listar(f)
+	if err != nil {
+		return err
+	}
+	for _, l := range lista {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// porRelevancia devuelve todos los libros encontrados que cumplen el filtro,
+// del más relevante al menos relevante (a igual puntaje, por ID).
+func porRelevancia(encontrados *RepositorioMemoria, f Filtro, puntajes map[int]float64) ([]models.Libro, error) {
+	f.Orden, f.Limite, f.Desplazamiento, f.Cursor = OrdenID, 0, 0, ""
+	lista, err := encontrados.Listar(f)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(lista, func(i, j int) bool {
+		return puntajes[lista[i].ID] > puntajes[lista[j].ID]
+	})
+	return lista, nil
+}
+
+// Contar devuelve cuántos libros cumplen el filtro.
+func (r *RepositorioIndexado) Contar(f Filtro) (int, error) {
+	encontrados, sub, _, err := r.buscar(f)
+	if err != nil {
+		return 0, err
+	}
+	if encontrados == nil {
+		return r.Repositorio.Contar(sub)
+	}
+	return encontrados.Contar(sub)
+}
+
+// Facetas cuenta los libros por cada valor de los filtros del catálogo.
+func (r *RepositorioIndexado) Facetas(f Filtro) (Facetas, error) {
+	encontrados, sub, _, err := r.buscar(f)
+	if err != nil {
+		return Facetas{}, err
+	}
+	if encontrados == nil {
+		return r.Repositorio.Facetas(sub)
+	}
+	return encontrados.Facetas(sub)
+}
+
+// buscar resuelve el texto del filtro con el índice. Devuelve los libros
+// encontrados en un repositorio en memoria (leídos del repositorio envuelto de
+// a loteIDs, con sus datos al día), el filtro sin la búsqueda para aplicarlo
+// sobre ellos y el puntaje de cada uno: los demás filtros, el orden y la página
+// se resuelven en memoria, sin volver a mandar los IDs a la base.
+//
+// Si no hay texto útil para buscar (solo palabras vacías), devuelve un
+// repositorio nil y el filtro sin búsqueda para el repositorio envuelto.
+func (r *RepositorioIndexado) buscar(f Filtro) (*RepositorioMemoria, Filtro, map[int]float64, error) {
+	if len(busqueda.Terminos(f.Buscar)) == 0 {
+		f.Buscar = ""
+		return nil, f, nil, nil
+	}
+
+	var permitidos map[int]bool
+	if f.IDs != nil {
+		permitidos = make(map[int]bool, len(f.IDs))
+		for _, id := range f.IDs {
+			permitidos[id] = true
+		}
+	}
+
+	resultados := r.Indice.Buscar(f.Buscar)
+	puntajes := make(map[int]float64, len(resultados))
+	ids := make([]int, 0, len(resultados))
+	for _, res := range resultados {
+		if permitidos != nil && !permitidos[res.ID] {
+			continue
+		}
+		puntajes[res.ID] = res.Puntaje
+		ids = append(ids, res.ID)
+	}
+
+	var lista []models.Libro
+	for inicio := 0; inicio < len(ids); inicio += loteIDs {
+		lote, err := r.Repositorio.Listar(Filtro{IDs: ids[inicio:min(inicio+loteIDs, len(ids))], Orden: OrdenID})
+		if err != nil {
+			return nil, f, nil, err
+		}
+		lista = append(lista, lote...)
+	}
+
+	f.Buscar = ""
+	f.IDs = nil
+	return NuevoRepositorioMemoria(lista...), f, puntajes, nil
+}
+
+// Estadisticas cuenta los libros del catálogo por formato.
+func (r *RepositorioIndexado) Estadisticas() (Estadisticas, error) {
+	return r.Repositorio.Estadisticas()
+}
+
+// Obtener devuelve todos los campos de un libro, o ErrNoExiste.
+func (r *RepositorioIndexado) Obtener(id int) (models.Libro, error) {
+	return r.Repositorio.Obtener(id)
+}
+
+// ArchivoAsignado indica si algún libro usa la clave de archivo.
+func (r *RepositorioIndexado) ArchivoAsignado(clave string) (bool, error) {
+	return r.Repositorio.ArchivoAsignado(clave)
+}
+
+// Coincidencias devuelve los libros con el mismo título y autor, o el mismo
+// ISBN, que alguno de la lista.
+func (r *RepositorioIndexado) Coincidencias(lista []models.Libro) ([]models.Libro, error) {
+	return r.Repositorio.Coincidencias(lista)
+}
+
+// Crear guarda un libro nuevo y lo agrega al índice.
+func (r *RepositorioIndexado) Crear(libro *models.Libro, autor models.Auditoria) error {
+	if err := r.Repositorio.Crear(libro, autor); err != nil {
+		return err
+	}
+	r.Indice.Agregar(*libro)
+	return nil
+}
+
+// CrearLote guarda varios libros nuevos y los agrega al índice.
+func (r *RepositorioIndexado) CrearLote(lista []models.Libro, autor models.Auditoria) error {
+	if err := r.Repositorio.CrearLote(lista, autor); err != nil {
+		return err
+	}
+	for _, l := range lista {
+		r.Indice.Agregar(l)
+	}
+	return nil
+}
+
+// Actualizar guarda los datos del libro y vuelve a indexarlo.
+func (r *RepositorioIndexado) Actualizar(libro models.Libro, reemplazarArchivo bool, autor models.Auditoria) (models.Libro, error) {
+	despues, err := r.Repositorio.Actualizar(libro, reemplazarArchivo, autor)
+	if err != nil {
+		return despues, err
+	}
+	r.Indice.Agregar(despues)
+	return despues, nil
+}
+
+// Eliminar borra un libro y lo saca del índice.
+func (r *RepositorioIndexado) Eliminar(id int, autor models.Auditoria) (models.Libro, error) {
+	antes, err := r.Repositorio.Eliminar(id, autor)
+	if err != nil {
+		return antes, err
+	}
+	r.Indice.Quitar(id)
+	return antes, nil
+}
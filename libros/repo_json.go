@@ -0,0 +1,246 @@
+package libros // Paquete libros.
+
+import (
+	"bytes"             // Paquete para reconocer el formato anterior del archivo.
+	"encoding/json"     // Paquete para leer y escribir el archivo.
+	"os"                // Paquete para leer y escribir archivos.
+	"sistema/auditoria" // Filtro de la pantalla de auditoría.
+	"sistema/models"    // Estructuras Libro y Auditoria.
+	"sync"              // Paquete para que dos cambios no escriban el archivo a la vez.
+)
+
+// libroJSON es un libro tal como se guarda en el archivo: todos los campos de
+// models.Libro más la clave del archivo, que la API no expone.
+type libroJSON struct {
+	models.Libro
+	Archivo string `json:"archivo,omitempty"` // Clave del archivo en el almacenamiento.
+	Anio    int    `json:"anio,omitempty"`    // Año en el formato anterior (id, titulo, autor, anio); solo se lee.
+}
+
+// archivoJSON es el contenido del archivo: los libros y la auditoría de sus cambios.
+type archivoJSON struct {
+	Libros    []libroJSON        `json:"libros"`
+	Auditoria []models.Auditoria `json:"auditoria"`
+}
+
+// RepositorioJSON guarda los libros en un archivo JSON, para demos y uso sin
+// conexión. Los libros se leen de un RepositorioMemoria y cada cambio reescribe
+// el archivo completo, con la auditoría incluida.
+type RepositorioJSON struct {
+	mu      sync.Mutex          // Ordena los cambios y la escritura del archivo.
+	archivo string              // Ruta del archivo JSON.
+	mem     *RepositorioMemoria // Libros cargados.
+}
+
+// NuevoRepositorioJSON crea el repositorio y carga el archivo si existe (si no
+// existe, empieza vacío y se crea con el primer cambio). Acepta también el
+// formato anterior de libros.json.
+func NuevoRepositorioJSON(archivo string) (*RepositorioJSON, error) {
+	r := &RepositorioJSON{archivo: archivo}
+	mem, err := r.cargar()
+	if err != nil {
+		return nil, err
+	}
+	r.mem = mem
+	return r, nil
+}
+
+// cargar lee los libros y la auditoría del archivo; los libros sin ID se
+// saltean. El formato anterior (solo la lista de libros) no trae auditoría.
+func (r *RepositorioJSON) cargar() (*RepositorioMemoria, error) {
+	datos, err := os.ReadFile(r.archivo)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return NuevoRepositorioMemoria(), nil
+		}
+		return nil, err
+	}
+
+	var contenido archivoJSON
+	if bytes.HasPrefix(bytes.TrimSpace(datos), []byte("[")) {
+		err = json.Unmarshal(datos, &contenido.Libros)
+	} else {
+		err = json.Unmarshal(datos, &contenido)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	libros := make([]models.Libro, 0, len(contenido.Libros))
+	for _, l := range contenido.Libros {
+		if l.ID <= 0 {
+			continue
+		}
+		if l.AnioPublicacion == 0 {
+			l.AnioPublicacion = l.Anio
+		}
+		l.Libro.Archivo = l.Archivo
+		libros = append(libros, l.Libro)
+	}
+	mem := NuevoRepositorioMemoria(libros...)
+	mem.auditoria = contenido.Auditoria
+	return mem, nil
+}
+
+// guardar escribe todos los libros y la auditoría en un archivo temporal y lo renombra, para
+// no dejar el archivo a medio escribir si el proceso se corta.
+func (r *RepositorioJSON) guardar() error {
+	libros, err := r.mem.Listar(Filtro{Orden: OrdenID})
+	if err != nil {
+		return err
+	}
+
+	contenido := archivoJSON{
+		Libros:    make([]libroJSON, 0, len(libros)),
+		Auditoria: r.mem.Auditoria(),
+	}
+	for _, l := range libros {
+		contenido.Libros = append(contenido.Libros, libroJSON{Libro: l, Archivo: l.Archivo})
+	}
+
+	datos, err := json.MarshalIndent(contenido, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := r.archivo + ".tmp"
+	if err := os.WriteFile(tmp, datos, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.archivo)
+}
+
+// confirmar guarda el archivo después de un cambio en memoria. Si no se pudo
+// escribir, vuelve a cargar el archivo para deshacer el cambio y sus entradas
+// de auditoría.
+func (r *RepositorioJSON) confirmar() error {
+	err := r.guardar()
+	if err == nil {
+		return nil
+	}
+	if mem, errCarga := r.cargar(); errCarga == nil {
+		r.mem = mem
+	}
+	return err
+}
+
+// Listar devuelve los libros que cumplen el filtro.
+func (r *RepositorioJSON) Listar(f Filtro) ([]models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Listar(f)
+}
+
+// Pagina devuelve una página de libros a partir del cursor del filtro.
+func (r *RepositorioJSON) Pagina(f Filtro) (Pagina, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Pagina(f)
+}
+
+// Recorrer llama a fn con cada libro que cumple el filtro. fn corre sin el
+// bloqueo, así una exportación lenta no frena los cambios.
+func (r *RepositorioJSON) Recorrer(f Filtro, fn func(models.Libro) error) error {
+	r.mu.Lock()
+	mem := r.mem
+	r.mu.Unlock()
+	return mem.Recorrer(f, fn)
+}
+
+// Contar devuelve cuántos libros cumplen el filtro.
+func (r *RepositorioJSON) Contar(f Filtro) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Contar(f)
+}
+
+// Estadisticas cuenta los libros del catálogo por formato.
+func (r *RepositorioJSON) Estadisticas() (Estadisticas, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Estadisticas()
+}
+
+// Obtener devuelve todos los campos de un libro, o ErrNoExiste.
+func (r *RepositorioJSON) Obtener(id int) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Obtener(id)
+}
+
+// Facetas cuenta los libros por cada valor de los filtros del catálogo.
+func (r *RepositorioJSON) Facetas(f Filtro) (Facetas, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Facetas(f)
+}
+
+// ArchivoAsignado indica si algún libro usa la clave de archivo.
+func (r *RepositorioJSON) ArchivoAsignado(clave string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.ArchivoAsignado(clave)
+}
+
+// Coincidencias devuelve los libros con el mismo título y autor, o el mismo
+// ISBN, que alguno de la lista.
+func (r *RepositorioJSON) Coincidencias(lista []models.Libro) ([]models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Coincidencias(lista)
+}
+
+// Crear guarda un libro nuevo y reescribe el archivo.
+func (r *RepositorioJSON) Crear(libro *models.Libro, autor models.Auditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.mem.Crear(libro, autor); err != nil {
+		return err
+	}
+	return r.confirmar()
+}
+
+// CrearLote guarda varios libros nuevos y reescribe el archivo una sola vez.
+func (r *RepositorioJSON) CrearLote(lista []models.Libro, autor models.Auditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.mem.CrearLote(lista, autor); err != nil {
+		return err
+	}
+	return r.confirmar()
+}
+
+// Actualizar guarda los datos del libro y reescribe el archivo.
+func (r *RepositorioJSON) Actualizar(libro models.Libro, reemplazarArchivo bool, autor models.Auditoria) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	despues, err := r.mem.Actualizar(libro, reemplazarArchivo, autor)
+	if err != nil {
+		return despues, err
+	}
+	return despues, r.confirmar()
+}
+
+// Eliminar borra un libro y reescribe el archivo.
+func (r *RepositorioJSON) Eliminar(id int, autor models.Auditoria) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	antes, err := r.mem.Eliminar(id, autor)
+	if err != nil {
+		return antes, err
+	}
+	return antes, r.confirmar()
+}
+
+// Auditoria devuelve los cambios guardados en el archivo, en orden.
+func (r *RepositorioJSON) Auditoria() []models.Auditoria {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mem.Auditoria()
+}
+
+// RecorrerAuditoria llama a fn con las entradas que cumplen el filtro, igual
+// que la consulta de MySQL; sirve como auditoria.Fuente.
+func (r *RepositorioJSON) RecorrerAuditoria(f auditoria.Filtro, fn func(models.Auditoria) error) error {
+	return auditoria.RecorrerLista(r.Auditoria(), f, fn)
+}
@@ -0,0 +1,71 @@
+package libros
+
+import (
+	"os"                // Paquete para escribir el archivo en formato anterior.
+	"path/filepath"     // Paquete para la ruta del archivo temporal.
+	"sistema/auditoria" // Filtro de la auditoría.
+	"sistema/models"    // Estructuras Libro y Auditoria.
+	"testing"           // Paquete de pruebas.
+)
+
+func TestRepositorioJSONGuardaLaAuditoria(t *testing.T) {
+	archivo := filepath.Join(t.TempDir(), "libros.json")
+	anterior := `[{"id": 2, "titulo": "Design Patterns", "autor": "Erich Gamma", "anio": 1994}]`
+	if err := os.WriteFile(archivo, []byte(anterior), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NuevoRepositorioJSON(archivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	autor := models.Auditoria{IDActor: 1, Actor: "Ana", Rol: "ADMIN"}
+	nuevo := models.Libro{Titulo: "Refactoring", Autor: "Martin Fowler", LicenciasTotales: 2}
+	if err := repo.Crear(&nuevo, autor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Eliminar(2, autor); err != nil {
+		t.Fatal(err)
+	}
+
+	// Al volver a cargar el archivo siguen los libros y la auditoría, y las
+	// entradas nuevas continúan la numeración.
+	repo, err = NuevoRepositorioJSON(archivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Obtener(nuevo.ID); err != nil {
+		t.Errorf("no se guardó el libro nuevo: %v", err)
+	}
+	if _, err := repo.Obtener(2); err != ErrNoExiste {
+		t.Errorf("el libro eliminado sigue en el archivo: %v", err)
+	}
+	nuevo.Autor = "M. Fowler"
+	if _, err := repo.Actualizar(nuevo, false, autor); err != nil {
+		t.Fatal(err)
+	}
+
+	entradas := repo.Auditoria()
+	if len(entradas) != 3 || entradas[2].ID != 3 || entradas[2].Accion != models.AuditoriaActualizar {
+		t.Fatalf("auditoría inesperada: %+v", entradas)
+	}
+
+	// La pantalla de auditoría filtra igual que la consulta de MySQL: más
+	// nuevas primero, por actor y por acción.
+	var vistas []models.Auditoria
+	err = repo.RecorrerAuditoria(auditoria.Filtro{Actor: "ana"}, func(a models.Auditoria) error {
+		vistas = append(vistas, a)
+		return nil
+	})
+	if err != nil || len(vistas) != 3 || vistas[0].ID != 3 {
+		t.Errorf("filtro por actor: %v %+v", err, vistas)
+	}
+	vistas = nil
+	_ = repo.RecorrerAuditoria(auditoria.Filtro{Accion: models.AuditoriaEliminar}, func(a models.Auditoria) error {
+		vistas = append(vistas, a)
+		return nil
+	})
+	if len(vistas) != 1 || vistas[0].IDLibro != 2 {
+		t.Errorf("filtro por acción: %+v", vistas)
+	}
+}
@@ -0,0 +1,325 @@
+package libros // Paquete libros.
+
+import (
+	"sistema/models" // Estructuras Libro y Auditoria.
+	"sort"           // Paquete para ordenar el listado.
+	"strconv"        // Paquete para el texto de las décadas.
+	"strings"        // Paquete para comparar textos sin distinguir mayúsculas.
+	"sync"           // Paquete para proteger el mapa ante accesos concurrentes.
+	"time"           // Paquete para la fecha de la auditoría.
+)
+
+// RepositorioMemoria guarda los libros en un mapa protegido por mutex, con la
+// auditoría en una lista. Sirve para pruebas y para correr sin base de datos;
+// los datos se pierden al reiniciar el servidor.
+type RepositorioMemoria struct {
+	mu        sync.Mutex           // Protege el mapa y la auditoría.
+	porID     map[int]models.Libro // Clave = ID del libro.
+	siguiente int                  // Último ID asignado.
+	auditoria []models.Auditoria   // Cambios registrados, en orden.
+}
+
+// NuevoRepositorioMemoria crea un repositorio en memoria con los libros dados
+// (conservan su ID; los nuevos siguen desde el mayor).
+func NuevoRepositorioMemoria(libros ...models.Libro) *RepositorioMemoria {
+	r := &RepositorioMemoria{
+		porID: make(map[int]models.Libro),
+	}
+	for _, l := range libros {
+		// Sin total de licencias (libros.json anterior): el stock era el total.
+		if l.LicenciasTotales == 0 {
+			l.LicenciasTotales = l.StockLicencias
+		}
+		r.porID[l.ID] = l
+		if l.ID > r.siguiente {
+			r.siguiente = l.ID
+		}
+	}
+	return r
+}
+
+// Listar devuelve los libros que cumplen el filtro.
+func (r *RepositorioMemoria) Listar(f Filtro) ([]models.Libro, error) {
+	r.mu.Lock()
+	libros := r.filtrar(f)
+	r.mu.Unlock()
+
+	orden := ordenBase(f.Orden)
+	sort.Slice(libros, func(i, j int) bool {
+		return comparar(orden, posicion(orden, libros[i], 0), posicion(orden, libros[j], 0)) < 0
+	})
+
+	if f.Desplazamiento >= len(libros) {
+		return nil, nil
+	}
+	libros = libros[max(f.Desplazamiento, 0):]
+	if f.Limite > 0 && f.Limite < len(libros) {
+		libros = libros[:f.Limite]
+	}
+	return libros, nil
+}
+
+// Pagina devuelve una página de libros a partir del cursor del filtro.
+func (r *RepositorioMemoria) Pagina(f Filtro) (Pagina, error) {
+	todos := f
+	todos.Limite, todos.Desplazamiento = 0, 0
+	lista, err := r.Listar(todos)
+	if err != nil {
+		return Pagina{}, err
+	}
+	return paginar(lista, ordenBase(f.Orden), f, nil)
+}
+
+// Recorrer llama a fn con cada libro que cumple el filtro. Los libros se
+// copian antes de recorrerlos, así fn puede usar el repositorio.
+func (r *RepositorioMemoria) Recorrer(f Filtro, fn func(models.Libro) error) error {
+	lista, err := r.Listar(f)
+	if err != nil {
+		return err
+	}
+	for _, l := range lista {
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contar devuelve cuántos libros cumplen el filtro.
+func (r *RepositorioMemoria) Contar(f Filtro) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.filtrar(f)), nil
+}
+
+// Facetas cuenta los libros por cada valor de los filtros del catálogo.
+func (r *RepositorioMemoria) Facetas(f Filtro) (Facetas, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var facetas Facetas
+
+	sinCategoria := f
+	sinCategoria.Categoria = ""
+	facetas.Categorias = agrupar(r.filtrar(sinCategoria), func(l models.Libro) string { return l.Categoria })
+
+	sinFormato := f
+	sinFormato.Formato = ""
+	facetas.Formatos = agrupar(r.filtrar(sinFormato), func(l models.Libro) string { return l.Formato })
+
+	// Las décadas se ordenan como número ("990" va antes que "1990").
+	sinAnios := f
+	sinAnios.AnioDesde, sinAnios.AnioHasta = 0, 0
+	facetas.Decadas = agrupar(r.filtrar(sinAnios), func(l models.Libro) string { return strconv.Itoa(l.AnioPublicacion / 10 * 10) })
+	sort.SliceStable(facetas.Decadas, func(i, j int) bool { return len(facetas.Decadas[i].Valor) < len(facetas.Decadas[j].Valor) })
+
+	disponibles := f
+	disponibles.Disponibles = true
+	facetas.Disponibles = len(r.filtrar(disponibles))
+	return facetas, nil
+}
+
+// Estadisticas cuenta los libros del catálogo por formato.
+func (r *RepositorioMemoria) Estadisticas() (Estadisticas, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e := Estadisticas{TotalLibros: len(r.porID)}
+	for _, l := range r.porID {
+		switch l.Formato {
+		case "PDF":
+			e.TotalPDF++
+		case "EPUB":
+			e.TotalEPUB++
+		case "MOBI":
+			e.TotalMOBI++
+		}
+	}
+	return e, nil
+}
+
+// Obtener devuelve todos los campos de un libro, o ErrNoExiste.
+func (r *RepositorioMemoria) Obtener(id int) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l, ok := r.porID[id]
+	if !ok {
+		return models.Libro{}, ErrNoExiste
+	}
+	return l, nil
+}
+
+// ArchivoAsignado indica si algún libro usa la clave de archivo.
+func (r *RepositorioMemoria) ArchivoAsignado(clave string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, l := range r.porID {
+		if l.Archivo == clave {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Coincidencias devuelve los libros con el mismo título y autor, o el mismo
+// ISBN, que alguno de la lista.
+func (r *RepositorioMemoria) Coincidencias(lista []models.Libro) ([]models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var coincidencias []models.Libro
+	for _, existente := range r.porID {
+		for _, l := range lista {
+			if mismoLibro(existente, l) {
+				coincidencias = append(coincidencias, existente)
+				break
+			}
+		}
+	}
+	sort.Slice(coincidencias, func(i, j int) bool { return coincidencias[i].ID < coincidencias[j].ID })
+	return coincidencias, nil
+}
+
+// Crear guarda un libro nuevo, le asigna el siguiente ID y registra el alta.
+func (r *RepositorioMemoria) Crear(libro *models.Libro, autor models.Auditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crear(libro, autor)
+	return nil
+}
+
+// CrearLote guarda varios libros nuevos y registra el alta de cada uno.
+func (r *RepositorioMemoria) CrearLote(lista []models.Libro, autor models.Auditoria) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range lista {
+		r.crear(&lista[i], autor)
+	}
+	return nil
+}
+
+// crear asigna el siguiente ID, guarda el libro y registra el alta (con el mutex tomado).
+func (r *RepositorioMemoria) crear(libro *models.Libro, autor models.Auditoria) {
+	prepararAlta(libro)
+	r.siguiente++
+	libro.ID = r.siguiente
+	r.porID[libro.ID] = *libro
+	r.registrar(entradaAuditoria(autor, models.AuditoriaCrear, libro.ID, nil, libro))
+}
+
+// Actualizar guarda los datos del libro y registra el estado anterior y el nuevo.
+func (r *RepositorioMemoria) Actualizar(libro models.Libro, reemplazarArchivo bool, autor models.Auditoria) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	antes, ok := r.porID[libro.ID]
+	if !ok {
+		return libro, ErrNoExiste
+	}
+	libres, err := licenciasLibres(antes, libro)
+	if err != nil {
+		return libro, err
+	}
+	libro.StockLicencias = libres
+	if !reemplazarArchivo {
+		libro.Archivo = antes.Archivo
+		libro.Paginas = antes.Paginas
+	}
+	r.porID[libro.ID] = libro
+	r.registrar(entradaAuditoria(autor, models.AuditoriaActualizar, libro.ID, &antes, &libro))
+	return libro, nil
+}
+
+// Eliminar borra un libro, registra cómo era y lo devuelve. Como aquí no hay
+// tablas de préstamos, un libro con licencias fuera del stock está en uso.
+func (r *RepositorioMemoria) Eliminar(id int, autor models.Auditoria) (models.Libro, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	antes, ok := r.porID[id]
+	if !ok {
+		return models.Libro{}, ErrNoExiste
+	}
+	if antes.StockLicencias < antes.LicenciasTotales {
+		return models.Libro{}, ErrLibroEnUso
+	}
+	delete(r.porID, id)
+	r.registrar(entradaAuditoria(autor, models.AuditoriaEliminar, id, &antes, nil))
+	return antes, nil
+}
+
+// Auditoria devuelve una copia de los cambios registrados, en orden.
+func (r *RepositorioMemoria) Auditoria() []models.Auditoria {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.Auditoria(nil), r.auditoria...)
+}
+
+// registrar agrega una entrada a la auditoría (con el mutex tomado).
+func (r *RepositorioMemoria) registrar(a models.Auditoria) {
+	a.ID = len(r.auditoria) + 1
+	a.Fecha = time.Now()
+	r.auditoria = append(r.auditoria, a)
+}
+
+// mismoLibro indica si dos libros comparten alguna clave de ClavesLibro.
+func mismoLibro(a, b models.Libro) bool {
+	for _, ca := range ClavesLibro(a) {
+		for _, cb := range ClavesLibro(b) {
+			if ca == cb {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// agrupar cuenta los libros por el valor que devuelve clave, en orden alfabético.
+// Los valores vacíos no se cuentan.
+func agrupar(libros []models.Libro, clave func(models.Libro) string) []Faceta {
+	cantidades := make(map[string]int)
+	for _, l := range libros {
+		if v := clave(l); v != "" {
+			cantidades[v]++
+		}
+	}
+	facetas := make([]Faceta, 0, len(cantidades))
+	for v, n := range cantidades {
+		facetas = append(facetas, Faceta{Valor: v, Cantidad: n})
+	}
+	sort.Slice(facetas, func(i, j int) bool { return facetas[i].Valor < facetas[j].Valor })
+	return facetas
+}
+
+// filtrar devuelve, sin orden, los libros que cumplen el filtro (con el mutex tomado).
+// Las comparaciones de texto no distinguen mayúsculas, igual que en MySQL.
+func (r *RepositorioMemoria) filtrar(f Filtro) []models.Libro {
+	contiene := func(texto, parte string) bool {
+		return strings.Contains(strings.ToLower(texto), strings.ToLower(parte))
+	}
+
+	var incluidos map[int]bool
+	if f.IDs != nil {
+		incluidos = make(map[int]bool, len(f.IDs))
+		for _, id := range f.IDs {
+			incluidos[id] = true
+		}
+	}
+
+	var libros []models.Libro
+	for _, l := range r.porID {
+		switch {
+		case incluidos != nil && !incluidos[l.ID]:
+		case f.Buscar != "" && !contiene(l.Titulo, f.Buscar) && !contiene(l.Autor, f.Buscar) && !contiene(l.Categoria, f.Buscar):
+		case f.Autor != "" && !contiene(l.Autor, f.Autor):
+		case f.Categoria != "" && !strings.EqualFold(l.Categoria, f.Categoria):
+		case f.Formato != "" && !strings.EqualFold(l.Formato, f.Formato):
+		case f.AnioDesde != 0 && l.AnioPublicacion < f.AnioDesde:
+		case f.AnioHasta != 0 && l.AnioPublicacion > f.AnioHasta:
+		case f.Disponibles && l.StockLicencias <= 0:
+		default:
+			libros = append(libros, l)
+		}
+	}
+	return libros
+}
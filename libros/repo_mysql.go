@@ -0,0 +1,565 @@
+package libros // Paquete libros.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"sistema/auditoria" // Registro de cambios del catálogo.
+	"sistema/models"    // Estructuras Libro y Auditoria.
+	"slices"            // Paquete para dar vuelta las páginas hacia atrás.
+	"strings"           // Paquete para armar filtros.
+)
+
+// columnasLibro son las columnas que lee escanearLibro, en el mismo orden.
+const columnasLibro = `id, titulo, autor, categoria, anio_publicacion, formato, stock_licencias, licencias_totales, COALESCE(archivo, ''),
+	COALESCE(idioma, ''), COALESCE(editorial, ''), COALESCE(isbn, ''), COALESCE(paginas, 0)`
+
+// loteCoincidencias es la cantidad de libros que Coincidencias busca por consulta.
+const loteCoincidencias = 200
+
+// RepositorioMySQL guarda los libros en la tabla "libros" y sus cambios en "auditoria".
+type RepositorioMySQL struct {
+	DB *sql.DB // Conexión a la base de datos.
+}
+
+// NuevoRepositorioMySQL crea un repositorio de libros respaldado por MySQL.
+func NuevoRepositorioMySQL(db *sql.DB) *RepositorioMySQL {
+	return &RepositorioMySQL{DB: db}
+}
+
+// Listar devuelve los libros que cumplen el filtro.
+func (r *RepositorioMySQL) Listar(f Filtro) ([]models.Libro, error) {
+	var libros []models.Libro
+	err := r.Recorrer(f, func(l models.Libro) error {
+		libros = append(libros, l)
+		return nil
+	})
+	return libros, err
+}
+
+// Recorrer llama a fn con cada libro que cumple el filtro, a medida que MySQL
+// devuelve las filas.
+func (r *RepositorioMySQL) Recorrer(f Filtro, fn func(models.Libro) error) error {
+	where, args := condicionesSQL(f)
+
+	query := "SELECT " + columnasLibro + " FROM libros " + where + " ORDER BY " + ordenSQL(clavesOrden(ordenBase(f.Orden)), false)
+	if f.Limite > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limite, f.Desplazamiento)
+	}
+	return r.recorrer(fn, query, args...)
+}
+
+// Pagina devuelve una página de libros a partir del cursor del filtro. En vez
+// de OFFSET, el WHERE empieza después del libro del cursor, así MySQL no lee
+// las filas de las páginas anteriores. Hacia atrás se consulta en orden
+// inverso y se da vuelta el resultado.
+func (r *RepositorioMySQL) Pagina(f Filtro) (Pagina, error) {
+	orden := ordenBase(f.Orden)
+	claves := clavesOrden(orden)
+	where, args := condicionesSQL(f)
+
+	var c cursor
+	if f.Cursor != "" {
+		var err error
+		if c, err = leerCursor(f.Cursor, orden); err != nil {
+			return Pagina{}, err
+		}
+		condicion, argsCursor := despuesDe(claves, c, 0)
+		if where == "" {
+			where = "WHERE " + condicion
+		} else {
+			where += " AND " + condicion
+		}
+		args = append(args, argsCursor...)
+	}
+
+	// Se pide un libro de más para saber si hay otra página.
+	query := "SELECT " + columnasLibro + " FROM libros " + where + " ORDER BY " + ordenSQL(claves, c.Atras)
+	if f.Limite > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limite+1)
+	}
+	lista, err := r.consultar(query, args...)
+	if err != nil {
+		return Pagina{}, err
+	}
+	hayMas := f.Limite > 0 && len(lista) > f.Limite
+	if hayMas {
+		lista = lista[:f.Limite]
+	}
+	if c.Atras {
+		slices.Reverse(lista)
+	}
+
+	pagina := Pagina{Libros: lista}
+	if len(lista) == 0 {
+		return pagina, nil
+	}
+	// Hacia adelante, hay página anterior si se llegó con un cursor; hacia
+	// atrás, siempre hay siguiente (la página desde la que se volvió).
+	haySiguiente, hayAnterior := hayMas, f.Cursor != ""
+	if c.Atras {
+		haySiguiente, hayAnterior = true, hayMas
+	}
+	if haySiguiente {
+		pagina.Siguiente = posicion(orden, lista[len(lista)-1], 0).texto()
+	}
+	if hayAnterior {
+		anterior := posicion(orden, lista[0], 0)
+		anterior.Atras = true
+		pagina.Anterior = anterior.texto()
+	}
+	return pagina, nil
+}
+
+// consultar ejecuta una consulta que devuelve las columnas de columnasLibro.
+func (r *RepositorioMySQL) consultar(query string, args ...any) ([]models.Libro, error) {
+	var libros []models.Libro
+	err := r.recorrer(func(l models.Libro) error {
+		libros = append(libros, l)
+		return nil
+	}, query, args...)
+	return libros, err
+}
+
+// recorrer ejecuta una consulta que devuelve las columnas de columnasLibro y
+// llama a fn con cada libro leído.
+func (r *RepositorioMySQL) recorrer(fn func(models.Libro) error, query string, args ...any) error {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		libro, err := escanearLibro(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(libro); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Contar devuelve cuántos libros cumplen el filtro.
+func (r *RepositorioMySQL) Contar(f Filtro) (int, error) {
+	where, args := condicionesSQL(f)
+	var total int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM libros "+where, args...).Scan(&total)
+	return total, err
+}
+
+// Facetas cuenta los libros por cada valor de los filtros del catálogo.
+func (r *RepositorioMySQL) Facetas(f Filtro) (Facetas, error) {
+	var (
+		facetas Facetas
+		err     error
+	)
+
+	sinCategoria := f
+	sinCategoria.Categoria = ""
+	if facetas.Categorias, err = r.agrupar("categoria", sinCategoria); err != nil {
+		return facetas, err
+	}
+
+	sinFormato := f
+	sinFormato.Formato = ""
+	if facetas.Formatos, err = r.agrupar("formato", sinFormato); err != nil {
+		return facetas, err
+	}
+
+	sinAnios := f
+	sinAnios.AnioDesde, sinAnios.AnioHasta = 0, 0
+	if facetas.Decadas, err = r.agrupar("FLOOR(anio_publicacion / 10) * 10", sinAnios); err != nil {
+		return facetas, err
+	}
+
+	disponibles := f
+	disponibles.Disponibles = true
+	facetas.Disponibles, err = r.Contar(disponibles)
+	return facetas, err
+}
+
+// agrupar cuenta los libros que cumplen el filtro por cada valor de la
+// expresión (una columna o un cálculo sobre ella). Los valores vacíos no se cuentan.
+func (r *RepositorioMySQL) agrupar(expresion string, f Filtro) ([]Faceta, error) {
+	where, args := condicionesSQL(f)
+	query := "SELECT " + expresion + " AS valor, COUNT(*) FROM libros " + where + " GROUP BY valor ORDER BY valor"
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facetas []Faceta
+	for rows.Next() {
+		var faceta Faceta
+		if err := rows.Scan(&faceta.Valor, &faceta.Cantidad); err != nil {
+			return nil, err
+		}
+		if faceta.Valor != "" {
+			facetas = append(facetas, faceta)
+		}
+	}
+	return facetas, rows.Err()
+}
+
+// Estadisticas cuenta los libros del catálogo por formato.
+func (r *RepositorioMySQL) Estadisticas() (Estadisticas, error) {
+	var e Estadisticas
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM libros) AS total_libros,
+			(SELECT COUNT(*) FROM libros WHERE formato = 'PDF') AS total_pdf,
+			(SELECT COUNT(*) FROM libros WHERE formato = 'EPUB') AS total_epub,
+			(SELECT COUNT(*) FROM libros WHERE formato = 'MOBI') AS total_mobi
+	`
+	err := r.DB.QueryRow(query).Scan(&e.TotalLibros, &e.TotalPDF, &e.TotalEPUB, &e.TotalMOBI)
+	return e, err
+}
+
+// Obtener devuelve todos los campos de un libro, o ErrNoExiste.
+func (r *RepositorioMySQL) Obtener(id int) (models.Libro, error) {
+	return leerLibro(r.DB, id, false)
+}
+
+// ArchivoAsignado indica si algún libro usa la clave de archivo.
+func (r *RepositorioMySQL) ArchivoAsignado(clave string) (bool, error) {
+	var usos int
+	err := r.DB.QueryRow(`SELECT COUNT(*) FROM libros WHERE archivo = ?`, clave).Scan(&usos)
+	return usos > 0, err
+}
+
+// Coincidencias devuelve los libros que comparten alguna clave de ClavesLibro
+// con alguno de la lista. Las claves se comparan en Go, igual que en la
+// importación (un IN sobre titulo y autor no ve los espacios de más): se leen
+// el título, el autor y el ISBN de todo el catálogo y después los libros que
+// coinciden, de a loteCoincidencias.
+func (r *RepositorioMySQL) Coincidencias(lista []models.Libro) ([]models.Libro, error) {
+	buscadas := make(map[string]bool)
+	for _, l := range lista {
+		for _, clave := range ClavesLibro(l) {
+			buscadas[clave] = true
+		}
+	}
+	if len(buscadas) == 0 {
+		return nil, nil
+	}
+
+	ids, err := r.idsCoincidentes(buscadas)
+	if err != nil {
+		return nil, err
+	}
+
+	var coincidencias []models.Libro
+	for inicio := 0; inicio < len(ids); inicio += loteCoincidencias {
+		lote := ids[inicio:min(inicio+loteCoincidencias, len(ids))]
+		marcas := make([]string, len(lote))
+		args := make([]any, len(lote))
+		for i, id := range lote {
+			marcas[i] = "?"
+			args[i] = id
+		}
+		encontrados, err := r.consultar("SELECT "+columnasLibro+" FROM libros WHERE id IN ("+strings.Join(marcas, ", ")+") ORDER BY id", args...)
+		if err != nil {
+			return nil, err
+		}
+		coincidencias = append(coincidencias, encontrados...)
+	}
+	return coincidencias, nil
+}
+
+// idsCoincidentes devuelve, en orden, los IDs de los libros con alguna clave buscada.
+func (r *RepositorioMySQL) idsCoincidentes(buscadas map[string]bool) ([]int, error) {
+	rows, err := r.DB.Query(`SELECT id, titulo, autor, COALESCE(isbn, '') FROM libros ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var l models.Libro
+		if err := rows.Scan(&l.ID, &l.Titulo, &l.Autor, &l.ISBN); err != nil {
+			return nil, err
+		}
+		for _, clave := range ClavesLibro(l) {
+			if buscadas[clave] {
+				ids = append(ids, l.ID)
+				break
+			}
+		}
+	}
+	return ids, rows.Err()
+}
+
+// Crear inserta el libro y registra el alta en la auditoría, en una misma
+// transacción. Asigna el ID generado a libro.
+func (r *RepositorioMySQL) Crear(libro *models.Libro, autor models.Auditoria) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertarLibro(tx, libro, autor); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CrearLote inserta los libros y registra el alta de cada uno en la auditoría,
+// todo en una misma transacción. Asigna el ID generado a cada libro.
+func (r *RepositorioMySQL) CrearLote(lista []models.Libro, autor models.Auditoria) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range lista {
+		if err := insertarLibro(tx, &lista[i], autor); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertarLibro inserta el libro dentro de la transacción, le asigna el ID
+// generado y registra el alta en la auditoría.
+func insertarLibro(tx *sql.Tx, libro *models.Libro, autor models.Auditoria) error {
+	prepararAlta(libro)
+	query := `
+		INSERT INTO libros (titulo, autor, categoria, anio_publicacion, formato, stock_licencias, licencias_totales, archivo, idioma, editorial, isbn, paginas)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0))
+	`
+	res, err := tx.Exec(query, libro.Titulo, libro.Autor, libro.Categoria, libro.AnioPublicacion, libro.Formato, libro.StockLicencias, libro.LicenciasTotales, libro.Archivo, libro.Idioma, libro.Editorial, libro.ISBN, libro.Paginas)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	libro.ID = int(id)
+
+	return auditoria.Registrar(tx, entradaAuditoria(autor, models.AuditoriaCrear, libro.ID, nil, libro))
+}
+
+// Actualizar guarda los cambios del libro y registra en la auditoría el estado
+// anterior y el nuevo, en una misma transacción.
+func (r *RepositorioMySQL) Actualizar(libro models.Libro, reemplazarArchivo bool, autor models.Auditoria) (models.Libro, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return libro, err
+	}
+	defer tx.Rollback()
+
+	// Se valida existencia antes de actualizar (RowsAffected es 0 si no hay cambios).
+	// La fila queda bloqueada, así ningún préstamo cambia el stock hasta el commit.
+	antes, err := leerLibro(tx, libro.ID, true)
+	if err != nil {
+		return libro, err
+	}
+	libro.StockLicencias, err = licenciasLibres(antes, libro)
+	if err != nil {
+		return libro, err
+	}
+
+	query := `
+		UPDATE libros
+		SET titulo = ?, autor = ?, categoria = ?, anio_publicacion = ?, formato = ?, stock_licencias = ?, licencias_totales = ?,
+			idioma = NULLIF(?, ''), editorial = NULLIF(?, ''), isbn = NULLIF(?, ''),
+			archivo = IF(?, NULLIF(?, ''), archivo), paginas = IF(?, NULLIF(?, 0), paginas)
+		WHERE id = ?
+	`
+	_, err = tx.Exec(query, libro.Titulo, libro.Autor, libro.Categoria, libro.AnioPublicacion, libro.Formato, libro.StockLicencias, libro.LicenciasTotales,
+		libro.Idioma, libro.Editorial, libro.ISBN, reemplazarArchivo, libro.Archivo, reemplazarArchivo, libro.Paginas, libro.ID)
+	if err != nil {
+		return libro, err
+	}
+
+	despues, err := leerLibro(tx, libro.ID, false)
+	if err != nil {
+		return libro, err
+	}
+	if err := auditoria.Registrar(tx, entradaAuditoria(autor, models.AuditoriaActualizar, libro.ID, &antes, &despues)); err != nil {
+		return libro, err
+	}
+	return despues, tx.Commit()
+}
+
+// Eliminar borra el libro y registra en la auditoría cómo era, en una misma
+// transacción. No borra libros con préstamos sin devolver o reservas en la
+// cola (ESPERANDO o ASIGNADA): quedarían huérfanos.
+func (r *RepositorioMySQL) Eliminar(id int, autor models.Auditoria) (models.Libro, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return models.Libro{}, err
+	}
+	defer tx.Rollback()
+
+	antes, err := leerLibro(tx, id, true)
+	if err != nil {
+		return models.Libro{}, err
+	}
+	var prestados, enCola int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM prestamos WHERE id_libro = ? AND devuelto_en IS NULL FOR UPDATE`, id).Scan(&prestados)
+	if err != nil {
+		return models.Libro{}, err
+	}
+	err = tx.QueryRow(`SELECT COUNT(*) FROM reservas WHERE id_libro = ? AND estado IN (?, ?) FOR UPDATE`,
+		id, models.ReservaEsperando, models.ReservaAsignada).Scan(&enCola)
+	if err != nil {
+		return models.Libro{}, err
+	}
+	if prestados+enCola > 0 {
+		return models.Libro{}, ErrLibroEnUso
+	}
+
+	if _, err := tx.Exec(`DELETE FROM libros WHERE id = ?`, id); err != nil {
+		return models.Libro{}, err
+	}
+	if err := auditoria.Registrar(tx, entradaAuditoria(autor, models.AuditoriaEliminar, id, &antes, nil)); err != nil {
+		return models.Libro{}, err
+	}
+	return antes, tx.Commit()
+}
+
+// condicionesSQL arma el WHERE del filtro y sus argumentos.
+func condicionesSQL(f Filtro) (string, []any) {
+	var (
+		condiciones []string
+		args        []any
+	)
+	if f.Buscar != "" {
+		condiciones = append(condiciones, "(titulo LIKE ? OR autor LIKE ? OR categoria LIKE ?)")
+		filtro := "%" + f.Buscar + "%"
+		args = append(args, filtro, filtro, filtro)
+	}
+	if f.Autor != "" {
+		condiciones = append(condiciones, "autor LIKE ?")
+		args = append(args, "%"+f.Autor+"%")
+	}
+	if f.Categoria != "" {
+		condiciones = append(condiciones, "categoria = ?")
+		args = append(args, f.Categoria)
+	}
+	if f.Formato != "" {
+		condiciones = append(condiciones, "formato = ?")
+		args = append(args, f.Formato)
+	}
+	if f.AnioDesde != 0 {
+		condiciones = append(condiciones, "anio_publicacion >= ?")
+		args = append(args, f.AnioDesde)
+	}
+	if f.AnioHasta != 0 {
+		condiciones = append(condiciones, "anio_publicacion <= ?")
+		args = append(args, f.AnioHasta)
+	}
+	if f.Disponibles {
+		condiciones = append(condiciones, "stock_licencias > 0")
+	}
+	if f.IDs != nil {
+		if len(f.IDs) == 0 {
+			condiciones = append(condiciones, "1 = 0")
+		} else {
+			condiciones = append(condiciones, "id IN (?"+strings.Repeat(", ?", len(f.IDs)-1)+")")
+			for _, id := range f.IDs {
+				args = append(args, id)
+			}
+		}
+	}
+
+	if len(condiciones) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(condiciones, " AND "), args
+}
+
+// ordenSQL arma el ORDER BY de las claves; con invertir=true, en sentido contrario.
+func ordenSQL(claves []claveOrden, invertir bool) string {
+	partes := make([]string, 0, len(claves))
+	for _, k := range claves {
+		if k.desc != invertir {
+			partes = append(partes, k.columna+" DESC")
+		} else {
+			partes = append(partes, k.columna+" ASC")
+		}
+	}
+	return strings.Join(partes, ", ")
+}
+
+// despuesDe arma la condición de los libros que van después del cursor (antes,
+// si c.Atras) a partir de la clave desde, por ejemplo para titulo, id:
+// (titulo > ? OR (titulo = ? AND id > ?)).
+func despuesDe(claves []claveOrden, c cursor, desde int) (string, []any) {
+	k := claves[desde]
+	operador := ">"
+	if k.desc != c.Atras {
+		operador = "<"
+	}
+	valor := c.valor(k.columna)
+	if desde == len(claves)-1 {
+		return k.columna + " " + operador + " ?", []any{valor}
+	}
+	resto, args := despuesDe(claves, c, desde+1)
+	return "(" + k.columna + " " + operador + " ? OR (" + k.columna + " = ? AND " + resto + "))", append([]any{valor, valor}, args...)
+}
+
+// consultorFila es una conexión (*sql.DB) o transacción (*sql.Tx) que lee una fila.
+type consultorFila interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// leerLibro lee todos los campos de un libro, incluido el archivo. Con
+// bloquear=true (dentro de una transacción) bloquea la fila hasta terminar el
+// cambio. Devuelve ErrNoExiste si no existe.
+func leerLibro(db consultorFila, id int, bloquear bool) (models.Libro, error) {
+	query := "SELECT " + columnasLibro + " FROM libros WHERE id = ?"
+	if bloquear {
+		query += " FOR UPDATE"
+	}
+
+	libro, err := escanearLibro(db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return libro, ErrNoExiste
+	}
+	return libro, err
+}
+
+// escaneable es una fila (*sql.Row) o un cursor (*sql.Rows).
+type escaneable interface {
+	Scan(dest ...any) error
+}
+
+// escanearLibro lee las columnas de columnasLibro.
+func escanearLibro(fila escaneable) (models.Libro, error) {
+	var libro models.Libro
+	err := fila.Scan(
+		&libro.ID,
+		&libro.Titulo,
+		&libro.Autor,
+		&libro.Categoria,
+		&libro.AnioPublicacion,
+		&libro.Formato,
+		&libro.StockLicencias,
+		&libro.LicenciasTotales,
+		&libro.Archivo,
+		&libro.Idioma,
+		&libro.Editorial,
+		&libro.ISBN,
+		&libro.Paginas,
+	)
+	return libro, err
+}
+
+// entradaAuditoria completa la entrada del autor con la acción y los cambios.
+// antes es nil al crear y despues es nil al eliminar.
+func entradaAuditoria(autor models.Auditoria, accion string, idLibro int, antes, despues *models.Libro) models.Auditoria {
+	autor.Accion = accion
+	autor.IDLibro = idLibro
+	autor.Cambios = auditoria.Diferencias(antes, despues)
+	return autor
+}
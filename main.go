@@ -1,171 +1,692 @@
-package main // Paquete principal: punto de entrada de la aplicación.
-
-import (
-	"html/template"    // Paquete para cargar y renderizar plantillas HTML.
-	"log"              // Paquete para imprimir mensajes en consola.
-	"net/http"         // Paquete para crear servidor web y manejar rutas HTTP.
-	"sistema/db"       // Paquete local para la conexión con MySQL.
-	"sistema/handlers" // Paquete local con handlers de libros, auth y catálogo.
-)
-
-func main() {
-	// =========================================================
-	// 1) CONEXIÓN A LA BASE DE DATOS
-	// =========================================================
-
-	// Se crea la conexión a MySQL usando la función del paquete db.
-	conexion := db.ConectarDB()
-
-	// Se asegura que la conexión se cierre cuando termine la aplicación.
-	defer conexion.Close()
-
-	// =========================================================
-	// 2) CARGA DE PLANTILLAS HTML
-	// =========================================================
-
-	// Se cargan todas las plantillas HTML de la carpeta "templates".
-	templates, err := template.ParseGlob("templates/*.html")
-	if err != nil {
-		// Si falla la carga de plantillas, se detiene la ejecución.
-		log.Fatal("❌ Error al cargar plantillas: ", err)
-	}
-
-	// =========================================================
-	// 3) CREACIÓN DE HANDLERS (CONTROLADORES)
-	// =========================================================
-
-	// Handler del módulo de libros (CRUD + dashboard + búsqueda).
-	libroHandler := handlers.NuevoLibroHandler(conexion, templates)
-
-	// Handler del módulo de autenticación (login / logout / cookies).
-	authHandler := handlers.NuevoAuthHandler(conexion, templates)
-
-	// Handler del módulo catálogo (usuario lector).
-	catalogoHandler := handlers.NuevoCatalogoHandler(conexion, templates)
-
-	// =========================================================
-	// 4) ARCHIVOS ESTÁTICOS (CSS, PDF demo, etc.)
-	// =========================================================
-
-	// Esta ruta permite servir archivos de la carpeta "static".
-	// Ejemplo:
-	//   URL:  /static/style.css
-	//   File: static/style.css
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
-
-	// =========================================================
-	// 5) RUTAS PÚBLICAS (AUTENTICACIÓN)
-	// =========================================================
-
-	// Ruta GET: muestra formulario de login.
-	http.HandleFunc("/login", authHandler.MostrarLogin)
-
-	// Ruta POST: procesa login (valida usuario/clave y crea cookies).
-	http.HandleFunc("/login/procesar", authHandler.ProcesarLogin)
-
-	// Ruta GET: cierra sesión y elimina cookies.
-	http.HandleFunc("/logout", authHandler.Logout)
-
-	// =========================================================
-	// 6) RUTAS DEL CATÁLOGO (USUARIO LECTOR)
-	//    Requieren login, pero no rol específico.
-	// =========================================================
-
-	// Ruta GET: muestra catálogo de libros.
-	http.HandleFunc("/catalogo", RequiereLogin(catalogoHandler.VerCatalogo))
-
-	// Ruta GET: muestra detalle de un libro.
-	http.HandleFunc("/catalogo/detalle", RequiereLogin(catalogoHandler.VerDetalleLibro))
-
-	// Ruta GET: descarga PDF demo del libro (flujo de demostración).
-	http.HandleFunc("/catalogo/descargar", RequiereLogin(catalogoHandler.DescargarLibroDemo))
-
-	// =========================================================
-	// 7) RUTAS DEL PANEL ADMINISTRATIVO / CRUD DE LIBROS
-	//    Requieren login + control por roles.
-	// =========================================================
-
-	// Ruta GET: panel principal (dashboard + listado + búsqueda).
-	http.HandleFunc("/", RequiereLogin(libroHandler.Index))
-
-	// Rutas CREATE (solo ADMIN y OPERADOR).
-	http.HandleFunc("/libros/nuevo", RequiereLoginYRol(libroHandler.NuevoLibroForm, "ADMIN", "OPERADOR"))
-	http.HandleFunc("/libros/crear", RequiereLoginYRol(libroHandler.CrearLibro, "ADMIN", "OPERADOR"))
-
-	// Rutas UPDATE (solo ADMIN y OPERADOR).
-	http.HandleFunc("/libros/editar", RequiereLoginYRol(libroHandler.EditarLibroForm, "ADMIN", "OPERADOR"))
-	http.HandleFunc("/libros/actualizar", RequiereLoginYRol(libroHandler.ActualizarLibro, "ADMIN", "OPERADOR"))
-
-	// Ruta DELETE (solo ADMIN).
-	http.HandleFunc("/libros/eliminar", RequiereLoginYRol(libroHandler.EliminarLibro, "ADMIN"))
-
-	// =========================================================
-	// 8) INICIO DEL SERVIDOR WEB
-	// =========================================================
-
-	// Mensaje en consola con la URL del sistema.
-	log.Println("🚀 Servidor iniciado en http://localhost:8082")
-
-	// Inicia servidor HTTP en puerto 8082.
-	err = http.ListenAndServe(":8082", nil)
-	if err != nil {
-		// Si falla el servidor, se muestra error y se detiene la app.
-		log.Fatal("❌ Error al iniciar servidor: ", err)
-	}
-}
-
-// =========================================================
-// MIDDLEWARE: REQUIERE LOGIN
-// =========================================================
-
-// RequiereLogin protege rutas que exigen sesión iniciada.
-// Recibe un handler y devuelve un handler protegido.
-func RequiereLogin(next http.HandlerFunc) http.HandlerFunc {
-	// Retorna una función wrapper.
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Verifica si el usuario está autenticado usando cookies.
-		if !handlers.EstaLogueado(r) {
-			// Si no está logueado, redirige al login.
-			http.Redirect(w, r, "/login?error=Debe+iniciar+sesión", http.StatusSeeOther)
-			return
-		}
-
-		// Si está autenticado, ejecuta el handler original.
-		next(w, r)
-	}
-}
-
-// =========================================================
-// MIDDLEWARE: REQUIERE LOGIN + ROL
-// =========================================================
-
-// RequiereLoginYRol protege rutas que exigen:
-// 1) sesión iniciada
-// 2) rol permitido (ej. ADMIN, OPERADOR)
-func RequiereLoginYRol(next http.HandlerFunc, rolesPermitidos ...string) http.HandlerFunc {
-	// Retorna una función wrapper.
-	return func(w http.ResponseWriter, r *http.Request) {
-		// -----------------------------------------------------
-		// 1) VALIDAR SESIÓN
-		// -----------------------------------------------------
-		if !handlers.EstaLogueado(r) {
-			// Si no hay sesión, redirige al login.
-			http.Redirect(w, r, "/login?error=Debe+iniciar+sesión", http.StatusSeeOther)
-			return
-		}
-
-		// -----------------------------------------------------
-		// 2) VALIDAR ROL
-		// -----------------------------------------------------
-		if !handlers.TieneRol(r, rolesPermitidos...) {
-			// Si no tiene permisos, redirige al panel con mensaje.
-			http.Redirect(w, r, "/?msg=No+tiene+permisos+para+esa+acción", http.StatusSeeOther)
-			return
-		}
-
-		// -----------------------------------------------------
-		// 3) EJECUTAR HANDLER ORIGINAL
-		// -----------------------------------------------------
-		next(w, r)
-	}
-}
+package main // Paquete principal: punto de entrada de la aplicación.
+
+import (
+	"crypto/rand"            // Paquete para generar un secreto de sesión temporal.
+	"database/sql"           // Paquete para la conexión con MySQL (nil con LIBROS_STORE=json).
+	"encoding/hex"           // Paquete para mostrar la clave temporal de la cuenta local.
+	"html/template"          // Paquete para cargar y renderizar plantillas HTML.
+	"log"                    // Paquete para imprimir mensajes en consola.
+	"net/http"               // Paquete para crear servidor web y manejar rutas HTTP.
+	"os"                     // Paquete para leer variables de entorno.
+	"sistema/almacenamiento" // Paquete local con el almacenamiento de archivos de libros.
+	"sistema/auditoria"      // Paquete local con la auditoría del catálogo.
+	"sistema/correo"         // Paquete local con el envío de correos.
+	"sistema/db"             // Paquete local para la conexión con MySQL.
+	"sistema/doblefactor"    // Paquete local con el segundo factor TOTP.
+	"sistema/handlers"       // Paquete local con handlers de libros, auth y catálogo.
+	"sistema/intentos"       // Paquete local con el límite de intentos de inicio de sesión.
+	"sistema/libros"         // Paquete local con el repositorio de libros.
+	"sistema/models"         // Paquete local con la estructura Sesion.
+	"sistema/permisos"       // Paquete local con los permisos de cada rol.
+	"sistema/prestamos"      // Paquete local con los préstamos de licencias.
+	"sistema/sesiones"       // Paquete local con el gestor de sesiones del servidor.
+	"sistema/tokens"         // Paquete local con tokens Bearer y claves de API.
+	"sistema/usuarios"       // Paquete local con la administración de usuarios.
+	"strconv"                // Paquete para leer valores numéricos de configuración.
+	"strings"                // Paquete para normalizar valores de configuración.
+	"time"                   // Paquete para la tarea periódica de vencimientos.
+)
+
+// Servicios de autenticación usados por los middlewares de este archivo.
+var (
+	gestorSesiones *sesiones.Gestor // Sesiones por cookie (navegador).
+	servicioTokens *tokens.Servicio // Tokens Bearer (clientes de la API).
+
+	servicioPermisos *permisos.Servicio // Permisos con nombre asignados a cada rol.
+)
+
+func main() {
+	// "go run . consola" abre el menú de libros sobre el archivo LIBROS_JSON,
+	// sin servidor web ni MySQL.
+	if len(os.Args) > 1 && os.Args[1] == "consola" {
+		if err := ejecutarConsola(archivoLibrosJSON()); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		return
+	}
+
+	// =========================================================
+	// 1) CONEXIÓN A LA BASE DE DATOS
+	// =========================================================
+
+	// LIBROS_STORE elige dónde se guardan los libros: "mysql" (por defecto) o
+	// "json" (archivo LIBROS_JSON, por defecto "libros.json"; útil para demos).
+	// Con "json" el sistema corre sin MySQL: sesiones e intentos de inicio de
+	// sesión quedan en memoria, la auditoría en el archivo de libros y la única
+	// cuenta es un ADMIN local. Cuentas, préstamos, historial, segundo factor y
+	// tokens responden 503 (handlers.SinMySQL).
+	librosEnJSON := strings.ToLower(os.Getenv("LIBROS_STORE")) == "json"
+
+	var conexion *sql.DB
+	if librosEnJSON {
+		log.Println("⚠️ LIBROS_STORE=json: el sistema corre sin MySQL")
+	} else {
+		// Se crea la conexión a MySQL usando la función del paquete db.
+		conexion = db.ConectarDB()
+
+		// Se asegura que la conexión se cierre cuando termine la aplicación.
+		defer conexion.Close()
+
+		// Se crean las tablas auxiliares (sesiones, etc.) si aún no existen.
+		if err := db.AplicarMigraciones(conexion); err != nil {
+			log.Fatal("❌ Error al aplicar migraciones: ", err)
+		}
+	}
+
+	// =========================================================
+	// 1.1) SESIONES DEL LADO DEL SERVIDOR
+	// =========================================================
+
+	// SESSION_STORE elige el almacén: "mysql" (por defecto) o "memoria".
+	// Sin MySQL siempre se usa la memoria.
+	var almacen sesiones.Almacen
+	if conexion == nil || strings.ToLower(os.Getenv("SESSION_STORE")) == "memoria" {
+		almacen = sesiones.NuevoAlmacenMemoria()
+	} else {
+		almacen = sesiones.NuevoAlmacenMySQL(conexion)
+	}
+
+	// SESSION_SECRET firma el ID de sesión en la cookie.
+	// Si no se define, se genera uno aleatorio y las sesiones no sobreviven a un reinicio.
+	secreto := []byte(os.Getenv("SESSION_SECRET"))
+	if len(secreto) == 0 {
+		log.Println("⚠️ SESSION_SECRET no definido: se usará un secreto temporal")
+		secreto = make([]byte, 32)
+		if _, err := rand.Read(secreto); err != nil {
+			log.Fatal("❌ Error al generar secreto de sesión: ", err)
+		}
+	}
+
+	gestorSesiones = sesiones.NuevoGestor(almacen, secreto)
+	gestorSesiones.CookieSegura = os.Getenv("COOKIE_SECURE") == "true"
+
+	// Tokens Bearer y claves de API para integraciones (sin MySQL no hay tokens).
+	if conexion != nil {
+		servicioTokens = tokens.NuevoServicio(conexion)
+	}
+
+	// Permisos de cada rol (tabla roles_permisos, editable desde /admin/permisos).
+	// Sin MySQL se usa la asignación inicial (permisos.AsignacionInicial).
+	servicioPermisos = permisos.NuevoServicio(conexion)
+
+	// Límite de intentos de inicio de sesión (formulario y API).
+	// LOGIN_MAX_FALLOS define los fallos seguidos que bloquean una cuenta (por defecto 5).
+	// LOGIN_LIMITE_IP define los fallos aceptados por IP cada 15 minutos (por defecto 20).
+	// Sin MySQL los intentos y bloqueos se guardan en memoria.
+	var servicioIntentos *intentos.Servicio
+	if conexion == nil {
+		servicioIntentos = intentos.NuevoServicioMemoria()
+	} else {
+		servicioIntentos = intentos.NuevoServicio(conexion)
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FALLOS")); err == nil && n > 0 {
+		servicioIntentos.MaxFallos = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_LIMITE_IP")); err == nil && n > 0 {
+		servicioIntentos.LimiteIP = n
+	}
+
+	// Segundo factor (TOTP) y códigos de recuperación. Los roles con el permiso
+	// doble_factor.obligatorio deben activarlo; el resto puede hacerlo desde /cuenta/seguridad.
+	// TOTP_EMISOR es el nombre que muestra la aplicación autenticadora.
+	servicioDobleFactor := doblefactor.NuevoServicio(conexion)
+	if emisor := strings.TrimSpace(os.Getenv("TOTP_EMISOR")); emisor != "" {
+		servicioDobleFactor.Emisor = emisor
+	}
+
+	// SMTP_HOST activa el envío real de correos (verificación de cuentas y recuperación de clave).
+	// Sin servidor configurado, los correos se escriben en la consola.
+	var enviador correo.Enviador
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		puerto := os.Getenv("SMTP_PUERTO")
+		if puerto == "" {
+			puerto = "587"
+		}
+		enviador = correo.NuevoSMTP(host, puerto, os.Getenv("SMTP_USUARIO"), os.Getenv("SMTP_CLAVE"), os.Getenv("SMTP_REMITENTE"))
+	} else {
+		log.Println("⚠️ SMTP_HOST no definido: los correos se mostrarán en la consola")
+		enviador = correo.NuevoConsola()
+	}
+
+	// Usuarios: administración, registro de lectores y enlaces enviados por correo.
+	// URL_BASE es la dirección pública usada en los enlaces enviados por correo.
+	servicioUsuarios := usuarios.NuevoServicio(conexion, almacen, servicioTokens, enviador)
+	if base := strings.TrimRight(os.Getenv("URL_BASE"), "/"); base != "" {
+		servicioUsuarios.URLBase = base
+	}
+
+	// Sin MySQL la única cuenta es un ADMIN local: ADMIN_CORREO (por defecto
+	// "admin@local") y ADMIN_CLAVE. Si no se define la clave, se genera una
+	// temporal y se muestra en la consola.
+	var cuentaLocal *usuarios.CuentaLocal
+	if conexion == nil {
+		correoLocal := strings.TrimSpace(os.Getenv("ADMIN_CORREO"))
+		if correoLocal == "" {
+			correoLocal = "admin@local"
+		}
+		claveLocal := os.Getenv("ADMIN_CLAVE")
+		if claveLocal == "" {
+			aleatorio := make([]byte, 9)
+			if _, err := rand.Read(aleatorio); err != nil {
+				log.Fatal("❌ Error al generar la clave de la cuenta local: ", err)
+			}
+			claveLocal = hex.EncodeToString(aleatorio)
+			log.Println("⚠️ ADMIN_CLAVE no definida: ingrese como " + correoLocal + " con la clave temporal " + claveLocal)
+		}
+		var err error
+		cuentaLocal, err = usuarios.NuevaCuentaLocal(correoLocal, claveLocal)
+		if err != nil {
+			log.Fatal("❌ Error en ADMIN_CLAVE: ", err)
+		}
+	}
+
+	// =========================================================
+	// 1.2) ALMACENAMIENTO DE ARCHIVOS DE LIBROS
+	// =========================================================
+
+	// STORAGE_DIR indica la carpeta local de archivos (por defecto "uploads").
+	carpetaArchivos := os.Getenv("STORAGE_DIR")
+	if carpetaArchivos == "" {
+		carpetaArchivos = "uploads"
+	}
+	archivos, err := almacenamiento.NuevoLocal(carpetaArchivos)
+	if err != nil {
+		log.Fatal("❌ Error al preparar almacenamiento de archivos: ", err)
+	}
+
+	// =========================================================
+	// 1.3) REPOSITORIO DE LIBROS
+	// =========================================================
+
+	// Con LIBROS_STORE=json los libros y la auditoría del catálogo se guardan
+	// en el archivo LIBROS_JSON.
+	var repositorioLibros libros.LibroRepository
+	fuenteAuditoria := auditoria.NuevaFuenteMySQL(conexion)
+	if librosEnJSON {
+		archivoLibros := archivoLibrosJSON()
+		repositorioJSON, err := libros.NuevoRepositorioJSON(archivoLibros)
+		if err != nil {
+			log.Fatal("❌ Error al cargar libros de "+archivoLibros+": ", err)
+		}
+		repositorioLibros = repositorioJSON
+		fuenteAuditoria = auditoria.FuenteFunc(repositorioJSON.RecorrerAuditoria)
+	} else {
+		repositorioLibros = libros.NuevoRepositorioMySQL(conexion)
+	}
+
+	// La búsqueda por texto usa un índice en memoria (título, autor y categoría,
+	// sin acentos y por raíz de palabra) que se arma al iniciar y se actualiza con
+	// cada alta, modificación y baja.
+	repositorioIndexado, err := libros.NuevoRepositorioIndexado(repositorioLibros)
+	if err != nil {
+		log.Fatal("❌ Error al indexar libros: ", err)
+	}
+	repositorioLibros = repositorioIndexado
+
+	// =========================================================
+	// 1.4) PRÉSTAMOS DE LICENCIAS
+	// =========================================================
+
+	// PRESTAMO_DIAS define el plazo de cada préstamo (por defecto 14 días).
+	// RESERVA_HORAS define el plazo para retirar una licencia asignada desde la lista de espera (por defecto 48).
+	// Los préstamos usan la tabla libros de MySQL: sin MySQL quedan desactivados.
+	var servicioPrestamos *prestamos.Servicio
+	if conexion != nil {
+		servicioPrestamos = prestamos.NuevoServicio(conexion)
+		if dias, err := strconv.Atoi(os.Getenv("PRESTAMO_DIAS")); err == nil && dias > 0 {
+			servicioPrestamos.Duracion = time.Duration(dias) * 24 * time.Hour
+		}
+		if horas, err := strconv.Atoi(os.Getenv("RESERVA_HORAS")); err == nil && horas > 0 {
+			servicioPrestamos.VentanaRetiro = time.Duration(horas) * time.Hour
+		}
+	}
+
+	// Cada minuto se liberan las licencias de préstamos vencidos y de reservas
+	// no retiradas (pasan al siguiente de la lista de espera o vuelven al stock)
+	// y se borran las sesiones vencidas o abandonadas.
+	go func() {
+		for range time.Tick(time.Minute) {
+			if servicioPrestamos != nil {
+				if n, err := servicioPrestamos.DevolverVencidos(); err != nil {
+					log.Println("⚠️ Error al procesar vencimientos de préstamos: ", err)
+				} else if n > 0 {
+					log.Printf("📚 %d préstamo(s) o reserva(s) vencido(s) procesado(s)", n)
+				}
+			}
+			if err := gestorSesiones.LimpiarVencidas(); err != nil {
+				log.Println("⚠️ Error al borrar sesiones vencidas: ", err)
+			}
+		}
+	}()
+
+	// =========================================================
+	// 2) CARGA DE PLANTILLAS HTML
+	// =========================================================
+
+	// Se cargan todas las plantillas HTML de la carpeta "templates".
+	// La función "puede" consulta el mismo servicio de permisos que los middlewares:
+	//   {{if puede .UsuarioRol "libros.crear"}} ... {{end}}
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"puede": servicioPermisos.Tiene,
+	}).ParseGlob("templates/*.html")
+	if err != nil {
+		// Si falla la carga de plantillas, se detiene la ejecución.
+		log.Fatal("❌ Error al cargar plantillas: ", err)
+	}
+
+	// =========================================================
+	// 3) CREACIÓN DE HANDLERS (CONTROLADORES)
+	// =========================================================
+
+	// Handler del módulo de libros (CRUD + dashboard + búsqueda).
+	libroHandler := handlers.NuevoLibroHandler(repositorioLibros, templates, archivos)
+
+	// Handler del módulo de autenticación (login / logout / sesiones).
+	authHandler := handlers.NuevoAuthHandler(conexion, templates, gestorSesiones, servicioIntentos, servicioDobleFactor, servicioPermisos)
+	authHandler.Local = cuentaLocal
+
+	// Handler del módulo catálogo (usuario lector).
+	catalogoHandler := handlers.NuevoCatalogoHandler(conexion, repositorioLibros, templates, archivos, servicioPrestamos, servicioPermisos)
+
+	// Handler del historial de vistas, descargas y préstamos.
+	historialHandler := handlers.NuevoHistorialHandler(conexion, templates)
+
+	// Handler de la auditoría de altas, cambios y bajas del catálogo.
+	auditoriaHandler := handlers.NuevoAuditoriaHandler(fuenteAuditoria, templates)
+
+	// Handler de préstamos (usuario lector).
+	prestamoHandler := handlers.NuevoPrestamoHandler(templates, servicioPrestamos)
+
+	// Handler de la importación de libros desde CSV.
+	importacionHandler := handlers.NuevoImportacionHandler(repositorioLibros, templates)
+
+	// Handler de la exportación del catálogo.
+	exportacionHandler := handlers.NuevoExportacionHandler(repositorioLibros)
+
+	// Handler de la API JSON de libros (clientes móviles y scripts).
+	libroAPIHandler := handlers.NuevoLibroAPIHandler(repositorioLibros, archivos)
+
+	// POR_PAGINA define los libros por página del panel, del catálogo y de la API
+	// (por defecto 20, máximo 100); cada URL puede pedir otro con por_pagina.
+	if n, err := strconv.Atoi(os.Getenv("POR_PAGINA")); err == nil && n > 0 && n <= 100 {
+		libroHandler.PorPagina = n
+		catalogoHandler.PorPagina = n
+		libroAPIHandler.PorPagina = n
+	}
+
+	// Handler de tokens Bearer y claves de API.
+	tokenAPIHandler := handlers.NuevoTokenAPIHandler(conexion, servicioTokens, servicioIntentos, servicioDobleFactor, servicioPermisos)
+
+	// Handlers de administración de usuarios (pantallas y API) y de registro de lectores.
+	usuarioHandler := handlers.NuevoUsuarioHandler(templates, servicioUsuarios, servicioDobleFactor)
+	usuarioAPIHandler := handlers.NuevoUsuarioAPIHandler(servicioUsuarios)
+	registroHandler := handlers.NuevoRegistroHandler(templates, servicioUsuarios)
+	recuperacionHandler := handlers.NuevoRecuperacionHandler(templates, servicioUsuarios)
+
+	// Handler de la asignación de permisos a roles.
+	permisoHandler := handlers.NuevoPermisoHandler(templates, servicioPermisos)
+
+	// Handler de intentos fallidos de inicio de sesión y desbloqueo de cuentas.
+	accesoHandler := handlers.NuevoAccesoHandler(templates, servicioIntentos)
+
+	// Handler de la seguridad de la cuenta (segundo factor del propio usuario).
+	dobleFactorHandler := handlers.NuevoDobleFactorHandler(templates, servicioDobleFactor, servicioPermisos, servicioUsuarios, servicioIntentos)
+
+	// Handler de la página 403 cuando falta o no coincide el token CSRF.
+	csrfHandler := handlers.NuevoCSRFHandler(templates)
+
+	// conMySQL deja la ruta como está si hay MySQL; sin MySQL (LIBROS_STORE=json)
+	// la ruta responde 503 con handlers.SinMySQL.
+	conMySQL := func(next http.HandlerFunc) http.HandlerFunc {
+		if conexion == nil {
+			return handlers.SinMySQL
+		}
+		return next
+	}
+
+	// =========================================================
+	// 4) ARCHIVOS ESTÁTICOS (CSS, imágenes, etc.)
+	// =========================================================
+
+	// Esta ruta permite servir archivos de la carpeta "static".
+	// Ejemplo:
+	//   URL:  /static/style.css
+	//   File: static/style.css
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+
+	// =========================================================
+	// 5) RUTAS PÚBLICAS (AUTENTICACIÓN)
+	// =========================================================
+
+	// Ruta GET: muestra formulario de login.
+	http.HandleFunc("/login", authHandler.MostrarLogin)
+
+	// Ruta POST: procesa login (valida usuario/clave y crea la sesión).
+	http.HandleFunc("/login/procesar", authHandler.ProcesarLogin)
+
+	// Segundo paso del login: código TOTP o de recuperación (y alta, si el rol lo exige).
+	http.HandleFunc("GET /login/segundo-paso", conMySQL(authHandler.MostrarSegundoPaso))
+	http.HandleFunc("POST /login/segundo-paso", conMySQL(authHandler.ProcesarSegundoPaso))
+	http.HandleFunc("GET /login/segundo-paso/qr", conMySQL(authHandler.QRSegundoPaso))
+
+	// Ruta GET: cierra sesión y elimina la cookie de sesión.
+	http.HandleFunc("/logout", authHandler.Logout)
+
+	// Registro de lectores (CONSULTA): formulario, alta pendiente y verificación del correo.
+	http.HandleFunc("/registro", conMySQL(registroHandler.MostrarRegistro))
+	http.HandleFunc("/registro/procesar", conMySQL(registroHandler.ProcesarRegistro))
+	http.HandleFunc("/registro/verificar", conMySQL(registroHandler.VerificarCorreo))
+
+	// Recuperación de clave: pedido del enlace, formulario y cambio (cierra todas las sesiones).
+	http.HandleFunc("/recuperar", conMySQL(recuperacionHandler.MostrarRecuperar))
+	http.HandleFunc("/recuperar/enviar", conMySQL(recuperacionHandler.EnviarEnlace))
+	http.HandleFunc("GET /recuperar/clave", conMySQL(recuperacionHandler.MostrarNuevaClave))
+	http.HandleFunc("POST /recuperar/clave", conMySQL(recuperacionHandler.GuardarNuevaClave))
+
+	// =========================================================
+	// 6) RUTAS DEL CATÁLOGO (USUARIO LECTOR)
+	//    Requieren login; préstamos e historial requieren su permiso.
+	// =========================================================
+
+	// Ruta GET: muestra catálogo de libros.
+	http.HandleFunc("/catalogo", RequiereLogin(catalogoHandler.VerCatalogo))
+
+	// Ruta GET: muestra detalle de un libro.
+	http.HandleFunc("/catalogo/detalle", RequiereLogin(catalogoHandler.VerDetalleLibro))
+
+	// Ruta GET: descarga el archivo propio del libro (PDF, EPUB o MOBI).
+	// Los lectores necesitan un préstamo vigente del libro.
+	http.HandleFunc("/catalogo/descargar", RequiereLogin(catalogoHandler.DescargarLibro))
+
+	// Rutas de préstamos: mis préstamos, prestar y devolver.
+	http.HandleFunc("/prestamos", RequierePermiso(conMySQL(prestamoHandler.VerPrestamos), permisos.PrestamosSolicitar))
+	http.HandleFunc("/prestamos/prestar", RequierePermiso(conMySQL(prestamoHandler.PrestarLibro), permisos.PrestamosSolicitar))
+	http.HandleFunc("/prestamos/devolver", RequierePermiso(conMySQL(prestamoHandler.DevolverPrestamo), permisos.PrestamosSolicitar))
+
+	// Lista de espera cuando no quedan licencias: reservar y cancelar.
+	http.HandleFunc("/prestamos/reservar", RequierePermiso(conMySQL(prestamoHandler.ReservarLibro), permisos.PrestamosSolicitar))
+	http.HandleFunc("/prestamos/cancelar-reserva", RequierePermiso(conMySQL(prestamoHandler.CancelarReserva), permisos.PrestamosSolicitar))
+
+	// Ruta GET: historial propio del lector (vistas, descargas, préstamos y devoluciones).
+	http.HandleFunc("/historial", RequierePermiso(conMySQL(historialHandler.MiHistorial), permisos.HistorialPropio))
+
+	// Seguridad de la cuenta: alta, baja y códigos de recuperación del segundo factor.
+	http.HandleFunc("/cuenta/seguridad", RequiereLogin(conMySQL(dobleFactorHandler.VerSeguridad)))
+	http.HandleFunc("/cuenta/seguridad/activar", RequiereLogin(conMySQL(dobleFactorHandler.IniciarAlta)))
+	http.HandleFunc("/cuenta/seguridad/qr", RequiereLogin(conMySQL(dobleFactorHandler.QRAlta)))
+	http.HandleFunc("/cuenta/seguridad/confirmar", RequiereLogin(conMySQL(dobleFactorHandler.ConfirmarAlta)))
+	http.HandleFunc("/cuenta/seguridad/codigos", RequiereLogin(conMySQL(dobleFactorHandler.RegenerarCodigos)))
+	http.HandleFunc("/cuenta/seguridad/desactivar", RequiereLogin(conMySQL(dobleFactorHandler.Desactivar)))
+
+	// =========================================================
+	// 7) RUTAS DEL PANEL ADMINISTRATIVO / CRUD DE LIBROS
+	//    Requieren login + permiso con nombre (ver paquete permisos).
+	// =========================================================
+
+	// Ruta GET: panel principal (dashboard + listado + búsqueda).
+	http.HandleFunc("/", RequiereLogin(libroHandler.Index))
+
+	// Rutas CREATE.
+	http.HandleFunc("/libros/nuevo", RequierePermiso(libroHandler.NuevoLibroForm, permisos.LibrosCrear))
+	http.HandleFunc("/libros/crear", RequierePermiso(libroHandler.CrearLibro, permisos.LibrosCrear))
+
+	// Ruta POST: lee metadatos del archivo elegido para prellenar nuevo.html.
+	http.HandleFunc("/libros/metadatos", RequiereAPI(libroHandler.ExtraerMetadatos, permisos.LibrosCrear))
+
+	// Importación desde CSV: vista previa y confirmación (mismo permiso que el alta).
+	http.HandleFunc("/libros/importar", RequierePermiso(importacionHandler.MostrarImportar, permisos.LibrosCrear))
+	http.HandleFunc("/libros/importar/procesar", RequierePermiso(importacionHandler.ProcesarImportar, permisos.LibrosCrear))
+
+	// Exportación del catálogo en CSV, JSON o MARCXML (mismos filtros que /catalogo).
+	http.HandleFunc("/admin/libros/exportar", RequierePermiso(exportacionHandler.ExportarLibros, permisos.LibrosExportar))
+
+	// Rutas UPDATE.
+	http.HandleFunc("/libros/editar", RequierePermiso(libroHandler.EditarLibroForm, permisos.LibrosEditar))
+	http.HandleFunc("/libros/actualizar", RequierePermiso(libroHandler.ActualizarLibro, permisos.LibrosEditar))
+
+	// Ruta DELETE.
+	http.HandleFunc("/libros/eliminar", RequierePermiso(libroHandler.EliminarLibro, permisos.LibrosEliminar))
+
+	// Ruta GET: historial de uso de todos los usuarios, con filtros.
+	http.HandleFunc("/admin/historial", RequierePermiso(conMySQL(historialHandler.HistorialAdmin), permisos.HistorialVer))
+
+	// Rutas GET: auditoría del catálogo y su exportación CSV.
+	http.HandleFunc("/admin/auditoria", RequierePermiso(auditoriaHandler.VerAuditoria, permisos.AuditoriaVer))
+	http.HandleFunc("/admin/auditoria/exportar", RequierePermiso(auditoriaHandler.ExportarAuditoria, permisos.AuditoriaVer))
+
+	// Administración de usuarios: listado, alta, rol, estado, clave y segundo factor.
+	http.HandleFunc("/admin/usuarios", RequierePermiso(conMySQL(usuarioHandler.VerUsuarios), permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/usuarios/crear", RequierePermiso(conMySQL(usuarioHandler.CrearUsuario), permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/usuarios/rol", RequierePermiso(conMySQL(usuarioHandler.CambiarRol), permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/usuarios/estado", RequierePermiso(conMySQL(usuarioHandler.CambiarEstado), permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/usuarios/clave", RequierePermiso(conMySQL(usuarioHandler.RestablecerClave), permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/usuarios/doble-factor", RequierePermiso(conMySQL(usuarioHandler.RestablecerDobleFactor), permisos.UsuariosAdministrar))
+
+	// Intentos fallidos de inicio de sesión y desbloqueo de cuentas.
+	http.HandleFunc("/admin/accesos", RequierePermiso(accesoHandler.VerAccesos, permisos.UsuariosAdministrar))
+	http.HandleFunc("/admin/accesos/desbloquear", RequierePermiso(accesoHandler.Desbloquear, permisos.UsuariosAdministrar))
+
+	// Asignación de permisos a roles, sin reiniciar el sistema.
+	http.HandleFunc("/admin/permisos", RequierePermiso(conMySQL(permisoHandler.VerPermisos), permisos.PermisosAdministrar))
+	http.HandleFunc("/admin/permisos/guardar", RequierePermiso(conMySQL(permisoHandler.GuardarPermisos), permisos.PermisosAdministrar))
+
+	// =========================================================
+	// 8) API JSON v1
+	//    Mismos permisos que las rutas HTML, con errores en JSON.
+	//    Aceptan cookie de sesión o encabezado Authorization: Bearer.
+	// =========================================================
+
+	// Autenticación por tokens (públicas: validan credenciales o refresh token).
+	http.HandleFunc("POST /api/v1/auth/token", conMySQL(tokenAPIHandler.EmitirToken))
+	http.HandleFunc("POST /api/v1/auth/refresh", conMySQL(tokenAPIHandler.RefrescarToken))
+
+	// Claves de API por usuario.
+	http.HandleFunc("GET /api/v1/claves-api", RequiereAPI(conMySQL(tokenAPIHandler.ListarClaves), permisos.ClavesAPIAdministrar))
+	http.HandleFunc("POST /api/v1/claves-api", RequiereAPI(conMySQL(tokenAPIHandler.CrearClave), permisos.ClavesAPIAdministrar))
+	http.HandleFunc("DELETE /api/v1/claves-api/{id}", RequiereAPI(conMySQL(tokenAPIHandler.RevocarClave), permisos.ClavesAPIAdministrar))
+
+	// Administración de usuarios y roles.
+	http.HandleFunc("GET /api/v1/roles", RequiereAPI(conMySQL(usuarioAPIHandler.ListarRoles), permisos.UsuariosAdministrar))
+	http.HandleFunc("GET /api/v1/usuarios", RequiereAPI(conMySQL(usuarioAPIHandler.Listar), permisos.UsuariosAdministrar))
+	http.HandleFunc("GET /api/v1/usuarios/{id}", RequiereAPI(conMySQL(usuarioAPIHandler.Obtener), permisos.UsuariosAdministrar))
+	http.HandleFunc("POST /api/v1/usuarios", RequiereAPI(conMySQL(usuarioAPIHandler.Crear), permisos.UsuariosAdministrar))
+	http.HandleFunc("PATCH /api/v1/usuarios/{id}", RequiereAPI(conMySQL(usuarioAPIHandler.Modificar), permisos.UsuariosAdministrar))
+	http.HandleFunc("PUT /api/v1/usuarios/{id}/clave", RequiereAPI(conMySQL(usuarioAPIHandler.RestablecerClave), permisos.UsuariosAdministrar))
+
+	// Lectura (cualquier usuario autenticado).
+	http.HandleFunc("GET /api/v1/libros", RequiereAPI(libroAPIHandler.Listar))
+	http.HandleFunc("GET /api/v1/libros/{id}", RequiereAPI(libroAPIHandler.Obtener))
+
+	// Creación y actualización (solo ADMIN y OPERADOR).
+	http.HandleFunc("POST /api/v1/libros", RequiereAPI(libroAPIHandler.Crear, permisos.LibrosCrear))
+	http.HandleFunc("PUT /api/v1/libros/{id}", RequiereAPI(libroAPIHandler.Actualizar, permisos.LibrosEditar))
+
+	// Eliminación (solo ADMIN).
+	http.HandleFunc("DELETE /api/v1/libros/{id}", RequiereAPI(libroAPIHandler.Eliminar, permisos.LibrosEliminar))
+
+	// Cualquier otra ruta bajo /api/ responde 404 en JSON.
+	http.HandleFunc("/api/", handlers.RutaAPINoEncontrada)
+
+	// =========================================================
+	// 9) INICIO DEL SERVIDOR WEB
+	// =========================================================
+
+	// Mensaje en consola con la URL del sistema.
+	log.Println("🚀 Servidor iniciado en http://localhost:8082")
+
+	// Inicia servidor HTTP en puerto 8082.
+	// Todas las rutas pasan por la verificación CSRF de los formularios.
+	err = http.ListenAndServe(":8082", ProtegerCSRF(http.DefaultServeMux, csrfHandler.Rechazar))
+	if err != nil {
+		// Si falla el servidor, se muestra error y se detiene la app.
+		log.Fatal("❌ Error al iniciar servidor: ", err)
+	}
+}
+
+// =========================================================
+// RESOLUCIÓN DE IDENTIDAD (COOKIE O BEARER)
+// =========================================================
+
+// resolverIdentidad obtiene la identidad de la petición.
+// Si hay encabezado Authorization: Bearer se valida el token (acceso o clave de API);
+// si no, se usa la cookie de sesión. Ambos caminos devuelven una Sesion con el rol,
+// por lo que los controles de rol funcionan igual.
+func resolverIdentidad(r *http.Request) (models.Sesion, bool) {
+	if encabezado := r.Header.Get("Authorization"); encabezado != "" {
+		token, ok := strings.CutPrefix(encabezado, "Bearer ")
+		if !ok {
+			return models.Sesion{}, false
+		}
+		if servicioTokens == nil {
+			return models.Sesion{}, false
+		}
+		sesion, err := servicioTokens.Resolver(strings.TrimSpace(token))
+		return sesion, err == nil
+	}
+	return gestorSesiones.Resolver(r)
+}
+
+// =========================================================
+// MIDDLEWARE: REQUIERE LOGIN
+// =========================================================
+
+// RequiereLogin protege rutas que exigen sesión iniciada.
+// Recibe un handler y devuelve un handler protegido.
+func RequiereLogin(next http.HandlerFunc) http.HandlerFunc {
+	// Retorna una función wrapper.
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Resuelve la sesión desde el almacén del servidor o el token Bearer.
+		sesion, ok := resolverIdentidad(r)
+		if !ok {
+			// Si no hay sesión vigente, redirige al login.
+			http.Redirect(w, r, "/login?error=Debe+iniciar+sesión", http.StatusSeeOther)
+			return
+		}
+
+		// Si está autenticado, ejecuta el handler original con la sesión en la petición.
+		next(w, handlers.ConSesion(r, sesion))
+	}
+}
+
+// =========================================================
+// MIDDLEWARE: REQUIERE LOGIN + PERMISO
+// =========================================================
+
+// RequierePermiso protege rutas que exigen:
+// 1) sesión iniciada
+// 2) un permiso asignado al rol de la sesión (ej. permisos.LibrosCrear)
+func RequierePermiso(next http.HandlerFunc, permiso string) http.HandlerFunc {
+	// Retorna una función wrapper.
+	return func(w http.ResponseWriter, r *http.Request) {
+		// -----------------------------------------------------
+		// 1) VALIDAR SESIÓN
+		// -----------------------------------------------------
+		sesion, ok := resolverIdentidad(r)
+		if !ok {
+			// Si no hay sesión, redirige al login.
+			http.Redirect(w, r, "/login?error=Debe+iniciar+sesión", http.StatusSeeOther)
+			return
+		}
+		r = handlers.ConSesion(r, sesion)
+
+		// -----------------------------------------------------
+		// 2) VALIDAR PERMISO
+		// -----------------------------------------------------
+		if !servicioPermisos.Tiene(sesion.Rol, permiso) {
+			// Si no tiene permisos, redirige al panel con mensaje.
+			http.Redirect(w, r, "/?msg=No+tiene+permisos+para+esa+acción", http.StatusSeeOther)
+			return
+		}
+
+		// -----------------------------------------------------
+		// 3) EJECUTAR HANDLER ORIGINAL
+		// -----------------------------------------------------
+		next(w, r)
+	}
+}
+
+// =========================================================
+// MIDDLEWARE: PROTECCIÓN CSRF
+// =========================================================
+
+// ProtegerCSRF verifica el token CSRF de las peticiones que cambian datos
+// (POST, PUT, PATCH, DELETE) y deja en todas las peticiones el token para que
+// los handlers lo pongan en sus formularios (handlers.TokenCSRF).
+// Si el token falta o no coincide, responde con rechazo (página 403).
+// La API JSON solo se verifica cuando usa la cookie de sesión: con token Bearer
+// (o sin credenciales, como /api/v1/auth/token) no hay sesión que falsificar.
+func ProtegerCSRF(next http.Handler, rechazo http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// El token se calcula una sola vez y solo si algún handler lo pide.
+		var token string
+		r = handlers.ConCSRF(r, func() string {
+			if token == "" {
+				var err error
+				if token, err = gestorSesiones.TokenCSRF(w, r); err != nil {
+					log.Println("⚠️ Error al generar token CSRF: ", err)
+				}
+			}
+			return token
+		})
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			if _, err := r.Cookie(sesiones.NombreCookie); err != nil || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		recibido, err := handlers.LeerTokenCSRF(w, r, func() bool {
+			_, ok := gestorSesiones.Resolver(r)
+			return ok
+		})
+		if err != nil {
+			http.Error(w, "Error al leer formulario", http.StatusBadRequest)
+			return
+		}
+		if !gestorSesiones.VerificarCSRF(r, recibido) {
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				handlers.ResponderErrorJSON(w, http.StatusForbidden, "csrf_invalido", "Falta el encabezado X-CSRF-Token o no es válido")
+				return
+			}
+			rechazo(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// =========================================================
+// MIDDLEWARE: API JSON
+// =========================================================
+
+// RequiereAPI protege rutas de la API JSON.
+// A diferencia de RequierePermiso no redirige: responde 401/403 en JSON.
+// Si no se indican permisos, basta con estar autenticado (cookie o Bearer);
+// si se indican varios, el rol debe tenerlos todos.
+func RequiereAPI(next http.HandlerFunc, permisosRequeridos ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sesion, ok := resolverIdentidad(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			handlers.ResponderErrorJSON(w, http.StatusUnauthorized, "no_autenticado", "Debe iniciar sesión o enviar un token Bearer válido")
+			return
+		}
+		r = handlers.ConSesion(r, sesion)
+
+		for _, permiso := range permisosRequeridos {
+			if !servicioPermisos.Tiene(sesion.Rol, permiso) {
+				handlers.ResponderErrorJSON(w, http.StatusForbidden, "sin_permiso", "No tiene permisos para esa acción")
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
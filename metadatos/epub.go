@@ -0,0 +1,145 @@
+package metadatos // Paquete metadatos.
+
+import (
+	"archive/zip"  // Paquete para abrir el contenedor EPUB (es un ZIP).
+	"encoding/xml" // Paquete para leer container.xml y el OPF.
+	"io"           // Paquete para leer entradas del ZIP.
+	"path"         // Paquete para rutas dentro del ZIP (siempre con "/").
+	"strings"      // Paquete para limpiar textos.
+)
+
+// limiteXML evita leer entradas XML desproporcionadas dentro del EPUB.
+const limiteXML = 4 << 20
+
+// contenedorEPUB representa META-INF/container.xml.
+type contenedorEPUB struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+// paqueteOPF representa la sección de metadatos del documento OPF.
+// Las etiquetas sin espacio de nombres coinciden con dc:title, dc:creator, etc.
+type paqueteOPF struct {
+	Metadata struct {
+		Titulos         []string `xml:"title"`
+		Creadores       []string `xml:"creator"`
+		Fechas          []string `xml:"date"`
+		Idiomas         []string `xml:"language"`
+		Editoriales     []string `xml:"publisher"`
+		Identificadores []struct {
+			ID      string `xml:"id,attr"`
+			Esquema string `xml:"scheme,attr"`
+			Valor   string `xml:",chardata"`
+		} `xml:"identifier"`
+	} `xml:"metadata"`
+}
+
+// ExtraerEPUB abre el contenedor EPUB, localiza el OPF mediante
+// META-INF/container.xml y lee título, autor, año, idioma, editorial e ISBN.
+func ExtraerEPUB(archivo io.ReaderAt, tamano int64) (Metadatos, error) {
+	lector, err := zip.NewReader(archivo, tamano)
+	if err != nil {
+		return Metadatos{}, ErrArchivoDanado
+	}
+
+	var contenedor contenedorEPUB
+	if err := leerXMLDelZip(lector, "META-INF/container.xml", &contenedor); err != nil {
+		return Metadatos{}, err
+	}
+
+	// Se usa el primer rootfile OPF (un EPUB puede declarar varias versiones).
+	rutaOPF := ""
+	for _, rf := range contenedor.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			rutaOPF = rf.FullPath
+			break
+		}
+	}
+	if rutaOPF == "" {
+		return Metadatos{}, ErrArchivoDanado
+	}
+
+	var opf paqueteOPF
+	if err := leerXMLDelZip(lector, path.Clean(rutaOPF), &opf); err != nil {
+		return Metadatos{}, err
+	}
+
+	md := opf.Metadata
+	m := Metadatos{
+		Titulo:    primero(md.Titulos),
+		Autor:     strings.Join(limpiarTodos(md.Creadores), ", "),
+		Idioma:    primero(md.Idiomas),
+		Editorial: primero(md.Editoriales),
+	}
+
+	// Año: primer dc:date con un año reconocible.
+	for _, f := range md.Fechas {
+		if anio := anioDesdeFecha(f); anio > 0 {
+			m.AnioPublicacion = anio
+			break
+		}
+	}
+
+	// ISBN: se prefiere un identificador marcado como ISBN; si no, cualquiera que sea válido.
+	for _, id := range md.Identificadores {
+		if strings.EqualFold(id.Esquema, "ISBN") || strings.HasPrefix(strings.ToLower(strings.TrimSpace(id.Valor)), "urn:isbn:") {
+			if isbn := NormalizarISBN(id.Valor); isbn != "" {
+				m.ISBN = isbn
+				break
+			}
+		}
+	}
+	if m.ISBN == "" {
+		for _, id := range md.Identificadores {
+			if isbn := NormalizarISBN(id.Valor); isbn != "" {
+				m.ISBN = isbn
+				break
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// leerXMLDelZip decodifica una entrada XML del ZIP.
+func leerXMLDelZip(lector *zip.Reader, nombre string, destino any) error {
+	for _, f := range lector.File {
+		if f.Name != nombre {
+			continue
+		}
+		contenido, err := f.Open()
+		if err != nil {
+			return ErrArchivoDanado
+		}
+		defer contenido.Close()
+
+		if err := xml.NewDecoder(io.LimitReader(contenido, limiteXML)).Decode(destino); err != nil {
+			return ErrArchivoDanado
+		}
+		return nil
+	}
+	return ErrArchivoDanado
+}
+
+// primero devuelve el primer valor no vacío de la lista.
+func primero(valores []string) string {
+	for _, v := range valores {
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// limpiarTodos normaliza espacios y descarta valores vacíos.
+func limpiarTodos(valores []string) []string {
+	var out []string
+	for _, v := range valores {
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
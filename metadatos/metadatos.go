@@ -0,0 +1,148 @@
+package metadatos // Paquete metadatos: lee los datos bibliográficos incluidos en los archivos de libros.
+
+import (
+	"errors"  // Paquete para errores de lectura.
+	"io"      // Paquete para leer el archivo por posiciones.
+	"strconv" // Paquete para convertir el año.
+	"strings" // Paquete para normalizar textos.
+	"unicode" // Paquete para filtrar dígitos del ISBN.
+)
+
+// ErrFormatoNoSoportado se usa cuando no hay extractor para el formato indicado.
+var ErrFormatoNoSoportado = errors.New("formato sin extracción de metadatos")
+
+// ErrArchivoDanado se usa cuando el archivo no tiene la estructura esperada.
+var ErrArchivoDanado = errors.New("archivo dañado o con estructura inválida")
+
+// Metadatos son los datos que se pudieron leer del archivo.
+// Los campos vacíos (o en cero) indican que el archivo no los trae.
+type Metadatos struct {
+	Titulo          string `json:"titulo,omitempty"`
+	Autor           string `json:"autor,omitempty"`
+	AnioPublicacion int    `json:"anio_publicacion,omitempty"`
+	Idioma          string `json:"idioma,omitempty"`
+	Editorial       string `json:"editorial,omitempty"`
+	ISBN            string `json:"isbn,omitempty"`
+	Paginas         int    `json:"paginas,omitempty"` // Solo PDF.
+}
+
+// Conflicto describe un campo en el que lo escrito por el operador
+// no coincide con lo que trae el archivo.
+type Conflicto struct {
+	Campo     string `json:"campo"`      // Nombre del campo del formulario (ej. "titulo").
+	Etiqueta  string `json:"etiqueta"`   // Nombre legible (ej. "Título").
+	Escrito   string `json:"escrito"`    // Valor ingresado por el operador.
+	EnArchivo string `json:"en_archivo"` // Valor encontrado en el archivo.
+}
+
+// Extraer lee los metadatos según el formato del libro.
+func Extraer(formato string, archivo io.ReaderAt, tamano int64) (Metadatos, error) {
+	switch strings.ToUpper(formato) {
+	case "EPUB":
+		return ExtraerEPUB(archivo, tamano)
+	case "PDF":
+		return ExtraerPDF(archivo, tamano)
+	default:
+		return Metadatos{}, ErrFormatoNoSoportado
+	}
+}
+
+// Comparar devuelve los campos en los que el valor escrito difiere del archivo.
+// Solo se comparan campos presentes en ambos lados; mayúsculas y espacios no cuentan.
+func Comparar(titulo, autor string, anio int, m Metadatos) []Conflicto {
+	var conflictos []Conflicto
+
+	if m.Titulo != "" && titulo != "" && !mismoTexto(titulo, m.Titulo) {
+		conflictos = append(conflictos, Conflicto{"titulo", "Título", titulo, m.Titulo})
+	}
+	if m.Autor != "" && autor != "" && !mismoTexto(autor, m.Autor) {
+		conflictos = append(conflictos, Conflicto{"autor", "Autor", autor, m.Autor})
+	}
+	if m.AnioPublicacion != 0 && anio != 0 && anio != m.AnioPublicacion {
+		conflictos = append(conflictos, Conflicto{"anio_publicacion", "Año de publicación", strconv.Itoa(anio), strconv.Itoa(m.AnioPublicacion)})
+	}
+	return conflictos
+}
+
+// mismoTexto compara ignorando mayúsculas y espacios repetidos.
+func mismoTexto(a, b string) bool {
+	return strings.EqualFold(strings.Join(strings.Fields(a), " "), strings.Join(strings.Fields(b), " "))
+}
+
+// anioDesdeFecha obtiene el año de fechas como "2008", "2008-08-01" o "2008-08-01T00:00:00Z".
+func anioDesdeFecha(fecha string) int {
+	fecha = strings.TrimSpace(fecha)
+	if len(fecha) < 4 {
+		return 0
+	}
+	anio, err := strconv.Atoi(fecha[:4])
+	if err != nil {
+		return 0
+	}
+	return anio
+}
+
+// NormalizarISBN quita guiones y espacios y valida el dígito de control.
+// Devuelve "" si el valor no es un ISBN-10 o ISBN-13 válido.
+func NormalizarISBN(valor string) string {
+	valor = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(valor)), "urn:isbn:")
+	valor = strings.TrimPrefix(valor, "isbn:")
+
+	var digitos []rune
+	for _, c := range valor {
+		switch {
+		case unicode.IsDigit(c):
+			digitos = append(digitos, c)
+		case c == 'x' || c == 'X':
+			digitos = append(digitos, 'X')
+		case c == '-' || c == ' ':
+			// Separadores permitidos.
+		default:
+			return ""
+		}
+	}
+
+	isbn := string(digitos)
+	switch {
+	case len(isbn) == 13 && isbn13Valido(isbn):
+		return isbn
+	case len(isbn) == 10 && isbn10Valido(isbn):
+		return isbn
+	default:
+		return ""
+	}
+}
+
+// isbn13Valido verifica el dígito de control de un ISBN-13.
+func isbn13Valido(isbn string) bool {
+	suma := 0
+	for i, c := range isbn {
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		suma += d
+	}
+	return suma%10 == 0
+}
+
+// isbn10Valido verifica el dígito de control de un ISBN-10 (la X vale 10 al final).
+func isbn10Valido(isbn string) bool {
+	suma := 0
+	for i, c := range isbn {
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		suma += d * (10 - i)
+	}
+	return suma%11 == 0
+}
@@ -0,0 +1,820 @@
+package metadatos // Paquete metadatos.
+
+import (
+	"bytes"           // Paquete para buscar palabras clave en el archivo.
+	"compress/zlib"   // Paquete para descomprimir flujos FlateDecode.
+	"encoding/binary" // Paquete para decodificar cadenas UTF-16.
+	"errors"          // Paquete para errores del analizador.
+	"io"              // Paquete para leer el archivo por posiciones.
+	"strconv"         // Paquete para convertir números.
+	"strings"         // Paquete para limpiar textos.
+	"unicode/utf16"   // Paquete para cadenas de texto PDF en UTF-16BE.
+)
+
+// Límites que evitan que un PDF malicioso consuma memoria o tiempo sin control.
+const (
+	ventanaObjetoPDF = 64 << 10 // Bytes leídos para analizar un objeto (sin su flujo).
+	limiteFlujoPDF   = 16 << 20 // Tamaño máximo de un flujo descomprimido.
+	limiteXrefPDF    = 64       // Máximo de secciones xref encadenadas con /Prev.
+	limiteCadenaPDF  = 32       // Máximo de referencias seguidas al resolver un objeto.
+)
+
+// errSintaxisPDF indica un objeto PDF mal formado.
+var errSintaxisPDF = errors.New("sintaxis PDF inválida")
+
+// Tipos de objetos PDF.
+type (
+	nombrePDF string            // /Nombre
+	cadenaPDF string            // (texto) o <hex>, en bytes sin interpretar
+	refPDF    struct{ num int } // N G R (la generación no se usa)
+	dicPDF    map[nombrePDF]any
+	flujoPDF  struct {
+		dic    dicPDF
+		inicio int64 // Posición del primer byte de datos del flujo.
+	}
+)
+
+// entradaXref indica dónde está cada objeto: en una posición del archivo
+// o dentro de un flujo de objetos (PDF 1.5+).
+type entradaXref struct {
+	posicion int64
+	flujo    int // Número del flujo de objetos; 0 si no está comprimido.
+	indice   int
+}
+
+// documentoPDF permite leer objetos del PDF bajo demanda, sin cargarlo entero.
+type documentoPDF struct {
+	archivo io.ReaderAt
+	tamano  int64
+	xref    map[int]entradaXref
+	trailer dicPDF
+	objStm  map[int]flujoObjetos // Flujos de objetos ya descomprimidos.
+	cifrado bool
+}
+
+// flujoObjetos es un flujo de objetos (/Type /ObjStm) ya descomprimido.
+type flujoObjetos struct {
+	datos   []byte
+	primero int64 // Posición del primer objeto (/First).
+}
+
+// ExtraerPDF lee el diccionario Info, los metadatos XMP del catálogo y la
+// cantidad de páginas. Si el PDF está cifrado solo se informa la cantidad de páginas.
+func ExtraerPDF(archivo io.ReaderAt, tamano int64) (Metadatos, error) {
+	doc := &documentoPDF{
+		archivo: archivo,
+		tamano:  tamano,
+		xref:    map[int]entradaXref{},
+		trailer: dicPDF{},
+		objStm:  map[int]flujoObjetos{},
+	}
+	if err := doc.leerXref(); err != nil {
+		return Metadatos{}, ErrArchivoDanado
+	}
+	_, doc.cifrado = doc.trailer["Encrypt"]
+
+	catalogo, _ := doc.resolver(doc.trailer["Root"]).(dicPDF)
+	if catalogo == nil {
+		return Metadatos{}, ErrArchivoDanado
+	}
+
+	var m Metadatos
+	if paginas, ok := doc.resolver(catalogo["Pages"]).(dicPDF); ok {
+		if n, ok := doc.resolver(paginas["Count"]).(int64); ok && n > 0 {
+			m.Paginas = int(n)
+		}
+	}
+	if doc.cifrado {
+		return m, nil
+	}
+
+	// XMP primero (es texto Unicode y el formato vigente desde PDF 2.0);
+	// el diccionario Info completa lo que falte.
+	if flujo, ok := doc.resolver(catalogo["Metadata"]).(flujoPDF); ok {
+		if xmp, err := doc.contenido(flujo); err == nil {
+			completar(&m, leerXMP(xmp))
+		}
+	}
+	if info, ok := doc.resolver(doc.trailer["Info"]).(dicPDF); ok {
+		completar(&m, Metadatos{
+			Titulo:          doc.texto(info["Title"]),
+			Autor:           doc.texto(info["Author"]),
+			AnioPublicacion: anioDesdeFecha(strings.TrimPrefix(doc.texto(info["CreationDate"]), "D:")),
+		})
+	}
+	return m, nil
+}
+
+// completar llena los campos vacíos de m con los de otra fuente.
+func completar(m *Metadatos, otro Metadatos) {
+	if m.Titulo == "" {
+		m.Titulo = otro.Titulo
+	}
+	if m.Autor == "" {
+		m.Autor = otro.Autor
+	}
+	if m.AnioPublicacion == 0 {
+		m.AnioPublicacion = otro.AnioPublicacion
+	}
+	if m.Idioma == "" {
+		m.Idioma = otro.Idioma
+	}
+	if m.Editorial == "" {
+		m.Editorial = otro.Editorial
+	}
+	if m.ISBN == "" {
+		m.ISBN = otro.ISBN
+	}
+}
+
+// leerXref ubica "startxref" al final del archivo y recorre las tablas
+// (clásicas o en flujo) siguiendo /Prev. Las entradas más nuevas tienen prioridad.
+func (d *documentoPDF) leerXref() error {
+	cola := d.leer(d.tamano-1024, 1024)
+	i := bytes.LastIndex(cola, []byte("startxref"))
+	if i < 0 {
+		return errSintaxisPDF
+	}
+	l := &lexerPDF{datos: cola, pos: i + len("startxref")}
+	v, err := l.objeto()
+	posicion, ok := v.(int64)
+	if err != nil || !ok {
+		return errSintaxisPDF
+	}
+
+	visitadas := map[int64]bool{}
+	for n := 0; posicion > 0 && n < limiteXrefPDF && !visitadas[posicion]; n++ {
+		visitadas[posicion] = true
+		trailer, err := d.leerSeccionXref(posicion)
+		if err != nil {
+			return err
+		}
+		for k, v := range trailer {
+			if _, existe := d.trailer[k]; !existe {
+				d.trailer[k] = v
+			}
+		}
+		// Archivos híbridos: la tabla clásica apunta además a un flujo xref.
+		if extra, ok := trailer["XRefStm"].(int64); ok && !visitadas[extra] {
+			visitadas[extra] = true
+			if _, err := d.leerSeccionXref(extra); err != nil {
+				return err
+			}
+		}
+		posicion, _ = trailer["Prev"].(int64)
+	}
+	if len(d.xref) == 0 {
+		return errSintaxisPDF
+	}
+	return nil
+}
+
+// leerSeccionXref lee una tabla xref clásica o un flujo xref y devuelve su trailer.
+func (d *documentoPDF) leerSeccionXref(posicion int64) (dicPDF, error) {
+	datos := d.leer(posicion, ventanaObjetoPDF)
+	l := &lexerPDF{datos: datos}
+	l.saltarEspacios()
+	if !bytes.HasPrefix(datos[l.pos:], []byte("xref")) {
+		return d.leerFlujoXref(posicion)
+	}
+	l.pos += len("xref")
+
+	// Subsecciones "primero cantidad" seguidas de entradas de 20 bytes.
+	for {
+		l.saltarEspacios()
+		if bytes.HasPrefix(datos[l.pos:], []byte("trailer")) {
+			l.pos += len("trailer")
+			break
+		}
+		primeroV, err1 := l.objeto()
+		cantidadV, err2 := l.objeto()
+		primero, ok1 := primeroV.(int64)
+		cantidad, ok2 := cantidadV.(int64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 || cantidad < 0 {
+			return nil, errSintaxisPDF
+		}
+
+		l.saltarEspacios()
+		inicio := posicion + int64(l.pos)
+		tabla := d.leer(inicio, int(cantidad)*20)
+		if len(tabla) < int(cantidad)*20 {
+			return nil, errSintaxisPDF
+		}
+		for j := 0; j < int(cantidad); j++ {
+			entrada := tabla[j*20 : j*20+20]
+			num := int(primero) + j
+			if _, existe := d.xref[num]; existe || entrada[17] != 'n' {
+				continue
+			}
+			pos, err := strconv.ParseInt(string(entrada[:10]), 10, 64)
+			if err != nil {
+				return nil, errSintaxisPDF
+			}
+			d.xref[num] = entradaXref{posicion: pos}
+		}
+
+		// Se continúa leyendo después de la subsección.
+		posicion = inicio + cantidad*20
+		datos = d.leer(posicion, ventanaObjetoPDF)
+		l = &lexerPDF{datos: datos}
+	}
+
+	v, err := l.objeto()
+	trailer, ok := v.(dicPDF)
+	if err != nil || !ok {
+		return nil, errSintaxisPDF
+	}
+	return trailer, nil
+}
+
+// leerFlujoXref lee un flujo de referencias cruzadas (/Type /XRef).
+func (d *documentoPDF) leerFlujoXref(posicion int64) (dicPDF, error) {
+	v, err := d.objetoEn(posicion)
+	if err != nil {
+		return nil, err
+	}
+	flujo, ok := v.(flujoPDF)
+	if !ok || flujo.dic["Type"] != nombrePDF("XRef") {
+		return nil, errSintaxisPDF
+	}
+	datos, err := d.contenido(flujo)
+	if err != nil {
+		return nil, err
+	}
+
+	anchos, _ := flujo.dic["W"].([]any)
+	if len(anchos) != 3 {
+		return nil, errSintaxisPDF
+	}
+	var w [3]int
+	fila := 0
+	for i, a := range anchos {
+		n, ok := a.(int64)
+		if !ok || n < 0 || n > 8 {
+			return nil, errSintaxisPDF
+		}
+		w[i] = int(n)
+		fila += int(n)
+	}
+	if fila == 0 {
+		return nil, errSintaxisPDF
+	}
+
+	indices, _ := flujo.dic["Index"].([]any)
+	if indices == nil {
+		tamano, _ := flujo.dic["Size"].(int64)
+		indices = []any{int64(0), tamano}
+	}
+
+	campo := func(b []byte, defecto int64) int64 {
+		if len(b) == 0 {
+			return defecto
+		}
+		var n int64
+		for _, c := range b {
+			n = n<<8 | int64(c)
+		}
+		return n
+	}
+
+	for i := 0; i+1 < len(indices); i += 2 {
+		primero, ok1 := indices[i].(int64)
+		cantidad, ok2 := indices[i+1].(int64)
+		if !ok1 || !ok2 {
+			return nil, errSintaxisPDF
+		}
+		for j := int64(0); j < cantidad && len(datos) >= fila; j++ {
+			b := datos[:fila]
+			datos = datos[fila:]
+			num := int(primero + j)
+			if _, existe := d.xref[num]; existe {
+				continue
+			}
+			tipo := campo(b[:w[0]], 1)
+			c2 := campo(b[w[0]:w[0]+w[1]], 0)
+			c3 := campo(b[w[0]+w[1]:], 0)
+			switch tipo {
+			case 1:
+				d.xref[num] = entradaXref{posicion: c2}
+			case 2:
+				d.xref[num] = entradaXref{flujo: int(c2), indice: int(c3)}
+			}
+		}
+	}
+	return flujo.dic, nil
+}
+
+// leer devuelve hasta n bytes desde la posición indicada (menos si se acaba el archivo).
+func (d *documentoPDF) leer(posicion int64, n int) []byte {
+	if posicion < 0 {
+		n += int(posicion)
+		posicion = 0
+	}
+	if resto := d.tamano - posicion; int64(n) > resto {
+		n = int(resto)
+	}
+	if n <= 0 {
+		return nil
+	}
+	buf := make([]byte, n)
+	leidos, _ := d.archivo.ReadAt(buf, posicion)
+	return buf[:leidos]
+}
+
+// objetoEn analiza el objeto indirecto "N G obj ... endobj" que empieza en la posición.
+func (d *documentoPDF) objetoEn(posicion int64) (any, error) {
+	l := &lexerPDF{datos: d.leer(posicion, ventanaObjetoPDF)}
+	for i := 0; i < 2; i++ {
+		if _, err := l.objeto(); err != nil {
+			return nil, err
+		}
+	}
+	if !l.palabra("obj") {
+		return nil, errSintaxisPDF
+	}
+	v, err := l.objeto()
+	if err != nil {
+		return nil, err
+	}
+
+	dic, ok := v.(dicPDF)
+	if !ok || !l.palabra("stream") {
+		return v, nil
+	}
+	// Los datos empiezan tras el fin de línea que sigue a "stream".
+	if l.pos < len(l.datos) && l.datos[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.datos) && l.datos[l.pos] == '\n' {
+		l.pos++
+	}
+	return flujoPDF{dic: dic, inicio: posicion + int64(l.pos)}, nil
+}
+
+// obtener devuelve el objeto indirecto con ese número, o nil si no existe.
+func (d *documentoPDF) obtener(num int) any {
+	entrada, ok := d.xref[num]
+	if !ok {
+		return nil
+	}
+	if entrada.flujo == 0 {
+		v, err := d.objetoEn(entrada.posicion)
+		if err != nil {
+			return nil
+		}
+		return v
+	}
+
+	// Objeto comprimido: se busca dentro del flujo de objetos.
+	contenedor, ok := d.objStm[entrada.flujo]
+	if !ok {
+		flujo, _ := d.obtener(entrada.flujo).(flujoPDF)
+		if flujo.dic["Type"] != nombrePDF("ObjStm") {
+			return nil
+		}
+		datos, err := d.contenido(flujo)
+		if err != nil {
+			return nil
+		}
+		primero, _ := flujo.dic["First"].(int64)
+		contenedor = flujoObjetos{datos: datos, primero: primero}
+		d.objStm[entrada.flujo] = contenedor
+	}
+	datos, primero := contenedor.datos, contenedor.primero
+
+	// Cabecera: pares "número posición" antes de /First.
+	l := &lexerPDF{datos: datos}
+	for i := 0; i <= entrada.indice; i++ {
+		numV, err1 := l.objeto()
+		posV, err2 := l.objeto()
+		if err1 != nil || err2 != nil {
+			return nil
+		}
+		if i == entrada.indice {
+			pos, _ := posV.(int64)
+			if n, _ := numV.(int64); int(n) != num || primero+pos >= int64(len(datos)) {
+				return nil
+			}
+			l = &lexerPDF{datos: datos, pos: int(primero + pos)}
+			v, err := l.objeto()
+			if err != nil {
+				return nil
+			}
+			return v
+		}
+	}
+	return nil
+}
+
+// resolver sigue las referencias indirectas hasta llegar a un objeto directo.
+func (d *documentoPDF) resolver(v any) any {
+	for i := 0; i < limiteCadenaPDF; i++ {
+		ref, ok := v.(refPDF)
+		if !ok {
+			return v
+		}
+		v = d.obtener(ref.num)
+	}
+	return nil
+}
+
+// contenido devuelve los datos del flujo ya decodificados.
+// Solo se admite FlateDecode (con o sin predictor PNG), que es lo habitual.
+func (d *documentoPDF) contenido(f flujoPDF) ([]byte, error) {
+	largo, ok := d.resolver(f.dic["Length"]).(int64)
+	if !ok || largo < 0 || largo > limiteFlujoPDF {
+		return nil, errSintaxisPDF
+	}
+	datos := d.leer(f.inicio, int(largo))
+
+	filtros := []any{}
+	switch filtro := d.resolver(f.dic["Filter"]).(type) {
+	case nombrePDF:
+		filtros = append(filtros, filtro)
+	case []any:
+		filtros = filtro
+	}
+	if len(filtros) == 0 {
+		return datos, nil
+	}
+	if len(filtros) != 1 || filtros[0] != nombrePDF("FlateDecode") {
+		return nil, ErrFormatoNoSoportado
+	}
+
+	lector, err := zlib.NewReader(bytes.NewReader(datos))
+	if err != nil {
+		return nil, errSintaxisPDF
+	}
+	defer lector.Close()
+	datos, err = io.ReadAll(io.LimitReader(lector, limiteFlujoPDF))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, errSintaxisPDF
+	}
+
+	parametros, _ := d.resolver(f.dic["DecodeParms"]).(dicPDF)
+	if predictor, _ := parametros["Predictor"].(int64); predictor >= 10 {
+		columnas, ok := parametros["Columns"].(int64)
+		if !ok {
+			columnas = 1
+		}
+		return predictorPNG(datos, int(columnas))
+	}
+	return datos, nil
+}
+
+// predictorPNG revierte el filtro PNG por fila (habitual en flujos xref).
+// Se asume 1 byte por muestra, que es lo que usan los flujos xref.
+func predictorPNG(datos []byte, columnas int) ([]byte, error) {
+	if columnas <= 0 {
+		return nil, errSintaxisPDF
+	}
+	fila := columnas + 1
+	salida := make([]byte, 0, len(datos)/fila*columnas)
+	anterior := make([]byte, columnas)
+	for len(datos) >= fila {
+		tipo, actual := datos[0], datos[1:fila]
+		datos = datos[fila:]
+		for i := range actual {
+			var izq, arriba, diag byte
+			if i > 0 {
+				izq, diag = actual[i-1], anterior[i-1]
+			}
+			arriba = anterior[i]
+			switch tipo {
+			case 1:
+				actual[i] += izq
+			case 2:
+				actual[i] += arriba
+			case 3:
+				actual[i] += byte((int(izq) + int(arriba)) / 2)
+			case 4:
+				actual[i] += paeth(izq, arriba, diag)
+			}
+		}
+		salida = append(salida, actual...)
+		copy(anterior, actual)
+	}
+	return salida, nil
+}
+
+// paeth es el predictor de PNG tipo 4.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// texto interpreta una cadena de texto PDF: UTF-16BE con BOM, UTF-8 con BOM
+// o PDFDocEncoding (que coincide con Latin-1 en los caracteres habituales).
+func (d *documentoPDF) texto(v any) string {
+	cadena, ok := d.resolver(v).(cadenaPDF)
+	if !ok {
+		return ""
+	}
+	b := []byte(cadena)
+
+	var s string
+	switch {
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		unidades := make([]uint16, (len(b)-2)/2)
+		for i := range unidades {
+			unidades[i] = binary.BigEndian.Uint16(b[2+2*i:])
+		}
+		s = string(utf16.Decode(unidades))
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		s = string(b[3:])
+	default:
+		runas := make([]rune, len(b))
+		for i, c := range b {
+			runas[i] = rune(c)
+		}
+		s = string(runas)
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// lexerPDF analiza la sintaxis de objetos PDF sobre un bloque de bytes.
+type lexerPDF struct {
+	datos []byte
+	pos   int
+}
+
+// esEspacioPDF y esDelimitadorPDF siguen la tabla de caracteres de la norma PDF.
+func esEspacioPDF(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func esDelimitadorPDF(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// saltarEspacios avanza sobre espacios y comentarios.
+func (l *lexerPDF) saltarEspacios() {
+	for l.pos < len(l.datos) {
+		c := l.datos[l.pos]
+		switch {
+		case esEspacioPDF(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.datos) && l.datos[l.pos] != '\n' && l.datos[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// palabra consume la palabra clave indicada si es la siguiente.
+func (l *lexerPDF) palabra(p string) bool {
+	l.saltarEspacios()
+	fin := l.pos + len(p)
+	if fin > len(l.datos) || string(l.datos[l.pos:fin]) != p {
+		return false
+	}
+	if fin < len(l.datos) && !esEspacioPDF(l.datos[fin]) && !esDelimitadorPDF(l.datos[fin]) {
+		return false
+	}
+	l.pos = fin
+	return true
+}
+
+// token devuelve la siguiente secuencia de caracteres regulares.
+func (l *lexerPDF) token() string {
+	inicio := l.pos
+	for l.pos < len(l.datos) && !esEspacioPDF(l.datos[l.pos]) && !esDelimitadorPDF(l.datos[l.pos]) {
+		l.pos++
+	}
+	return string(l.datos[inicio:l.pos])
+}
+
+// objeto analiza el siguiente objeto directo (o una referencia "N G R").
+func (l *lexerPDF) objeto() (any, error) {
+	l.saltarEspacios()
+	if l.pos >= len(l.datos) {
+		return nil, errSintaxisPDF
+	}
+
+	switch c := l.datos[l.pos]; {
+	case c == '/':
+		l.pos++
+		return nombrePDF(decodificarNombre(l.token())), nil
+	case c == '(':
+		return l.cadenaLiteral()
+	case c == '<' && l.pos+1 < len(l.datos) && l.datos[l.pos+1] == '<':
+		return l.diccionario()
+	case c == '<':
+		return l.cadenaHex()
+	case c == '[':
+		l.pos++
+		var arreglo []any
+		for {
+			l.saltarEspacios()
+			if l.pos < len(l.datos) && l.datos[l.pos] == ']' {
+				l.pos++
+				return arreglo, nil
+			}
+			v, err := l.objeto()
+			if err != nil {
+				return nil, err
+			}
+			arreglo = append(arreglo, v)
+		}
+	}
+
+	t := l.token()
+	switch t {
+	case "":
+		return nil, errSintaxisPDF
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	entero, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		decimal, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return nil, errSintaxisPDF
+		}
+		return decimal, nil
+	}
+
+	// ¿Es una referencia "N G R"?
+	guardada := l.pos
+	l.saltarEspacios()
+	if gen := l.token(); gen != "" {
+		if _, err := strconv.Atoi(gen); err == nil && l.palabra("R") {
+			return refPDF{num: int(entero)}, nil
+		}
+	}
+	l.pos = guardada
+	return entero, nil
+}
+
+// diccionario analiza "<< /Clave valor ... >>".
+func (l *lexerPDF) diccionario() (any, error) {
+	l.pos += 2
+	dic := dicPDF{}
+	for {
+		l.saltarEspacios()
+		if l.pos+1 < len(l.datos) && l.datos[l.pos] == '>' && l.datos[l.pos+1] == '>' {
+			l.pos += 2
+			return dic, nil
+		}
+		clave, err := l.objeto()
+		if err != nil {
+			return nil, err
+		}
+		nombre, ok := clave.(nombrePDF)
+		if !ok {
+			return nil, errSintaxisPDF
+		}
+		valor, err := l.objeto()
+		if err != nil {
+			return nil, err
+		}
+		dic[nombre] = valor
+	}
+}
+
+// cadenaLiteral analiza "(texto)" con paréntesis anidados y secuencias de escape.
+func (l *lexerPDF) cadenaLiteral() (any, error) {
+	l.pos++
+	var b []byte
+	nivel := 1
+	for l.pos < len(l.datos) {
+		c := l.datos[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			nivel++
+		case ')':
+			nivel--
+			if nivel == 0 {
+				return cadenaPDF(b), nil
+			}
+		case '\\':
+			if l.pos >= len(l.datos) {
+				return nil, errSintaxisPDF
+			}
+			e := l.datos[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Continuación de línea.
+				if l.pos < len(l.datos) && l.datos[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.datos) && l.datos[l.pos] >= '0' && l.datos[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.datos[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, errSintaxisPDF
+}
+
+// cadenaHex analiza "<48656C6C6F>"; un dígito final impar vale como si siguiera un 0.
+func (l *lexerPDF) cadenaHex() (any, error) {
+	l.pos++
+	var b []byte
+	var alto, mitad = byte(0), false
+	for l.pos < len(l.datos) {
+		c := l.datos[l.pos]
+		l.pos++
+		if c == '>' {
+			if mitad {
+				b = append(b, alto<<4)
+			}
+			return cadenaPDF(b), nil
+		}
+		if esEspacioPDF(c) {
+			continue
+		}
+		v, ok := valorHex(c)
+		if !ok {
+			return nil, errSintaxisPDF
+		}
+		if mitad {
+			b = append(b, alto<<4|v)
+		} else {
+			alto = v
+		}
+		mitad = !mitad
+	}
+	return nil, errSintaxisPDF
+}
+
+// valorHex convierte un dígito hexadecimal.
+func valorHex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// decodificarNombre resuelve las secuencias #xx de los nombres PDF.
+func decodificarNombre(t string) string {
+	if !strings.Contains(t, "#") {
+		return t
+	}
+	var b []byte
+	for i := 0; i < len(t); i++ {
+		if t[i] == '#' && i+2 < len(t) {
+			alto, ok1 := valorHex(t[i+1])
+			bajo, ok2 := valorHex(t[i+2])
+			if ok1 && ok2 {
+				b = append(b, alto<<4|bajo)
+				i += 2
+				continue
+			}
+		}
+		b = append(b, t[i])
+	}
+	return string(b)
+}
@@ -0,0 +1,104 @@
+package metadatos // Paquete metadatos.
+
+import (
+	"bytes"        // Paquete para leer el paquete XMP desde memoria.
+	"encoding/xml" // Paquete para recorrer el RDF/XML del XMP.
+	"strings"      // Paquete para limpiar textos.
+)
+
+// Espacios de nombres XMP que se leen.
+const (
+	nsDublinCore = "http://purl.org/dc/elements/1.1/"
+	nsXMPBasico  = "http://ns.adobe.com/xap/1.0/"
+)
+
+// leerXMP obtiene título, autor, fecha de creación, idioma, editorial e ISBN
+// de un paquete XMP. Acepta tanto propiedades como elementos (<dc:title>)
+// como en forma de atributo de rdf:Description (xmp:CreateDate="...").
+// Si el XML está dañado se devuelve lo que se alcanzó a leer.
+func leerXMP(datos []byte) Metadatos {
+	valores := map[string]string{}
+	guardar := func(campo, valor string) {
+		valor = strings.Join(strings.Fields(valor), " ")
+		if valor != "" && valores[campo] == "" {
+			valores[campo] = valor
+		}
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(datos))
+	decoder.Strict = false
+
+	// campo es la propiedad abierta (ej. "dc:title"); texto acumula su contenido.
+	// En listas (rdf:Alt, rdf:Seq, rdf:Bag) solo cuenta el primer rdf:li.
+	var campo string
+	var texto strings.Builder
+	profundidad := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if campo != "" {
+				profundidad++
+				continue
+			}
+			if nombre := nombreXMP(t.Name); nombre != "" {
+				campo, profundidad = nombre, 0
+				texto.Reset()
+				continue
+			}
+			for _, a := range t.Attr {
+				if nombre := nombreXMP(a.Name); nombre != "" {
+					guardar(nombre, a.Value)
+				}
+			}
+		case xml.CharData:
+			if campo != "" {
+				texto.Write(t)
+			}
+		case xml.EndElement:
+			if campo == "" {
+				continue
+			}
+			if profundidad > 0 {
+				profundidad--
+				if t.Name.Local == "li" && strings.TrimSpace(texto.String()) != "" {
+					guardar(campo, texto.String())
+					campo = "-" // Se ignora el resto de la lista.
+				}
+				continue
+			}
+			if campo != "-" {
+				guardar(campo, texto.String())
+			}
+			campo = ""
+		}
+	}
+
+	return Metadatos{
+		Titulo:          valores["dc:title"],
+		Autor:           valores["dc:creator"],
+		AnioPublicacion: anioDesdeFecha(valores["xmp:CreateDate"]),
+		Idioma:          valores["dc:language"],
+		Editorial:       valores["dc:publisher"],
+		ISBN:            NormalizarISBN(valores["dc:identifier"]),
+	}
+}
+
+// nombreXMP devuelve el nombre corto de las propiedades XMP que interesan, o "".
+func nombreXMP(n xml.Name) string {
+	switch n.Space {
+	case nsDublinCore:
+		switch n.Local {
+		case "title", "creator", "language", "publisher", "identifier":
+			return "dc:" + n.Local
+		}
+	case nsXMPBasico:
+		if n.Local == "CreateDate" {
+			return "xmp:CreateDate"
+		}
+	}
+	return ""
+}
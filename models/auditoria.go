@@ -0,0 +1,45 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para la fecha de cada entrada.
+
+// Acciones registradas en la auditoría del catálogo.
+const (
+	AuditoriaCrear      = "CREAR"      // Se registró un libro.
+	AuditoriaActualizar = "ACTUALIZAR" // Se modificaron datos o el archivo de un libro.
+	AuditoriaEliminar   = "ELIMINAR"   // Se eliminó un libro.
+)
+
+// CambioCampo guarda el valor de un campo del libro antes y después de la acción.
+// Al crear, Antes está vacío; al eliminar, Despues está vacío.
+type CambioCampo struct {
+	Campo   string `json:"campo"`
+	Antes   string `json:"antes"`
+	Despues string `json:"despues"`
+}
+
+// Auditoria representa una modificación del catálogo hecha por un usuario.
+// Las entradas solo se agregan: nunca se editan ni se borran.
+type Auditoria struct {
+	// ID guarda el identificador de la entrada.
+	ID int `json:"id"`
+
+	// IDActor, Actor y Rol guardan quién hizo el cambio, tal como era en ese momento.
+	IDActor int    `json:"id_actor"`
+	Actor   string `json:"actor"`
+	Rol     string `json:"rol"`
+
+	// Accion guarda AuditoriaCrear, AuditoriaActualizar o AuditoriaEliminar.
+	Accion string `json:"accion"`
+
+	// IDLibro guarda el libro afectado (puede ya no existir).
+	IDLibro int `json:"id_libro"`
+
+	// Cambios guarda los campos que cambiaron, con su valor anterior y nuevo.
+	Cambios []CambioCampo `json:"cambios"`
+
+	// IP guarda la dirección desde la que se hizo la petición.
+	IP string `json:"ip"`
+
+	// Fecha guarda el momento del cambio.
+	Fecha time.Time `json:"fecha"`
+}
@@ -0,0 +1,31 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para fechas de creación, uso y revocación.
+
+// ClaveAPI representa una clave de API de larga duración asignada a un usuario.
+// El valor secreto solo se muestra al crearla; en la base se guarda su hash.
+type ClaveAPI struct {
+	// ID guarda el identificador de la clave.
+	ID int `json:"id"`
+
+	// IDUsuario guarda el usuario con cuyos permisos actúa la clave.
+	IDUsuario int `json:"id_usuario"`
+
+	// Nombre guarda una descripción (ej. "Script de inventario").
+	Nombre string `json:"nombre"`
+
+	// Prefijo guarda los primeros caracteres de la clave para reconocerla.
+	Prefijo string `json:"prefijo"`
+
+	// CreadaPor guarda el ADMIN que creó la clave.
+	CreadaPor int `json:"creada_por"`
+
+	// CreadaEn guarda la fecha de creación.
+	CreadaEn time.Time `json:"creada_en"`
+
+	// UltimoUso guarda la última vez que se usó (nil si nunca).
+	UltimoUso *time.Time `json:"ultimo_uso,omitempty"`
+
+	// RevocadaEn guarda la fecha de revocación (nil si sigue activa).
+	RevocadaEn *time.Time `json:"revocada_en,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para la fecha de activación.
+
+// DobleFactor representa el estado del segundo factor (TOTP) de un usuario.
+type DobleFactor struct {
+	// Activo indica si el usuario confirmó el alta y se le pide el código al iniciar sesión.
+	Activo bool `json:"activo"`
+
+	// ActivadoEn guarda el momento en que se confirmó el alta (nil si no está activo).
+	ActivadoEn *time.Time `json:"activado_en,omitempty"`
+
+	// CodigosRestantes guarda la cantidad de códigos de recuperación sin usar.
+	CodigosRestantes int `json:"codigos_restantes"`
+}
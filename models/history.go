@@ -1,9 +1,21 @@
-package models
-
-type History struct {
-	ID     int    `json:"id"`
-	UserID int    `json:"user_id"`
-	BookID int    `json:"book_id"`
-	Accion string `json:"accion"`
-	Fecha  string `json:"fecha"`
-}
+package models
+
+// Acciones registradas en el historial.
+const (
+	AccionVer        = "VER"        // Se abrió el detalle del libro en el catálogo.
+	AccionDescargar  = "DESCARGAR"  // Se descargó el archivo del libro.
+	AccionPrestamo   = "PRESTAMO"   // Se tomó una licencia en préstamo.
+	AccionDevolucion = "DEVOLUCION" // Se devolvió la licencia (a mano o por vencimiento).
+)
+
+type History struct {
+	ID     int    `json:"id"`
+	UserID int    `json:"user_id"`
+	BookID int    `json:"book_id"`
+	Accion string `json:"accion"`
+	Fecha  string `json:"fecha"`
+
+	// Datos de referencia para mostrar el historial (no se guardan en la tabla).
+	Usuario string `json:"usuario,omitempty"`
+	Titulo  string `json:"titulo,omitempty"`
+}
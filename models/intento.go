@@ -0,0 +1,39 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para fechas de intentos y bloqueos.
+
+// IntentoLogin representa un intento fallido de inicio de sesión (formulario o API).
+type IntentoLogin struct {
+	// ID guarda el identificador del intento.
+	ID int `json:"id"`
+
+	// Correo guarda el correo escrito (exista o no una cuenta con él).
+	Correo string `json:"correo"`
+
+	// IP guarda la dirección desde la que llegó el intento.
+	IP string `json:"ip"`
+
+	// Fecha guarda el momento del intento.
+	Fecha time.Time `json:"fecha"`
+}
+
+// BloqueoLogin representa el contador de fallos de un correo y su bloqueo temporal.
+type BloqueoLogin struct {
+	// Correo guarda el correo al que se aplica el bloqueo.
+	Correo string `json:"correo"`
+
+	// Fallos guarda los fallos seguidos desde el último bloqueo o acceso correcto.
+	Fallos int `json:"fallos"`
+
+	// Bloqueos guarda cuántas veces se bloqueó seguidas (define la espera siguiente).
+	Bloqueos int `json:"bloqueos"`
+
+	// UltimoFallo guarda el momento del último intento fallido.
+	UltimoFallo time.Time `json:"ultimo_fallo"`
+
+	// BloqueadoHasta guarda el fin del bloqueo actual (nil si nunca se bloqueó).
+	BloqueadoHasta *time.Time `json:"bloqueado_hasta,omitempty"`
+
+	// Activo indica si el bloqueo sigue vigente al momento de la consulta.
+	Activo bool `json:"activo"`
+}
@@ -1,26 +1,47 @@
-package models // Se declara el paquete models, que agrupa las estructuras de datos del sistema.
-
-// Libro representa la estructura de un libro electrónico dentro del sistema.
-// En Go usamos "struct" (estructura) en lugar de clases como en Java.
-type Libro struct {
-	// ID almacena el identificador único del libro (clave primaria en MySQL).
-	ID int
-
-	// Titulo almacena el nombre o título del libro electrónico.
-	Titulo string
-
-	// Autor almacena el nombre del autor del libro.
-	Autor string
-
-	// Categoria almacena la categoría o género del libro (ej. Programación, Novela).
-	Categoria string
-
-	// AnioPublicacion almacena el año de publicación del libro.
-	AnioPublicacion int
-
-	// Formato almacena el formato del archivo (PDF, EPUB o MOBI).
-	Formato string
-
-	// StockLicencias almacena la cantidad disponible/licencias del libro.
-	StockLicencias int
-}
+package models // Se declara el paquete models, que agrupa las estructuras de datos del sistema.
+
+// Libro representa la estructura de un libro electrónico dentro del sistema.
+// En Go usamos "struct" (estructura) en lugar de clases como en Java.
+type Libro struct {
+	// ID almacena el identificador único del libro (clave primaria en MySQL).
+	ID int `json:"id"`
+
+	// Titulo almacena el nombre o título del libro electrónico.
+	Titulo string `json:"titulo"`
+
+	// Autor almacena el nombre del autor del libro.
+	Autor string `json:"autor"`
+
+	// Categoria almacena la categoría o género del libro (ej. Programación, Novela).
+	Categoria string `json:"categoria"`
+
+	// AnioPublicacion almacena el año de publicación del libro.
+	AnioPublicacion int `json:"anio_publicacion"`
+
+	// Formato almacena el formato del archivo (PDF, EPUB o MOBI).
+	Formato string `json:"formato"`
+
+	// StockLicencias almacena la cantidad de licencias libres (no prestadas ni
+	// apartadas para la lista de espera). La cambian los préstamos.
+	StockLicencias int `json:"stock_licencias"`
+
+	// LicenciasTotales almacena la cantidad de licencias del libro. Es lo que
+	// se edita; el stock libre se corre en la misma cantidad.
+	LicenciasTotales int `json:"licencias_totales"`
+
+	// Idioma almacena el código de idioma del libro (ej. "es"), si se conoce.
+	Idioma string `json:"idioma,omitempty"`
+
+	// Editorial almacena el nombre de la editorial, si se conoce.
+	Editorial string `json:"editorial,omitempty"`
+
+	// ISBN almacena el ISBN-10 o ISBN-13 normalizado (sin guiones), si se conoce.
+	ISBN string `json:"isbn,omitempty"`
+
+	// Paginas almacena la cantidad de páginas leída del PDF (0 si no se conoce).
+	Paginas int `json:"paginas,omitempty"`
+
+	// Archivo almacena la clave del archivo del libro dentro del almacenamiento.
+	// Está vacío si aún no se subió el archivo.
+	Archivo string `json:"-"`
+}
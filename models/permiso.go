@@ -0,0 +1,12 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+// Permiso representa una acción con nombre que se puede asignar a un rol
+// (ej. "libros.crear"). La lista de permisos la define el código; en la base
+// de datos solo se guarda qué roles tienen cada uno.
+type Permiso struct {
+	// Nombre guarda el identificador del permiso (ej. "libros.eliminar").
+	Nombre string `json:"nombre"`
+
+	// Descripcion guarda el texto que se muestra en la pantalla de permisos.
+	Descripcion string `json:"descripcion"`
+}
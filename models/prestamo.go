@@ -0,0 +1,38 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para fechas de préstamo, vencimiento y devolución.
+
+// Motivos por los que un préstamo deja de estar activo.
+const (
+	DevolucionManual  = "MANUAL"  // El usuario devolvió el libro.
+	DevolucionVencida = "VENCIDO" // Se cumplió el plazo del préstamo.
+)
+
+// Prestamo representa una licencia de un libro asignada a un usuario por un tiempo.
+// Mientras está activo, la licencia se descuenta de Libro.StockLicencias.
+type Prestamo struct {
+	// ID guarda el identificador del préstamo.
+	ID int `json:"id"`
+
+	// IDLibro guarda el libro prestado.
+	IDLibro int `json:"id_libro"`
+
+	// IDUsuario guarda el usuario que tiene el préstamo.
+	IDUsuario int `json:"id_usuario"`
+
+	// Titulo y Formato se copian del libro para mostrar el préstamo en pantalla.
+	Titulo  string `json:"titulo"`
+	Formato string `json:"formato"`
+
+	// PrestadoEn guarda la fecha del préstamo.
+	PrestadoEn time.Time `json:"prestado_en"`
+
+	// VenceEn guarda la fecha en que la licencia vuelve al stock.
+	VenceEn time.Time `json:"vence_en"`
+
+	// DevueltoEn guarda la fecha de devolución (nil si sigue activo).
+	DevueltoEn *time.Time `json:"devuelto_en,omitempty"`
+
+	// MotivoDevolucion guarda DevolucionManual o DevolucionVencida.
+	MotivoDevolucion string `json:"motivo_devolucion,omitempty"`
+}
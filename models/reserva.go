@@ -0,0 +1,40 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para fechas de la reserva.
+
+// Estados de una reserva en la lista de espera.
+const (
+	ReservaEsperando = "ESPERANDO" // En la cola, sin licencia todavía.
+	ReservaAsignada  = "ASIGNADA"  // Se le apartó una licencia; debe retirarla a tiempo.
+	ReservaRetirada  = "RETIRADA"  // Se convirtió en préstamo.
+	ReservaVencida   = "VENCIDA"   // No se retiró dentro del plazo.
+	ReservaCancelada = "CANCELADA" // El usuario salió de la cola.
+)
+
+// Reserva representa el lugar de un usuario en la lista de espera de un libro
+// sin licencias disponibles. La cola se atiende por orden de llegada (FIFO).
+type Reserva struct {
+	// ID guarda el identificador de la reserva (también define el orden en la cola).
+	ID int `json:"id"`
+
+	// IDLibro guarda el libro reservado.
+	IDLibro int `json:"id_libro"`
+
+	// IDUsuario guarda el usuario que espera.
+	IDUsuario int `json:"id_usuario"`
+
+	// Titulo se copia del libro para mostrar la reserva en pantalla.
+	Titulo string `json:"titulo"`
+
+	// Estado guarda uno de los estados Reserva* de este archivo.
+	Estado string `json:"estado"`
+
+	// CreadaEn guarda el momento en que el usuario entró a la cola.
+	CreadaEn time.Time `json:"creada_en"`
+
+	// RetiroHasta guarda el límite para retirar la licencia asignada (nil si aún espera).
+	RetiroHasta *time.Time `json:"retiro_hasta,omitempty"`
+
+	// Posicion guarda el lugar en la cola (1 = el siguiente); 0 si ya tiene licencia asignada.
+	Posicion int `json:"posicion"`
+}
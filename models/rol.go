@@ -0,0 +1,10 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+// Rol representa una fila de la tabla roles (ADMIN, OPERADOR, CONSULTA).
+type Rol struct {
+	// IDRol guarda el identificador del rol.
+	IDRol int `json:"id_rol"`
+
+	// NombreRol guarda el nombre del rol.
+	NombreRol string `json:"nombre_rol"`
+}
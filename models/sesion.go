@@ -0,0 +1,28 @@
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+import "time" // Paquete para manejar fechas de creación y expiración.
+
+// Sesion representa una sesión iniciada y guardada del lado del servidor.
+// El navegador solo conoce el ID opaco; usuario y rol se resuelven desde el almacén.
+type Sesion struct {
+	// ID guarda el identificador aleatorio de la sesión (valor de la cookie).
+	ID string
+
+	// IDUsuario guarda el identificador del usuario dueño de la sesión.
+	IDUsuario int
+
+	// Nombre guarda el nombre del usuario para mostrar en la interfaz.
+	Nombre string
+
+	// Rol guarda el nombre del rol (ADMIN, OPERADOR, CONSULTA).
+	Rol string
+
+	// CreadaEn guarda el momento en que se inició la sesión.
+	CreadaEn time.Time
+
+	// UltimoAcceso guarda el último uso de la sesión (para el tiempo de inactividad).
+	UltimoAcceso time.Time
+
+	// ExpiraEn guarda el vencimiento absoluto de la sesión.
+	ExpiraEn time.Time
+}
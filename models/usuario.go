@@ -1,25 +1,33 @@
-package models // Paquete models: contiene estructuras de datos del sistema.
-
-// Usuario representa a un usuario del sistema que puede iniciar sesión.
-type Usuario struct {
-	// IDUsuario guarda el identificador único del usuario en la base de datos.
-	IDUsuario int
-
-	// Nombre guarda el nombre del usuario.
-	Nombre string
-
-	// Correo guarda el correo electrónico usado para iniciar sesión.
-	Correo string
-
-	// Clave guarda la contraseña (en esta fase se usa texto simple para pruebas).
-	Clave string
-
-	// IDRol guarda el identificador del rol del usuario.
-	IDRol int
-
-	// NombreRol guarda el nombre del rol (ADMIN, OPERADOR, CONSULTA).
-	NombreRol string
-
-	// Estado guarda si el usuario está ACTIVO o INACTIVO.
-	Estado string
-}
+package models // Paquete models: contiene estructuras de datos del sistema.
+
+// Estados posibles de un usuario.
+const (
+	EstadoActivo    = "ACTIVO"    // Puede iniciar sesión.
+	EstadoInactivo  = "INACTIVO"  // Deshabilitado por un ADMIN.
+	EstadoPendiente = "PENDIENTE" // Registrado por su cuenta, falta verificar el correo.
+)
+
+// Usuario representa a un usuario del sistema que puede iniciar sesión.
+type Usuario struct {
+	// IDUsuario guarda el identificador único del usuario en la base de datos.
+	IDUsuario int `json:"id_usuario"`
+
+	// Nombre guarda el nombre del usuario.
+	Nombre string `json:"nombre"`
+
+	// Correo guarda el correo electrónico usado para iniciar sesión.
+	Correo string `json:"correo"`
+
+	// Clave guarda el hash bcrypt de la contraseña (nunca se envía en JSON).
+	// Las claves heredadas en texto plano se convierten a hash en el primer login exitoso.
+	Clave string `json:"-"`
+
+	// IDRol guarda el identificador del rol del usuario.
+	IDRol int `json:"id_rol"`
+
+	// NombreRol guarda el nombre del rol (ADMIN, OPERADOR, CONSULTA).
+	NombreRol string `json:"nombre_rol"`
+
+	// Estado guarda si el usuario está ACTIVO, INACTIVO o PENDIENTE.
+	Estado string `json:"estado"`
+}
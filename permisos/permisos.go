@@ -0,0 +1,266 @@
+package permisos // Paquete permisos: permisos con nombre y su asignación a roles.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"errors"         // Paquete para errores del módulo.
+	"log"            // Paquete para informar fallas al resolver permisos.
+	"sistema/models" // Estructuras Permiso y Rol.
+	"strings"        // Paquete para normalizar nombres de rol.
+	"sync"           // Paquete para proteger la caché.
+	"time"           // Paquete para la vigencia de la caché.
+)
+
+// Nombres de los permisos que controla el sistema.
+const (
+	LibrosCrear          = "libros.crear"           // Registrar libros y leer metadatos de archivos.
+	LibrosEditar         = "libros.editar"          // Modificar libros existentes.
+	LibrosEliminar       = "libros.eliminar"        // Borrar libros.
+	LibrosDescargar      = "libros.descargar"       // Descargar archivos sin préstamo.
+	LibrosExportar       = "libros.exportar"        // Exportar el catálogo (CSV, JSON y MARCXML).
+	PrestamosSolicitar   = "prestamos.solicitar"    // Pedir préstamos y unirse a listas de espera.
+	HistorialPropio      = "historial.propio"       // Ver el historial propio.
+	HistorialVer         = "historial.ver"          // Ver el historial de todos los usuarios.
+	AuditoriaVer         = "auditoria.ver"          // Ver y exportar la auditoría del catálogo.
+	UsuariosAdministrar  = "usuarios.administrar"   // Alta, rol, estado y clave de usuarios.
+	ClavesAPIAdministrar = "claves_api.administrar" // Crear y revocar claves de API.
+	PermisosAdministrar  = "permisos.administrar"   // Editar la asignación de permisos a roles.
+
+	// DobleFactorObligatorio no habilita una acción: obliga a los usuarios del rol
+	// a activar el segundo factor (TOTP) y a ingresar el código en cada inicio de sesión.
+	DobleFactorObligatorio = "doble_factor.obligatorio"
+)
+
+// Catalogo lista los permisos en el orden de la pantalla de administración.
+var Catalogo = []models.Permiso{
+	{Nombre: LibrosCrear, Descripcion: "Registrar libros"},
+	{Nombre: LibrosEditar, Descripcion: "Editar libros"},
+	{Nombre: LibrosEliminar, Descripcion: "Eliminar libros"},
+	{Nombre: LibrosDescargar, Descripcion: "Descargar archivos sin préstamo"},
+	{Nombre: LibrosExportar, Descripcion: "Exportar el catálogo"},
+	{Nombre: PrestamosSolicitar, Descripcion: "Pedir préstamos y reservas"},
+	{Nombre: HistorialPropio, Descripcion: "Ver su propio historial"},
+	{Nombre: HistorialVer, Descripcion: "Ver el historial de todos los usuarios"},
+	{Nombre: AuditoriaVer, Descripcion: "Ver y exportar la auditoría del catálogo"},
+	{Nombre: UsuariosAdministrar, Descripcion: "Administrar usuarios"},
+	{Nombre: ClavesAPIAdministrar, Descripcion: "Administrar claves de API"},
+	{Nombre: PermisosAdministrar, Descripcion: "Editar los permisos de cada rol"},
+	{Nombre: DobleFactorObligatorio, Descripcion: "Exigir segundo factor (TOTP) al iniciar sesión"},
+}
+
+// AsignacionInicial son los permisos de cada rol que cargan las migraciones en
+// roles_permisos. Sin MySQL (LIBROS_STORE=json) es la asignación fija del sistema.
+var AsignacionInicial = map[string][]string{
+	"ADMIN": {
+		LibrosCrear, LibrosEditar, LibrosEliminar, LibrosDescargar, LibrosExportar,
+		HistorialVer, AuditoriaVer, UsuariosAdministrar, ClavesAPIAdministrar, PermisosAdministrar,
+	},
+	"OPERADOR": {LibrosCrear, LibrosEditar, LibrosDescargar},
+	"CONSULTA": {PrestamosSolicitar, HistorialPropio},
+}
+
+// Errores del módulo de permisos.
+var (
+	ErrPermisoNoExiste  = errors.New("permiso no existe")
+	ErrRolNoExiste      = errors.New("rol no existe")
+	ErrSinAdministrador = errors.New("algún usuario activo debe conservar el permiso " + PermisosAdministrar)
+)
+
+// Servicio resuelve los permisos de cada rol desde la tabla roles_permisos.
+// Lo usan tanto los middlewares como las plantillas (función "puede"), por lo
+// que la asignación se guarda en una caché que se vuelve a leer al vencer
+// Vigencia o al guardar cambios desde la pantalla de administración. Sin
+// conexión (DB nil) usa AsignacionInicial, que no se puede editar.
+type Servicio struct {
+	DB       *sql.DB       // Conexión a la base de datos.
+	Vigencia time.Duration // Tiempo que se reutiliza la asignación leída.
+
+	mu      sync.Mutex                 // Protege cache y leidaEn.
+	cache   map[string]map[string]bool // Permisos por nombre de rol (en mayúsculas).
+	leidaEn time.Time                  // Momento de la última lectura.
+}
+
+// NuevoServicio crea el servicio de permisos con una caché de 30 segundos
+// (otras instancias del sistema ven los cambios en ese plazo).
+func NuevoServicio(db *sql.DB) *Servicio {
+	return &Servicio{
+		DB:       db,
+		Vigencia: 30 * time.Second,
+	}
+}
+
+// Tiene indica si el rol tiene el permiso. Si la asignación no se puede leer
+// se niega el acceso.
+func (s *Servicio) Tiene(rol, permiso string) bool {
+	asignacion, err := s.asignacion()
+	if err != nil {
+		log.Println("⚠️ Error al leer permisos: ", err)
+		return false
+	}
+	return asignacion[normalizarRol(rol)][permiso]
+}
+
+// Asignacion devuelve los roles y, por ID de rol, los permisos que tiene cada uno.
+func (s *Servicio) Asignacion() ([]models.Rol, map[int]map[string]bool, error) {
+	rows, err := s.DB.Query(`SELECT id_rol, nombre_rol FROM roles ORDER BY id_rol`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Rol{}
+	for rows.Next() {
+		var r models.Rol
+		if err := rows.Scan(&r.IDRol, &r.NombreRol); err != nil {
+			return nil, nil, err
+		}
+		roles = append(roles, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	filas, err := s.DB.Query(`SELECT id_rol, permiso FROM roles_permisos`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer filas.Close()
+
+	asignados := map[int]map[string]bool{}
+	for filas.Next() {
+		var idRol int
+		var permiso string
+		if err := filas.Scan(&idRol, &permiso); err != nil {
+			return nil, nil, err
+		}
+		if asignados[idRol] == nil {
+			asignados[idRol] = map[string]bool{}
+		}
+		asignados[idRol][permiso] = true
+	}
+	return roles, asignados, filas.Err()
+}
+
+// Guardar reemplaza los permisos de todos los roles indicados en una sola
+// transacción. Falla con ErrSinAdministrador si ningún usuario activo quedaría
+// con el permiso para volver a editar la asignación.
+func (s *Servicio) Guardar(asignados map[int][]string) error {
+	validos := map[string]bool{}
+	for _, p := range Catalogo {
+		validos[p.Nombre] = true
+	}
+	for _, lista := range asignados {
+		for _, permiso := range lista {
+			if !validos[permiso] {
+				return ErrPermisoNoExiste
+			}
+		}
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for idRol, lista := range asignados {
+		var existe int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM roles WHERE id_rol = ?`, idRol).Scan(&existe); err != nil {
+			return err
+		}
+		if existe == 0 {
+			return ErrRolNoExiste
+		}
+
+		if _, err := tx.Exec(`DELETE FROM roles_permisos WHERE id_rol = ?`, idRol); err != nil {
+			return err
+		}
+		for _, permiso := range lista {
+			if _, err := tx.Exec(`INSERT IGNORE INTO roles_permisos (id_rol, permiso) VALUES (?, ?)`, idRol, permiso); err != nil {
+				return err
+			}
+		}
+	}
+
+	var administradores int
+	err = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM usuarios u
+		INNER JOIN roles_permisos rp ON rp.id_rol = u.id_rol
+		WHERE rp.permiso = ? AND u.estado = ?
+	`, PermisosAdministrar, models.EstadoActivo).Scan(&administradores)
+	if err != nil {
+		return err
+	}
+	if administradores == 0 {
+		return ErrSinAdministrador
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.invalidar()
+	return nil
+}
+
+// asignacion devuelve la asignación en caché o la vuelve a leer si venció.
+func (s *Servicio) asignacion() (map[string]map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cache != nil && time.Since(s.leidaEn) < s.Vigencia {
+		return s.cache, nil
+	}
+	if s.DB == nil {
+		s.cache = map[string]map[string]bool{}
+		for rol, lista := range AsignacionInicial {
+			s.cache[rol] = map[string]bool{}
+			for _, permiso := range lista {
+				s.cache[rol][permiso] = true
+			}
+		}
+		s.leidaEn = time.Now()
+		return s.cache, nil
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT r.nombre_rol, rp.permiso
+		FROM roles_permisos rp
+		INNER JOIN roles r ON r.id_rol = rp.id_rol
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	asignacion := map[string]map[string]bool{}
+	for rows.Next() {
+		var rol, permiso string
+		if err := rows.Scan(&rol, &permiso); err != nil {
+			return nil, err
+		}
+		rol = normalizarRol(rol)
+		if asignacion[rol] == nil {
+			asignacion[rol] = map[string]bool{}
+		}
+		asignacion[rol][permiso] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.cache = asignacion
+	s.leidaEn = time.Now()
+	return asignacion, nil
+}
+
+// invalidar descarta la caché para que la próxima consulta lea la base.
+func (s *Servicio) invalidar() {
+	s.mu.Lock()
+	s.cache = nil
+	s.mu.Unlock()
+}
+
+// normalizarRol pasa el nombre del rol a mayúsculas sin espacios, igual que la sesión.
+func normalizarRol(rol string) string {
+	return strings.ToUpper(strings.TrimSpace(rol))
+}
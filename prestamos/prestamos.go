@@ -0,0 +1,355 @@
+package prestamos // Paquete prestamos: préstamo de licencias de libros a usuarios lectores.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"errors"            // Paquete para errores del servicio.
+	"sistema/historial" // Registro de préstamos y devoluciones.
+	"sistema/models"    // Estructuras Prestamo y Reserva.
+	"time"              // Paquete para plazos y vencimientos.
+)
+
+// ErrLibroNoExiste se usa cuando se intenta prestar un libro inexistente.
+var ErrLibroNoExiste = errors.New("libro no existe")
+
+// ErrSinLicencias se usa cuando el libro no tiene licencias disponibles.
+var ErrSinLicencias = errors.New("no hay licencias disponibles")
+
+// ErrYaPrestado se usa cuando el usuario ya tiene un préstamo activo del libro.
+var ErrYaPrestado = errors.New("el usuario ya tiene este libro prestado")
+
+// ErrPrestamoNoExiste se usa cuando no hay un préstamo activo con esos datos.
+var ErrPrestamoNoExiste = errors.New("préstamo no existe o ya fue devuelto")
+
+// Servicio administra los préstamos (tabla prestamos) y la lista de espera
+// (tabla reservas). La columna libros.stock_licencias cuenta las licencias
+// libres: prestar la descuenta y, al devolver, la licencia pasa al primero
+// de la cola o, si no hay nadie esperando, vuelve al stock.
+//
+// Para evitar bloqueos cruzados, toda transacción bloquea primero la fila
+// del libro y después las de prestamos y reservas.
+type Servicio struct {
+	DB            *sql.DB       // Conexión a la base de datos.
+	Duracion      time.Duration // Plazo de cada préstamo.
+	VentanaRetiro time.Duration // Tiempo para retirar una licencia asignada desde la cola.
+}
+
+// NuevoServicio crea un servicio con préstamos de 14 días y 48 horas para retirar reservas.
+func NuevoServicio(db *sql.DB) *Servicio {
+	return &Servicio{
+		DB:            db,
+		Duracion:      14 * 24 * time.Hour,
+		VentanaRetiro: 48 * time.Hour,
+	}
+}
+
+// Prestar reserva una licencia del libro para el usuario.
+// Si el usuario tiene una licencia asignada desde la cola, se usa esa;
+// si no, se toma una del stock. La fila del libro se bloquea durante la
+// transacción, así dos préstamos simultáneos no pueden llevarse la misma licencia.
+func (s *Servicio) Prestar(idUsuario, idLibro int) (models.Prestamo, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.Prestamo{}, err
+	}
+	defer tx.Rollback()
+
+	ahora := time.Now()
+	p := models.Prestamo{IDLibro: idLibro, IDUsuario: idUsuario, PrestadoEn: ahora, VenceEn: ahora.Add(s.Duracion)}
+
+	if err := bloquearLibro(tx, idLibro, &p.Titulo, &p.Formato); err != nil {
+		return models.Prestamo{}, err
+	}
+	// Vencimientos pendientes de este libro antes de decidir.
+	if _, err := s.ponerAlDia(tx, idLibro, ahora); err != nil {
+		return models.Prestamo{}, err
+	}
+
+	var activos int
+	query := `SELECT COUNT(*) FROM prestamos WHERE id_libro = ? AND id_usuario = ? AND devuelto_en IS NULL`
+	if err := tx.QueryRow(query, idLibro, idUsuario).Scan(&activos); err != nil {
+		return models.Prestamo{}, err
+	}
+	if activos > 0 {
+		return models.Prestamo{}, ErrYaPrestado
+	}
+
+	// ¿Tiene una licencia asignada desde la lista de espera?
+	res, err := tx.Exec(`
+		UPDATE reservas SET estado = ?, cerrada_en = ?
+		WHERE id_libro = ? AND id_usuario = ? AND estado = ?
+	`, models.ReservaRetirada, ahora, idLibro, idUsuario, models.ReservaAsignada)
+	if err != nil {
+		return models.Prestamo{}, err
+	}
+	if retiradas, err := res.RowsAffected(); err != nil {
+		return models.Prestamo{}, err
+	} else if retiradas == 0 {
+		// Sin reserva asignada: se toma una licencia libre del stock.
+		var stock int
+		if err := tx.QueryRow(`SELECT stock_licencias FROM libros WHERE id = ?`, idLibro).Scan(&stock); err != nil {
+			return models.Prestamo{}, err
+		}
+		if stock <= 0 {
+			return models.Prestamo{}, ErrSinLicencias
+		}
+		if _, err := tx.Exec(`UPDATE libros SET stock_licencias = stock_licencias - 1 WHERE id = ?`, idLibro); err != nil {
+			return models.Prestamo{}, err
+		}
+	}
+
+	resultado, err := tx.Exec(`
+		INSERT INTO prestamos (id_libro, id_usuario, prestado_en, vence_en)
+		VALUES (?, ?, ?, ?)
+	`, idLibro, idUsuario, p.PrestadoEn, p.VenceEn)
+	if err != nil {
+		return models.Prestamo{}, err
+	}
+	id, err := resultado.LastInsertId()
+	if err != nil {
+		return models.Prestamo{}, err
+	}
+	p.ID = int(id)
+
+	if err := historial.Registrar(tx, idUsuario, idLibro, models.AccionPrestamo); err != nil {
+		return models.Prestamo{}, err
+	}
+	return p, tx.Commit()
+}
+
+// Devolver cierra el préstamo activo del usuario y libera la licencia.
+func (s *Servicio) Devolver(idPrestamo, idUsuario int) error {
+	var idLibro int
+	query := `SELECT id_libro FROM prestamos WHERE id = ? AND id_usuario = ? AND devuelto_en IS NULL`
+	if err := s.DB.QueryRow(query, idPrestamo, idUsuario).Scan(&idLibro); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPrestamoNoExiste
+		}
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := bloquearLibro(tx, idLibro, nil, nil); err != nil {
+		return err
+	}
+
+	// Se vuelve a comprobar con la fila bloqueada (pudo vencer entre tanto).
+	var activo int
+	query = `SELECT COUNT(*) FROM prestamos WHERE id = ? AND devuelto_en IS NULL FOR UPDATE`
+	if err := tx.QueryRow(query, idPrestamo).Scan(&activo); err != nil {
+		return err
+	}
+	if activo == 0 {
+		return ErrPrestamoNoExiste
+	}
+
+	if err := s.cerrarPrestamo(tx, idPrestamo, idUsuario, idLibro, time.Now(), models.DevolucionManual); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DevolverVencidos cierra los préstamos cuyo plazo pasó, vence las reservas
+// asignadas que no se retiraron a tiempo y pasa las licencias liberadas a la
+// lista de espera. Devuelve cuántos préstamos y reservas se cerraron.
+func (s *Servicio) DevolverVencidos() (int, error) {
+	ahora := time.Now()
+
+	// Libros con algo pendiente; cada uno se procesa en su propia transacción.
+	rows, err := s.DB.Query(`
+		SELECT id_libro FROM prestamos WHERE devuelto_en IS NULL AND vence_en <= ?
+		UNION
+		SELECT id_libro FROM reservas WHERE estado = ? AND retiro_hasta <= ?
+		UNION
+		SELECT r.id_libro FROM reservas r INNER JOIN libros l ON l.id = r.id_libro
+		WHERE r.estado = ? AND l.stock_licencias > 0
+	`, ahora, models.ReservaAsignada, ahora, models.ReservaEsperando)
+	if err != nil {
+		return 0, err
+	}
+	var libros []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		libros = append(libros, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, idLibro := range libros {
+		n, err := s.ponerAlDiaLibro(idLibro, ahora)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ponerAlDiaLibro aplica ponerAlDia a un libro en una transacción propia.
+func (s *Servicio) ponerAlDiaLibro(idLibro int, ahora time.Time) (int, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := bloquearLibro(tx, idLibro, nil, nil); err != nil {
+		if err == ErrLibroNoExiste {
+			return 0, nil
+		}
+		return 0, err
+	}
+	n, err := s.ponerAlDia(tx, idLibro, ahora)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// Activo devuelve el préstamo vigente del usuario para el libro.
+// Un préstamo vencido no cuenta aunque todavía no se haya cerrado.
+func (s *Servicio) Activo(idUsuario, idLibro int) (models.Prestamo, error) {
+	prestamos, err := s.listar(`p.id_usuario = ? AND p.id_libro = ?`, idUsuario, idLibro)
+	if err != nil {
+		return models.Prestamo{}, err
+	}
+	if len(prestamos) == 0 {
+		return models.Prestamo{}, ErrPrestamoNoExiste
+	}
+	return prestamos[0], nil
+}
+
+// ListarActivos devuelve los préstamos vigentes del usuario, del más próximo a vencer al más lejano.
+func (s *Servicio) ListarActivos(idUsuario int) ([]models.Prestamo, error) {
+	return s.listar(`p.id_usuario = ?`, idUsuario)
+}
+
+// listar consulta préstamos vigentes con una condición adicional.
+func (s *Servicio) listar(condicion string, args ...any) ([]models.Prestamo, error) {
+	query := `
+		SELECT p.id, p.id_libro, p.id_usuario, l.titulo, l.formato, p.prestado_en, p.vence_en
+		FROM prestamos p
+		INNER JOIN libros l ON l.id = p.id_libro
+		WHERE p.devuelto_en IS NULL AND p.vence_en > ? AND ` + condicion + `
+		ORDER BY p.vence_en ASC
+	`
+	rows, err := s.DB.Query(query, append([]any{time.Now()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prestamos []models.Prestamo
+	for rows.Next() {
+		var p models.Prestamo
+		err := rows.Scan(&p.ID, &p.IDLibro, &p.IDUsuario, &p.Titulo, &p.Formato, &p.PrestadoEn, &p.VenceEn)
+		if err != nil {
+			return nil, err
+		}
+		prestamos = append(prestamos, p)
+	}
+	return prestamos, rows.Err()
+}
+
+// bloquearLibro bloquea la fila del libro hasta el fin de la transacción.
+// Si titulo y formato no son nil, también los lee.
+func bloquearLibro(tx *sql.Tx, idLibro int, titulo, formato *string) error {
+	var t, f string
+	err := tx.QueryRow(`SELECT titulo, formato FROM libros WHERE id = ? FOR UPDATE`, idLibro).Scan(&t, &f)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrLibroNoExiste
+		}
+		return err
+	}
+	if titulo != nil {
+		*titulo = t
+	}
+	if formato != nil {
+		*formato = f
+	}
+	return nil
+}
+
+// ponerAlDia, con la fila del libro ya bloqueada, cierra sus préstamos vencidos,
+// vence las reservas asignadas no retiradas y reparte el stock libre entre la
+// lista de espera. Devuelve cuántos préstamos y reservas cerró.
+func (s *Servicio) ponerAlDia(tx *sql.Tx, idLibro int, ahora time.Time) (int, error) {
+	vencidos, err := paresBloqueados(tx, `
+		SELECT id, id_usuario FROM prestamos
+		WHERE id_libro = ? AND devuelto_en IS NULL AND vence_en <= ?
+		FOR UPDATE
+	`, idLibro, ahora)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range vencidos {
+		if err := s.cerrarPrestamo(tx, v[0], v[1], idLibro, ahora, models.DevolucionVencida); err != nil {
+			return 0, err
+		}
+	}
+
+	noRetiradas, err := paresBloqueados(tx, `
+		SELECT id, id_usuario FROM reservas
+		WHERE id_libro = ? AND estado = ? AND retiro_hasta <= ?
+		FOR UPDATE
+	`, idLibro, models.ReservaAsignada, ahora)
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range noRetiradas {
+		if _, err := tx.Exec(`UPDATE reservas SET estado = ?, cerrada_en = ? WHERE id = ?`, models.ReservaVencida, ahora, r[0]); err != nil {
+			return 0, err
+		}
+		if err := s.liberarLicencia(tx, idLibro, ahora); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := s.asignarStockLibre(tx, idLibro, ahora); err != nil {
+		return 0, err
+	}
+	return len(vencidos) + len(noRetiradas), nil
+}
+
+// paresBloqueados ejecuta una consulta que devuelve pares (id, id_usuario).
+func paresBloqueados(tx *sql.Tx, query string, args ...any) ([][2]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids [][2]int
+	for rows.Next() {
+		var par [2]int
+		if err := rows.Scan(&par[0], &par[1]); err != nil {
+			return nil, err
+		}
+		ids = append(ids, par)
+	}
+	return ids, rows.Err()
+}
+
+// cerrarPrestamo marca el préstamo como devuelto, lo anota en el historial y libera su licencia.
+func (s *Servicio) cerrarPrestamo(tx *sql.Tx, idPrestamo, idUsuario, idLibro int, ahora time.Time, motivo string) error {
+	_, err := tx.Exec(`UPDATE prestamos SET devuelto_en = ?, motivo_devolucion = ? WHERE id = ?`, ahora, motivo, idPrestamo)
+	if err != nil {
+		return err
+	}
+	if err := historial.Registrar(tx, idUsuario, idLibro, models.AccionDevolucion); err != nil {
+		return err
+	}
+	return s.liberarLicencia(tx, idLibro, ahora)
+}
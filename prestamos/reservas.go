@@ -0,0 +1,235 @@
+package prestamos // Paquete prestamos.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"errors"         // Paquete para errores de la lista de espera.
+	"sistema/models" // Estructura Reserva.
+	"time"           // Paquete para la ventana de retiro.
+)
+
+// ErrHayLicencias se usa al reservar un libro que todavía tiene licencias libres.
+var ErrHayLicencias = errors.New("el libro tiene licencias disponibles")
+
+// ErrYaReservado se usa cuando el usuario ya está en la cola del libro.
+var ErrYaReservado = errors.New("el usuario ya tiene una reserva de este libro")
+
+// ErrReservaNoExiste se usa cuando no hay una reserva activa con esos datos.
+var ErrReservaNoExiste = errors.New("reserva no existe o ya fue cerrada")
+
+// Reservar pone al usuario al final de la lista de espera del libro.
+// Solo se puede reservar cuando no quedan licencias libres.
+func (s *Servicio) Reservar(idUsuario, idLibro int) (models.Reserva, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return models.Reserva{}, err
+	}
+	defer tx.Rollback()
+
+	ahora := time.Now()
+	r := models.Reserva{IDLibro: idLibro, IDUsuario: idUsuario, Estado: models.ReservaEsperando, CreadaEn: ahora}
+
+	if err := bloquearLibro(tx, idLibro, &r.Titulo, nil); err != nil {
+		return models.Reserva{}, err
+	}
+	if _, err := s.ponerAlDia(tx, idLibro, ahora); err != nil {
+		return models.Reserva{}, err
+	}
+
+	var prestados, reservados, stock int
+	err = tx.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM prestamos WHERE id_libro = ? AND id_usuario = ? AND devuelto_en IS NULL),
+			(SELECT COUNT(*) FROM reservas WHERE id_libro = ? AND id_usuario = ? AND estado IN (?, ?)),
+			(SELECT stock_licencias FROM libros WHERE id = ?)
+	`, idLibro, idUsuario, idLibro, idUsuario, models.ReservaEsperando, models.ReservaAsignada, idLibro).
+		Scan(&prestados, &reservados, &stock)
+	if err != nil {
+		return models.Reserva{}, err
+	}
+	switch {
+	case prestados > 0:
+		return models.Reserva{}, ErrYaPrestado
+	case reservados > 0:
+		return models.Reserva{}, ErrYaReservado
+	case stock > 0:
+		return models.Reserva{}, ErrHayLicencias
+	}
+
+	resultado, err := tx.Exec(`
+		INSERT INTO reservas (id_libro, id_usuario, estado, creada_en)
+		VALUES (?, ?, ?, ?)
+	`, idLibro, idUsuario, r.Estado, r.CreadaEn)
+	if err != nil {
+		return models.Reserva{}, err
+	}
+	id, err := resultado.LastInsertId()
+	if err != nil {
+		return models.Reserva{}, err
+	}
+	r.ID = int(id)
+
+	query := `SELECT COUNT(*) FROM reservas WHERE id_libro = ? AND estado = ? AND id <= ?`
+	if err := tx.QueryRow(query, idLibro, models.ReservaEsperando, r.ID).Scan(&r.Posicion); err != nil {
+		return models.Reserva{}, err
+	}
+
+	return r, tx.Commit()
+}
+
+// CancelarReserva saca al usuario de la cola. Si ya tenía una licencia
+// asignada, esta pasa al siguiente de la cola (o vuelve al stock).
+func (s *Servicio) CancelarReserva(idReserva, idUsuario int) error {
+	var idLibro int
+	query := `SELECT id_libro FROM reservas WHERE id = ? AND id_usuario = ? AND estado IN (?, ?)`
+	err := s.DB.QueryRow(query, idReserva, idUsuario, models.ReservaEsperando, models.ReservaAsignada).Scan(&idLibro)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrReservaNoExiste
+		}
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := bloquearLibro(tx, idLibro, nil, nil); err != nil {
+		return err
+	}
+
+	// Se vuelve a leer el estado con la fila bloqueada.
+	var estado string
+	err = tx.QueryRow(`SELECT estado FROM reservas WHERE id = ? FOR UPDATE`, idReserva).Scan(&estado)
+	if err != nil {
+		return err
+	}
+	if estado != models.ReservaEsperando && estado != models.ReservaAsignada {
+		return ErrReservaNoExiste
+	}
+
+	ahora := time.Now()
+	if _, err := tx.Exec(`UPDATE reservas SET estado = ?, cerrada_en = ? WHERE id = ?`, models.ReservaCancelada, ahora, idReserva); err != nil {
+		return err
+	}
+	if estado == models.ReservaAsignada {
+		if err := s.liberarLicencia(tx, idLibro, ahora); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ReservaActiva devuelve la reserva en curso del usuario para el libro.
+func (s *Servicio) ReservaActiva(idUsuario, idLibro int) (models.Reserva, error) {
+	reservas, err := s.listarReservas(`r.id_usuario = ? AND r.id_libro = ?`, idUsuario, idLibro)
+	if err != nil {
+		return models.Reserva{}, err
+	}
+	if len(reservas) == 0 {
+		return models.Reserva{}, ErrReservaNoExiste
+	}
+	return reservas[0], nil
+}
+
+// ListarReservas devuelve las reservas en curso del usuario (asignadas primero).
+func (s *Servicio) ListarReservas(idUsuario int) ([]models.Reserva, error) {
+	return s.listarReservas(`r.id_usuario = ?`, idUsuario)
+}
+
+// listarReservas consulta reservas en curso, con su posición en la cola.
+// Las asignadas cuyo plazo de retiro ya pasó no se muestran aunque aún no se hayan cerrado.
+func (s *Servicio) listarReservas(condicion string, args ...any) ([]models.Reserva, error) {
+	query := `
+		SELECT r.id, r.id_libro, r.id_usuario, l.titulo, r.estado, r.creada_en, r.retiro_hasta,
+			(SELECT COUNT(*) FROM reservas c
+			 WHERE c.id_libro = r.id_libro AND c.estado = ? AND c.id <= r.id AND r.estado = ?) AS posicion
+		FROM reservas r
+		INNER JOIN libros l ON l.id = r.id_libro
+		WHERE (r.estado = ? OR (r.estado = ? AND r.retiro_hasta > ?)) AND ` + condicion + `
+		ORDER BY r.estado ASC, r.id ASC
+	`
+	base := []any{
+		models.ReservaEsperando, models.ReservaEsperando,
+		models.ReservaEsperando, models.ReservaAsignada, time.Now(),
+	}
+	rows, err := s.DB.Query(query, append(base, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservas []models.Reserva
+	for rows.Next() {
+		var r models.Reserva
+		var retiro sql.NullTime
+		err := rows.Scan(&r.ID, &r.IDLibro, &r.IDUsuario, &r.Titulo, &r.Estado, &r.CreadaEn, &retiro, &r.Posicion)
+		if err != nil {
+			return nil, err
+		}
+		if retiro.Valid {
+			r.RetiroHasta = &retiro.Time
+		}
+		reservas = append(reservas, r)
+	}
+	return reservas, rows.Err()
+}
+
+// liberarLicencia entrega una licencia que quedó libre: al primero de la
+// cola si hay alguien esperando, o de vuelta al stock si no.
+func (s *Servicio) liberarLicencia(tx *sql.Tx, idLibro int, ahora time.Time) error {
+	asignada, err := s.asignarSiguiente(tx, idLibro, ahora)
+	if err != nil || asignada {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE libros SET stock_licencias = stock_licencias + 1 WHERE id = ?`, idLibro)
+	return err
+}
+
+// asignarStockLibre reparte las licencias libres del stock entre la cola.
+// Cubre el caso en que se aumenta el stock de un libro con gente esperando.
+func (s *Servicio) asignarStockLibre(tx *sql.Tx, idLibro int, ahora time.Time) error {
+	var stock int
+	if err := tx.QueryRow(`SELECT stock_licencias FROM libros WHERE id = ?`, idLibro).Scan(&stock); err != nil {
+		return err
+	}
+	for ; stock > 0; stock-- {
+		asignada, err := s.asignarSiguiente(tx, idLibro, ahora)
+		if err != nil {
+			return err
+		}
+		if !asignada {
+			break
+		}
+		if _, err := tx.Exec(`UPDATE libros SET stock_licencias = stock_licencias - 1 WHERE id = ?`, idLibro); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asignarSiguiente aparta una licencia para la reserva más antigua en espera.
+// Devuelve false si la cola está vacía.
+func (s *Servicio) asignarSiguiente(tx *sql.Tx, idLibro int, ahora time.Time) (bool, error) {
+	var id int
+	err := tx.QueryRow(`
+		SELECT id FROM reservas
+		WHERE id_libro = ? AND estado = ?
+		ORDER BY id ASC
+		LIMIT 1
+		FOR UPDATE
+	`, idLibro, models.ReservaEsperando).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE reservas SET estado = ?, asignada_en = ?, retiro_hasta = ?
+		WHERE id = ?
+	`, models.ReservaAsignada, ahora, ahora.Add(s.VentanaRetiro), id)
+	return err == nil, err
+}
@@ -0,0 +1,481 @@
+package qr // Paquete qr: genera códigos QR para mostrar URIs en pantalla (alta del segundo factor).
+
+import (
+	"errors"  // Paquete para el error de capacidad.
+	"fmt"     // Paquete para armar el SVG.
+	"strings" // Paquete para acumular el SVG.
+)
+
+// Se codifica en modo byte con corrección de errores nivel M (15 %), versiones 1 a 10:
+// alcanza para unos 210 bytes, más que una URI otpauth:// con un correo largo.
+
+// ErrDemasiadoLargo se usa cuando el texto no entra en la versión más grande soportada.
+var ErrDemasiadoLargo = errors.New("texto demasiado largo para el código QR")
+
+// bloques describe la división en bloques Reed-Solomon de una versión (nivel M).
+type bloques struct {
+	ec     int // Codewords de corrección por bloque.
+	grupo1 int // Cantidad de bloques del primer grupo.
+	datos1 int // Codewords de datos de cada bloque del primer grupo.
+	grupo2 int // Cantidad de bloques del segundo grupo.
+	datos2 int // Codewords de datos de cada bloque del segundo grupo.
+}
+
+// capacidad devuelve la cantidad de codewords de datos de la versión.
+func (b bloques) capacidad() int {
+	return b.grupo1*b.datos1 + b.grupo2*b.datos2
+}
+
+// tablaM indexa por versión la estructura de bloques del nivel M (ISO/IEC 18004, tabla 9).
+var tablaM = [...]bloques{
+	{},
+	{10, 1, 16, 0, 0},
+	{16, 1, 28, 0, 0},
+	{26, 1, 44, 0, 0},
+	{18, 2, 32, 0, 0},
+	{24, 2, 43, 0, 0},
+	{16, 4, 27, 0, 0},
+	{18, 4, 31, 0, 0},
+	{22, 2, 38, 2, 39},
+	{22, 3, 36, 2, 37},
+	{26, 4, 43, 1, 44},
+}
+
+// alineacion indexa por versión las coordenadas de los patrones de alineación.
+var alineacion = [...][]int{
+	nil,
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+// Codigo es la matriz de módulos de un código QR; true es un módulo oscuro.
+type Codigo struct {
+	Tamano  int      // Módulos por lado.
+	Modulos [][]bool // Filas de módulos.
+
+	funcion [][]bool // Módulos de patrones fijos, que no llevan datos ni máscara.
+}
+
+// Codificar genera el código QR del texto con la versión más chica que lo contenga.
+func Codificar(texto string) (*Codigo, error) {
+	datos := []byte(texto)
+
+	version := 0
+	for v := 1; v < len(tablaM); v++ {
+		if 4+bitsLongitud(v)+8*len(datos) <= tablaM[v].capacidad()*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrDemasiadoLargo
+	}
+
+	q := nuevoCodigo(version)
+	q.dibujarPatrones(version)
+	q.dibujarDatos(intercalar(tablaM[version], segmento(datos, version)))
+
+	// Se elige la máscara con menor penalización, como indica la norma.
+	mejor, menor := 0, -1
+	for mascara := 0; mascara < 8; mascara++ {
+		q.aplicarMascara(mascara)
+		q.dibujarFormato(mascara)
+		if p := q.penalizacion(); menor < 0 || p < menor {
+			mejor, menor = mascara, p
+		}
+		q.aplicarMascara(mascara) // La máscara es un XOR: aplicarla de nuevo la quita.
+	}
+	q.aplicarMascara(mejor)
+	q.dibujarFormato(mejor)
+	return q, nil
+}
+
+// SVG genera el código QR del texto como imagen SVG, con el margen de 4 módulos
+// que exige la norma y cada módulo de tamModulo píxeles.
+func SVG(texto string, tamModulo int) (string, error) {
+	q, err := Codificar(texto)
+	if err != nil {
+		return "", err
+	}
+
+	lado := q.Tamano + 8
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`, lado, lado, lado*tamModulo, lado*tamModulo)
+	b.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, fila := range q.Modulos {
+		for x, oscuro := range fila {
+			if oscuro {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+4, y+4)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return b.String(), nil
+}
+
+// bitsLongitud devuelve el tamaño del indicador de longitud en modo byte.
+func bitsLongitud(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// segmento arma los codewords de datos: modo byte, longitud, datos, terminador y relleno.
+func segmento(datos []byte, version int) []byte {
+	capacidad := tablaM[version].capacidad() * 8
+
+	var bits []bool
+	agregar := func(valor, cantidad int) {
+		for i := cantidad - 1; i >= 0; i-- {
+			bits = append(bits, (valor>>i)&1 == 1)
+		}
+	}
+	agregar(0b0100, 4)
+	agregar(len(datos), bitsLongitud(version))
+	for _, b := range datos {
+		agregar(int(b), 8)
+	}
+	agregar(0, min(4, capacidad-len(bits)))
+	agregar(0, (8-len(bits)%8)%8)
+	for relleno := 0xEC; len(bits) < capacidad; relleno ^= 0xEC ^ 0x11 {
+		agregar(relleno, 8)
+	}
+
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return codewords
+}
+
+// intercalar divide los datos en bloques, calcula la corrección de cada uno y
+// los intercala codeword por codeword.
+func intercalar(b bloques, datos []byte) []byte {
+	divisor := divisorRS(b.ec)
+
+	var partes, correcciones [][]byte
+	for i := 0; i < b.grupo1+b.grupo2; i++ {
+		largo := b.datos1
+		if i >= b.grupo1 {
+			largo = b.datos2
+		}
+		parte := datos[:largo]
+		datos = datos[largo:]
+		partes = append(partes, parte)
+		correcciones = append(correcciones, restoRS(parte, divisor))
+	}
+
+	var resultado []byte
+	for i := 0; i < max(b.datos1, b.datos2); i++ {
+		for _, parte := range partes {
+			if i < len(parte) {
+				resultado = append(resultado, parte[i])
+			}
+		}
+	}
+	for i := 0; i < b.ec; i++ {
+		for _, correccion := range correcciones {
+			resultado = append(resultado, correccion[i])
+		}
+	}
+	return resultado
+}
+
+// multiplicarGF multiplica en GF(256) con el polinomio x^8 + x^4 + x^3 + x^2 + 1.
+func multiplicarGF(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+// divisorRS calcula los coeficientes del polinomio generador de grado dado
+// (sin el coeficiente principal, que siempre es 1).
+func divisorRS(grado int) []byte {
+	resultado := make([]byte, grado)
+	resultado[grado-1] = 1
+	raiz := byte(1)
+	for i := 0; i < grado; i++ {
+		for j := range resultado {
+			resultado[j] = multiplicarGF(resultado[j], raiz)
+			if j+1 < len(resultado) {
+				resultado[j] ^= resultado[j+1]
+			}
+		}
+		raiz = multiplicarGF(raiz, 0x02)
+	}
+	return resultado
+}
+
+// restoRS devuelve los codewords de corrección de los datos.
+func restoRS(datos, divisor []byte) []byte {
+	resultado := make([]byte, len(divisor))
+	for _, b := range datos {
+		factor := b ^ resultado[0]
+		copy(resultado, resultado[1:])
+		resultado[len(resultado)-1] = 0
+		for i := range resultado {
+			resultado[i] ^= multiplicarGF(divisor[i], factor)
+		}
+	}
+	return resultado
+}
+
+// nuevoCodigo crea la matriz vacía de la versión.
+func nuevoCodigo(version int) *Codigo {
+	tamano := version*4 + 17
+	q := &Codigo{Tamano: tamano}
+	q.Modulos = make([][]bool, tamano)
+	q.funcion = make([][]bool, tamano)
+	for i := range q.Modulos {
+		q.Modulos[i] = make([]bool, tamano)
+		q.funcion[i] = make([]bool, tamano)
+	}
+	return q
+}
+
+// fijar pone un módulo de patrón fijo (x es la columna, y la fila).
+func (q *Codigo) fijar(x, y int, oscuro bool) {
+	q.Modulos[y][x] = oscuro
+	q.funcion[y][x] = true
+}
+
+// dibujarPatrones dibuja temporización, buscadores, alineación, versión y
+// reserva el lugar de la información de formato.
+func (q *Codigo) dibujarPatrones(version int) {
+	for i := 0; i < q.Tamano; i++ {
+		q.fijar(6, i, i%2 == 0)
+		q.fijar(i, 6, i%2 == 0)
+	}
+
+	// Buscadores con su separador blanco, en tres esquinas.
+	for _, centro := range [][2]int{{3, 3}, {q.Tamano - 4, 3}, {3, q.Tamano - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := centro[0]+dx, centro[1]+dy
+				if x < 0 || x >= q.Tamano || y < 0 || y >= q.Tamano {
+					continue
+				}
+				distancia := max(abs(dx), abs(dy))
+				q.fijar(x, y, distancia != 2 && distancia != 4)
+			}
+		}
+	}
+
+	// Alineación: todas las combinaciones salvo las que caen sobre un buscador.
+	posiciones := alineacion[version]
+	for i, y := range posiciones {
+		for j, x := range posiciones {
+			ultimo := len(posiciones) - 1
+			if (i == 0 && j == 0) || (i == 0 && j == ultimo) || (i == ultimo && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.fijar(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.dibujarFormato(0)
+
+	// Información de versión (BCH 18,6) desde la versión 7.
+	if version >= 7 {
+		resto := version
+		for i := 0; i < 12; i++ {
+			resto = (resto << 1) ^ ((resto >> 11) * 0x1F25)
+		}
+		bits := version<<12 | resto
+		for i := 0; i < 18; i++ {
+			oscuro := (bits>>i)&1 == 1
+			a, b := q.Tamano-11+i%3, i/3
+			q.fijar(a, b, oscuro)
+			q.fijar(b, a, oscuro)
+		}
+	}
+}
+
+// dibujarFormato escribe las dos copias de la información de formato
+// (nivel M y máscara, BCH 15,5) y el módulo oscuro fijo.
+func (q *Codigo) dibujarFormato(mascara int) {
+	datos := 0b00<<3 | mascara // Nivel M = 00.
+	resto := datos
+	for i := 0; i < 10; i++ {
+		resto = (resto << 1) ^ ((resto >> 9) * 0x537)
+	}
+	bits := (datos<<10 | resto) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.fijar(8, i, bit(i))
+	}
+	q.fijar(8, 7, bit(6))
+	q.fijar(8, 8, bit(7))
+	q.fijar(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.fijar(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.fijar(q.Tamano-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.fijar(8, q.Tamano-15+i, bit(i))
+	}
+	q.fijar(8, q.Tamano-8, true)
+}
+
+// dibujarDatos recorre la matriz en zigzag de a dos columnas, de abajo hacia
+// arriba y de derecha a izquierda, saltando la columna de temporización.
+func (q *Codigo) dibujarDatos(codewords []byte) {
+	i := 0
+	for derecha := q.Tamano - 1; derecha >= 1; derecha -= 2 {
+		if derecha == 6 {
+			derecha = 5
+		}
+		for vertical := 0; vertical < q.Tamano; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := derecha - j
+				y := vertical
+				if (derecha+1)&2 == 0 {
+					y = q.Tamano - 1 - vertical
+				}
+				if !q.funcion[y][x] && i < len(codewords)*8 {
+					q.Modulos[y][x] = (codewords[i/8]>>(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// aplicarMascara invierte los módulos de datos donde la máscara lo indica.
+func (q *Codigo) aplicarMascara(mascara int) {
+	for y := 0; y < q.Tamano; y++ {
+		for x := 0; x < q.Tamano; x++ {
+			if q.funcion[y][x] {
+				continue
+			}
+			var invertir bool
+			switch mascara {
+			case 0:
+				invertir = (x+y)%2 == 0
+			case 1:
+				invertir = y%2 == 0
+			case 2:
+				invertir = x%3 == 0
+			case 3:
+				invertir = (x+y)%3 == 0
+			case 4:
+				invertir = (x/3+y/2)%2 == 0
+			case 5:
+				invertir = x*y%2+x*y%3 == 0
+			case 6:
+				invertir = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invertir = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invertir {
+				q.Modulos[y][x] = !q.Modulos[y][x]
+			}
+		}
+	}
+}
+
+// penalizacion aplica las cuatro reglas de la norma para comparar máscaras.
+func (q *Codigo) penalizacion() int {
+	n := q.Tamano
+	total := 0
+	en := func(x, y int, vertical bool) bool {
+		if vertical {
+			x, y = y, x
+		}
+		if x < 0 || x >= n || y < 0 || y >= n {
+			return false
+		}
+		return q.Modulos[y][x]
+	}
+	patron := []bool{true, false, true, true, true, false, true}
+
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < n; y++ {
+			// Regla 1: cinco o más módulos seguidos del mismo color.
+			racha := 1
+			for x := 1; x <= n; x++ {
+				if x < n && en(x, y, vertical) == en(x-1, y, vertical) {
+					racha++
+					continue
+				}
+				if racha >= 5 {
+					total += 3 + racha - 5
+				}
+				racha = 1
+			}
+
+			// Regla 3: patrón 1:1:3:1:1 con cuatro módulos claros de un lado.
+			for x := 0; x+7 <= n; x++ {
+				coincide := true
+				for k, oscuro := range patron {
+					if en(x+k, y, vertical) != oscuro {
+						coincide = false
+						break
+					}
+				}
+				if !coincide {
+					continue
+				}
+				antes, despues := true, true
+				for k := 1; k <= 4; k++ {
+					antes = antes && !en(x-k, y, vertical)
+					despues = despues && !en(x+6+k, y, vertical)
+				}
+				if antes || despues {
+					total += 40
+				}
+			}
+		}
+	}
+
+	// Regla 2: bloques de 2x2 del mismo color.
+	oscuros := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if q.Modulos[y][x] {
+				oscuros++
+			}
+			if x+1 < n && y+1 < n {
+				c := q.Modulos[y][x]
+				if c == q.Modulos[y][x+1] && c == q.Modulos[y+1][x] && c == q.Modulos[y+1][x+1] {
+					total += 3
+				}
+			}
+		}
+	}
+
+	// Regla 4: proporción de módulos oscuros lejos del 50 %.
+	porcentaje := oscuros * 100 / (n * n)
+	total += abs(porcentaje-50) / 5 * 10
+	return total
+}
+
+// abs devuelve el valor absoluto de un entero.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
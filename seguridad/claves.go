@@ -0,0 +1,92 @@
+package seguridad // Paquete seguridad: utilidades criptográficas del sistema.
+
+import (
+	"crypto/subtle" // Paquete para comparar textos en tiempo constante.
+	"errors"        // Paquete para errores de validación.
+	"strings"       // Paquete para revisar el prefijo del hash.
+	"unicode/utf8"  // Paquete para contar caracteres de la clave.
+
+	"golang.org/x/crypto/bcrypt" // Algoritmo bcrypt para hashear contraseñas.
+)
+
+// CostoBcrypt es el factor de trabajo usado al generar hashes nuevos.
+const CostoBcrypt = 12
+
+// LongitudMinimaClave es la cantidad mínima de caracteres de una contraseña nueva.
+const LongitudMinimaClave = 8
+
+// LongitudMaximaClave es la cantidad máxima de bytes que bcrypt acepta (una
+// letra acentuada ocupa dos).
+const LongitudMaximaClave = 72
+
+// ErrClaveVacia se usa cuando se intenta hashear una contraseña vacía.
+var ErrClaveVacia = errors.New("la clave no puede estar vacía")
+
+// ErrClaveCorta se usa cuando una contraseña nueva no alcanza la longitud mínima.
+var ErrClaveCorta = errors.New("la clave debe tener al menos 8 caracteres")
+
+// ErrClaveLarga se usa cuando una contraseña supera lo que bcrypt puede hashear.
+var ErrClaveLarga = errors.New("la clave no puede superar los 72 bytes (los acentos cuentan doble)")
+
+// hashFicticio se compara cuando el usuario no existe, para que la respuesta
+// tarde lo mismo y no revele qué correos están registrados.
+var hashFicticio, _ = bcrypt.GenerateFromPassword([]byte("clave-ficticia"), CostoBcrypt)
+
+// HashearClave genera el hash bcrypt de una contraseña.
+func HashearClave(clave string) (string, error) {
+	if clave == "" {
+		return "", ErrClaveVacia
+	}
+	if len(clave) > LongitudMaximaClave {
+		return "", ErrClaveLarga
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(clave), CostoBcrypt)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// ValidarClaveNueva revisa que una contraseña elegida tenga entre
+// LongitudMinimaClave caracteres y LongitudMaximaClave bytes.
+// Las claves existentes no se revisan al iniciar sesión.
+func ValidarClaveNueva(clave string) error {
+	if utf8.RuneCountInString(clave) < LongitudMinimaClave {
+		return ErrClaveCorta
+	}
+	if len(clave) > LongitudMaximaClave {
+		return ErrClaveLarga
+	}
+	return nil
+}
+
+// EsHash indica si el valor guardado ya es un hash bcrypt.
+func EsHash(guardada string) bool {
+	return strings.HasPrefix(guardada, "$2a$") ||
+		strings.HasPrefix(guardada, "$2b$") ||
+		strings.HasPrefix(guardada, "$2y$")
+}
+
+// VerificarClave compara la contraseña ingresada con el valor guardado.
+// Devuelve ok=true si coinciden y rehashear=true si el valor guardado es
+// texto plano heredado o un hash con costo menor al actual.
+func VerificarClave(guardada, ingresada string) (ok bool, rehashear bool) {
+	if !EsHash(guardada) {
+		// Clave heredada en texto plano: se compara en tiempo constante.
+		ok = subtle.ConstantTimeCompare([]byte(guardada), []byte(ingresada)) == 1
+		return ok, ok
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(guardada), []byte(ingresada)) != nil {
+		return false, false
+	}
+
+	costo, err := bcrypt.Cost([]byte(guardada))
+	return true, err == nil && costo < CostoBcrypt
+}
+
+// SimularVerificacion consume el mismo tiempo que una verificación real.
+// Se usa cuando el correo no existe.
+func SimularVerificacion(ingresada string) {
+	_ = bcrypt.CompareHashAndPassword(hashFicticio, []byte(ingresada))
+}
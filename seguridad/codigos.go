@@ -0,0 +1,23 @@
+package seguridad // Paquete seguridad: utilidades criptográficas del sistema.
+
+import (
+	"crypto/rand"   // Paquete para generar códigos aleatorios seguros.
+	"crypto/sha256" // Paquete para el hash de los códigos.
+	"encoding/hex"  // Paquete para representar bytes como texto.
+)
+
+// GenerarCodigo crea un código aleatorio de 32 bytes en hexadecimal, para enlaces
+// de un solo uso enviados por correo (verificación, recuperación de clave).
+func GenerarCodigo() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashCodigo calcula el SHA-256 del código; en la base nunca se guarda el código en claro.
+func HashCodigo(codigo string) string {
+	suma := sha256.Sum256([]byte(codigo))
+	return hex.EncodeToString(suma[:])
+}
@@ -0,0 +1,99 @@
+package seguridad // Paquete seguridad: utilidades criptográficas del sistema.
+
+import (
+	"crypto/hmac"     // Paquete para el HMAC del código.
+	"crypto/rand"     // Paquete para generar secretos aleatorios.
+	"crypto/sha1"     // Algoritmo por defecto de RFC 6238 (lo usan todas las aplicaciones).
+	"crypto/subtle"   // Paquete para comparar códigos en tiempo constante.
+	"encoding/base32" // Formato del secreto que se carga en la aplicación.
+	"encoding/binary" // Paquete para convertir el paso en 8 bytes.
+	"fmt"             // Paquete para completar el código con ceros.
+	"net/url"         // Paquete para armar la URI otpauth://.
+	"strings"         // Paquete para normalizar el secreto.
+	"time"            // Paquete para calcular el paso actual.
+)
+
+// Parámetros TOTP (RFC 6238): pasos de 30 segundos y códigos de 6 dígitos.
+const (
+	PeriodoTOTP = 30 // Segundos de cada paso.
+	DigitosTOTP = 6  // Cantidad de dígitos del código.
+	VentanaTOTP = 1  // Pasos de tolerancia antes y después, por relojes desfasados.
+)
+
+// codificacionTOTP es base32 sin relleno, el formato que esperan las aplicaciones autenticadoras.
+var codificacionTOTP = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerarSecretoTOTP crea un secreto aleatorio de 160 bits en base32.
+func GenerarSecretoTOTP() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return codificacionTOTP.EncodeToString(b), nil
+}
+
+// PasoTOTP devuelve el número de paso que corresponde al momento t.
+func PasoTOTP(t time.Time) int64 {
+	return t.Unix() / PeriodoTOTP
+}
+
+// CodigoTOTP calcula el código del secreto para un paso (HOTP de RFC 4226 con el paso como contador).
+func CodigoTOTP(secreto string, paso int64) (string, error) {
+	clave, err := codificacionTOTP.DecodeString(strings.ToUpper(strings.TrimRight(secreto, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var contador [8]byte
+	binary.BigEndian.PutUint64(contador[:], uint64(paso))
+	mac := hmac.New(sha1.New, clave)
+	mac.Write(contador[:])
+	suma := mac.Sum(nil)
+
+	// Truncamiento dinámico: 31 bits a partir del desplazamiento indicado por el último nibble.
+	desplazamiento := suma[len(suma)-1] & 0x0f
+	valor := binary.BigEndian.Uint32(suma[desplazamiento:desplazamiento+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < DigitosTOTP; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", DigitosTOTP, valor%modulo), nil
+}
+
+// VerificarTOTP compara el código con los pasos cercanos a t. Solo acepta pasos
+// posteriores a ultimoPaso, para que un código ya usado no sirva dos veces.
+// Devuelve el paso aceptado.
+func VerificarTOTP(secreto, codigo string, t time.Time, ultimoPaso int64) (int64, bool) {
+	if len(codigo) != DigitosTOTP {
+		return 0, false
+	}
+	actual := PasoTOTP(t)
+	for paso := actual - VentanaTOTP; paso <= actual+VentanaTOTP; paso++ {
+		if paso <= ultimoPaso {
+			continue
+		}
+		esperado, err := CodigoTOTP(secreto, paso)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(esperado), []byte(codigo)) == 1 {
+			return paso, true
+		}
+	}
+	return 0, false
+}
+
+// URIProvisionTOTP arma la URI otpauth:// que se muestra como código QR para
+// cargar la cuenta en una aplicación autenticadora.
+func URIProvisionTOTP(emisor, cuenta, secreto string) string {
+	parametros := url.Values{}
+	parametros.Set("secret", secreto)
+	parametros.Set("issuer", emisor)
+	parametros.Set("algorithm", "SHA1")
+	parametros.Set("digits", fmt.Sprint(DigitosTOTP))
+	parametros.Set("period", fmt.Sprint(PeriodoTOTP))
+
+	etiqueta := url.PathEscape(emisor + ":" + cuenta)
+	return "otpauth://totp/" + etiqueta + "?" + strings.ReplaceAll(parametros.Encode(), "+", "%20")
+}
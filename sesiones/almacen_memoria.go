@@ -0,0 +1,87 @@
+package sesiones // Paquete sesiones.
+
+import (
+	"sistema/models" // Estructura Sesion.
+	"sync"           // Paquete para proteger el mapa ante accesos concurrentes.
+	"time"           // Paquete para fechas.
+)
+
+// AlmacenMemoria guarda las sesiones en un mapa protegido por mutex.
+// Las sesiones se pierden al reiniciar el servidor.
+type AlmacenMemoria struct {
+	mu       sync.Mutex               // Protege el mapa.
+	sesiones map[string]models.Sesion // Clave = ID de sesión.
+}
+
+// NuevoAlmacenMemoria crea un almacén en memoria vacío.
+func NuevoAlmacenMemoria() *AlmacenMemoria {
+	return &AlmacenMemoria{
+		sesiones: make(map[string]models.Sesion),
+	}
+}
+
+// Guardar inserta o reemplaza una sesión.
+func (a *AlmacenMemoria) Guardar(s models.Sesion) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sesiones[s.ID] = s
+	return nil
+}
+
+// Obtener busca una sesión por ID.
+func (a *AlmacenMemoria) Obtener(id string) (models.Sesion, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sesiones[id]
+	if !ok {
+		return models.Sesion{}, ErrSesionNoExiste
+	}
+	return s, nil
+}
+
+// Tocar actualiza el último acceso de la sesión.
+func (a *AlmacenMemoria) Tocar(id string, momento time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sesiones[id]
+	if !ok {
+		return ErrSesionNoExiste
+	}
+	s.UltimoAcceso = momento
+	a.sesiones[id] = s
+	return nil
+}
+
+// Eliminar borra una sesión (no falla si no existe).
+func (a *AlmacenMemoria) Eliminar(id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sesiones, id)
+	return nil
+}
+
+// EliminarPorUsuario borra todas las sesiones de un usuario.
+func (a *AlmacenMemoria) EliminarPorUsuario(idUsuario int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, s := range a.sesiones {
+		if s.IDUsuario == idUsuario {
+			delete(a.sesiones, id)
+		}
+	}
+	return nil
+}
+
+// LimpiarVencidas borra las sesiones cuyo vencimiento absoluto ya pasó o sin
+// uso desde antes de inactivasDesde.
+func (a *AlmacenMemoria) LimpiarVencidas(inactivasDesde time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ahora := time.Now()
+	for id, s := range a.sesiones {
+		if ahora.After(s.ExpiraEn) || s.UltimoAcceso.Before(inactivasDesde) {
+			delete(a.sesiones, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package sesiones // Paquete sesiones.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"sistema/models" // Estructura Sesion.
+	"time"           // Paquete para fechas.
+)
+
+// AlmacenMySQL guarda las sesiones en la tabla "sesiones".
+// Por seguridad solo se guarda el SHA-256 del ID, nunca el ID en claro.
+type AlmacenMySQL struct {
+	DB *sql.DB // Conexión a la base de datos.
+}
+
+// NuevoAlmacenMySQL crea un almacén de sesiones respaldado por MySQL.
+func NuevoAlmacenMySQL(db *sql.DB) *AlmacenMySQL {
+	return &AlmacenMySQL{DB: db}
+}
+
+// Guardar inserta o reemplaza una sesión.
+func (a *AlmacenMySQL) Guardar(s models.Sesion) error {
+	query := `
+		REPLACE INTO sesiones (id_hash, id_usuario, nombre, rol, creada_en, ultimo_acceso, expira_en)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := a.DB.Exec(query, hashID(s.ID), s.IDUsuario, s.Nombre, s.Rol, s.CreadaEn, s.UltimoAcceso, s.ExpiraEn)
+	return err
+}
+
+// Obtener busca una sesión por ID.
+func (a *AlmacenMySQL) Obtener(id string) (models.Sesion, error) {
+	s := models.Sesion{ID: id}
+	query := `
+		SELECT id_usuario, nombre, rol, creada_en, ultimo_acceso, expira_en
+		FROM sesiones
+		WHERE id_hash = ?
+	`
+	err := a.DB.QueryRow(query, hashID(id)).Scan(
+		&s.IDUsuario,
+		&s.Nombre,
+		&s.Rol,
+		&s.CreadaEn,
+		&s.UltimoAcceso,
+		&s.ExpiraEn,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Sesion{}, ErrSesionNoExiste
+		}
+		return models.Sesion{}, err
+	}
+	return s, nil
+}
+
+// Tocar actualiza el último acceso de la sesión.
+func (a *AlmacenMySQL) Tocar(id string, momento time.Time) error {
+	_, err := a.DB.Exec(`UPDATE sesiones SET ultimo_acceso = ? WHERE id_hash = ?`, momento, hashID(id))
+	return err
+}
+
+// Eliminar borra una sesión.
+func (a *AlmacenMySQL) Eliminar(id string) error {
+	_, err := a.DB.Exec(`DELETE FROM sesiones WHERE id_hash = ?`, hashID(id))
+	return err
+}
+
+// EliminarPorUsuario borra todas las sesiones de un usuario.
+func (a *AlmacenMySQL) EliminarPorUsuario(idUsuario int) error {
+	_, err := a.DB.Exec(`DELETE FROM sesiones WHERE id_usuario = ?`, idUsuario)
+	return err
+}
+
+// LimpiarVencidas borra las sesiones cuyo vencimiento absoluto ya pasó o sin
+// uso desde antes de inactivasDesde.
+func (a *AlmacenMySQL) LimpiarVencidas(inactivasDesde time.Time) error {
+	_, err := a.DB.Exec(`DELETE FROM sesiones WHERE expira_en < ? OR ultimo_acceso < ?`, time.Now(), inactivasDesde)
+	return err
+}
@@ -0,0 +1,60 @@
+package sesiones // Paquete sesiones.
+
+import (
+	"crypto/hmac" // Paquete para comparar tokens en tiempo constante.
+	"net/http"    // Paquete para leer y escribir cookies.
+)
+
+// Nombres usados por la protección CSRF.
+const (
+	CampoCSRF        = "csrf_token"   // Campo oculto de los formularios.
+	EncabezadoCSRF   = "X-CSRF-Token" // Encabezado para peticiones hechas con fetch.
+	NombreCookieCSRF = "csrf_id"      // Identificador anónimo para formularios previos al login.
+)
+
+// TokenCSRF devuelve el token CSRF de la sesión de la petición. El token es la
+// firma HMAC del ID de sesión, por lo que no se guarda y cambia con cada sesión.
+// Si aún no hay sesión (login, registro, recuperación), se firma un identificador
+// anónimo que se guarda en una cookie propia y se crea aquí si falta.
+func (g *Gestor) TokenCSRF(w http.ResponseWriter, r *http.Request) (string, error) {
+	if base, ok := g.baseCSRF(r); ok {
+		return g.firmar("csrf|" + base), nil
+	}
+
+	id, err := generarID()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     NombreCookieCSRF,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   g.CookieSegura,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return g.firmar("csrf|anonimo|" + id), nil
+}
+
+// VerificarCSRF indica si el token recibido corresponde a la sesión (o al
+// identificador anónimo) de la petición. La comparación es en tiempo constante.
+func (g *Gestor) VerificarCSRF(r *http.Request, token string) bool {
+	base, ok := g.baseCSRF(r)
+	if !ok || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(g.firmar("csrf|"+base)))
+}
+
+// baseCSRF devuelve el valor que se firma para el token: el ID de la cookie de
+// sesión si su firma es válida o, si no, el identificador anónimo.
+func (g *Gestor) baseCSRF(r *http.Request) (string, bool) {
+	if id, ok := g.idDesdeCookie(r); ok {
+		return id, true
+	}
+	cookie, err := r.Cookie(NombreCookieCSRF)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return "anonimo|" + cookie.Value, true
+}
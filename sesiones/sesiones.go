@@ -0,0 +1,173 @@
+package sesiones // Paquete sesiones: sesiones del lado del servidor con ID opaco.
+
+import (
+	"crypto/hmac"    // Paquete para firmar el ID de sesión.
+	"crypto/rand"    // Paquete para generar IDs aleatorios seguros.
+	"crypto/sha256"  // Paquete para el hash usado en la firma.
+	"encoding/hex"   // Paquete para representar bytes como texto.
+	"errors"         // Paquete para errores del almacén.
+	"net/http"       // Paquete para leer y escribir cookies.
+	"sistema/models" // Estructuras Sesion y Usuario.
+	"strings"        // Paquete para separar ID y firma.
+	"time"           // Paquete para expiración e inactividad.
+)
+
+// NombreCookie es el nombre de la cookie que guarda el ID firmado de la sesión.
+const NombreCookie = "sesion_id"
+
+// ErrSesionNoExiste se usa cuando el ID no corresponde a ninguna sesión guardada.
+var ErrSesionNoExiste = errors.New("sesión no existe")
+
+// Almacen define dónde se guardan las sesiones (memoria, MySQL, etc.).
+type Almacen interface {
+	Guardar(s models.Sesion) error
+	Obtener(id string) (models.Sesion, error)
+	Tocar(id string, momento time.Time) error
+	Eliminar(id string) error
+	EliminarPorUsuario(idUsuario int) error
+	// LimpiarVencidas borra las sesiones vencidas (ExpiraEn ya pasó) o sin uso
+	// desde antes de inactivasDesde.
+	LimpiarVencidas(inactivasDesde time.Time) error
+}
+
+// Gestor crea, valida y destruye sesiones usando un Almacen.
+type Gestor struct {
+	Almacen      Almacen       // Dónde se guardan las sesiones.
+	Secreto      []byte        // Clave para firmar el ID en la cookie.
+	Duracion     time.Duration // Vida máxima de una sesión.
+	Inactividad  time.Duration // Tiempo sin uso tras el cual la sesión vence.
+	CookieSegura bool          // Marca la cookie como Secure (solo HTTPS).
+}
+
+// NuevoGestor crea un gestor con duración de 8 horas e inactividad de 30 minutos.
+func NuevoGestor(almacen Almacen, secreto []byte) *Gestor {
+	return &Gestor{
+		Almacen:     almacen,
+		Secreto:     secreto,
+		Duracion:    8 * time.Hour,
+		Inactividad: 30 * time.Minute,
+	}
+}
+
+// Crear inicia una sesión para el usuario y escribe la cookie en la respuesta.
+func (g *Gestor) Crear(w http.ResponseWriter, usuario models.Usuario) (models.Sesion, error) {
+	id, err := generarID()
+	if err != nil {
+		return models.Sesion{}, err
+	}
+
+	ahora := time.Now()
+	sesion := models.Sesion{
+		ID:           id,
+		IDUsuario:    usuario.IDUsuario,
+		Nombre:       usuario.Nombre,
+		Rol:          usuario.NombreRol,
+		CreadaEn:     ahora,
+		UltimoAcceso: ahora,
+		ExpiraEn:     ahora.Add(g.Duracion),
+	}
+	if err := g.Almacen.Guardar(sesion); err != nil {
+		return models.Sesion{}, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     NombreCookie,
+		Value:    id + "." + g.firmar(id), // ID + firma HMAC.
+		Path:     "/",
+		Expires:  sesion.ExpiraEn,
+		HttpOnly: true,
+		Secure:   g.CookieSegura,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return sesion, nil
+}
+
+// Resolver devuelve la sesión vigente asociada a la cookie de la petición.
+// Si la sesión venció (por tiempo total o inactividad) se elimina del almacén.
+func (g *Gestor) Resolver(r *http.Request) (models.Sesion, bool) {
+	id, ok := g.idDesdeCookie(r)
+	if !ok {
+		return models.Sesion{}, false
+	}
+
+	sesion, err := g.Almacen.Obtener(id)
+	if err != nil {
+		return models.Sesion{}, false
+	}
+
+	ahora := time.Now()
+	if ahora.After(sesion.ExpiraEn) || ahora.Sub(sesion.UltimoAcceso) > g.Inactividad {
+		_ = g.Almacen.Eliminar(id)
+		return models.Sesion{}, false
+	}
+
+	// Renueva el último acceso para extender el tiempo de inactividad.
+	if err := g.Almacen.Tocar(id, ahora); err == nil {
+		sesion.UltimoAcceso = ahora
+	}
+	return sesion, true
+}
+
+// LimpiarVencidas borra del almacén las sesiones vencidas por tiempo total o
+// por inactividad que nadie volvió a usar (Resolver solo borra las que recibe).
+func (g *Gestor) LimpiarVencidas() error {
+	return g.Almacen.LimpiarVencidas(time.Now().Add(-g.Inactividad))
+}
+
+// Destruir elimina la sesión actual del almacén y borra la cookie.
+func (g *Gestor) Destruir(w http.ResponseWriter, r *http.Request) {
+	if id, ok := g.idDesdeCookie(r); ok {
+		_ = g.Almacen.Eliminar(id)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     NombreCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1, // MaxAge negativo elimina la cookie.
+		HttpOnly: true,
+		Secure:   g.CookieSegura,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// idDesdeCookie lee la cookie y valida la firma del ID.
+func (g *Gestor) idDesdeCookie(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(NombreCookie)
+	if err != nil {
+		return "", false
+	}
+
+	id, firma, ok := strings.Cut(cookie.Value, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+
+	// Comparación en tiempo constante para no filtrar información de la firma.
+	if !hmac.Equal([]byte(firma), []byte(g.firmar(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// firmar calcula el HMAC-SHA256 del ID con el secreto del gestor.
+func (g *Gestor) firmar(id string) string {
+	mac := hmac.New(sha256.New, g.Secreto)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generarID crea un identificador aleatorio de 32 bytes en hexadecimal.
+func generarID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashID calcula el SHA-256 del ID; los almacenes persistentes guardan solo este hash.
+func hashID(id string) string {
+	suma := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(suma[:])
+}
@@ -0,0 +1,130 @@
+package tokens // Paquete tokens.
+
+import (
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"sistema/models" // Estructuras Sesion y ClaveAPI.
+	"time"           // Paquete para fechas.
+)
+
+// CrearClave genera una clave de API para un usuario.
+// Devuelve los datos guardados y el valor secreto, que no vuelve a mostrarse.
+func (s *Servicio) CrearClave(idUsuario, creadaPor int, nombre string) (models.ClaveAPI, string, error) {
+	secreto, err := generarToken(PrefijoClave)
+	if err != nil {
+		return models.ClaveAPI{}, "", err
+	}
+
+	clave := models.ClaveAPI{
+		IDUsuario: idUsuario,
+		Nombre:    nombre,
+		Prefijo:   secreto[:len(PrefijoClave)+8],
+		CreadaPor: creadaPor,
+		CreadaEn:  time.Now(),
+	}
+
+	query := `
+		INSERT INTO claves_api (hash, prefijo, nombre, id_usuario, creada_por, creada_en)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	res, err := s.DB.Exec(query, hashToken(secreto), clave.Prefijo, clave.Nombre, clave.IDUsuario, clave.CreadaPor, clave.CreadaEn)
+	if err != nil {
+		return models.ClaveAPI{}, "", err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.ClaveAPI{}, "", err
+	}
+	clave.ID = int(id)
+	return clave, secreto, nil
+}
+
+// ListarClaves devuelve las claves de API; si idUsuario es 0 lista todas.
+func (s *Servicio) ListarClaves(idUsuario int) ([]models.ClaveAPI, error) {
+	query := `
+		SELECT id, id_usuario, nombre, prefijo, creada_por, creada_en, ultimo_uso, revocada_en
+		FROM claves_api
+		WHERE ? = 0 OR id_usuario = ?
+		ORDER BY id DESC
+	`
+	rows, err := s.DB.Query(query, idUsuario, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claves := []models.ClaveAPI{}
+	for rows.Next() {
+		var (
+			clave      models.ClaveAPI
+			ultimoUso  sql.NullTime
+			revocadaEn sql.NullTime
+		)
+		err := rows.Scan(
+			&clave.ID,
+			&clave.IDUsuario,
+			&clave.Nombre,
+			&clave.Prefijo,
+			&clave.CreadaPor,
+			&clave.CreadaEn,
+			&ultimoUso,
+			&revocadaEn,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if ultimoUso.Valid {
+			clave.UltimoUso = &ultimoUso.Time
+		}
+		if revocadaEn.Valid {
+			clave.RevocadaEn = &revocadaEn.Time
+		}
+		claves = append(claves, clave)
+	}
+	return claves, rows.Err()
+}
+
+// RevocarClave marca una clave como revocada; deja de autenticar de inmediato.
+func (s *Servicio) RevocarClave(id int) error {
+	res, err := s.DB.Exec(`UPDATE claves_api SET revocada_en = ? WHERE id = ? AND revocada_en IS NULL`, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrClaveNoExiste
+	}
+	return nil
+}
+
+// resolverClave valida una clave de API y registra su último uso.
+func (s *Servicio) resolverClave(secreto string) (models.Sesion, error) {
+	var (
+		sesion  models.Sesion
+		idClave int
+	)
+	query := `
+		SELECT c.id, u.id_usuario, u.nombre, r.nombre_rol, c.creada_en
+		FROM claves_api c
+		INNER JOIN usuarios u ON u.id_usuario = c.id_usuario
+		INNER JOIN roles r ON r.id_rol = u.id_rol
+		WHERE c.hash = ? AND c.revocada_en IS NULL AND u.estado = 'ACTIVO'
+	`
+	err := s.DB.QueryRow(query, hashToken(secreto)).Scan(
+		&idClave,
+		&sesion.IDUsuario,
+		&sesion.Nombre,
+		&sesion.Rol,
+		&sesion.CreadaEn,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Sesion{}, ErrTokenInvalido
+		}
+		return models.Sesion{}, err
+	}
+
+	ahora := time.Now()
+	_, _ = s.DB.Exec(`UPDATE claves_api SET ultimo_uso = ? WHERE id = ?`, ahora, idClave)
+	sesion.UltimoAcceso = ahora
+	return sesion, nil
+}
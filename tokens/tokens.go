@@ -0,0 +1,203 @@
+package tokens // Paquete tokens: autenticación por tokens Bearer para clientes de la API.
+
+import (
+	"crypto/rand"    // Paquete para generar tokens aleatorios.
+	"crypto/sha256"  // Paquete para guardar solo el hash de cada token.
+	"database/sql"   // Paquete para trabajar con MySQL.
+	"encoding/hex"   // Paquete para representar bytes como texto.
+	"errors"         // Paquete para errores del servicio.
+	"sistema/models" // Estructuras Sesion y ClaveAPI.
+	"strings"        // Paquete para revisar prefijos.
+	"time"           // Paquete para expiración.
+)
+
+// Prefijos que identifican el tipo de token a simple vista.
+const (
+	PrefijoAcceso   = "at_" // Token de acceso (corta duración).
+	PrefijoRefresco = "rt_" // Token de refresco (larga duración, un solo uso).
+	PrefijoClave    = "ak_" // Clave de API (sin vencimiento, revocable).
+)
+
+// Tipos de token guardados en la tabla tokens_api.
+const (
+	tipoAcceso   = "ACCESO"
+	tipoRefresco = "REFRESCO"
+)
+
+// ErrTokenInvalido se usa cuando el token no existe, venció, fue revocado
+// o pertenece a un usuario que ya no está ACTIVO.
+var ErrTokenInvalido = errors.New("token inválido o vencido")
+
+// ErrClaveNoExiste se usa al revocar una clave de API inexistente o ya revocada.
+var ErrClaveNoExiste = errors.New("clave de API no existe")
+
+// ParTokens es la respuesta del intercambio de credenciales o del refresco.
+type ParTokens struct {
+	AccessToken  string `json:"access_token"`  // Token para el encabezado Authorization.
+	RefreshToken string `json:"refresh_token"` // Token para obtener un nuevo par.
+	TipoToken    string `json:"token_type"`    // Siempre "Bearer".
+	ExpiraEn     int    `json:"expires_in"`    // Segundos de vida del token de acceso.
+}
+
+// Servicio emite, valida y revoca tokens guardados en MySQL.
+type Servicio struct {
+	DB               *sql.DB       // Conexión a la base de datos.
+	DuracionAcceso   time.Duration // Vida del token de acceso.
+	DuracionRefresco time.Duration // Vida del token de refresco.
+}
+
+// NuevoServicio crea un servicio con acceso de 15 minutos y refresco de 30 días.
+func NuevoServicio(db *sql.DB) *Servicio {
+	return &Servicio{
+		DB:               db,
+		DuracionAcceso:   15 * time.Minute,
+		DuracionRefresco: 30 * 24 * time.Hour,
+	}
+}
+
+// Emitir crea un par de tokens (acceso + refresco) para el usuario.
+func (s *Servicio) Emitir(idUsuario int) (ParTokens, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return ParTokens{}, err
+	}
+	defer tx.Rollback()
+
+	par, err := s.emitirEn(tx, idUsuario)
+	if err != nil {
+		return ParTokens{}, err
+	}
+	return par, tx.Commit()
+}
+
+// Refrescar canjea un token de refresco por un par nuevo.
+// El token usado queda revocado (rotación), por lo que solo sirve una vez.
+func (s *Servicio) Refrescar(refresco string) (ParTokens, error) {
+	if !strings.HasPrefix(refresco, PrefijoRefresco) {
+		return ParTokens{}, ErrTokenInvalido
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return ParTokens{}, err
+	}
+	defer tx.Rollback()
+
+	// Se bloquea la fila para que dos refrescos simultáneos no usen el mismo token.
+	var (
+		id        int
+		idUsuario int
+	)
+	query := `
+		SELECT t.id, t.id_usuario
+		FROM tokens_api t
+		INNER JOIN usuarios u ON u.id_usuario = t.id_usuario
+		WHERE t.hash = ? AND t.tipo = ? AND t.revocado = 0 AND t.expira_en > ? AND u.estado = 'ACTIVO'
+		FOR UPDATE
+	`
+	err = tx.QueryRow(query, hashToken(refresco), tipoRefresco, time.Now()).Scan(&id, &idUsuario)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ParTokens{}, ErrTokenInvalido
+		}
+		return ParTokens{}, err
+	}
+
+	if _, err := tx.Exec(`UPDATE tokens_api SET revocado = 1 WHERE id = ?`, id); err != nil {
+		return ParTokens{}, err
+	}
+
+	par, err := s.emitirEn(tx, idUsuario)
+	if err != nil {
+		return ParTokens{}, err
+	}
+	return par, tx.Commit()
+}
+
+// Resolver valida un token Bearer (de acceso o clave de API) y devuelve la
+// identidad con el rol actual del usuario. La Sesion devuelta no tiene ID.
+func (s *Servicio) Resolver(token string) (models.Sesion, error) {
+	if strings.HasPrefix(token, PrefijoClave) {
+		return s.resolverClave(token)
+	}
+	if !strings.HasPrefix(token, PrefijoAcceso) {
+		return models.Sesion{}, ErrTokenInvalido
+	}
+
+	var sesion models.Sesion
+	query := `
+		SELECT u.id_usuario, u.nombre, r.nombre_rol, t.creado_en, t.expira_en
+		FROM tokens_api t
+		INNER JOIN usuarios u ON u.id_usuario = t.id_usuario
+		INNER JOIN roles r ON r.id_rol = u.id_rol
+		WHERE t.hash = ? AND t.tipo = ? AND t.revocado = 0 AND t.expira_en > ? AND u.estado = 'ACTIVO'
+	`
+	err := s.DB.QueryRow(query, hashToken(token), tipoAcceso, time.Now()).Scan(
+		&sesion.IDUsuario,
+		&sesion.Nombre,
+		&sesion.Rol,
+		&sesion.CreadaEn,
+		&sesion.ExpiraEn,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Sesion{}, ErrTokenInvalido
+		}
+		return models.Sesion{}, err
+	}
+	sesion.UltimoAcceso = time.Now()
+	return sesion, nil
+}
+
+// RevocarUsuario revoca todos los tokens de acceso y refresco de un usuario.
+func (s *Servicio) RevocarUsuario(idUsuario int) error {
+	_, err := s.DB.Exec(`UPDATE tokens_api SET revocado = 1 WHERE id_usuario = ?`, idUsuario)
+	return err
+}
+
+// emitirEn inserta un par de tokens dentro de una transacción.
+func (s *Servicio) emitirEn(tx *sql.Tx, idUsuario int) (ParTokens, error) {
+	acceso, err := generarToken(PrefijoAcceso)
+	if err != nil {
+		return ParTokens{}, err
+	}
+	refresco, err := generarToken(PrefijoRefresco)
+	if err != nil {
+		return ParTokens{}, err
+	}
+
+	ahora := time.Now()
+	query := `
+		INSERT INTO tokens_api (hash, tipo, id_usuario, creado_en, expira_en)
+		VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(query,
+		hashToken(acceso), tipoAcceso, idUsuario, ahora, ahora.Add(s.DuracionAcceso),
+		hashToken(refresco), tipoRefresco, idUsuario, ahora, ahora.Add(s.DuracionRefresco),
+	)
+	if err != nil {
+		return ParTokens{}, err
+	}
+
+	return ParTokens{
+		AccessToken:  acceso,
+		RefreshToken: refresco,
+		TipoToken:    "Bearer",
+		ExpiraEn:     int(s.DuracionAcceso.Seconds()),
+	}, nil
+}
+
+// generarToken crea un token aleatorio de 32 bytes con el prefijo indicado.
+func generarToken(prefijo string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefijo + hex.EncodeToString(b), nil
+}
+
+// hashToken calcula el SHA-256 del token; en la base nunca se guarda el token en claro.
+func hashToken(token string) string {
+	suma := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(suma[:])
+}
@@ -1,4 +1,4 @@
-﻿package main // Paquete principal.
+package main // Paquete principal.
 
 import ( // Bloque de imports.
 	"bufio"   // Para leer entrada del usuario por consola.
@@ -25,3 +25,32 @@ func leerEntero(r *bufio.Reader, mensaje string) int { // Recibe lector y mensaj
 		fmt.Println("❌ Ingresa un número válido.") // Si falla, se informa y se repite el ciclo.
 	}
 }
+
+// leerNoNegativo solicita un entero de 0 o más (año, licencias); Enter deja el valor actual.
+func leerNoNegativo(r *bufio.Reader, mensaje string, actual int) int { // Recibe lector, mensaje y valor actual.
+	for { // Se repite hasta recibir un número válido.
+		fmt.Print(mensaje) // Muestra el mensaje (ej: "Año: ").
+		s := leerLinea(r)  // Lee la entrada del usuario.
+		if s == "" {       // Si el usuario solo presionó Enter...
+			return actual // Se conserva el valor actual.
+		}
+		n, err := strconv.Atoi(s) // Intenta convertir a entero.
+		if err == nil && n >= 0 { // No se aceptan negativos.
+			return n // Devuelve el número válido.
+		}
+		fmt.Println("❌ Ingresa un número válido (0 o más).") // Si falla, se informa y se repite el ciclo.
+	}
+}
+
+// leerTexto solicita un texto mostrando el valor actual; Enter lo conserva.
+func leerTexto(r *bufio.Reader, campo, actual string) string { // Recibe lector, nombre del campo y valor actual.
+	if actual == "" { // Sin valor actual solo se muestra el campo.
+		fmt.Print(campo + ": ")
+	} else { // Con valor actual se muestra entre corchetes.
+		fmt.Printf("%s [%s]: ", campo, actual)
+	}
+	if s := leerLinea(r); s != "" { // Si el usuario escribió algo...
+		return s // Se usa el texto nuevo.
+	}
+	return actual // Si no, se conserva el actual.
+}
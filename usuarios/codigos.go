@@ -0,0 +1,90 @@
+package usuarios // Paquete usuarios.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"errors"            // Paquete para errores de códigos.
+	"sistema/seguridad" // Generación y hash de códigos.
+	"time"              // Paquete para vencimientos.
+)
+
+// Tipos de códigos enviados por correo.
+const (
+	codigoVerificacion = "VERIFICACION" // Activa una cuenta registrada por su dueño.
+	codigoRecuperacion = "RECUPERACION" // Permite elegir una clave nueva.
+)
+
+// ErrCodigoInvalido se usa cuando el enlace no existe, ya se usó o venció.
+var ErrCodigoInvalido = errors.New("enlace inválido o vencido")
+
+// emitirCodigo genera un código de un solo uso para el usuario y guarda su hash.
+// Los códigos anteriores del mismo tipo quedan anulados.
+func (s *Servicio) emitirCodigo(tipo string, idUsuario int, vigencia time.Duration) (string, error) {
+	codigo, err := seguridad.GenerarCodigo()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	ahora := time.Now()
+	_, err = tx.Exec(`
+		UPDATE codigos_correo SET usado_en = ?
+		WHERE id_usuario = ? AND tipo = ? AND usado_en IS NULL
+	`, ahora, idUsuario, tipo)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO codigos_correo (hash, tipo, id_usuario, creado_en, expira_en)
+		VALUES (?, ?, ?, ?, ?)
+	`, seguridad.HashCodigo(codigo), tipo, idUsuario, ahora, ahora.Add(vigencia))
+	if err != nil {
+		return "", err
+	}
+	return codigo, tx.Commit()
+}
+
+// codigoVigente indica si el código existe, no se usó y no venció, sin consumirlo.
+func (s *Servicio) codigoVigente(tipo, codigo string) (bool, error) {
+	if codigo == "" {
+		return false, nil
+	}
+	var n int
+	err := s.DB.QueryRow(`
+		SELECT COUNT(*) FROM codigos_correo
+		WHERE hash = ? AND tipo = ? AND usado_en IS NULL AND expira_en > ?
+	`, seguridad.HashCodigo(codigo), tipo, time.Now()).Scan(&n)
+	return n > 0, err
+}
+
+// consumirCodigo valida el código dentro de la transacción y lo marca como usado.
+// Devuelve el usuario dueño, o ErrCodigoInvalido.
+func consumirCodigo(tx *sql.Tx, tipo, codigo string) (int, error) {
+	if codigo == "" {
+		return 0, ErrCodigoInvalido
+	}
+
+	var id, idUsuario int
+	ahora := time.Now()
+	err := tx.QueryRow(`
+		SELECT id, id_usuario FROM codigos_correo
+		WHERE hash = ? AND tipo = ? AND usado_en IS NULL AND expira_en > ?
+		FOR UPDATE
+	`, seguridad.HashCodigo(codigo), tipo, ahora).Scan(&id, &idUsuario)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrCodigoInvalido
+		}
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE codigos_correo SET usado_en = ? WHERE id = ?`, ahora, id); err != nil {
+		return 0, err
+	}
+	return idUsuario, nil
+}
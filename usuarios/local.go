@@ -0,0 +1,46 @@
+package usuarios // Paquete usuarios.
+
+import (
+	"sistema/models"    // Estructura Usuario.
+	"sistema/seguridad" // Hash y verificación de la clave.
+	"strings"           // Paquete para comparar correos.
+)
+
+// CuentaLocal es la única cuenta cuando el sistema corre sin MySQL
+// (LIBROS_STORE=json): un ADMIN con el correo y la clave de la configuración.
+// No tiene segundo factor ni se puede administrar desde la web.
+type CuentaLocal struct {
+	usuario models.Usuario // Datos de la cuenta, con el hash de la clave.
+}
+
+// NuevaCuentaLocal crea la cuenta guardando solo el hash de la clave.
+func NuevaCuentaLocal(correo, clave string) (*CuentaLocal, error) {
+	if err := seguridad.ValidarClaveNueva(clave); err != nil {
+		return nil, err
+	}
+	hash, err := seguridad.HashearClave(clave)
+	if err != nil {
+		return nil, err
+	}
+	return &CuentaLocal{usuario: models.Usuario{
+		Nombre:    "Administrador",
+		Correo:    strings.TrimSpace(correo),
+		Clave:     hash,
+		NombreRol: rolAdmin,
+		Estado:    models.EstadoActivo,
+	}}, nil
+}
+
+// Verificar indica si correo y clave son los de la cuenta y, si lo son,
+// devuelve el usuario. Si el correo no coincide se simula la verificación,
+// igual que con la tabla usuarios.
+func (c *CuentaLocal) Verificar(correo, clave string) (models.Usuario, bool) {
+	if !strings.EqualFold(strings.TrimSpace(correo), c.usuario.Correo) {
+		seguridad.SimularVerificacion(clave)
+		return models.Usuario{}, false
+	}
+	if ok, _ := seguridad.VerificarClave(c.usuario.Clave, clave); !ok {
+		return models.Usuario{}, false
+	}
+	return c.usuario, true
+}
@@ -0,0 +1,94 @@
+package usuarios // Paquete usuarios.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"fmt"               // Paquete para armar el correo.
+	"net/url"           // Paquete para armar el enlace.
+	"sistema/correo"    // Envío del enlace de recuperación.
+	"sistema/models"    // Estructura Usuario y estados.
+	"sistema/seguridad" // Hash y validación de la clave nueva.
+	"time"              // Paquete para marcar códigos usados.
+)
+
+// SolicitarRecuperacion envía un enlace para elegir una clave nueva si el correo
+// pertenece a un usuario ACTIVO. Si no, no hace nada: la respuesta al visitante
+// es la misma para no revelar qué correos están registrados.
+func (s *Servicio) SolicitarRecuperacion(direccion string) error {
+	var u models.Usuario
+	err := s.DB.QueryRow(`SELECT id_usuario, nombre, correo FROM usuarios WHERE correo = ? AND estado = ?`,
+		normalizarCorreo(direccion), models.EstadoActivo).Scan(&u.IDUsuario, &u.Nombre, &u.Correo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	codigo, err := s.emitirCodigo(codigoRecuperacion, u.IDUsuario, s.VigenciaRecuperacion)
+	if err != nil {
+		return err
+	}
+
+	enlace := s.URLBase + "/recuperar/clave?codigo=" + url.QueryEscape(codigo)
+	return s.Correo.Enviar(correo.Mensaje{
+		Para:   u.Correo,
+		Asunto: "Recuperación de contraseña",
+		Texto: fmt.Sprintf("Hola %s:\n\n"+
+			"Recibimos un pedido para cambiar la contraseña de su cuenta. Para elegir una nueva, abra este enlace:\n\n"+
+			"%s\n\n"+
+			"El enlace vence en %s y sirve una sola vez. Si usted no lo pidió, ignore este correo: su contraseña no cambia.\n",
+			u.Nombre, enlace, duracionLegible(s.VigenciaRecuperacion)),
+	})
+}
+
+// CodigoRecuperacionVigente indica si el enlace de recuperación todavía sirve
+// (para mostrar el formulario de clave nueva sin consumirlo).
+func (s *Servicio) CodigoRecuperacionVigente(codigo string) (bool, error) {
+	return s.codigoVigente(codigoRecuperacion, codigo)
+}
+
+// RecuperarClave guarda la clave nueva del dueño del código, anula sus demás
+// enlaces de recuperación y cierra todas sus sesiones y tokens.
+// Devuelve ErrCodigoInvalido si el enlace no sirve o la cuenta ya no está ACTIVA.
+func (s *Servicio) RecuperarClave(codigo, clave string) error {
+	if err := seguridad.ValidarClaveNueva(clave); err != nil {
+		return err
+	}
+	hash, err := seguridad.HashearClave(clave)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	idUsuario, err := consumirCodigo(tx, codigoRecuperacion, codigo)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`UPDATE usuarios SET clave = ? WHERE id_usuario = ? AND estado = ?`, hash, idUsuario, models.EstadoActivo)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// La cuenta se desactivó después de pedir el enlace.
+		return ErrCodigoInvalido
+	}
+
+	_, err = tx.Exec(`
+		UPDATE codigos_correo SET usado_en = ?
+		WHERE id_usuario = ? AND tipo = ? AND usado_en IS NULL
+	`, time.Now(), idUsuario, codigoRecuperacion)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return s.cerrarAccesos(idUsuario)
+}
@@ -0,0 +1,126 @@
+package usuarios // Paquete usuarios.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"fmt"               // Paquete para armar los correos.
+	"net/url"           // Paquete para armar el enlace.
+	"sistema/correo"    // Envío del enlace de verificación.
+	"sistema/models"    // Estructura Usuario y estados.
+	"sistema/seguridad" // Para igualar tiempos de respuesta.
+	"time"              // Paquete para expresar la vigencia del enlace.
+)
+
+// Registrar crea una cuenta de lector (CONSULTA) en estado PENDIENTE y envía el
+// enlace de verificación. Si el correo ya está registrado no se informa en la
+// respuesta: se reenvía el enlace (cuenta pendiente) o se avisa al dueño por correo.
+func (s *Servicio) Registrar(nombre, direccion, clave string) error {
+	idRol, err := s.idRol(rolLector)
+	if err != nil {
+		return err
+	}
+
+	usuario, err := s.crear(nombre, direccion, clave, idRol, models.EstadoPendiente)
+	switch err {
+	case nil:
+		return s.enviarVerificacion(usuario)
+	case ErrCorreoEnUso:
+		// Se consume el mismo tiempo que un alta real (hash bcrypt).
+		seguridad.SimularVerificacion(clave)
+		return s.avisarCuentaExistente(normalizarCorreo(direccion))
+	default:
+		return err
+	}
+}
+
+// VerificarCorreo activa la cuenta pendiente dueña del código.
+// Devuelve ErrCodigoInvalido si el enlace no sirve o la cuenta ya no está pendiente.
+func (s *Servicio) VerificarCorreo(codigo string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	idUsuario, err := consumirCodigo(tx, codigoVerificacion, codigo)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`UPDATE usuarios SET estado = ? WHERE id_usuario = ? AND estado = ?`,
+		models.EstadoActivo, idUsuario, models.EstadoPendiente)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Un ADMIN pudo haber desactivado la cuenta mientras estaba pendiente.
+		return ErrCodigoInvalido
+	}
+	return tx.Commit()
+}
+
+// enviarVerificacion emite un código de verificación y lo envía al usuario.
+func (s *Servicio) enviarVerificacion(u models.Usuario) error {
+	codigo, err := s.emitirCodigo(codigoVerificacion, u.IDUsuario, s.VigenciaVerificacion)
+	if err != nil {
+		return err
+	}
+
+	enlace := s.URLBase + "/registro/verificar?codigo=" + url.QueryEscape(codigo)
+	return s.Correo.Enviar(correo.Mensaje{
+		Para:   u.Correo,
+		Asunto: "Active su cuenta de la biblioteca",
+		Texto: fmt.Sprintf("Hola %s:\n\n"+
+			"Para activar su cuenta en el sistema de libros electrónicos, abra este enlace:\n\n"+
+			"%s\n\n"+
+			"El enlace vence en %s. Si usted no creó esta cuenta, ignore este correo.\n",
+			u.Nombre, enlace, duracionLegible(s.VigenciaVerificacion)),
+	})
+}
+
+// avisarCuentaExistente atiende un registro con un correo que ya tiene cuenta.
+func (s *Servicio) avisarCuentaExistente(direccion string) error {
+	var u models.Usuario
+	err := s.DB.QueryRow(`SELECT id_usuario, nombre, correo, estado FROM usuarios WHERE correo = ?`, direccion).Scan(
+		&u.IDUsuario, &u.Nombre, &u.Correo, &u.Estado,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+
+	if u.Estado == models.EstadoPendiente {
+		return s.enviarVerificacion(u)
+	}
+	return s.Correo.Enviar(correo.Mensaje{
+		Para:   u.Correo,
+		Asunto: "Ya tiene una cuenta en la biblioteca",
+		Texto: fmt.Sprintf("Hola %s:\n\n"+
+			"Alguien intentó registrarse con este correo, pero ya tiene una cuenta.\n"+
+			"Puede iniciar sesión en %s/login o, si olvidó su contraseña, recuperarla en %s/recuperar\n\n"+
+			"Si no fue usted, ignore este correo.\n",
+			u.Nombre, s.URLBase, s.URLBase),
+	})
+}
+
+// idRol devuelve el ID del rol con ese nombre, o ErrRolNoExiste.
+func (s *Servicio) idRol(nombre string) (int, error) {
+	var id int
+	err := s.DB.QueryRow(`SELECT id_rol FROM roles WHERE nombre_rol = ?`, nombre).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrRolNoExiste
+	}
+	return id, err
+}
+
+// duracionLegible expresa un plazo en horas o minutos para los correos.
+func duracionLegible(d time.Duration) string {
+	if d == time.Hour {
+		return "1 hora"
+	}
+	if d > time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d horas", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d minutos", int(d.Minutes()))
+}
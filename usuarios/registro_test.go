@@ -0,0 +1,270 @@
+package usuarios
+
+import (
+	"database/sql/driver" // Paquete para los argumentos de las consultas simuladas.
+	"net/url"             // Paquete para leer el código del enlace.
+	"regexp"              // Paquete para encontrar el enlace en el correo.
+	"sistema/correo"      // Enviador falso con bandeja en memoria.
+	"sistema/models"      // Estados de usuario.
+	"sistema/seguridad"   // Hash de los códigos.
+	"strings"             // Paquete para revisar el texto de los correos.
+	"testing"             // Paquete de pruebas.
+	"time"                // Paquete para la vigencia de los enlaces.
+
+	"github.com/DATA-DOG/go-sqlmock" // Base de datos simulada.
+)
+
+// enlaceVerificacion encuentra el código del enlace de verificación en un correo.
+var enlaceVerificacion = regexp.MustCompile(`/registro/verificar\?codigo=(\S+)`)
+
+// captura guarda el valor con que se ejecutó una consulta simulada.
+type captura struct {
+	valor driver.Value
+}
+
+// Match acepta cualquier valor y lo guarda.
+func (c *captura) Match(v driver.Value) bool {
+	c.valor = v
+	return true
+}
+
+// alrededorDe acepta una fecha a menos de un minuto de la esperada.
+type alrededorDe time.Time
+
+// Match compara la fecha recibida con la esperada.
+func (a alrededorDe) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := t.Sub(time.Time(a))
+	return d > -time.Minute && d < time.Minute
+}
+
+// nuevoServicioPrueba crea un servicio con la base simulada y el correo en memoria.
+func nuevoServicioPrueba(t *testing.T) (*Servicio, sqlmock.Sqlmock, *correo.Memoria) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	bandeja := correo.NuevoMemoria()
+	s := NuevoServicio(db, nil, nil, bandeja)
+	s.URLBase = "https://biblioteca.test"
+	return s, mock, bandeja
+}
+
+// esperarEmision prepara la emisión de un código de verificación para el
+// usuario y devuelve la captura del hash guardado.
+func esperarEmision(mock sqlmock.Sqlmock, idUsuario int, vigencia time.Duration) *captura {
+	hash := &captura{}
+	ahora := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE codigos_correo SET usado_en`).
+		WithArgs(alrededorDe(ahora), idUsuario, codigoVerificacion).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO codigos_correo`).
+		WithArgs(hash, codigoVerificacion, idUsuario, alrededorDe(ahora), alrededorDe(ahora.Add(vigencia))).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	return hash
+}
+
+// esperarAlta prepara el alta de una cuenta de lector nueva con ID id.
+func esperarAlta(mock sqlmock.Sqlmock, direccion string, id int) {
+	mock.ExpectQuery(`SELECT id_rol FROM roles WHERE nombre_rol`).
+		WithArgs(rolLector).
+		WillReturnRows(sqlmock.NewRows([]string{"id_rol"}).AddRow(3))
+	mock.ExpectQuery(`SELECT nombre_rol FROM roles WHERE id_rol`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"nombre_rol"}).AddRow(rolLector))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM usuarios WHERE correo`).
+		WithArgs(direccion).
+		WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(0))
+	mock.ExpectExec(`INSERT INTO usuarios`).
+		WithArgs("Ana", direccion, sqlmock.AnyArg(), 3, models.EstadoPendiente).
+		WillReturnResult(sqlmock.NewResult(int64(id), 1))
+}
+
+// esperarExistente prepara un registro con un correo que ya tiene cuenta.
+func esperarExistente(mock sqlmock.Sqlmock, direccion, estado string, id int) {
+	mock.ExpectQuery(`SELECT id_rol FROM roles WHERE nombre_rol`).
+		WithArgs(rolLector).
+		WillReturnRows(sqlmock.NewRows([]string{"id_rol"}).AddRow(3))
+	mock.ExpectQuery(`SELECT nombre_rol FROM roles WHERE id_rol`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"nombre_rol"}).AddRow(rolLector))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM usuarios WHERE correo`).
+		WithArgs(direccion).
+		WillReturnRows(sqlmock.NewRows([]string{"n"}).AddRow(1))
+	mock.ExpectQuery(`SELECT id_usuario, nombre, correo, estado FROM usuarios WHERE correo`).
+		WithArgs(direccion).
+		WillReturnRows(sqlmock.NewRows([]string{"id_usuario", "nombre", "correo", "estado"}).AddRow(id, "Ana", direccion, estado))
+}
+
+// codigoEnviado devuelve el código del último enlace de verificación enviado
+// a la dirección, y verifica que su hash sea el que se guardó.
+func codigoEnviado(t *testing.T, bandeja *correo.Memoria, direccion string, hash *captura) string {
+	t.Helper()
+	msg, ok := bandeja.Ultimo(direccion)
+	if !ok {
+		t.Fatalf("no se envió ningún correo a %s", direccion)
+	}
+	m := enlaceVerificacion.FindStringSubmatch(msg.Texto)
+	if m == nil {
+		t.Fatalf("el correo no tiene enlace de verificación:\n%s", msg.Texto)
+	}
+	codigo, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash.valor != seguridad.HashCodigo(codigo) {
+		t.Fatalf("el hash guardado (%v) no corresponde al código enviado", hash.valor)
+	}
+	return codigo
+}
+
+func TestRegistrarYVerificar(t *testing.T) {
+	s, mock, bandeja := nuevoServicioPrueba(t)
+
+	esperarAlta(mock, "ana@correo.test", 7)
+	hash := esperarEmision(mock, 7, s.VigenciaVerificacion)
+	if err := s.Registrar("Ana", " Ana@Correo.test ", "clave-segura-1"); err != nil {
+		t.Fatalf("Registrar: %v", err)
+	}
+	codigo := codigoEnviado(t, bandeja, "ana@correo.test", hash)
+	if msg, _ := bandeja.Ultimo("ana@correo.test"); !strings.HasPrefix(msg.Texto, "Hola Ana:") || !strings.Contains(msg.Texto, "24 horas") {
+		t.Errorf("correo de verificación inesperado:\n%s", msg.Texto)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, id_usuario FROM codigos_correo .* FOR UPDATE`).
+		WithArgs(seguridad.HashCodigo(codigo), codigoVerificacion, alrededorDe(time.Now())).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_usuario"}).AddRow(1, 7))
+	mock.ExpectExec(`UPDATE codigos_correo SET usado_en = \? WHERE id = \?`).
+		WithArgs(alrededorDe(time.Now()), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE usuarios SET estado`).
+		WithArgs(models.EstadoActivo, 7, models.EstadoPendiente).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := s.VerificarCorreo(codigo); err != nil {
+		t.Fatalf("VerificarCorreo: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistrarReenviaEnlaceACuentaPendiente(t *testing.T) {
+	s, mock, bandeja := nuevoServicioPrueba(t)
+
+	esperarAlta(mock, "ana@correo.test", 7)
+	primero := esperarEmision(mock, 7, s.VigenciaVerificacion)
+	if err := s.Registrar("Ana", "ana@correo.test", "clave-segura-1"); err != nil {
+		t.Fatalf("Registrar: %v", err)
+	}
+	codigoViejo := codigoEnviado(t, bandeja, "ana@correo.test", primero)
+
+	// El segundo registro no crea otra cuenta: emite un código nuevo (que
+	// anula el anterior con el UPDATE de esperarEmision) y lo vuelve a enviar.
+	esperarExistente(mock, "ana@correo.test", models.EstadoPendiente, 7)
+	segundo := esperarEmision(mock, 7, s.VigenciaVerificacion)
+	if err := s.Registrar("Ana", "ana@correo.test", "otra-clave-2"); err != nil {
+		t.Fatalf("Registrar de nuevo: %v", err)
+	}
+	codigoNuevo := codigoEnviado(t, bandeja, "ana@correo.test", segundo)
+
+	if codigoNuevo == codigoViejo {
+		t.Error("el reenvío repitió el código anterior")
+	}
+	if n := len(bandeja.Enviados()); n != 2 {
+		t.Errorf("se enviaron %d correos, se esperaban 2", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVerificarCodigoVencido(t *testing.T) {
+	s, mock, bandeja := nuevoServicioPrueba(t)
+	s.VigenciaVerificacion = 30 * time.Minute
+
+	esperarAlta(mock, "ana@correo.test", 7)
+	hash := esperarEmision(mock, 7, 30*time.Minute)
+	if err := s.Registrar("Ana", "ana@correo.test", "clave-segura-1"); err != nil {
+		t.Fatalf("Registrar: %v", err)
+	}
+	codigo := codigoEnviado(t, bandeja, "ana@correo.test", hash)
+	if msg, _ := bandeja.Ultimo("ana@correo.test"); !strings.Contains(msg.Texto, "30 minutos") {
+		t.Errorf("el correo no informa la vigencia:\n%s", msg.Texto)
+	}
+
+	// Pasado expira_en la consulta (expira_en > ahora) no encuentra el código:
+	// la cuenta no se activa ni se marca el código.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, id_usuario FROM codigos_correo .* expira_en > \?`).
+		WithArgs(seguridad.HashCodigo(codigo), codigoVerificacion, alrededorDe(time.Now())).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id_usuario"}))
+	mock.ExpectRollback()
+	if err := s.VerificarCorreo(codigo); err != ErrCodigoInvalido {
+		t.Fatalf("VerificarCorreo con código vencido: %v, se esperaba ErrCodigoInvalido", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	if err := s.VerificarCorreo(""); err != ErrCodigoInvalido {
+		t.Errorf("VerificarCorreo sin código: %v, se esperaba ErrCodigoInvalido", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistrarCorreoDuplicado(t *testing.T) {
+	s, mock, bandeja := nuevoServicioPrueba(t)
+
+	// La respuesta es la misma que un alta (no revela la cuenta); el aviso va
+	// por correo al dueño, sin enlace de verificación ni usuario nuevo.
+	esperarExistente(mock, "ana@correo.test", models.EstadoActivo, 7)
+	if err := s.Registrar("Otra persona", "ANA@correo.test", "clave-segura-1"); err != nil {
+		t.Fatalf("Registrar: %v", err)
+	}
+
+	msg, ok := bandeja.Ultimo("ana@correo.test")
+	if !ok {
+		t.Fatal("no se avisó al dueño de la cuenta")
+	}
+	if msg.Asunto != "Ya tiene una cuenta en la biblioteca" {
+		t.Errorf("asunto inesperado: %q", msg.Asunto)
+	}
+	if enlaceVerificacion.MatchString(msg.Texto) {
+		t.Error("el aviso de cuenta existente incluye un enlace de verificación")
+	}
+	if !strings.Contains(msg.Texto, "https://biblioteca.test/recuperar") {
+		t.Errorf("el aviso no ofrece recuperar la clave:\n%s", msg.Texto)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegistrarCorreoInvalido(t *testing.T) {
+	s, mock, bandeja := nuevoServicioPrueba(t)
+
+	mock.ExpectQuery(`SELECT id_rol FROM roles WHERE nombre_rol`).
+		WithArgs(rolLector).
+		WillReturnRows(sqlmock.NewRows([]string{"id_rol"}).AddRow(3))
+	if err := s.Registrar("Ana", "Ana <ana@correo.test>", "clave-segura-1"); err != ErrCorreoInvalido {
+		t.Fatalf("Registrar: %v, se esperaba ErrCorreoInvalido", err)
+	}
+	if n := len(bandeja.Enviados()); n != 0 {
+		t.Errorf("se enviaron %d correos", n)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,305 @@
+package usuarios // Paquete usuarios: alta y administración de usuarios por un ADMIN.
+
+import (
+	"database/sql"      // Paquete para trabajar con MySQL.
+	"errors"            // Paquete para errores del módulo.
+	"net/mail"          // Paquete para validar el formato del correo.
+	"sistema/correo"    // Envío de correos de verificación.
+	"sistema/models"    // Estructuras Usuario y Rol.
+	"sistema/seguridad" // Hash y validación de contraseñas.
+	"sistema/sesiones"  // Cierre de sesiones al cambiar rol, estado o clave.
+	"sistema/tokens"    // Revocación de tokens al cambiar estado o clave.
+	"strconv"           // Paquete para reconocer búsquedas por ID.
+	"strings"           // Paquete para limpiar textos y armar filtros.
+	"time"              // Paquete para la vigencia de los enlaces.
+)
+
+// Roles con reglas propias en este módulo.
+const (
+	rolAdmin  = "ADMIN"    // Siempre debe quedar asignado a algún usuario activo.
+	rolLector = "CONSULTA" // Rol de las cuentas creadas por registro propio.
+)
+
+// Errores del módulo de usuarios.
+var (
+	ErrUsuarioNoExiste = errors.New("usuario no existe")
+	ErrRolNoExiste     = errors.New("rol no existe")
+	ErrCorreoEnUso     = errors.New("el correo ya está registrado")
+	ErrCorreoInvalido  = errors.New("correo inválido")
+	ErrNombreVacio     = errors.New("el nombre es obligatorio")
+	ErrEstadoInvalido  = errors.New("estado inválido")
+	ErrUltimoAdmin     = errors.New("debe quedar al menos un ADMIN activo")
+)
+
+// Servicio administra la tabla usuarios. Al cambiar el rol, el estado o la clave
+// de un usuario cierra sus sesiones y revoca sus tokens, para que el cambio rija
+// de inmediato (la sesión guarda el rol con que se inició).
+type Servicio struct {
+	DB                   *sql.DB          // Conexión a la base de datos.
+	Sesiones             sesiones.Almacen // Almacén de sesiones del servidor.
+	Tokens               *tokens.Servicio // Tokens Bearer de la API.
+	Correo               correo.Enviador  // Envío de enlaces de verificación y recuperación.
+	URLBase              string           // Dirección pública del sistema para armar enlaces.
+	VigenciaVerificacion time.Duration    // Plazo para usar el enlace de verificación.
+	VigenciaRecuperacion time.Duration    // Plazo para usar el enlace de recuperación de clave.
+}
+
+// NuevoServicio crea el servicio de usuarios con enlaces de verificación de
+// 24 horas, de recuperación de 1 hora y URL base http://localhost:8082.
+func NuevoServicio(db *sql.DB, almacen sesiones.Almacen, servicioTokens *tokens.Servicio, enviador correo.Enviador) *Servicio {
+	return &Servicio{
+		DB:                   db,
+		Sesiones:             almacen,
+		Tokens:               servicioTokens,
+		Correo:               enviador,
+		URLBase:              "http://localhost:8082",
+		VigenciaVerificacion: 24 * time.Hour,
+		VigenciaRecuperacion: time.Hour,
+	}
+}
+
+// consultaUsuarios selecciona los campos de models.Usuario sin la clave.
+const consultaUsuarios = `
+	SELECT u.id_usuario, u.nombre, u.correo, u.id_rol, r.nombre_rol, u.estado
+	FROM usuarios u
+	INNER JOIN roles r ON r.id_rol = u.id_rol
+`
+
+// Listar devuelve los usuarios ordenados por nombre. Si busqueda no está vacía,
+// filtra por parte del nombre o del correo, o por ID exacto.
+func (s *Servicio) Listar(busqueda string) ([]models.Usuario, error) {
+	query := consultaUsuarios
+	var args []any
+	if busqueda = strings.TrimSpace(busqueda); busqueda != "" {
+		query += ` WHERE u.nombre LIKE ? OR u.correo LIKE ? OR u.id_usuario = ?`
+		id, _ := strconv.Atoi(busqueda)
+		args = append(args, "%"+busqueda+"%", "%"+busqueda+"%", id)
+	}
+	query += ` ORDER BY u.nombre, u.id_usuario`
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lista := []models.Usuario{}
+	for rows.Next() {
+		var u models.Usuario
+		if err := rows.Scan(&u.IDUsuario, &u.Nombre, &u.Correo, &u.IDRol, &u.NombreRol, &u.Estado); err != nil {
+			return nil, err
+		}
+		lista = append(lista, u)
+	}
+	return lista, rows.Err()
+}
+
+// Obtener devuelve un usuario por ID (sin la clave).
+func (s *Servicio) Obtener(id int) (models.Usuario, error) {
+	var u models.Usuario
+	err := s.DB.QueryRow(consultaUsuarios+` WHERE u.id_usuario = ?`, id).Scan(
+		&u.IDUsuario, &u.Nombre, &u.Correo, &u.IDRol, &u.NombreRol, &u.Estado,
+	)
+	if err == sql.ErrNoRows {
+		return u, ErrUsuarioNoExiste
+	}
+	return u, err
+}
+
+// Roles devuelve los roles existentes.
+func (s *Servicio) Roles() ([]models.Rol, error) {
+	rows, err := s.DB.Query(`SELECT id_rol, nombre_rol FROM roles ORDER BY id_rol`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Rol{}
+	for rows.Next() {
+		var r models.Rol
+		if err := rows.Scan(&r.IDRol, &r.NombreRol); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// Crear registra un usuario ACTIVO con la clave indicada (se guarda su hash).
+func (s *Servicio) Crear(nombre, correo, clave string, idRol int) (models.Usuario, error) {
+	return s.crear(nombre, correo, clave, idRol, models.EstadoActivo)
+}
+
+// crear valida los datos y registra un usuario con el estado indicado.
+func (s *Servicio) crear(nombre, correo, clave string, idRol int, estado string) (models.Usuario, error) {
+	nombre = strings.TrimSpace(nombre)
+	correo = normalizarCorreo(correo)
+	if nombre == "" {
+		return models.Usuario{}, ErrNombreVacio
+	}
+	if !correoValido(correo) {
+		return models.Usuario{}, ErrCorreoInvalido
+	}
+	if err := seguridad.ValidarClaveNueva(clave); err != nil {
+		return models.Usuario{}, err
+	}
+	nombreRol, err := s.nombreRol(idRol)
+	if err != nil {
+		return models.Usuario{}, err
+	}
+
+	var enUso int
+	if err := s.DB.QueryRow(`SELECT COUNT(*) FROM usuarios WHERE correo = ?`, correo).Scan(&enUso); err != nil {
+		return models.Usuario{}, err
+	}
+	if enUso > 0 {
+		return models.Usuario{}, ErrCorreoEnUso
+	}
+
+	hash, err := seguridad.HashearClave(clave)
+	if err != nil {
+		return models.Usuario{}, err
+	}
+	res, err := s.DB.Exec(`
+		INSERT INTO usuarios (nombre, correo, clave, id_rol, estado)
+		VALUES (?, ?, ?, ?, ?)
+	`, nombre, correo, hash, idRol, estado)
+	if err != nil {
+		return models.Usuario{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Usuario{}, err
+	}
+
+	return models.Usuario{
+		IDUsuario: int(id),
+		Nombre:    nombre,
+		Correo:    correo,
+		IDRol:     idRol,
+		NombreRol: nombreRol,
+		Estado:    estado,
+	}, nil
+}
+
+// CambiarRol asigna otro rol al usuario y cierra sus sesiones.
+func (s *Servicio) CambiarRol(id, idRol int) error {
+	actual, err := s.Obtener(id)
+	if err != nil {
+		return err
+	}
+	nombreRol, err := s.nombreRol(idRol)
+	if err != nil {
+		return err
+	}
+	if actual.IDRol == idRol {
+		return nil
+	}
+	if actual.NombreRol == rolAdmin && nombreRol != rolAdmin && actual.Estado == models.EstadoActivo {
+		if err := s.verificarOtroAdmin(id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.DB.Exec(`UPDATE usuarios SET id_rol = ? WHERE id_usuario = ?`, idRol, id); err != nil {
+		return err
+	}
+	return s.cerrarAccesos(id)
+}
+
+// CambiarEstado activa o desactiva al usuario. Al desactivarlo se cierran
+// sus sesiones y se revocan sus tokens.
+func (s *Servicio) CambiarEstado(id int, estado string) error {
+	estado = strings.ToUpper(strings.TrimSpace(estado))
+	if estado != models.EstadoActivo && estado != models.EstadoInactivo {
+		return ErrEstadoInvalido
+	}
+	actual, err := s.Obtener(id)
+	if err != nil {
+		return err
+	}
+	if actual.Estado == estado {
+		return nil
+	}
+	if estado == models.EstadoInactivo && actual.NombreRol == rolAdmin {
+		if err := s.verificarOtroAdmin(id); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.DB.Exec(`UPDATE usuarios SET estado = ? WHERE id_usuario = ?`, estado, id); err != nil {
+		return err
+	}
+	if estado == models.EstadoInactivo {
+		return s.cerrarAccesos(id)
+	}
+	return nil
+}
+
+// RestablecerClave reemplaza la clave del usuario y cierra sus sesiones y tokens.
+func (s *Servicio) RestablecerClave(id int, clave string) error {
+	if err := seguridad.ValidarClaveNueva(clave); err != nil {
+		return err
+	}
+	if _, err := s.Obtener(id); err != nil {
+		return err
+	}
+
+	hash, err := seguridad.HashearClave(clave)
+	if err != nil {
+		return err
+	}
+	if _, err := s.DB.Exec(`UPDATE usuarios SET clave = ? WHERE id_usuario = ?`, hash, id); err != nil {
+		return err
+	}
+	return s.cerrarAccesos(id)
+}
+
+// cerrarAccesos elimina las sesiones y revoca los tokens del usuario.
+// Las claves de API se mantienen: resuelven rol y estado en cada uso.
+func (s *Servicio) cerrarAccesos(id int) error {
+	if err := s.Sesiones.EliminarPorUsuario(id); err != nil {
+		return err
+	}
+	return s.Tokens.RevocarUsuario(id)
+}
+
+// nombreRol devuelve el nombre del rol o ErrRolNoExiste.
+func (s *Servicio) nombreRol(idRol int) (string, error) {
+	var nombre string
+	err := s.DB.QueryRow(`SELECT nombre_rol FROM roles WHERE id_rol = ?`, idRol).Scan(&nombre)
+	if err == sql.ErrNoRows {
+		return "", ErrRolNoExiste
+	}
+	return strings.ToUpper(strings.TrimSpace(nombre)), err
+}
+
+// verificarOtroAdmin devuelve ErrUltimoAdmin si, sin el usuario indicado, no
+// quedaría ningún ADMIN activo.
+func (s *Servicio) verificarOtroAdmin(id int) error {
+	var otros int
+	err := s.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM usuarios u
+		INNER JOIN roles r ON r.id_rol = u.id_rol
+		WHERE r.nombre_rol = ? AND u.estado = ? AND u.id_usuario <> ?
+	`, rolAdmin, models.EstadoActivo, id).Scan(&otros)
+	if err != nil {
+		return err
+	}
+	if otros == 0 {
+		return ErrUltimoAdmin
+	}
+	return nil
+}
+
+// normalizarCorreo quita espacios y pasa el correo a minúsculas.
+func normalizarCorreo(correo string) string {
+	return strings.ToLower(strings.TrimSpace(correo))
+}
+
+// correoValido acepta solo una dirección simple (sin nombre ni <>).
+func correoValido(correo string) bool {
+	direccion, err := mail.ParseAddress(correo)
+	return err == nil && direccion.Address == correo
+}